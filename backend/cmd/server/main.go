@@ -7,16 +7,27 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/joho/godotenv"
 	"github.com/saint0x/file-storage-app/backend/internal/api"
 	"github.com/saint0x/file-storage-app/backend/internal/api/handlers"
 	"github.com/saint0x/file-storage-app/backend/internal/db"
+	"github.com/saint0x/file-storage-app/backend/internal/events"
 	"github.com/saint0x/file-storage-app/backend/internal/models"
+	"github.com/saint0x/file-storage-app/backend/internal/repository"
+	"github.com/saint0x/file-storage-app/backend/internal/services/activity"
 	"github.com/saint0x/file-storage-app/backend/internal/services/ai"
 	"github.com/saint0x/file-storage-app/backend/internal/services/auth"
+	"github.com/saint0x/file-storage-app/backend/internal/services/email"
+	"github.com/saint0x/file-storage-app/backend/internal/services/identity"
+	"github.com/saint0x/file-storage-app/backend/internal/services/notifications"
+	"github.com/saint0x/file-storage-app/backend/internal/services/search"
 	"github.com/saint0x/file-storage-app/backend/internal/services/storage"
+	"github.com/saint0x/file-storage-app/backend/internal/services/webhooks"
 	"github.com/saint0x/file-storage-app/backend/internal/services/websocket"
 )
 
@@ -79,12 +90,50 @@ func main() {
 	}
 
 	// Initialize database
-	dbClient, err := db.NewSQLiteClient(os.Getenv("SQLITE_DB_PATH"))
+	dbClient, err := db.NewSQLiteClientWithOptions(os.Getenv("SQLITE_DB_PATH"), sqliteOptions())
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 	defer dbClient.Close()
 
+	// "server migrate ..." manages the schema and exits
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(dbClient.DB, os.Args[2:]); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+
+	// Bring the schema up to date unless it is migrated separately
+	if os.Getenv("MIGRATE_ON_START") != "false" {
+		migrator, err := db.NewMigrator(dbClient.DB)
+		if err != nil {
+			log.Fatalf("Failed to load migrations: %v", err)
+		}
+		applied, err := migrator.Up(-1)
+		for _, m := range applied {
+			log.Printf("Applied migration %03d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatalf("Failed to migrate database: %v", err)
+		}
+	}
+
+	// Grant the admin role to the configured users who have signed in
+	if v := os.Getenv("ADMIN_EMAILS"); v != "" {
+		var emails []string
+		for _, email := range strings.Split(v, ",") {
+			emails = append(emails, strings.TrimSpace(email))
+		}
+		granted, err := dbClient.GrantAdminRole(emails)
+		if err != nil {
+			log.Fatalf("Failed to grant admin role: %v", err)
+		}
+		if granted > 0 {
+			log.Printf("Granted the admin role to %d user(s) from ADMIN_EMAILS", granted)
+		}
+	}
+
 	// Initialize B2 service
 	b2Service, err := storage.NewB2Service(
 		os.Getenv("BACKBLAZE_ACCOUNT_ID"),
@@ -96,14 +145,100 @@ func main() {
 	}
 	defer b2Service.Close()
 
-	// Initialize Clerk service
-	clerkService := auth.NewClerkService()
-	clerkService.SetSecretKey(os.Getenv("CLERK_SECRET_KEY"))
+	// Initialize the authentication provider and map its users to ours
+	var users *identity.Resolver
+	var clerkWebhooks *auth.SvixVerifier
+	switch os.Getenv("AUTH_PROVIDER") {
+	case "", "clerk":
+		clerkService := auth.NewClerkService()
+		clerkService.SetSecretKey(os.Getenv("CLERK_SECRET_KEY"))
+		if issuer := os.Getenv("CLERK_ISSUER"); issuer != "" {
+			// Verify session tokens locally against Clerk's published keys
+			jwks := auth.NewJWKSCache(envOr("CLERK_JWKS_URL", strings.TrimRight(issuer, "/")+"/.well-known/jwks.json"), nil)
+			go jwks.Run()
+			defer jwks.Stop()
+			var parties []string
+			if v := os.Getenv("CLERK_AUTHORIZED_PARTIES"); v != "" {
+				parties = strings.Split(v, ",")
+			}
+			clerkService.SetJWTVerifier(auth.NewJWTVerifier(jwks, issuer, parties))
+		} else {
+			log.Println("CLERK_ISSUER is not set; verifying every session token with the Clerk API")
+		}
+		clerkService.SetSessionStore(dbClient)
+		users = identity.NewResolver(dbClient, auth.ProviderClerk, clerkService, clerkService)
+		if secret := os.Getenv("CLERK_WEBHOOK_SECRET"); secret != "" {
+			if clerkWebhooks, err = auth.NewSvixVerifier(secret); err != nil {
+				log.Fatalf("Invalid CLERK_WEBHOOK_SECRET: %v", err)
+			}
+		}
+	case "dev":
+		// Locally signed tokens for development and testing; POST /auth/dev/token issues them
+		dev, err := auth.NewDevAuthenticator(os.Getenv("AUTH_DEV_SECRET"))
+		if err != nil {
+			log.Fatalf("Invalid AUTH_DEV_SECRET: %v", err)
+		}
+		log.Println("WARNING: using the dev authentication provider; anyone can sign in as any user")
+		users = identity.NewResolver(dbClient, auth.ProviderDev, dev, dev)
+	default:
+		log.Fatalf("Unknown AUTH_PROVIDER %q", os.Getenv("AUTH_PROVIDER"))
+	}
 
 	// Initialize WebSocket hub
-	wsHub := websocket.NewHub(dbClient)
+	var broker websocket.Broker
+	switch os.Getenv("WS_BROKER") {
+	case "", "memory":
+		broker = websocket.NewMemoryBroker()
+	case "sqlite":
+		// Lets several server processes sharing SQLITE_DB_PATH fan out updates to each
+		// other. Presence lists stay per process; see Hub.Viewers.
+		broker = websocket.NewSQLiteBroker(dbClient, websocket.DefaultBrokerPollInterval)
+	default:
+		log.Fatalf("Unknown WS_BROKER %q", os.Getenv("WS_BROKER"))
+	}
+	wsHub := websocket.NewHub(dbClient, broker)
+	if origins := os.Getenv("WS_ALLOWED_ORIGINS"); origins != "" {
+		wsHub.SetAllowedOrigins(strings.Split(origins, ","))
+	}
+	if retention := os.Getenv("EVENT_RETENTION"); retention != "" {
+		d, err := time.ParseDuration(retention)
+		if err != nil {
+			log.Fatalf("Invalid EVENT_RETENTION: %v", err)
+		}
+		wsHub.SetEventRetention(d)
+	}
 	go wsHub.Run()
 
+	// Initialize the domain event bus and its consumers
+	bus := events.NewBus()
+	bus.Subscribe(wsHub.HandleEvent)
+	indexer := search.NewIndexer(dbClient)
+	bus.Subscribe(indexer.HandleEvent, search.EventTypes...)
+	bus.Subscribe(activity.NewRecorder(dbClient).HandleEvent)
+	notifier := notifications.NewNotifier(dbClient, wsHub)
+	bus.Subscribe(notifier.HandleEvent, notifications.Types...)
+	dispatcher := webhooks.NewDispatcher(dbClient)
+	// Lets webhooks point at servers on localhost or the local network, for development
+	dispatcher.SetAllowPrivateNetworks(os.Getenv("WEBHOOKS_ALLOW_PRIVATE_NETWORKS") == "true")
+	bus.Subscribe(dispatcher.HandleEvent)
+	go dispatcher.Run()
+	defer dispatcher.Stop()
+
+	// Email notifications are sent only when an SMTP server is configured
+	if smtpHost := os.Getenv("SMTP_HOST"); smtpHost != "" {
+		smtpPort := 587
+		if v := os.Getenv("SMTP_PORT"); v != "" {
+			if smtpPort, err = strconv.Atoi(v); err != nil {
+				log.Fatalf("Invalid SMTP_PORT: %v", err)
+			}
+		}
+		mailer := email.NewSMTPMailer(smtpHost, smtpPort, os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"), os.Getenv("SMTP_FROM"))
+		emailService := email.NewService(dbClient, mailer, envOr("APP_URL", "http://localhost:3000"), envOr("API_URL", "http://localhost:8080"))
+		notifier.SetEmailer(emailService)
+		go emailService.Run()
+		defer emailService.Stop()
+	}
+
 	// Initialize AI processor
 	aiProcessor := ai.NewProcessor(os.Getenv("OPENAI_API_KEY"))
 
@@ -111,11 +246,12 @@ func main() {
 	router := chi.NewRouter()
 
 	// Initialize API routes
-	api.SetupRoutes(router, dbClient, clerkService, b2Service, wsHub, aiProcessor)
+	repos := repository.NewSQLiteStore(dbClient.DB, dbClient.Reader())
+	api.SetupRoutes(router, dbClient, repos, users, b2Service, wsHub, aiProcessor, bus, dispatcher, clerkWebhooks)
 
 	// Add routes for user creation and file upload
 	router.Post("/users", createUser)
-	router.Post("/upload", handlers.UploadFile(b2Service, dbClient))
+	router.Post("/upload", handlers.UploadFile(b2Service, repos, bus))
 
 	// Add health check route
 	router.Get("/health", healthCheck)
@@ -130,3 +266,52 @@ func main() {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
+
+// sqliteOptions returns the default SQLite options, overridden by any
+// SQLITE_* environment variables that are set.
+func sqliteOptions() db.SQLiteOptions {
+	options := db.DefaultSQLiteOptions()
+	options.JournalMode = envOr("SQLITE_JOURNAL_MODE", options.JournalMode)
+	if v := os.Getenv("SQLITE_FOREIGN_KEYS"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			log.Fatalf("Invalid SQLITE_FOREIGN_KEYS: %v", err)
+		}
+		options.ForeignKeys = enabled
+	}
+	for key, d := range map[string]*time.Duration{
+		"SQLITE_BUSY_TIMEOUT":      &options.BusyTimeout,
+		"SQLITE_OPTIMIZE_INTERVAL": &options.OptimizeInterval,
+		"SQLITE_VACUUM_INTERVAL":   &options.VacuumInterval,
+	} {
+		if v := os.Getenv(key); v != "" {
+			parsed, err := time.ParseDuration(v)
+			if err != nil {
+				log.Fatalf("Invalid %s: %v", key, err)
+			}
+			*d = parsed
+		}
+	}
+	for key, n := range map[string]*int{
+		"SQLITE_READ_CONNECTIONS":     &options.ReadConnections,
+		"SQLITE_STATEMENT_CACHE_SIZE": &options.StatementCacheSize,
+		"SQLITE_VACUUM_PAGES":         &options.VacuumPages,
+	} {
+		if v := os.Getenv(key); v != "" {
+			parsed, err := strconv.Atoi(v)
+			if err != nil || parsed < 0 {
+				log.Fatalf("Invalid %s: %q", key, v)
+			}
+			*n = parsed
+		}
+	}
+	return options
+}
+
+// envOr returns the environment variable key, or fallback if it is unset.
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
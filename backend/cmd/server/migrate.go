@@ -0,0 +1,137 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/saint0x/file-storage-app/backend/internal/db"
+)
+
+const migrateUsage = `usage: server migrate <command>
+
+commands:
+  up [version]        apply pending migrations, up to version if given
+  down [steps]        roll back the last steps migrations (default 1)
+  status              list migrations and whether they are applied
+  baseline <version>  record migrations up to version as applied without
+                      running them, for databases created before migrations
+                      were tracked`
+
+// runMigrate implements the migrate subcommand.
+func runMigrate(conn *sql.DB, args []string) error {
+	migrator, err := db.NewMigrator(conn)
+	if err != nil {
+		return err
+	}
+
+	command := "up"
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("too many arguments\n%s", migrateUsage)
+	}
+	arg := func(fallback int) (int, error) {
+		if len(args) == 0 {
+			return fallback, nil
+		}
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid argument %q\n%s", args[0], migrateUsage)
+		}
+		return n, nil
+	}
+
+	var done []db.Migration
+	switch command {
+	case "up":
+		target, err := arg(-1)
+		if err != nil {
+			return err
+		}
+		done, err = migrator.Up(target)
+		printMigrations("Applied", done)
+		if err != nil {
+			return err
+		}
+	case "down":
+		steps, err := arg(1)
+		if err != nil {
+			return err
+		}
+		done, err = migrator.Down(steps)
+		printMigrations("Rolled back", done)
+		if err != nil {
+			return err
+		}
+	case "baseline":
+		if len(args) == 0 {
+			return fmt.Errorf("baseline needs a version\n%s", migrateUsage)
+		}
+		version, err := arg(0)
+		if err != nil {
+			return err
+		}
+		done, err = migrator.Baseline(version)
+		printMigrations("Recorded", done)
+		if err != nil {
+			return err
+		}
+	case "status":
+		return printMigrationStatus(migrator)
+	default:
+		return fmt.Errorf("unknown migrate command %q\n%s", command, migrateUsage)
+	}
+
+	if len(done) == 0 {
+		fmt.Println("Nothing to do")
+	}
+	return nil
+}
+
+func printMigrations(verb string, migrations []db.Migration) {
+	for _, m := range migrations {
+		fmt.Printf("%s %03d_%s\n", verb, m.Version, m.Name)
+	}
+}
+
+func printMigrationStatus(migrator *db.Migrator) error {
+	statuses, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+	version, err := migrator.Version()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED\t")
+	for _, s := range statuses {
+		applied := "pending"
+		if s.AppliedAt != nil {
+			applied = s.AppliedAt.Local().Format("2006-01-02 15:04:05")
+		}
+		note := ""
+		switch {
+		case s.Modified:
+			note = "changed since it was applied"
+		case s.Unknown:
+			note = "not in this build"
+		}
+		fmt.Fprintf(w, "%03d\t%s\t%s\t%s\n", s.Version, s.Name, applied, note)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if version < 0 {
+		fmt.Println("\nNo migrations applied")
+	} else {
+		fmt.Printf("\nDatabase is at version %03d\n", version)
+	}
+	return nil
+}
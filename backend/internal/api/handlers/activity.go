@@ -2,14 +2,34 @@ package handlers
 
 import (
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/saint0x/file-storage-app/backend/internal/db"
+	"github.com/saint0x/file-storage-app/backend/internal/models"
 	"github.com/saint0x/file-storage-app/backend/internal/services/auth"
 	"github.com/saint0x/file-storage-app/backend/pkg/errors"
 	"github.com/saint0x/file-storage-app/backend/pkg/utils"
 )
 
+// GetRecentActivity returns the caller's own activity.
 func GetRecentActivity(db *db.SQLiteClient) http.HandlerFunc {
+	return activityFeed(db.GetActivity)
+}
+
+// GetSharedActivity returns what other users have done to files shared
+// with the caller.
+func GetSharedActivity(db *db.SQLiteClient) http.HandlerFunc {
+	return activityFeed(db.GetSharedActivity)
+}
+
+type activityQuery func(userID string, filter models.ActivityFilter) ([]models.Activity, string, error)
+
+// activityFeed serves a feed page. It accepts cursor and limit for
+// pagination, and verb (comma-separated), target_type, target_id, from and
+// to (RFC 3339) as filters.
+func activityFeed(query activityQuery) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		userID, err := auth.GetUserIDFromContext(r.Context())
 		if err != nil {
@@ -17,11 +37,60 @@ func GetRecentActivity(db *db.SQLiteClient) http.HandlerFunc {
 			return
 		}
 
-		activity, err := db.GetRecentActivity(userID)
+		filter, err := parseActivityFilter(r)
+		if err != nil {
+			utils.RespondError(w, err)
+			return
+		}
+
+		activities, next, err := query(userID, filter)
+		if err == db.ErrInvalidCursor {
+			utils.RespondError(w, errors.BadRequest("Invalid cursor"))
+			return
+		}
 		if err != nil {
-			utils.RespondError(w, errors.InternalServerError("Failed to fetch recent activity"))
+			utils.RespondError(w, errors.InternalServerError("Failed to fetch activity"))
 			return
 		}
-		utils.RespondJSON(w, http.StatusOK, activity)
+
+		utils.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"activities":  activities,
+			"next_cursor": next,
+		})
+	}
+}
+
+func parseActivityFilter(r *http.Request) (models.ActivityFilter, error) {
+	q := r.URL.Query()
+	filter := models.ActivityFilter{
+		TargetType: q.Get("target_type"),
+		TargetID:   q.Get("target_id"),
+		Cursor:     q.Get("cursor"),
+		Limit:      50,
+	}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > 200 {
+			return filter, errors.BadRequest("limit must be between 1 and 200")
+		}
+		filter.Limit = limit
+	}
+	if v := q.Get("verb"); v != "" {
+		for _, verb := range strings.Split(v, ",") {
+			if verb = strings.TrimSpace(verb); verb != "" {
+				filter.Verbs = append(filter.Verbs, verb)
+			}
+		}
+	}
+	for name, dest := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		if v := q.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return filter, errors.BadRequest(name + " must be an RFC 3339 timestamp")
+			}
+			*dest = &t
+		}
 	}
+	return filter, nil
 }
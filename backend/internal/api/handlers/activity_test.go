@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/saint0x/file-storage-app/backend/internal/db/dbtest"
+)
+
+func TestGetRecentActivityRejectsBadQueries(t *testing.T) {
+	client := dbtest.New(t)
+	alice := dbtest.CreateUser(t, client, "alice")
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"?cursor=abc", http.StatusBadRequest},
+		{"?limit=0", http.StatusBadRequest},
+		{"?limit=500", http.StatusBadRequest},
+		{"?from=yesterday", http.StatusBadRequest},
+		{"?verb=file.created,file.deleted&target_type=file&limit=10", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			req := newRequest(http.MethodGet, alice, "", nil)
+			req.URL.RawQuery = tt.query[1:]
+			rec := httptest.NewRecorder()
+			GetRecentActivity(client)(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/saint0x/file-storage-app/backend/internal/db"
+	"github.com/saint0x/file-storage-app/backend/internal/events"
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+	"github.com/saint0x/file-storage-app/backend/internal/services/auth"
+	"github.com/saint0x/file-storage-app/backend/internal/services/storage"
+	"github.com/saint0x/file-storage-app/backend/pkg/errors"
+	"github.com/saint0x/file-storage-app/backend/pkg/utils"
+)
+
+// AdminListUsers returns users, optionally matching q, paged with limit
+// (default 50) and offset.
+func AdminListUsers(db *db.SQLiteClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		limit, ok := queryInt(w, r, "limit", 50, 1, 200)
+		if !ok {
+			return
+		}
+		offset, ok := queryInt(w, r, "offset", 0, 0, -1)
+		if !ok {
+			return
+		}
+
+		users, err := db.ListUsers(q.Get("q"), limit, offset)
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to fetch users"))
+			return
+		}
+		utils.RespondJSON(w, http.StatusOK, users)
+	}
+}
+
+// AdminGetUser returns a user along with their storage usage.
+func AdminGetUser(db *db.SQLiteClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := chi.URLParam(r, "id")
+		user, err := db.GetUserByID(userID)
+		if err == sql.ErrNoRows {
+			utils.RespondError(w, errors.NotFound("User not found"))
+			return
+		}
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to fetch user"))
+			return
+		}
+		usage, err := db.GetUserStorageUsage(userID)
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to fetch storage usage"))
+			return
+		}
+		utils.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"user":    user,
+			"storage": usage,
+		})
+	}
+}
+
+// AdminSetUserSuspended suspends a user, which rejects all of their tokens,
+// or reinstates them. Administrators cannot suspend themselves.
+func AdminSetUserSuspended(db *db.SQLiteClient, suspended bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		actorID, err := auth.GetUserIDFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+			return
+		}
+		userID := chi.URLParam(r, "id")
+		if userID == actorID {
+			utils.RespondError(w, errors.BadRequest("You cannot suspend yourself"))
+			return
+		}
+
+		err = db.SetUserSuspended(userID, suspended)
+		if err == sql.ErrNoRows {
+			utils.RespondError(w, errors.NotFound("User not found"))
+			return
+		}
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to update user"))
+			return
+		}
+
+		action := models.AuditUserUnsuspended
+		if suspended {
+			action = models.AuditUserSuspended
+		}
+		recordAudit(db, actorID, action, "user", userID, nil)
+		utils.RespondJSON(w, http.StatusOK, map[string]interface{}{"suspended": suspended})
+	}
+}
+
+// AdminSetUserRole makes a user an administrator or a regular user.
+// Administrators cannot demote themselves, so there is always one left.
+func AdminSetUserRole(db *db.SQLiteClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		actorID, err := auth.GetUserIDFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+			return
+		}
+		userID := chi.URLParam(r, "id")
+
+		var req struct {
+			Role models.UserRole `json:"role"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || !req.Role.Valid() {
+			utils.RespondError(w, errors.BadRequest("role must be user or admin"))
+			return
+		}
+		if userID == actorID && req.Role != models.RoleAdmin {
+			utils.RespondError(w, errors.BadRequest("You cannot remove your own admin role"))
+			return
+		}
+
+		user, err := db.GetUserByID(userID)
+		if err == sql.ErrNoRows {
+			utils.RespondError(w, errors.NotFound("User not found"))
+			return
+		}
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to fetch user"))
+			return
+		}
+		if err := db.SetUserRole(userID, req.Role); err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to update user"))
+			return
+		}
+
+		recordAudit(db, actorID, models.AuditUserRoleChanged, "user", userID, map[string]models.UserRole{
+			"from": user.Role,
+			"to":   req.Role,
+		})
+		utils.RespondJSON(w, http.StatusOK, map[string]interface{}{"role": req.Role})
+	}
+}
+
+// AdminGetStorageUsage returns total storage and the users storing the
+// most, up to limit (default 50).
+func AdminGetStorageUsage(db *db.SQLiteClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit, ok := queryInt(w, r, "limit", 50, 1, 200)
+		if !ok {
+			return
+		}
+		users, total, err := db.GetStorageUsage(limit)
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to fetch storage usage"))
+			return
+		}
+		utils.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"file_count":  total.FileCount,
+			"total_bytes": total.TotalBytes,
+			"users":       users,
+		})
+	}
+}
+
+// AdminDeleteFile deletes any user's file from storage and the database.
+func AdminDeleteFile(db *db.SQLiteClient, storageService *storage.B2Service, bus *events.Bus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		actorID, err := auth.GetUserIDFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+			return
+		}
+		fileID := chi.URLParam(r, "id")
+
+		file, err := db.GetFileByID(fileID)
+		if err == sql.ErrNoRows {
+			utils.RespondError(w, errors.NotFound("File not found"))
+			return
+		}
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to fetch file"))
+			return
+		}
+		audience := fileAudience(db, fileID)
+
+		if err := storageService.DeleteFile(r.Context(), file.Key); err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to delete file from storage"))
+			return
+		}
+		if err := db.DeleteFileRecords(fileID); err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to delete file metadata"))
+			return
+		}
+
+		recordAudit(db, actorID, models.AuditFileDeleted, "file", fileID, map[string]string{
+			"owner_id": file.UserID.String(),
+			"name":     file.Name,
+		})
+		bus.Publish(r.Context(), events.Event{
+			Type:     events.FileDeleted,
+			ActorID:  actorID,
+			Audience: audience,
+			Payload:  events.FilePayload{File: file},
+		})
+		utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "File deleted successfully"})
+	}
+}
+
+// AdminDeleteComment deletes any comment.
+func AdminDeleteComment(db *db.SQLiteClient, bus *events.Bus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		actorID, err := auth.GetUserIDFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+			return
+		}
+
+		comment, err := db.GetComment(chi.URLParam(r, "id"))
+		if err != nil || comment.DeletedAt != nil {
+			utils.RespondError(w, errors.NotFound("Comment not found"))
+			return
+		}
+		if err := db.DeleteComment(comment.ID); err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to delete comment"))
+			return
+		}
+
+		recordAudit(db, actorID, models.AuditCommentDeleted, "comment", comment.ID, map[string]string{
+			"author_id": comment.UserID,
+			"body":      comment.Body,
+		})
+		if deleted, err := db.GetComment(comment.ID); err == nil {
+			publishCommentEvent(r, db, bus, events.CommentDeleted, actorID, deleted, nil)
+		}
+		utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "Comment deleted successfully"})
+	}
+}
+
+// AdminGetAuditLogs returns a page of the audit log. It takes the same
+// parameters as the activity feed, with verb matching actions, plus
+// actor_id.
+func AdminGetAuditLogs(client *db.SQLiteClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseActivityFilter(r)
+		if err != nil {
+			utils.RespondError(w, err)
+			return
+		}
+
+		entries, next, err := client.GetAuditLogs(r.URL.Query().Get("actor_id"), filter)
+		if err == db.ErrInvalidCursor {
+			utils.RespondError(w, errors.BadRequest("Invalid cursor"))
+			return
+		}
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to fetch audit logs"))
+			return
+		}
+		utils.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"audit_logs":  entries,
+			"next_cursor": next,
+		})
+	}
+}
+
+// recordAudit adds an entry to the audit log. The action has already
+// happened, so failures are only logged.
+func recordAudit(db *db.SQLiteClient, actorID, action, targetType, targetID string, metadata interface{}) {
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		log.Printf("Error encoding audit metadata for %s: %v", action, err)
+		data = nil
+	}
+	err = db.RecordAuditLog(models.AuditLog{
+		ActorID:    actorID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Metadata:   data,
+	})
+	if err != nil {
+		log.Printf("Error recording audit log %s on %s %s: %v", action, targetType, targetID, err)
+	}
+}
+
+// queryInt parses the query parameter name, which must lie between min and
+// max (no upper bound if max is negative), and responds with an error if it
+// does not.
+func queryInt(w http.ResponseWriter, r *http.Request, name string, fallback, min, max int) (int, bool) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return fallback, true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < min || (max >= 0 && n > max) {
+		utils.RespondError(w, errors.BadRequest("Invalid "+name))
+		return 0, false
+	}
+	return n, true
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/saint0x/file-storage-app/backend/internal/db/dbtest"
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+)
+
+func TestAdminUserGuards(t *testing.T) {
+	client := dbtest.New(t)
+	admin := dbtest.CreateUser(t, client, "admin")
+	user := dbtest.CreateUser(t, client, "user")
+	if err := client.SetUserRole(admin, models.RoleAdmin); err != nil {
+		t.Fatalf("SetUserRole failed: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		target  string
+		body    string
+		want    int
+	}{
+		{"suspend themselves", AdminSetUserSuspended(client, true), admin, "", http.StatusBadRequest},
+		{"demote themselves", AdminSetUserRole(client), admin, `{"role": "user"}`, http.StatusBadRequest},
+		{"unknown role", AdminSetUserRole(client), user, `{"role": "owner"}`, http.StatusBadRequest},
+		{"suspend an unknown user", AdminSetUserSuspended(client, true), uuid.New().String(), "", http.StatusNotFound},
+		{"promote an unknown user", AdminSetUserRole(client), uuid.New().String(), `{"role": "admin"}`, http.StatusNotFound},
+		{"keep their own admin role", AdminSetUserRole(client), admin, `{"role": "admin"}`, http.StatusOK},
+		{"suspend someone else", AdminSetUserSuspended(client, true), user, "", http.StatusOK},
+		{"promote someone else", AdminSetUserRole(client), user, `{"role": "admin"}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tt.handler(rec, newRequest(http.MethodPost, admin, tt.body, map[string]string{"id": tt.target}))
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+
+	self, err := client.GetUserByID(admin)
+	if err != nil {
+		t.Fatalf("GetUserByID failed: %v", err)
+	}
+	if self.Role != models.RoleAdmin || self.SuspendedAt != nil {
+		t.Errorf("admin = role %s, suspended %v, want an active admin", self.Role, self.SuspendedAt)
+	}
+
+	logs, _, err := client.GetAuditLogs(admin, models.ActivityFilter{Limit: 10})
+	if err != nil {
+		t.Fatalf("GetAuditLogs failed: %v", err)
+	}
+	var actions []string
+	for _, entry := range logs {
+		actions = append(actions, entry.Action+" "+entry.TargetID)
+	}
+	want := []string{
+		models.AuditUserRoleChanged + " " + user,
+		models.AuditUserSuspended + " " + user,
+		models.AuditUserRoleChanged + " " + admin,
+	}
+	if len(actions) != len(want) {
+		t.Fatalf("audit log = %v, want %v", actions, want)
+	}
+	for i := range want {
+		if actions[i] != want[i] {
+			t.Errorf("audit log = %v, want %v", actions, want)
+			break
+		}
+	}
+}
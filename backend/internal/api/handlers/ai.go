@@ -6,6 +6,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/saint0x/file-storage-app/backend/internal/db"
+	"github.com/saint0x/file-storage-app/backend/internal/events"
 	"github.com/saint0x/file-storage-app/backend/internal/models"
 	"github.com/saint0x/file-storage-app/backend/internal/services/ai"
 	"github.com/saint0x/file-storage-app/backend/internal/services/auth"
@@ -13,7 +14,7 @@ import (
 	"github.com/saint0x/file-storage-app/backend/pkg/utils"
 )
 
-func OrganizeFiles(db *db.SQLiteClient, aiProcessor *ai.Processor) http.HandlerFunc {
+func OrganizeFiles(db *db.SQLiteClient, aiProcessor *ai.Processor, bus *events.Bus) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		userID, err := auth.GetUserIDFromContext(r.Context())
 		if err != nil {
@@ -49,6 +50,14 @@ func OrganizeFiles(db *db.SQLiteClient, aiProcessor *ai.Processor) http.HandlerF
 			return
 		}
 
+		// Only the caller's own files are moved, looked up by the names the AI returned
+		owned := make(map[string]models.File)
+		for _, file := range files {
+			if file.UserID == userUUID {
+				owned[file.Name] = file
+			}
+		}
+
 		// Create folders and update file associations
 		for _, folder := range aiResp.Folders {
 			newFolder := models.Folder{
@@ -60,12 +69,42 @@ func OrganizeFiles(db *db.SQLiteClient, aiProcessor *ai.Processor) http.HandlerF
 				utils.RespondError(w, errors.InternalServerError("Failed to create folder"))
 				return
 			}
+			newFolder.ID, _ = uuid.Parse(folderID)
+			bus.Publish(r.Context(), events.Event{
+				Type:     events.FolderCreated,
+				ActorID:  userID,
+				Audience: []string{userID},
+				Payload:  events.FolderPayload{Folder: newFolder},
+			})
 
 			for _, fileName := range folder.Files {
-				if err := db.UpdateFileFolder(fileName, folderID); err != nil {
+				match, ok := owned[fileName]
+				if !ok {
+					continue
+				}
+				file, err := db.GetOwnedFile(userID, match.ID.String())
+				if err != nil {
+					utils.RespondError(w, errors.InternalServerError("Failed to fetch file"))
+					return
+				}
+				if err := db.MoveFile(file.ID.String(), &folderID); err != nil {
 					utils.RespondError(w, errors.InternalServerError("Failed to update file folder"))
 					return
 				}
+
+				var from *string
+				if file.FolderID.Valid {
+					id := file.FolderID.UUID.String()
+					from = &id
+				}
+				to := folderID
+				file.FolderID = uuid.NullUUID{UUID: newFolder.ID, Valid: true}
+				bus.Publish(r.Context(), events.Event{
+					Type:     events.FileMoved,
+					ActorID:  userID,
+					Audience: fileAudience(db, file.ID.String()),
+					Payload:  events.FileMovedPayload{File: file, FromFolderID: from, ToFolderID: &to},
+				})
 			}
 		}
 
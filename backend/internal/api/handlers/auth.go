@@ -1,72 +1,226 @@
 package handlers
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"fmt"
+	"io"
+	"log"
 	"net/http"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/saint0x/file-storage-app/backend/internal/db"
+	"github.com/saint0x/file-storage-app/backend/internal/events"
 	"github.com/saint0x/file-storage-app/backend/internal/models"
 	"github.com/saint0x/file-storage-app/backend/internal/services/auth"
+	"github.com/saint0x/file-storage-app/backend/internal/services/identity"
+	"github.com/saint0x/file-storage-app/backend/internal/services/storage"
+	"github.com/saint0x/file-storage-app/backend/pkg/errors"
+	"github.com/saint0x/file-storage-app/backend/pkg/utils"
 )
 
-func ClerkWebhook(authService *auth.ClerkService, db *db.SQLiteClient) http.HandlerFunc {
+// maxClerkWebhookSize bounds the body of a Clerk webhook.
+const maxClerkWebhookSize = 1 << 20
+
+// ClerkWebhook handles the user, session and organization membership events
+// that Clerk delivers through Svix. Requests must carry a valid Svix
+// signature, and an event delivered more than once is only handled once.
+func ClerkWebhook(verifier *auth.SvixVerifier, users *identity.Resolver, db *db.SQLiteClient, storageService *storage.B2Service, bus *events.Bus) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		var webhookData struct {
-			Data struct {
-				ID        string `json:"id"`
-				Email     string `json:"email_address"`
-				FirstName string `json:"first_name"`
-				LastName  string `json:"last_name"`
-				Username  string `json:"username"`
-			} `json:"data"`
-			Type string `json:"type"`
-		}
-
-		err := json.NewDecoder(r.Body).Decode(&webhookData)
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxClerkWebhookSize))
 		if err != nil {
-			http.Error(w, "Invalid webhook data", http.StatusBadRequest)
+			http.Error(w, "Invalid webhook body", http.StatusBadRequest)
+			return
+		}
+		if err := verifier.Verify(r.Header, body); err != nil {
+			http.Error(w, "Invalid webhook signature", http.StatusUnauthorized)
 			return
 		}
 
-		switch webhookData.Type {
-		case "user.created":
-			newUser := models.User{
-				ID:        uuid.New(),
-				ClerkID:   webhookData.Data.ID,
-				Email:     webhookData.Data.Email,
-				Username:  webhookData.Data.Username,
-				FirstName: webhookData.Data.FirstName,
-				LastName:  webhookData.Data.LastName,
-				CreatedAt: time.Now(),
-				UpdatedAt: time.Now(),
-			}
+		var event struct {
+			Type string          `json:"type"`
+			Data json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(body, &event); err != nil {
+			http.Error(w, "Invalid webhook data", http.StatusBadRequest)
+			return
+		}
 
-			_, err = db.DB.Exec("INSERT INTO users (id, clerk_id, email, username, first_name, last_name, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
-				newUser.ID, newUser.ClerkID, newUser.Email, newUser.Username, newUser.FirstName, newUser.LastName, newUser.CreatedAt, newUser.UpdatedAt)
-			if err != nil {
-				http.Error(w, "Failed to create user", http.StatusInternalServerError)
+		eventID := r.Header.Get("svix-id")
+		processed, err := db.ClerkEventProcessed(eventID)
+		if err != nil {
+			http.Error(w, "Failed to check webhook", http.StatusInternalServerError)
+			return
+		}
+		if !processed {
+			if err := handleClerkEvent(r.Context(), users, db, storageService, bus, event.Type, event.Data); err != nil {
+				// Svix retries the delivery; every event is safe to handle again.
+				log.Printf("Error handling Clerk webhook %s (%s): %v", eventID, event.Type, err)
+				http.Error(w, "Failed to process webhook", http.StatusInternalServerError)
 				return
 			}
-
-		case "user.updated":
-			_, err = db.DB.Exec("UPDATE users SET email = ?, username = ?, first_name = ?, last_name = ?, updated_at = ? WHERE clerk_id = ?",
-				webhookData.Data.Email, webhookData.Data.Username, webhookData.Data.FirstName, webhookData.Data.LastName, time.Now(), webhookData.Data.ID)
-			if err != nil {
-				http.Error(w, "Failed to update user", http.StatusInternalServerError)
-				return
+			if err := db.MarkClerkEventProcessed(eventID, event.Type); err != nil {
+				log.Printf("Error recording Clerk webhook %s: %v", eventID, err)
 			}
+		}
 
-		case "user.deleted":
-			_, err = db.DB.Exec("DELETE FROM users WHERE clerk_id = ?", webhookData.Data.ID)
-			if err != nil {
-				http.Error(w, "Failed to delete user", http.StatusInternalServerError)
+		utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "Webhook processed successfully"})
+	}
+}
+
+func handleClerkEvent(ctx context.Context, users *identity.Resolver, db *db.SQLiteClient, storageService *storage.B2Service, bus *events.Bus, eventType string, data json.RawMessage) error {
+	switch eventType {
+	case "user.created", "user.updated":
+		var clerkUser map[string]interface{}
+		if err := json.Unmarshal(data, &clerkUser); err != nil {
+			return err
+		}
+		subject, _ := clerkUser["id"].(string)
+		profile := auth.ClerkUserProfile(clerkUser)
+		user, err := db.GetUserByIdentity(auth.ProviderClerk, subject)
+		if err == sql.ErrNoRows {
+			_, err = users.Provision(subject, profile)
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		// Email address changes arrive as user updates
+		return db.SyncUserProfile(user.ID.String(), models.User{
+			Email:     profile.Email,
+			Username:  profile.Username,
+			FirstName: profile.FirstName,
+			LastName:  profile.LastName,
+		}, profile.EmailVerified)
+
+	case "user.deleted":
+		var deleted struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(data, &deleted); err != nil {
+			return err
+		}
+		user, err := db.GetUserByIdentity(auth.ProviderClerk, deleted.ID)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return deleteAccount(ctx, db, storageService, bus, user.ID.String())
+
+	case "session.ended", "session.removed", "session.revoked":
+		var session struct {
+			ID     string `json:"id"`
+			UserID string `json:"user_id"`
+		}
+		if err := json.Unmarshal(data, &session); err != nil {
+			return err
+		}
+		user, err := db.GetUserByIdentity(auth.ProviderClerk, session.UserID)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return db.EndSession(session.ID, user.ID.String())
+
+	case "organizationMembership.created", "organizationMembership.updated":
+		var membership struct {
+			ID           string `json:"id"`
+			Role         string `json:"role"`
+			Organization struct {
+				ID   string `json:"id"`
+				Name string `json:"name"`
+			} `json:"organization"`
+			PublicUserData struct {
+				UserID string `json:"user_id"`
+			} `json:"public_user_data"`
+		}
+		if err := json.Unmarshal(data, &membership); err != nil {
+			return err
+		}
+		user, err := users.Resolve(ctx, membership.PublicUserData.UserID)
+		if err != nil {
+			return err
+		}
+		return db.SaveOrganizationMembership(membership.ID, membership.Organization.ID, membership.Organization.Name, user.ID, membership.Role)
+
+	case "organizationMembership.deleted":
+		var membership struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(data, &membership); err != nil {
+			return err
+		}
+		return db.DeleteOrganizationMembership(membership.ID)
+	}
+	return nil
+}
+
+// deleteAccount deletes a user's files from storage one at a time, letting
+// the people they were shared with know, and then the rest of their data.
+// Each file's records go with its storage object, so a retry picks up where
+// a failed attempt stopped.
+func deleteAccount(ctx context.Context, db *db.SQLiteClient, storageService *storage.B2Service, bus *events.Bus, userID string) error {
+	files, err := db.GetOwnedFiles(userID)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		fileID := file.ID.String()
+		audience := fileAudience(db, fileID)
+		if err := storageService.DeleteFile(ctx, file.Key); err != nil {
+			return fmt.Errorf("failed to delete %s from storage: %w", file.Key, err)
+		}
+		if err := db.DeleteFileRecords(fileID); err != nil {
+			return err
+		}
+		bus.Publish(ctx, events.Event{
+			Type:     events.FileDeleted,
+			ActorID:  userID,
+			Audience: audience,
+			Payload:  events.FilePayload{File: file},
+		})
+	}
+	return db.DeleteUserData(userID)
+}
+
+// IssueDevToken signs a token for any dev subject, so that the API can be
+// used without Clerk during development. The subject is provisioned as a
+// user the first time the token is used. It is only routed when the dev
+// authentication provider is configured.
+func IssueDevToken(dev *auth.DevAuthenticator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			UserID string `json:"user_id"`
+			TTL    string `json:"ttl"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UserID == "" {
+			utils.RespondError(w, errors.BadRequest("user_id is required"))
+			return
+		}
+
+		ttl := 24 * time.Hour
+		if req.TTL != "" {
+			d, err := time.ParseDuration(req.TTL)
+			if err != nil || d <= 0 {
+				utils.RespondError(w, errors.BadRequest("ttl must be a positive duration such as 1h"))
 				return
 			}
+			ttl = d
 		}
 
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(map[string]string{"message": "Webhook processed successfully"})
+		token, err := dev.IssueToken(req.UserID, ttl)
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to issue token"))
+			return
+		}
+		utils.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"token":      token,
+			"expires_at": time.Now().Add(ttl),
+		})
 	}
 }
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/saint0x/file-storage-app/backend/internal/db/dbtest"
+	"github.com/saint0x/file-storage-app/backend/internal/events"
+	"github.com/saint0x/file-storage-app/backend/internal/services/auth"
+	"github.com/saint0x/file-storage-app/backend/internal/services/identity"
+)
+
+const clerkWebhookSecret = "whsec_MfKQ9r8GKYqrTwjUPD8ILPZIo2LaLaSw"
+
+// clerkWebhookRequest returns a Clerk webhook delivery of body, signed as
+// Svix would sign message id.
+func clerkWebhookRequest(t *testing.T, id, body string) *http.Request {
+	t.Helper()
+	key, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(clerkWebhookSecret, "whsec_"))
+	if err != nil {
+		t.Fatalf("invalid secret: %v", err)
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(id + "." + timestamp + "." + body))
+
+	req := httptest.NewRequest(http.MethodPost, "/webhooks/clerk", strings.NewReader(body))
+	req.Header.Set("svix-id", id)
+	req.Header.Set("svix-timestamp", timestamp)
+	req.Header.Set("svix-signature", "v1,"+base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	return req
+}
+
+func clerkUserEvent(eventType, username string) string {
+	return `{"type": "` + eventType + `", "data": {"id": "user_1", "username": "` + username + `",
+		"primary_email_address_id": "email_1",
+		"email_addresses": [{"id": "email_1", "email_address": "alice@example.com", "verification": {"status": "verified"}}]}}`
+}
+
+func TestClerkWebhookHandlesEachEventOnce(t *testing.T) {
+	client := dbtest.New(t)
+	verifier, err := auth.NewSvixVerifier(clerkWebhookSecret)
+	if err != nil {
+		t.Fatalf("NewSvixVerifier failed: %v", err)
+	}
+	users := identity.NewResolver(client, auth.ProviderClerk, nil, nil)
+	handler := ClerkWebhook(verifier, users, client, nil, events.NewBus())
+
+	deliver := func(req *http.Request, want int) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		if rec.Code != want {
+			t.Fatalf("status = %d, want %d: %s", rec.Code, want, rec.Body)
+		}
+	}
+	username := func() string {
+		t.Helper()
+		user, err := client.GetUserByIdentity(auth.ProviderClerk, "user_1")
+		if err != nil {
+			t.Fatalf("GetUserByIdentity failed: %v", err)
+		}
+		return user.Username
+	}
+
+	forged := clerkWebhookRequest(t, "msg_0", clerkUserEvent("user.created", "alice"))
+	forged.Header.Set("svix-signature", "v1,"+base64.StdEncoding.EncodeToString([]byte("forged")))
+	deliver(forged, http.StatusUnauthorized)
+
+	deliver(clerkWebhookRequest(t, "msg_1", clerkUserEvent("user.created", "alice")), http.StatusOK)
+	deliver(clerkWebhookRequest(t, "msg_2", clerkUserEvent("user.updated", "alice-renamed")), http.StatusOK)
+	if got := username(); got != "alice-renamed" {
+		t.Fatalf("username = %q after the update, want alice-renamed", got)
+	}
+
+	// Handling user.created again would sync the old username back.
+	deliver(clerkWebhookRequest(t, "msg_1", clerkUserEvent("user.created", "alice")), http.StatusOK)
+	if got := username(); got != "alice-renamed" {
+		t.Errorf("username = %q after a redelivery, want alice-renamed", got)
+	}
+}
@@ -1,20 +1,24 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
+	"log"
 	"net/http"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"github.com/saint0x/file-storage-app/backend/internal/db"
+	"github.com/saint0x/file-storage-app/backend/internal/events"
 	"github.com/saint0x/file-storage-app/backend/internal/models"
+	"github.com/saint0x/file-storage-app/backend/internal/repository"
 	"github.com/saint0x/file-storage-app/backend/internal/services/auth"
 	"github.com/saint0x/file-storage-app/backend/pkg/errors"
 	"github.com/saint0x/file-storage-app/backend/pkg/utils"
 )
 
-func CreateCollection(db *db.SQLiteClient) http.HandlerFunc {
+func CreateCollection(repos repository.Store, bus *events.Bus) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		userID, err := auth.GetUserIDFromContext(r.Context())
 		if err != nil {
@@ -41,13 +45,19 @@ func CreateCollection(db *db.SQLiteClient) http.HandlerFunc {
 		collection.CreatedAt = time.Now()
 		collection.UpdatedAt = time.Now()
 
-		_, err = db.DB.Exec("INSERT INTO collections (id, user_id, name, description, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)",
-			collection.ID, collection.UserID, collection.Name, collection.Description, collection.CreatedAt, collection.UpdatedAt)
+		err = repos.Collections().Create(r.Context(), collection)
 		if err != nil {
 			utils.RespondError(w, errors.InternalServerError("Failed to create collection"))
 			return
 		}
 
+		bus.Publish(r.Context(), events.Event{
+			Type:     events.CollectionCreated,
+			ActorID:  userID,
+			Audience: []string{userID},
+			Payload:  events.CollectionPayload{Collection: collection},
+		})
+
 		utils.RespondJSON(w, http.StatusCreated, collection)
 	}
 }
@@ -60,35 +70,94 @@ func GetCollections(db *db.SQLiteClient) http.HandlerFunc {
 			return
 		}
 
-		userUUID, err := uuid.Parse(userID)
+		collections, err := db.GetCollectionsForUser(userID)
 		if err != nil {
-			utils.RespondError(w, errors.InternalServerError("Invalid user ID format"))
+			utils.RespondError(w, errors.InternalServerError("Failed to fetch collections"))
 			return
 		}
 
-		rows, err := db.DB.Query("SELECT * FROM collections WHERE user_id = ?", userUUID)
+		utils.RespondJSON(w, http.StatusOK, collections)
+	}
+}
+
+func UpdateCollection(db *db.SQLiteClient, repos repository.Store, bus *events.Bus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := auth.GetUserIDFromContext(r.Context())
 		if err != nil {
-			utils.RespondError(w, errors.InternalServerError("Failed to fetch collections"))
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
 			return
 		}
-		defer rows.Close()
 
-		var collections []models.Collection
-		for rows.Next() {
-			var c models.Collection
-			err := rows.Scan(&c.ID, &c.UserID, &c.Name, &c.Description, &c.CreatedAt, &c.UpdatedAt)
-			if err != nil {
-				utils.RespondError(w, errors.InternalServerError("Failed to scan collection"))
+		collectionID := chi.URLParam(r, "id")
+		collectionUUID, err := uuid.Parse(collectionID)
+		if err != nil {
+			utils.RespondError(w, errors.BadRequest("Invalid collection ID"))
+			return
+		}
+
+		if !requireCollectionRole(w, db, collectionID, userID, models.CollectionRoleAdmin) {
+			return
+		}
+
+		// Fields left out of the request keep their value. A null rule turns
+		// a smart collection back into a manually curated one.
+		var req struct {
+			Name        *string         `json:"name"`
+			Description *string         `json:"description"`
+			Rule        json.RawMessage `json:"rule"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.RespondError(w, errors.BadRequest("Invalid request body"))
+			return
+		}
+		var rule *models.CollectionRule
+		if len(req.Rule) > 0 && string(req.Rule) != "null" {
+			rule = &models.CollectionRule{}
+			if err := json.Unmarshal(req.Rule, rule); err != nil {
+				utils.RespondError(w, errors.BadRequest("Invalid collection rule"))
 				return
 			}
-			collections = append(collections, c)
 		}
 
-		utils.RespondJSON(w, http.StatusOK, collections)
+		err = repos.WithinTx(r.Context(), func(tx repository.Store) error {
+			collection, err := tx.Collections().Get(r.Context(), collectionUUID.String())
+			if err != nil {
+				return err
+			}
+			if req.Name != nil {
+				collection.Name = *req.Name
+			}
+			if req.Description != nil {
+				collection.Description = *req.Description
+			}
+			if len(req.Rule) > 0 {
+				collection.Rule = rule
+			}
+			return tx.Collections().Update(r.Context(), collection)
+		})
+		if err == repository.ErrNotFound {
+			utils.RespondError(w, errors.NotFound("Collection not found"))
+			return
+		}
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to update collection"))
+			return
+		}
+
+		if updated, err := repos.Collections().Get(r.Context(), collectionID); err == nil {
+			bus.Publish(r.Context(), events.Event{
+				Type:     events.CollectionUpdated,
+				ActorID:  userID,
+				Audience: collectionAudience(db, collectionID),
+				Payload:  events.CollectionPayload{Collection: updated},
+			})
+		}
+
+		utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "Collection updated successfully"})
 	}
 }
 
-func UpdateCollection(db *db.SQLiteClient) http.HandlerFunc {
+func DeleteCollection(db *db.SQLiteClient, bus *events.Bus) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		userID, err := auth.GetUserIDFromContext(r.Context())
 		if err != nil {
@@ -96,38 +165,120 @@ func UpdateCollection(db *db.SQLiteClient) http.HandlerFunc {
 			return
 		}
 
-		userUUID, err := uuid.Parse(userID)
+		collectionID := chi.URLParam(r, "id")
+		if _, err := uuid.Parse(collectionID); err != nil {
+			utils.RespondError(w, errors.BadRequest("Invalid collection ID"))
+			return
+		}
+
+		// Members are told about the deletion, so capture them first
+		collection, collectionErr := db.GetCollection(collectionID)
+		audience := collectionAudience(db, collectionID)
+
+		err = db.DeleteCollection(collectionID, userID)
+		if err == sql.ErrNoRows {
+			utils.RespondError(w, errors.NotFound("Collection not found or not owned by user"))
+			return
+		}
 		if err != nil {
-			utils.RespondError(w, errors.InternalServerError("Invalid user ID format"))
+			utils.RespondError(w, errors.InternalServerError("Failed to delete collection"))
+			return
+		}
+
+		if collectionErr == nil {
+			bus.Publish(r.Context(), events.Event{
+				Type:     events.CollectionDeleted,
+				ActorID:  userID,
+				Audience: audience,
+				Payload:  events.CollectionPayload{Collection: collection},
+			})
+		}
+
+		utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "Collection deleted successfully"})
+	}
+}
+
+func GetCollectionMembers(db *db.SQLiteClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := auth.GetUserIDFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
 			return
 		}
 
 		collectionID := chi.URLParam(r, "id")
-		collectionUUID, err := uuid.Parse(collectionID)
+		if !requireCollectionRole(w, db, collectionID, userID, models.CollectionRoleViewer) {
+			return
+		}
+
+		members, err := db.GetCollectionMembers(collectionID)
 		if err != nil {
-			utils.RespondError(w, errors.BadRequest("Invalid collection ID"))
+			utils.RespondError(w, errors.InternalServerError("Failed to fetch collection members"))
 			return
 		}
+		utils.RespondJSON(w, http.StatusOK, members)
+	}
+}
 
-		var updateData models.Collection
-		err = json.NewDecoder(r.Body).Decode(&updateData)
+func AddCollectionMember(db *db.SQLiteClient, bus *events.Bus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := auth.GetUserIDFromContext(r.Context())
 		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+			return
+		}
+
+		collectionID := chi.URLParam(r, "id")
+		if !requireCollectionRole(w, db, collectionID, userID, models.CollectionRoleAdmin) {
+			return
+		}
+
+		var req struct {
+			UserID string                `json:"user_id"`
+			Role   models.CollectionRole `json:"role"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			utils.RespondError(w, errors.BadRequest("Invalid request body"))
 			return
 		}
+		if req.Role == "" {
+			req.Role = models.CollectionRoleViewer
+		}
+		if !req.Role.Valid() {
+			utils.RespondError(w, errors.BadRequest("Invalid collection role"))
+			return
+		}
+		if req.UserID == "" || req.UserID == userID {
+			utils.RespondError(w, errors.BadRequest("Invalid user ID"))
+			return
+		}
 
-		_, err = db.DB.Exec("UPDATE collections SET name = ?, description = ?, updated_at = ? WHERE id = ? AND user_id = ?",
-			updateData.Name, updateData.Description, time.Now(), collectionUUID, userUUID)
+		friends, err := db.AreFriends(userID, req.UserID)
 		if err != nil {
-			utils.RespondError(w, errors.InternalServerError("Failed to update collection"))
+			utils.RespondError(w, errors.InternalServerError("Failed to check friendship"))
+			return
+		}
+		if !friends {
+			utils.RespondError(w, errors.Forbidden("Collections can only be shared with friends"))
 			return
 		}
 
-		utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "Collection updated successfully"})
+		if err := db.AddCollectionMember(collectionID, req.UserID, req.Role, userID); err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to add collection member"))
+			return
+		}
+
+		bus.Publish(r.Context(), events.Event{
+			Type:     events.CollectionMemberAdded,
+			ActorID:  userID,
+			Audience: collectionAudience(db, collectionID),
+			Payload:  events.CollectionMemberPayload{CollectionID: collectionID, UserID: req.UserID, Role: req.Role},
+		})
+		utils.RespondJSON(w, http.StatusCreated, map[string]string{"message": "Collection member added successfully"})
 	}
 }
 
-func DeleteCollection(db *db.SQLiteClient) http.HandlerFunc {
+func UpdateCollectionMember(db *db.SQLiteClient, bus *events.Bus) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		userID, err := auth.GetUserIDFromContext(r.Context())
 		if err != nil {
@@ -135,25 +286,296 @@ func DeleteCollection(db *db.SQLiteClient) http.HandlerFunc {
 			return
 		}
 
-		userUUID, err := uuid.Parse(userID)
+		collectionID := chi.URLParam(r, "id")
+		if !requireCollectionRole(w, db, collectionID, userID, models.CollectionRoleAdmin) {
+			return
+		}
+
+		var req struct {
+			Role models.CollectionRole `json:"role"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.RespondError(w, errors.BadRequest("Invalid request body"))
+			return
+		}
+		if !req.Role.Valid() {
+			utils.RespondError(w, errors.BadRequest("Invalid collection role"))
+			return
+		}
+
+		memberID := chi.URLParam(r, "userID")
+		err = db.UpdateCollectionMemberRole(collectionID, memberID, req.Role)
+		if err == sql.ErrNoRows {
+			utils.RespondError(w, errors.NotFound("Collection member not found"))
+			return
+		}
 		if err != nil {
-			utils.RespondError(w, errors.InternalServerError("Invalid user ID format"))
+			utils.RespondError(w, errors.InternalServerError("Failed to update collection member"))
+			return
+		}
+
+		bus.Publish(r.Context(), events.Event{
+			Type:     events.CollectionMemberUpdated,
+			ActorID:  userID,
+			Audience: collectionAudience(db, collectionID),
+			Payload:  events.CollectionMemberPayload{CollectionID: collectionID, UserID: memberID, Role: req.Role},
+		})
+		utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "Collection member updated successfully"})
+	}
+}
+
+func RemoveCollectionMember(db *db.SQLiteClient, bus *events.Bus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := auth.GetUserIDFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
 			return
 		}
 
 		collectionID := chi.URLParam(r, "id")
-		collectionUUID, err := uuid.Parse(collectionID)
+		memberID := chi.URLParam(r, "userID")
+
+		// Members may always leave a collection; removing others requires admin.
+		required := models.CollectionRoleAdmin
+		if memberID == userID {
+			required = models.CollectionRoleViewer
+		}
+		if !requireCollectionRole(w, db, collectionID, userID, required) {
+			return
+		}
+
+		// The removed member is told too, so capture the audience first
+		audience := collectionAudience(db, collectionID)
+		if err := db.RemoveCollectionMember(collectionID, memberID); err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to remove collection member"))
+			return
+		}
+
+		bus.Publish(r.Context(), events.Event{
+			Type:     events.CollectionMemberRemoved,
+			ActorID:  userID,
+			Audience: audience,
+			Payload:  events.CollectionMemberPayload{CollectionID: collectionID, UserID: memberID},
+		})
+		utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "Collection member removed successfully"})
+	}
+}
+
+func GetCollectionFiles(db *db.SQLiteClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := auth.GetUserIDFromContext(r.Context())
 		if err != nil {
-			utils.RespondError(w, errors.BadRequest("Invalid collection ID"))
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
 			return
 		}
 
-		_, err = db.DB.Exec("DELETE FROM collections WHERE id = ? AND user_id = ?", collectionUUID, userUUID)
+		collectionID := chi.URLParam(r, "id")
+		if !requireCollectionRole(w, db, collectionID, userID, models.CollectionRoleViewer) {
+			return
+		}
+
+		collection, err := db.GetCollection(collectionID)
 		if err != nil {
-			utils.RespondError(w, errors.InternalServerError("Failed to delete collection"))
+			utils.RespondError(w, errors.InternalServerError("Failed to fetch collection"))
 			return
 		}
 
-		utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "Collection deleted successfully"})
+		var files []models.CollectionFile
+		if collection.IsSmart() {
+			files, err = db.GetSmartCollectionFiles(collection, userID)
+		} else {
+			files, err = db.GetCollectionFiles(collectionID, userID)
+		}
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to fetch collection files"))
+			return
+		}
+		utils.RespondJSON(w, http.StatusOK, files)
+	}
+}
+
+// AddFileToCollection adds a file the caller owns or that was shared with
+// them, so shared-with-me files can be curated into a collection. Other
+// members only see a shared file while it is shared with them as well.
+func AddFileToCollection(db *db.SQLiteClient, bus *events.Bus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := auth.GetUserIDFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+			return
+		}
+
+		collectionID := chi.URLParam(r, "id")
+		if !requireCollectionRole(w, db, collectionID, userID, models.CollectionRoleContributor) {
+			return
+		}
+		if !requireManualCollection(w, db, collectionID) {
+			return
+		}
+
+		var req struct {
+			FileIDs []string `json:"file_ids"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.FileIDs) == 0 {
+			utils.RespondError(w, errors.BadRequest("Invalid request body"))
+			return
+		}
+
+		for _, fileID := range req.FileIDs {
+			canAccess, err := db.UserCanAccessFile(userID, fileID)
+			if err != nil {
+				utils.RespondError(w, errors.InternalServerError("Failed to check file access"))
+				return
+			}
+			if !canAccess {
+				utils.RespondError(w, errors.NotFound("File not found or not accessible"))
+				return
+			}
+		}
+
+		for _, fileID := range req.FileIDs {
+			if err := db.AddFileToCollection(collectionID, fileID, userID); err != nil {
+				utils.RespondError(w, errors.InternalServerError("Failed to add file to collection"))
+				return
+			}
+		}
+
+		bus.Publish(r.Context(), events.Event{
+			Type:     events.CollectionFilesAdded,
+			ActorID:  userID,
+			Audience: collectionAudience(db, collectionID),
+			Payload:  events.CollectionFilesPayload{CollectionID: collectionID, FileIDs: req.FileIDs},
+		})
+		utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "Files added to collection successfully"})
+	}
+}
+
+func RemoveFileFromCollection(db *db.SQLiteClient, bus *events.Bus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := auth.GetUserIDFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+			return
+		}
+
+		collectionID := chi.URLParam(r, "id")
+		fileID := chi.URLParam(r, "fileID")
+
+		role, err := db.GetCollectionRole(collectionID, userID)
+		if err != nil {
+			utils.RespondError(w, errors.NotFound("Collection not found"))
+			return
+		}
+		if !requireManualCollection(w, db, collectionID) {
+			return
+		}
+
+		// Contributors may remove the files they added; admins may remove any file.
+		if !role.Allows(models.CollectionRoleAdmin) {
+			addedBy, err := db.GetCollectionFileAddedBy(collectionID, fileID)
+			if err != nil {
+				utils.RespondError(w, errors.NotFound("File not found in collection"))
+				return
+			}
+			if !role.Allows(models.CollectionRoleContributor) || addedBy != userID {
+				utils.RespondError(w, errors.Forbidden("Insufficient collection permissions"))
+				return
+			}
+		}
+
+		if err := db.RemoveFileFromCollection(collectionID, fileID); err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to remove file from collection"))
+			return
+		}
+
+		bus.Publish(r.Context(), events.Event{
+			Type:     events.CollectionFilesRemoved,
+			ActorID:  userID,
+			Audience: collectionAudience(db, collectionID),
+			Payload:  events.CollectionFilesPayload{CollectionID: collectionID, FileIDs: []string{fileID}},
+		})
+		utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "File removed from collection successfully"})
+	}
+}
+
+func ReorderCollectionFiles(db *db.SQLiteClient, bus *events.Bus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := auth.GetUserIDFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+			return
+		}
+
+		collectionID := chi.URLParam(r, "id")
+		if !requireCollectionRole(w, db, collectionID, userID, models.CollectionRoleContributor) {
+			return
+		}
+		if !requireManualCollection(w, db, collectionID) {
+			return
+		}
+
+		var req struct {
+			FileIDs []string `json:"file_ids"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.RespondError(w, errors.BadRequest("Invalid request body"))
+			return
+		}
+
+		if err := db.ReorderCollectionFiles(collectionID, req.FileIDs); err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to reorder collection files"))
+			return
+		}
+
+		bus.Publish(r.Context(), events.Event{
+			Type:     events.CollectionFilesReordered,
+			ActorID:  userID,
+			Audience: collectionAudience(db, collectionID),
+			Payload:  events.CollectionFilesPayload{CollectionID: collectionID, FileIDs: req.FileIDs},
+		})
+		utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "Collection files reordered successfully"})
+	}
+}
+
+// requireCollectionRole writes an error response and returns false unless
+// userID holds at least the required role on the collection.
+func requireCollectionRole(w http.ResponseWriter, db *db.SQLiteClient, collectionID, userID string, required models.CollectionRole) bool {
+	role, err := db.GetCollectionRole(collectionID, userID)
+	if err == sql.ErrNoRows {
+		utils.RespondError(w, errors.NotFound("Collection not found"))
+		return false
+	}
+	if err != nil {
+		utils.RespondError(w, errors.InternalServerError("Failed to check collection access"))
+		return false
+	}
+	if !role.Allows(required) {
+		utils.RespondError(w, errors.Forbidden("Insufficient collection permissions"))
+		return false
+	}
+	return true
+}
+
+// requireManualCollection writes an error response and returns false if the
+// collection's contents are computed from a rule and cannot be edited directly.
+func requireManualCollection(w http.ResponseWriter, db *db.SQLiteClient, collectionID string) bool {
+	collection, err := db.GetCollection(collectionID)
+	if err != nil {
+		utils.RespondError(w, errors.InternalServerError("Failed to fetch collection"))
+		return false
+	}
+	if collection.IsSmart() {
+		utils.RespondError(w, errors.BadRequest("Smart collection contents are defined by its rule"))
+		return false
+	}
+	return true
+}
+
+// collectionAudience returns the owner and members of a collection.
+func collectionAudience(db *db.SQLiteClient, collectionID string) []string {
+	audience, err := db.GetCollectionAudience(collectionID)
+	if err != nil {
+		log.Printf("Error fetching audience for collection %s: %v", collectionID, err)
 	}
+	return audience
 }
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/saint0x/file-storage-app/backend/internal/db"
+	"github.com/saint0x/file-storage-app/backend/internal/db/dbtest"
+	"github.com/saint0x/file-storage-app/backend/internal/events"
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+)
+
+// collectionFixture is a collection owned by owner, with a member of each
+// assignable role and an outsider. The admin is friends with everyone but
+// the owner.
+type collectionFixture struct {
+	client                                      *db.SQLiteClient
+	id                                          string
+	owner, admin, contributor, viewer, outsider string
+}
+
+func newCollectionFixture(t *testing.T) collectionFixture {
+	t.Helper()
+	client := dbtest.New(t)
+	f := collectionFixture{
+		client:      client,
+		id:          uuid.New().String(),
+		owner:       dbtest.CreateUser(t, client, "owner"),
+		admin:       dbtest.CreateUser(t, client, "admin"),
+		contributor: dbtest.CreateUser(t, client, "contributor"),
+		viewer:      dbtest.CreateUser(t, client, "viewer"),
+		outsider:    dbtest.CreateUser(t, client, "outsider"),
+	}
+	if _, err := client.DB.Exec(`INSERT INTO collections (id, user_id, name) VALUES (?, ?, 'Reports')`, f.id, f.owner); err != nil {
+		t.Fatalf("failed to create collection: %v", err)
+	}
+	for userID, role := range map[string]models.CollectionRole{
+		f.admin:       models.CollectionRoleAdmin,
+		f.contributor: models.CollectionRoleContributor,
+		f.viewer:      models.CollectionRoleViewer,
+	} {
+		if err := client.AddCollectionMember(f.id, userID, role, f.owner); err != nil {
+			t.Fatalf("failed to add collection member: %v", err)
+		}
+	}
+	for _, userID := range []string{f.contributor, f.viewer, f.outsider} {
+		_, err := client.DB.Exec(`INSERT INTO friends (id, user_id, friend_id, status) VALUES (?, ?, ?, 'accepted')`,
+			uuid.New().String(), f.admin, userID)
+		if err != nil {
+			t.Fatalf("failed to create friendship: %v", err)
+		}
+	}
+	return f
+}
+
+func TestAddFileToCollectionRoles(t *testing.T) {
+	f := newCollectionFixture(t)
+	ownFile := dbtest.CreateFile(t, f.client, f.contributor)
+	otherFile := dbtest.CreateFile(t, f.client, f.outsider)
+
+	tests := []struct {
+		name   string
+		userID string
+		fileID string
+		want   int
+	}{
+		{"outsider", f.outsider, ownFile, http.StatusNotFound},
+		{"viewer", f.viewer, ownFile, http.StatusForbidden},
+		{"contributor with a file they cannot access", f.contributor, otherFile, http.StatusNotFound},
+		{"contributor with their own file", f.contributor, ownFile, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			body := `{"file_ids": ["` + tt.fileID + `"]}`
+			AddFileToCollection(f.client, events.NewBus())(rec, newRequest(http.MethodPost, tt.userID, body, map[string]string{"id": f.id}))
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
+
+func TestCollectionMemberRoles(t *testing.T) {
+	f := newCollectionFixture(t)
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		userID  string
+		params  map[string]string
+		body    string
+		want    int
+	}{
+		{"contributor adds a member", AddCollectionMember(f.client, events.NewBus()), f.contributor,
+			map[string]string{"id": f.id}, `{"user_id": "` + f.outsider + `"}`, http.StatusForbidden},
+		{"admin adds someone who is not their friend", AddCollectionMember(f.client, events.NewBus()), f.admin,
+			map[string]string{"id": f.id}, `{"user_id": "` + f.owner + `"}`, http.StatusForbidden},
+		{"admin adds the owner role", AddCollectionMember(f.client, events.NewBus()), f.admin,
+			map[string]string{"id": f.id}, `{"user_id": "` + f.outsider + `", "role": "owner"}`, http.StatusBadRequest},
+		{"admin adds a friend", AddCollectionMember(f.client, events.NewBus()), f.admin,
+			map[string]string{"id": f.id}, `{"user_id": "` + f.outsider + `"}`, http.StatusCreated},
+		{"viewer promotes themselves", UpdateCollectionMember(f.client, events.NewBus()), f.viewer,
+			map[string]string{"id": f.id, "userID": f.viewer}, `{"role": "admin"}`, http.StatusForbidden},
+		{"contributor removes someone else", RemoveCollectionMember(f.client, events.NewBus()), f.contributor,
+			map[string]string{"id": f.id, "userID": f.viewer}, "", http.StatusForbidden},
+		{"viewer leaves", RemoveCollectionMember(f.client, events.NewBus()), f.viewer,
+			map[string]string{"id": f.id, "userID": f.viewer}, "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tt.handler(rec, newRequest(http.MethodPost, tt.userID, tt.body, tt.params))
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
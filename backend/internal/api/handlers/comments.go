@@ -0,0 +1,347 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/saint0x/file-storage-app/backend/internal/db"
+	"github.com/saint0x/file-storage-app/backend/internal/events"
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+	"github.com/saint0x/file-storage-app/backend/internal/services/auth"
+	"github.com/saint0x/file-storage-app/backend/pkg/errors"
+	"github.com/saint0x/file-storage-app/backend/pkg/utils"
+)
+
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@([A-Za-z0-9_.-]+)`)
+
+func GetComments(db *db.SQLiteClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := auth.GetUserIDFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+			return
+		}
+
+		targetType := models.CommentTargetType(r.URL.Query().Get("target_type"))
+		targetID := r.URL.Query().Get("target_id")
+		if !targetType.Valid() || targetID == "" {
+			utils.RespondError(w, errors.BadRequest("target_type and target_id are required"))
+			return
+		}
+		if !requireTargetAccess(w, db, userID, targetType, targetID) {
+			return
+		}
+
+		comments, err := db.GetComments(targetType, targetID)
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to fetch comments"))
+			return
+		}
+		utils.RespondJSON(w, http.StatusOK, comments)
+	}
+}
+
+func CreateComment(db *db.SQLiteClient, bus *events.Bus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := auth.GetUserIDFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+			return
+		}
+
+		var req struct {
+			TargetType models.CommentTargetType `json:"target_type"`
+			TargetID   string                   `json:"target_id"`
+			ParentID   *string                  `json:"parent_id"`
+			Body       string                   `json:"body"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.RespondError(w, errors.BadRequest("Invalid request body"))
+			return
+		}
+		req.Body = strings.TrimSpace(req.Body)
+		if !req.TargetType.Valid() || req.TargetID == "" || req.Body == "" {
+			utils.RespondError(w, errors.BadRequest("target_type, target_id and body are required"))
+			return
+		}
+		if !requireTargetAccess(w, db, userID, req.TargetType, req.TargetID) {
+			return
+		}
+
+		if req.ParentID != nil {
+			parent, err := db.GetComment(*req.ParentID)
+			if err != nil || parent.TargetType != req.TargetType || parent.TargetID != req.TargetID {
+				utils.RespondError(w, errors.BadRequest("Invalid parent comment"))
+				return
+			}
+		}
+
+		mentions, err := db.ResolveMentionedFriends(userID, parseMentions(req.Body))
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to resolve mentions"))
+			return
+		}
+
+		comment, err := db.CreateComment(models.Comment{
+			TargetType: req.TargetType,
+			TargetID:   req.TargetID,
+			UserID:     userID,
+			ParentID:   req.ParentID,
+			Body:       req.Body,
+			Mentions:   mentions,
+		})
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to create comment"))
+			return
+		}
+
+		publishCommentEvent(r, db, bus, events.CommentCreated, userID, comment, mentions)
+
+		utils.RespondJSON(w, http.StatusCreated, comment)
+	}
+}
+
+func UpdateComment(db *db.SQLiteClient, bus *events.Bus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := auth.GetUserIDFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+			return
+		}
+
+		comment, err := db.GetComment(chi.URLParam(r, "id"))
+		if err != nil || comment.DeletedAt != nil {
+			utils.RespondError(w, errors.NotFound("Comment not found"))
+			return
+		}
+		if comment.UserID != userID {
+			utils.RespondError(w, errors.Forbidden("Only the author can edit a comment"))
+			return
+		}
+
+		var req struct {
+			Body string `json:"body"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Body) == "" {
+			utils.RespondError(w, errors.BadRequest("Invalid request body"))
+			return
+		}
+		req.Body = strings.TrimSpace(req.Body)
+
+		mentions, err := db.ResolveMentionedFriends(userID, parseMentions(req.Body))
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to resolve mentions"))
+			return
+		}
+
+		if err := db.UpdateCommentBody(comment.ID, req.Body, mentions); err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to update comment"))
+			return
+		}
+
+		updated, err := db.GetComment(comment.ID)
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to fetch comment"))
+			return
+		}
+
+		publishCommentEvent(r, db, bus, events.CommentUpdated, userID, updated, newMentions(comment.Mentions, mentions))
+
+		utils.RespondJSON(w, http.StatusOK, updated)
+	}
+}
+
+func DeleteComment(db *db.SQLiteClient, bus *events.Bus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := auth.GetUserIDFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+			return
+		}
+
+		comment, err := db.GetComment(chi.URLParam(r, "id"))
+		if err != nil || comment.DeletedAt != nil {
+			utils.RespondError(w, errors.NotFound("Comment not found"))
+			return
+		}
+
+		// Authors can delete their own comments; owners can moderate their items.
+		if comment.UserID != userID {
+			owns, err := ownsTarget(db, userID, comment.TargetType, comment.TargetID)
+			if err != nil {
+				utils.RespondError(w, errors.InternalServerError("Failed to check comment permissions"))
+				return
+			}
+			if !owns {
+				utils.RespondError(w, errors.Forbidden("Only the author or item owner can delete a comment"))
+				return
+			}
+		}
+
+		if err := db.DeleteComment(comment.ID); err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to delete comment"))
+			return
+		}
+
+		deleted, err := db.GetComment(comment.ID)
+		if err == nil {
+			publishCommentEvent(r, db, bus, events.CommentDeleted, userID, deleted, nil)
+		}
+
+		utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "Comment deleted successfully"})
+	}
+}
+
+// SetCommentResolved resolves or reopens a top-level comment thread.
+func SetCommentResolved(db *db.SQLiteClient, bus *events.Bus, resolved bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := auth.GetUserIDFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+			return
+		}
+
+		comment, err := db.GetComment(chi.URLParam(r, "id"))
+		if err != nil || comment.DeletedAt != nil {
+			utils.RespondError(w, errors.NotFound("Comment not found"))
+			return
+		}
+		if comment.ParentID != nil {
+			utils.RespondError(w, errors.BadRequest("Only top-level comments can be resolved"))
+			return
+		}
+		if !requireTargetAccess(w, db, userID, comment.TargetType, comment.TargetID) {
+			return
+		}
+
+		if err := db.SetCommentResolved(comment.ID, userID, resolved); err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to update comment"))
+			return
+		}
+
+		updated, err := db.GetComment(comment.ID)
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to fetch comment"))
+			return
+		}
+		publishCommentEvent(r, db, bus, events.CommentResolved, userID, updated, nil)
+
+		utils.RespondJSON(w, http.StatusOK, updated)
+	}
+}
+
+// GetCommentHistory lists a comment's earlier bodies, including the body of a
+// deleted comment. Only the author and the item owner may read it.
+func GetCommentHistory(db *db.SQLiteClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := auth.GetUserIDFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+			return
+		}
+
+		comment, err := db.GetComment(chi.URLParam(r, "id"))
+		if err != nil {
+			utils.RespondError(w, errors.NotFound("Comment not found"))
+			return
+		}
+		if !requireTargetAccess(w, db, userID, comment.TargetType, comment.TargetID) {
+			return
+		}
+		if comment.UserID != userID {
+			owns, err := ownsTarget(db, userID, comment.TargetType, comment.TargetID)
+			if err != nil {
+				utils.RespondError(w, errors.InternalServerError("Failed to check comment permissions"))
+				return
+			}
+			if !owns {
+				utils.RespondError(w, errors.Forbidden("Only the author or item owner can view a comment's history"))
+				return
+			}
+		}
+
+		revisions, err := db.GetCommentRevisions(comment.ID)
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to fetch comment history"))
+			return
+		}
+		utils.RespondJSON(w, http.StatusOK, revisions)
+	}
+}
+
+// requireTargetAccess writes an error response and returns false unless
+// userID can see the commented item.
+func requireTargetAccess(w http.ResponseWriter, db *db.SQLiteClient, userID string, targetType models.CommentTargetType, targetID string) bool {
+	var canAccess bool
+	var err error
+	switch targetType {
+	case models.CommentTargetFile:
+		canAccess, err = db.UserCanAccessFile(userID, targetID)
+	case models.CommentTargetFolder:
+		canAccess, err = db.UserCanAccessFolder(userID, targetID)
+	}
+	if err != nil {
+		utils.RespondError(w, errors.InternalServerError("Failed to check access"))
+		return false
+	}
+	if !canAccess {
+		utils.RespondError(w, errors.NotFound("Item not found or not accessible"))
+		return false
+	}
+	return true
+}
+
+func ownsTarget(db *db.SQLiteClient, userID string, targetType models.CommentTargetType, targetID string) (bool, error) {
+	ownerID, err := db.GetTargetOwner(targetType, targetID)
+	if err != nil {
+		return false, err
+	}
+	return ownerID == userID, nil
+}
+
+// publishCommentEvent announces a comment change to everyone who can see
+// the commented item.
+func publishCommentEvent(r *http.Request, db *db.SQLiteClient, bus *events.Bus, eventType events.Type, actorID string, comment models.Comment, mentioned []string) {
+	audience, err := db.GetTargetAudience(comment.TargetType, comment.TargetID)
+	if err != nil {
+		log.Printf("Error fetching audience for comment %s: %v", comment.ID, err)
+		return
+	}
+	bus.Publish(r.Context(), events.Event{
+		Type:     eventType,
+		ActorID:  actorID,
+		Audience: audience,
+		Payload:  events.CommentPayload{Comment: comment, Mentioned: mentioned},
+	})
+}
+
+func parseMentions(body string) []string {
+	seen := make(map[string]bool)
+	var usernames []string
+	for _, match := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		username := strings.TrimRight(match[1], ".")
+		if username != "" && !seen[username] {
+			seen[username] = true
+			usernames = append(usernames, username)
+		}
+	}
+	return usernames
+}
+
+func newMentions(previous, current []string) []string {
+	existing := make(map[string]bool, len(previous))
+	for _, id := range previous {
+		existing[id] = true
+	}
+	var added []string
+	for _, id := range current {
+		if !existing[id] {
+			added = append(added, id)
+		}
+	}
+	return added
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/saint0x/file-storage-app/backend/internal/db/dbtest"
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+)
+
+func TestGetCommentHistoryOfDeletedComment(t *testing.T) {
+	client := dbtest.New(t)
+	owner := dbtest.CreateUser(t, client, "owner")
+	author := dbtest.CreateUser(t, client, "author")
+	collaborator := dbtest.CreateUser(t, client, "collaborator")
+	fileID := dbtest.CreateFile(t, client, owner)
+	if _, err := client.ShareFileWithFriends(fileID, owner, []string{author, collaborator}); err != nil {
+		t.Fatalf("failed to share file: %v", err)
+	}
+	comment, err := client.CreateComment(models.Comment{
+		TargetType: models.CommentTargetFile,
+		TargetID:   fileID,
+		UserID:     author,
+		Body:       "posted by mistake",
+	})
+	if err != nil {
+		t.Fatalf("CreateComment failed: %v", err)
+	}
+	if err := client.DeleteComment(comment.ID); err != nil {
+		t.Fatalf("DeleteComment failed: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		userID string
+		want   int
+	}{
+		{"collaborator", collaborator, http.StatusForbidden},
+		{"author", author, http.StatusOK},
+		{"item owner", owner, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			GetCommentHistory(client)(rec, newRequest(http.MethodGet, tt.userID, "", map[string]string{"id": comment.ID}))
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+			var revisions []models.CommentRevision
+			if err := json.NewDecoder(rec.Body).Decode(&revisions); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if len(revisions) != 1 || revisions[0].Body != "posted by mistake" {
+				t.Errorf("revisions = %+v, want the deleted body", revisions)
+			}
+		})
+	}
+}
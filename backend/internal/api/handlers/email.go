@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"github.com/saint0x/file-storage-app/backend/internal/db"
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+	"github.com/saint0x/file-storage-app/backend/internal/services/auth"
+	"github.com/saint0x/file-storage-app/backend/pkg/errors"
+	"github.com/saint0x/file-storage-app/backend/pkg/utils"
+)
+
+func GetEmailSettings(db *db.SQLiteClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := auth.GetUserIDFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+			return
+		}
+
+		settings, err := db.GetEmailSettings(userID)
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to fetch email settings"))
+			return
+		}
+		utils.RespondJSON(w, http.StatusOK, settings)
+	}
+}
+
+// UpdateEmailSettings changes the digest frequency and resubscribes or
+// unsubscribes the caller. Fields that are left out keep their value.
+func UpdateEmailSettings(db *db.SQLiteClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := auth.GetUserIDFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+			return
+		}
+
+		var req struct {
+			DigestFrequency *models.DigestFrequency `json:"digest_frequency"`
+			Unsubscribed    *bool                   `json:"unsubscribed"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.RespondError(w, errors.BadRequest("Invalid request body"))
+			return
+		}
+
+		settings, err := db.GetEmailSettings(userID)
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to fetch email settings"))
+			return
+		}
+		if req.DigestFrequency != nil {
+			if !req.DigestFrequency.Valid() {
+				utils.RespondError(w, errors.BadRequest("digest_frequency must be off, daily or weekly"))
+				return
+			}
+			settings.DigestFrequency = *req.DigestFrequency
+		}
+		if req.Unsubscribed != nil {
+			settings.Unsubscribed = *req.Unsubscribed
+		}
+
+		if err := db.UpdateEmailSettings(userID, settings.DigestFrequency, settings.Unsubscribed); err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to update email settings"))
+			return
+		}
+		utils.RespondJSON(w, http.StatusOK, settings)
+	}
+}
+
+// Unsubscribe stops all email to the user an unsubscribe link was sent to.
+// It needs no authentication: the token in the link identifies the user. It
+// accepts POST as well as GET for one-click unsubscribe from mail clients.
+func Unsubscribe(db *db.SQLiteClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get("token")
+		if token == "" {
+			utils.RespondError(w, errors.BadRequest("Missing token"))
+			return
+		}
+
+		err := db.UnsubscribeByToken(token)
+		if err == sql.ErrNoRows {
+			utils.RespondError(w, errors.NotFound("Unknown unsubscribe link"))
+			return
+		}
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to unsubscribe"))
+			return
+		}
+		utils.RespondJSON(w, http.StatusOK, map[string]bool{"unsubscribed": true})
+	}
+}
@@ -1,23 +1,28 @@
 package handlers
 
 import (
-	"database/sql"
+	"context"
 	"encoding/json"
+	stderrors "errors"
 	"fmt"
+	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"github.com/saint0x/file-storage-app/backend/internal/db"
+	"github.com/saint0x/file-storage-app/backend/internal/events"
 	"github.com/saint0x/file-storage-app/backend/internal/models"
+	"github.com/saint0x/file-storage-app/backend/internal/repository"
 	"github.com/saint0x/file-storage-app/backend/internal/services/auth"
 	"github.com/saint0x/file-storage-app/backend/internal/services/storage"
 	"github.com/saint0x/file-storage-app/backend/pkg/errors"
 	"github.com/saint0x/file-storage-app/backend/pkg/utils"
 )
 
-func UploadFile(b2Service *storage.B2Service, dbClient *db.SQLiteClient) http.HandlerFunc {
+func UploadFile(b2Service *storage.B2Service, repos repository.Store, bus *events.Bus) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		userID, err := auth.GetUserIDFromContext(r.Context())
 		if err != nil {
@@ -57,17 +62,24 @@ func UploadFile(b2Service *storage.B2Service, dbClient *db.SQLiteClient) http.Ha
 		}
 
 		// Save the file metadata to the database
-		err = dbClient.CreateFile(newFile)
+		err = repos.Files().Create(r.Context(), newFile)
 		if err != nil {
 			utils.RespondError(w, fmt.Errorf("failed to save file metadata: %w", err))
 			return
 		}
 
+		bus.Publish(r.Context(), events.Event{
+			Type:     events.FileCreated,
+			ActorID:  userID,
+			Audience: []string{userID},
+			Payload:  events.FilePayload{File: newFile},
+		})
+
 		utils.RespondJSON(w, http.StatusCreated, newFile)
 	}
 }
 
-func GetFiles(db *db.SQLiteClient) http.HandlerFunc {
+func GetFiles(repos repository.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		userID, err := auth.GetUserIDFromContext(r.Context())
 		if err != nil {
@@ -81,32 +93,14 @@ func GetFiles(db *db.SQLiteClient) http.HandlerFunc {
 			return
 		}
 
-		rows, err := db.DB.Query("SELECT * FROM files WHERE user_id = ? LIMIT ? OFFSET ?", userID, pagination.PageSize, pagination.CalculateOffset())
+		files, err := repos.Files().ListByUser(r.Context(), userID, pagination.PageSize, pagination.CalculateOffset())
 		if err != nil {
 			utils.RespondError(w, errors.InternalServerError("Failed to fetch files"))
 			return
 		}
-		defer rows.Close()
-
-		var files []models.File
-		for rows.Next() {
-			var f models.File
-			var collectionID sql.NullString
-			err := rows.Scan(&f.ID, &f.UserID, &collectionID, &f.Key, &f.Name, &f.Size, &f.ContentType, &f.UploadedAt, &f.CreatedAt, &f.UpdatedAt)
-			if err != nil {
-				utils.RespondError(w, errors.InternalServerError("Failed to scan file"))
-				return
-			}
-			if collectionID.Valid {
-				collUUID, _ := uuid.Parse(collectionID.String)
-				f.CollectionID = uuid.NullUUID{UUID: collUUID, Valid: true}
-			}
-			files = append(files, f)
-		}
 
 		// Get total count for pagination
-		var totalCount int
-		err = db.DB.QueryRow("SELECT COUNT(*) FROM files WHERE user_id = ?", userID).Scan(&totalCount)
+		totalCount, err := repos.Files().CountByUser(r.Context(), userID)
 		if err != nil {
 			utils.RespondError(w, errors.InternalServerError("Failed to get total file count"))
 			return
@@ -123,7 +117,7 @@ func GetFiles(db *db.SQLiteClient) http.HandlerFunc {
 	}
 }
 
-func DeleteFile(db *db.SQLiteClient, storageService *storage.B2Service) http.HandlerFunc {
+func DeleteFile(repos repository.Store, storageService *storage.B2Service, bus *events.Bus) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		userID, err := auth.GetUserIDFromContext(r.Context())
 		if err != nil {
@@ -132,32 +126,45 @@ func DeleteFile(db *db.SQLiteClient, storageService *storage.B2Service) http.Han
 		}
 		fileID := chi.URLParam(r, "id")
 
-		var key string
-		err = db.DB.QueryRow("SELECT key FROM files WHERE id = ? AND user_id = ?", fileID, userID).Scan(&key)
+		file, err := repos.Files().GetOwned(r.Context(), userID, fileID)
 		if err != nil {
 			utils.RespondError(w, errors.NotFound("File not found or not owned by user"))
 			return
 		}
+		// Capture who could see the file while it still exists
+		audience := sharedFileAudience(r.Context(), repos, file)
 
-		err = storageService.DeleteFile(r.Context(), key)
+		err = storageService.DeleteFile(r.Context(), file.Key)
 		if err != nil {
 			utils.RespondError(w, errors.InternalServerError("Failed to delete file from storage"))
 			return
 		}
 
-		_, err = db.DB.Exec("DELETE FROM files WHERE id = ?", fileID)
+		err = repos.Files().Delete(r.Context(), fileID)
 		if err != nil {
 			utils.RespondError(w, errors.InternalServerError("Failed to delete file metadata"))
 			return
 		}
 
+		bus.Publish(r.Context(), events.Event{
+			Type:     events.FileDeleted,
+			ActorID:  userID,
+			Audience: audience,
+			Payload:  events.FilePayload{File: file},
+		})
+
 		utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "File deleted successfully"})
 	}
 }
 
 func GetFileCategories(db *db.SQLiteClient) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		categories, err := db.GetFileCategories()
+		userID, err := auth.GetUserIDFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+			return
+		}
+		categories, err := db.GetFileCategories(userID)
 		if err != nil {
 			utils.RespondError(w, errors.InternalServerError("Failed to fetch file categories"))
 			return
@@ -168,8 +175,13 @@ func GetFileCategories(db *db.SQLiteClient) http.HandlerFunc {
 
 func GetFilesByCategory(db *db.SQLiteClient) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := auth.GetUserIDFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+			return
+		}
 		categoryName := chi.URLParam(r, "categoryName")
-		files, err := db.GetFilesByCategory(categoryName)
+		files, err := db.GetFilesByCategory(userID, categoryName)
 		if err != nil {
 			utils.RespondError(w, errors.InternalServerError("Failed to fetch files by category"))
 			return
@@ -191,9 +203,19 @@ func GetFileDetails(db *db.SQLiteClient, storageService *storage.B2Service) http
 	}
 }
 
-func ShareFileWithFriends(db *db.SQLiteClient) http.HandlerFunc {
+func ShareFileWithFriends(repos repository.Store, bus *events.Bus) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		fileID := chi.URLParam(r, "id")
+		userID, err := auth.GetUserIDFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+			return
+		}
+		file, err := repos.Files().GetOwned(r.Context(), userID, chi.URLParam(r, "id"))
+		if err != nil {
+			utils.RespondError(w, errors.NotFound("File not found or not owned by user"))
+			return
+		}
+
 		var req struct {
 			FriendIDs []string `json:"friend_ids"`
 		}
@@ -201,18 +223,112 @@ func ShareFileWithFriends(db *db.SQLiteClient) http.HandlerFunc {
 			utils.RespondError(w, errors.BadRequest("Invalid request body"))
 			return
 		}
-		if err := db.ShareFileWithFriends(fileID, req.FriendIDs); err != nil {
+		for _, friendID := range req.FriendIDs {
+			if friendID == "" || friendID == userID {
+				utils.RespondError(w, errors.BadRequest("Invalid friend ID"))
+				return
+			}
+		}
+
+		// Either every friend gets the file or none does
+		var shared []string
+		err = repos.WithinTx(r.Context(), func(tx repository.Store) error {
+			for _, friendID := range req.FriendIDs {
+				friends, err := tx.Friends().AreFriends(r.Context(), userID, friendID)
+				if err != nil {
+					return err
+				}
+				if !friends {
+					return errNotFriends
+				}
+				added, err := tx.Shares().Share(r.Context(), file.ID.String(), userID, friendID)
+				if err != nil {
+					return err
+				}
+				if added {
+					shared = append(shared, friendID)
+				}
+			}
+			return nil
+		})
+		if err == errNotFriends {
+			utils.RespondError(w, errors.Forbidden("Files can only be shared with friends"))
+			return
+		}
+		if err != nil {
 			utils.RespondError(w, errors.InternalServerError("Failed to share file with friends"))
 			return
 		}
+
+		if len(shared) > 0 {
+			bus.Publish(r.Context(), events.Event{
+				Type:     events.ShareGranted,
+				ActorID:  userID,
+				Audience: sharedFileAudience(r.Context(), repos, file),
+				Payload: events.SharePayload{
+					FileID:   file.ID.String(),
+					FileName: file.Name,
+					OwnerID:  userID,
+					UserIDs:  shared,
+				},
+			})
+		}
 		utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "File shared successfully"})
 	}
 }
 
-func GetSharedWithMeFiles(db *db.SQLiteClient) http.HandlerFunc {
+// errNotFriends rolls back a share with someone who is not a friend.
+var errNotFriends = stderrors.New("not friends")
+
+func UnshareFile(repos repository.Store, bus *events.Bus) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		userID, _ := auth.GetUserIDFromContext(r.Context())
-		files, err := db.GetSharedWithMeFiles(userID)
+		userID, err := auth.GetUserIDFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+			return
+		}
+		file, err := repos.Files().GetOwned(r.Context(), userID, chi.URLParam(r, "id"))
+		if err != nil {
+			utils.RespondError(w, errors.NotFound("File not found or not owned by user"))
+			return
+		}
+
+		// Revoked users are still told, so capture the audience first
+		audience := sharedFileAudience(r.Context(), repos, file)
+		revokedUserID := chi.URLParam(r, "userID")
+		revoked, err := repos.Shares().Unshare(r.Context(), file.ID.String(), revokedUserID)
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to unshare file"))
+			return
+		}
+		if !revoked {
+			utils.RespondError(w, errors.NotFound("File is not shared with this user"))
+			return
+		}
+
+		bus.Publish(r.Context(), events.Event{
+			Type:     events.ShareRevoked,
+			ActorID:  userID,
+			Audience: audience,
+			Payload: events.SharePayload{
+				FileID:   file.ID.String(),
+				FileName: file.Name,
+				OwnerID:  userID,
+				UserIDs:  []string{revokedUserID},
+			},
+		})
+		utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "File unshared successfully"})
+	}
+}
+
+func GetSharedWithMeFiles(repos repository.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := auth.GetUserIDFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+			return
+		}
+		files, err := repos.Shares().ListSharedWith(r.Context(), userID)
 		if err != nil {
 			utils.RespondError(w, errors.InternalServerError("Failed to fetch shared files"))
 			return
@@ -233,8 +349,144 @@ func GetOrganizedFileStructure(db *db.SQLiteClient) http.HandlerFunc {
 	}
 }
 
-func UpdateFile(db *db.SQLiteClient) http.HandlerFunc {
+// UpdateFile renames a file and/or moves it to another folder. An empty
+// folder_id moves the file to the root. Both changes are saved together.
+func UpdateFile(repos repository.Store, bus *events.Bus) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Implementation for updating file metadata
+		userID, err := auth.GetUserIDFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+			return
+		}
+
+		var req struct {
+			Name     *string `json:"name"`
+			FolderID *string `json:"folder_id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.RespondError(w, errors.BadRequest("Invalid request body"))
+			return
+		}
+		var name string
+		if req.Name != nil {
+			name = strings.TrimSpace(*req.Name)
+			if name == "" {
+				utils.RespondError(w, errors.BadRequest("Name cannot be empty"))
+				return
+			}
+		}
+
+		var file models.File
+		var renamed, moved bool
+		var from, to *string
+		err = repos.WithinTx(r.Context(), func(tx repository.Store) error {
+			file, err = tx.Files().GetOwned(r.Context(), userID, chi.URLParam(r, "id"))
+			if err != nil {
+				return err
+			}
+
+			if req.Name != nil && name != file.Name {
+				if err := tx.Files().Rename(r.Context(), file.ID.String(), name); err != nil {
+					return err
+				}
+				file.Name = name
+				renamed = true
+			}
+
+			if req.FolderID == nil {
+				return nil
+			}
+			if *req.FolderID != "" {
+				folder, err := tx.Folders().Get(r.Context(), *req.FolderID)
+				if err == repository.ErrNotFound || (err == nil && folder.UserID.String() != userID) {
+					return errFolderNotFound
+				}
+				if err != nil {
+					return err
+				}
+				to = req.FolderID
+			}
+			if file.FolderID.Valid {
+				id := file.FolderID.UUID.String()
+				from = &id
+			}
+			if sameFolder(from, to) {
+				return nil
+			}
+			if err := tx.Files().Move(r.Context(), file.ID.String(), to); err != nil {
+				return err
+			}
+			file.FolderID = uuid.NullUUID{}
+			if to != nil {
+				folderUUID, _ := uuid.Parse(*to)
+				file.FolderID = uuid.NullUUID{UUID: folderUUID, Valid: true}
+			}
+			moved = true
+			return nil
+		})
+		if err == repository.ErrNotFound {
+			utils.RespondError(w, errors.NotFound("File not found or not owned by user"))
+			return
+		}
+		if err == errFolderNotFound {
+			utils.RespondError(w, errors.NotFound("Folder not found"))
+			return
+		}
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to update file"))
+			return
+		}
+
+		if renamed || moved {
+			audience := sharedFileAudience(r.Context(), repos, file)
+			if renamed {
+				bus.Publish(r.Context(), events.Event{
+					Type:     events.FileUpdated,
+					ActorID:  userID,
+					Audience: audience,
+					Payload:  events.FilePayload{File: file},
+				})
+			}
+			if moved {
+				bus.Publish(r.Context(), events.Event{
+					Type:     events.FileMoved,
+					ActorID:  userID,
+					Audience: audience,
+					Payload:  events.FileMovedPayload{File: file, FromFolderID: from, ToFolderID: to},
+				})
+			}
+		}
+
+		utils.RespondJSON(w, http.StatusOK, file)
+	}
+}
+
+// errFolderNotFound rolls back a move to a folder the user does not own.
+var errFolderNotFound = stderrors.New("folder not found")
+
+// fileAudience returns the owner of a file and everyone it is shared with.
+func fileAudience(db *db.SQLiteClient, fileID string) []string {
+	audience, err := db.GetTargetAudience(models.CommentTargetFile, fileID)
+	if err != nil {
+		log.Printf("Error fetching audience for file %s: %v", fileID, err)
+	}
+	return audience
+}
+
+// sharedFileAudience returns the owner of file and everyone it is shared
+// with, as fileAudience does for handlers without a Store.
+func sharedFileAudience(ctx context.Context, repos repository.Store, file models.File) []string {
+	audience := []string{file.UserID.String()}
+	recipients, err := repos.Shares().ListRecipients(ctx, file.ID.String())
+	if err != nil {
+		log.Printf("Error fetching audience for file %s: %v", file.ID, err)
+	}
+	return append(audience, recipients...)
+}
+
+func sameFolder(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
 	}
+	return *a == *b
 }
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/saint0x/file-storage-app/backend/internal/events"
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+	"github.com/saint0x/file-storage-app/backend/internal/repository"
+	"github.com/saint0x/file-storage-app/backend/internal/services/auth"
+)
+
+// newRequest returns a request made by userID, with the given URL
+// parameters as chi would route them.
+func newRequest(method, userID, body string, params map[string]string) *http.Request {
+	req := httptest.NewRequest(method, "/", strings.NewReader(body))
+	routeCtx := chi.NewRouteContext()
+	for key, value := range params {
+		routeCtx.URLParams.Add(key, value)
+	}
+	ctx := context.WithValue(req.Context(), chi.RouteCtxKey, routeCtx)
+	return req.WithContext(auth.SetCurrentUserInContext(ctx, auth.CurrentUser{ID: userID}))
+}
+
+func putUser(store *repository.MemoryStore, username string) string {
+	id := uuid.New()
+	store.PutUser(models.User{ID: id, Email: username + "@example.com", Username: username, Role: models.RoleUser})
+	return id.String()
+}
+
+func putFile(t *testing.T, store *repository.MemoryStore, ownerID string) models.File {
+	t.Helper()
+	file := models.File{ID: uuid.New(), UserID: uuid.MustParse(ownerID), Name: "report.pdf", CreatedAt: time.Now()}
+	if err := store.Files().Create(context.Background(), file); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	return file
+}
+
+func putFriends(t *testing.T, store *repository.MemoryStore, userID, friendID string) {
+	t.Helper()
+	friend := models.Friend{ID: uuid.New(), UserID: userID, FriendID: friendID, Status: "accepted", CreatedAt: time.Now()}
+	if err := store.Friends().Create(context.Background(), friend); err != nil {
+		t.Fatalf("failed to create friendship: %v", err)
+	}
+}
+
+func TestShareFileWithFriends(t *testing.T) {
+	store := repository.NewMemoryStore()
+	alice := putUser(store, "alice")
+	bob := putUser(store, "bob")
+	carol := putUser(store, "carol")
+	putFriends(t, store, alice, bob)
+	file := putFile(t, store, alice)
+	params := map[string]string{"id": file.ID.String()}
+
+	tests := []struct {
+		name    string
+		userID  string
+		body    string
+		want    int
+		wantBob bool
+	}{
+		{"not the owner", bob, `{"friend_ids": ["` + alice + `"]}`, http.StatusNotFound, false},
+		{"with themselves", alice, `{"friend_ids": ["` + alice + `"]}`, http.StatusBadRequest, false},
+		{"with a stranger", alice, `{"friend_ids": ["` + bob + `", "` + carol + `"]}`, http.StatusForbidden, false},
+		{"with a friend", alice, `{"friend_ids": ["` + bob + `"]}`, http.StatusOK, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			ShareFileWithFriends(store, events.NewBus())(rec, newRequest(http.MethodPost, tt.userID, tt.body, params))
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			shared, err := store.Shares().ListSharedWith(context.Background(), bob)
+			if err != nil {
+				t.Fatalf("ListSharedWith failed: %v", err)
+			}
+			if got := len(shared) == 1; got != tt.wantBob {
+				t.Errorf("shared with bob = %v, want %v", got, tt.wantBob)
+			}
+		})
+	}
+}
+
+func TestUpdateFileMovesOnlyToOwnFolders(t *testing.T) {
+	store := repository.NewMemoryStore()
+	alice := putUser(store, "alice")
+	bob := putUser(store, "bob")
+	file := putFile(t, store, alice)
+	folders := map[string]models.Folder{}
+	for _, owner := range []string{alice, bob} {
+		folder := models.Folder{ID: uuid.New(), UserID: uuid.MustParse(owner), Name: "Reports", CreatedAt: time.Now()}
+		if err := store.Folders().Create(context.Background(), folder); err != nil {
+			t.Fatalf("failed to create folder: %v", err)
+		}
+		folders[owner] = folder
+	}
+	params := map[string]string{"id": file.ID.String()}
+
+	// The rename is rolled back with the rejected move.
+	body := `{"name": "renamed.pdf", "folder_id": "` + folders[bob].ID.String() + `"}`
+	rec := httptest.NewRecorder()
+	UpdateFile(store, events.NewBus())(rec, newRequest(http.MethodPut, alice, body, params))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("moving into another user's folder: status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	got, err := store.Files().Get(context.Background(), file.ID.String())
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.Name != file.Name || got.FolderID.Valid {
+		t.Errorf("file = %+v after a rejected update, want it unchanged", got)
+	}
+
+	body = `{"name": "renamed.pdf", "folder_id": "` + folders[alice].ID.String() + `"}`
+	rec = httptest.NewRecorder()
+	UpdateFile(store, events.NewBus())(rec, newRequest(http.MethodPut, alice, body, params))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("moving into own folder: status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	got, err = store.Files().Get(context.Background(), file.ID.String())
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.Name != "renamed.pdf" || got.FolderID.UUID != folders[alice].ID {
+		t.Errorf("file = %+v, want it renamed and in %s", got, folders[alice].ID)
+	}
+}
+
+func TestUnshareFile(t *testing.T) {
+	store := repository.NewMemoryStore()
+	alice := putUser(store, "alice")
+	bob := putUser(store, "bob")
+	file := putFile(t, store, alice)
+	if _, err := store.Shares().Share(context.Background(), file.ID.String(), alice, bob); err != nil {
+		t.Fatalf("failed to share file: %v", err)
+	}
+	params := map[string]string{"id": file.ID.String(), "userID": bob}
+
+	for _, want := range []int{http.StatusOK, http.StatusNotFound} {
+		rec := httptest.NewRecorder()
+		UnshareFile(store, events.NewBus())(rec, newRequest(http.MethodDelete, alice, "", params))
+		if rec.Code != want {
+			t.Errorf("status = %d, want %d", rec.Code, want)
+		}
+	}
+}
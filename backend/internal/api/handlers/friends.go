@@ -3,18 +3,21 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"github.com/saint0x/file-storage-app/backend/internal/db"
+	"github.com/saint0x/file-storage-app/backend/internal/events"
 	"github.com/saint0x/file-storage-app/backend/internal/models"
+	"github.com/saint0x/file-storage-app/backend/internal/repository"
 	"github.com/saint0x/file-storage-app/backend/internal/services/auth"
 	"github.com/saint0x/file-storage-app/backend/pkg/errors"
 	"github.com/saint0x/file-storage-app/backend/pkg/utils"
 )
 
-func AddFriend(db *db.SQLiteClient) http.HandlerFunc {
+func AddFriend(repos repository.Store, bus *events.Bus) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		userID, err := auth.GetUserIDFromContext(r.Context())
 		if err != nil {
@@ -31,6 +34,11 @@ func AddFriend(db *db.SQLiteClient) http.HandlerFunc {
 			return
 		}
 
+		if friendRequest.FriendID == "" || friendRequest.FriendID == userID {
+			http.Error(w, "Invalid friend ID", http.StatusBadRequest)
+			return
+		}
+
 		friend := models.Friend{
 			ID:        uuid.New(),
 			UserID:    userID,
@@ -40,19 +48,34 @@ func AddFriend(db *db.SQLiteClient) http.HandlerFunc {
 			UpdatedAt: time.Now(),
 		}
 
-		_, err = db.DB.Exec("INSERT INTO friends (id, user_id, friend_id, status, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)",
-			friend.ID, friend.UserID, friend.FriendID, friend.Status, friend.CreatedAt, friend.UpdatedAt)
+		err = repos.WithinTx(r.Context(), func(tx repository.Store) error {
+			if _, err := tx.Users().Get(r.Context(), friend.FriendID); err != nil {
+				return err
+			}
+			return tx.Friends().Create(r.Context(), friend)
+		})
+		if err == repository.ErrNotFound {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
 		if err != nil {
 			http.Error(w, "Failed to add friend", http.StatusInternalServerError)
 			return
 		}
 
+		bus.Publish(r.Context(), events.Event{
+			Type:     events.FriendRequested,
+			ActorID:  userID,
+			Audience: []string{friend.UserID, friend.FriendID},
+			Payload:  friendPayload(friend),
+		})
+
 		w.WriteHeader(http.StatusCreated)
 		json.NewEncoder(w).Encode(friend)
 	}
 }
 
-func GetFriends(db *db.SQLiteClient) http.HandlerFunc {
+func GetFriends(repos repository.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		userID, err := auth.GetUserIDFromContext(r.Context())
 		if err != nil {
@@ -60,30 +83,18 @@ func GetFriends(db *db.SQLiteClient) http.HandlerFunc {
 			return
 		}
 
-		rows, err := db.DB.Query("SELECT * FROM friends WHERE user_id = ? OR friend_id = ?", userID, userID)
+		friends, err := repos.Friends().ListByUser(r.Context(), userID)
 		if err != nil {
 			http.Error(w, "Failed to fetch friends", http.StatusInternalServerError)
 			return
 		}
-		defer rows.Close()
-
-		var friends []models.Friend
-		for rows.Next() {
-			var f models.Friend
-			err := rows.Scan(&f.ID, &f.UserID, &f.FriendID, &f.Status, &f.CreatedAt, &f.UpdatedAt)
-			if err != nil {
-				http.Error(w, "Failed to scan friend", http.StatusInternalServerError)
-				return
-			}
-			friends = append(friends, f)
-		}
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(friends)
 	}
 }
 
-func UpdateFriendStatus(db *db.SQLiteClient) http.HandlerFunc {
+func UpdateFriendStatus(repos repository.Store, bus *events.Bus) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		userID, err := auth.GetUserIDFromContext(r.Context())
 		if err != nil {
@@ -102,19 +113,46 @@ func UpdateFriendStatus(db *db.SQLiteClient) http.HandlerFunc {
 			return
 		}
 
-		_, err = db.DB.Exec("UPDATE friends SET status = ?, updated_at = ? WHERE id = ? AND (user_id = ? OR friend_id = ?)",
-			updateData.Status, time.Now(), friendshipID, userID, userID)
+		var friend models.Friend
+		err = repos.WithinTx(r.Context(), func(tx repository.Store) error {
+			friend, err = tx.Friends().Get(r.Context(), friendshipID, userID)
+			if err != nil {
+				return err
+			}
+			return tx.Friends().UpdateStatus(r.Context(), friendshipID, updateData.Status)
+		})
+		if err == repository.ErrNotFound {
+			http.Error(w, "Friendship not found", http.StatusNotFound)
+			return
+		}
 		if err != nil {
 			http.Error(w, "Failed to update friend status", http.StatusInternalServerError)
 			return
 		}
 
+		var eventType events.Type
+		switch updateData.Status {
+		case "accepted":
+			eventType = events.FriendAccepted
+		case "blocked":
+			eventType = events.FriendBlocked
+		}
+		if eventType != "" && updateData.Status != friend.Status {
+			friend.Status = updateData.Status
+			bus.Publish(r.Context(), events.Event{
+				Type:     eventType,
+				ActorID:  userID,
+				Audience: []string{friend.UserID, friend.FriendID},
+				Payload:  friendPayload(friend),
+			})
+		}
+
 		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(map[string]string{"message": "Friend status updated successfully"})
 	}
 }
 
-func RemoveFriend(db *db.SQLiteClient) http.HandlerFunc {
+func RemoveFriend(repos repository.Store, bus *events.Bus) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		userID, err := auth.GetUserIDFromContext(r.Context())
 		if err != nil {
@@ -124,12 +162,30 @@ func RemoveFriend(db *db.SQLiteClient) http.HandlerFunc {
 
 		friendshipID := chi.URLParam(r, "id")
 
-		_, err = db.DB.Exec("DELETE FROM friends WHERE id = ? AND (user_id = ? OR friend_id = ?)", friendshipID, userID, userID)
+		var friend models.Friend
+		err = repos.WithinTx(r.Context(), func(tx repository.Store) error {
+			friend, err = tx.Friends().Get(r.Context(), friendshipID, userID)
+			if err != nil {
+				return err
+			}
+			return tx.Friends().Delete(r.Context(), friendshipID)
+		})
+		if err == repository.ErrNotFound {
+			http.Error(w, "Friendship not found", http.StatusNotFound)
+			return
+		}
 		if err != nil {
 			http.Error(w, "Failed to remove friend", http.StatusInternalServerError)
 			return
 		}
 
+		bus.Publish(r.Context(), events.Event{
+			Type:     events.FriendRemoved,
+			ActorID:  userID,
+			Audience: []string{friend.UserID, friend.FriendID},
+			Payload:  friendPayload(friend),
+		})
+
 		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(map[string]string{"message": "Friend removed successfully"})
 	}
@@ -221,3 +277,63 @@ func UnlikeFriend(db *db.SQLiteClient) http.HandlerFunc {
 		utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "Friend unliked successfully"})
 	}
 }
+
+func GetFriendSuggestions(db *db.SQLiteClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := auth.GetUserIDFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+			return
+		}
+
+		limit := 10
+		if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+			limit, err = strconv.Atoi(limitStr)
+			if err != nil || limit < 1 || limit > 50 {
+				utils.RespondError(w, errors.BadRequest("Invalid limit"))
+				return
+			}
+		}
+
+		suggestions, err := db.GetFriendSuggestions(userID, limit)
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to fetch friend suggestions"))
+			return
+		}
+		utils.RespondJSON(w, http.StatusOK, suggestions)
+	}
+}
+
+func DismissFriendSuggestion(db *db.SQLiteClient, repos repository.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := auth.GetUserIDFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+			return
+		}
+
+		suggestedUserID := chi.URLParam(r, "id")
+		if _, err := repos.Users().Get(r.Context(), suggestedUserID); err != nil {
+			if err == repository.ErrNotFound {
+				utils.RespondError(w, errors.NotFound("User not found"))
+				return
+			}
+			utils.RespondError(w, errors.InternalServerError("Failed to dismiss friend suggestion"))
+			return
+		}
+		if err := db.DismissFriendSuggestion(userID, suggestedUserID); err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to dismiss friend suggestion"))
+			return
+		}
+		utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "Friend suggestion dismissed successfully"})
+	}
+}
+
+func friendPayload(friend models.Friend) events.FriendPayload {
+	return events.FriendPayload{
+		FriendshipID: friend.ID.String(),
+		UserID:       friend.UserID,
+		FriendID:     friend.FriendID,
+		Status:       friend.Status,
+	}
+}
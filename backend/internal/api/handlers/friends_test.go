@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/saint0x/file-storage-app/backend/internal/events"
+	"github.com/saint0x/file-storage-app/backend/internal/repository"
+)
+
+func TestAddFriend(t *testing.T) {
+	store := repository.NewMemoryStore()
+	alice := putUser(store, "alice")
+	bob := putUser(store, "bob")
+
+	tests := []struct {
+		name     string
+		friendID string
+		want     int
+	}{
+		{"missing friend", "", http.StatusBadRequest},
+		{"themselves", alice, http.StatusBadRequest},
+		{"unknown user", uuid.New().String(), http.StatusNotFound},
+		{"known user", bob, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			body := `{"friend_id": "` + tt.friendID + `"}`
+			AddFriend(store, events.NewBus())(rec, newRequest(http.MethodPost, alice, body, nil))
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+
+	friends, err := store.Friends().ListByUser(context.Background(), alice)
+	if err != nil {
+		t.Fatalf("ListByUser failed: %v", err)
+	}
+	if len(friends) != 1 || friends[0].FriendID != bob || friends[0].Status != "pending" {
+		t.Errorf("friendships = %+v, want one pending request to bob", friends)
+	}
+}
+
+func TestFriendshipsAreOnlyVisibleToTheirUsers(t *testing.T) {
+	store := repository.NewMemoryStore()
+	alice := putUser(store, "alice")
+	bob := putUser(store, "bob")
+	carol := putUser(store, "carol")
+	putFriends(t, store, alice, bob)
+	friends, err := store.Friends().ListByUser(context.Background(), alice)
+	if err != nil || len(friends) != 1 {
+		t.Fatalf("ListByUser = %v, %v, want one friendship", friends, err)
+	}
+	params := map[string]string{"id": friends[0].ID.String()}
+
+	rec := httptest.NewRecorder()
+	UpdateFriendStatus(store, events.NewBus())(rec, newRequest(http.MethodPut, carol, `{"status": "blocked"}`, params))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("updating someone else's friendship: status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	rec = httptest.NewRecorder()
+	RemoveFriend(store, events.NewBus())(rec, newRequest(http.MethodDelete, carol, "", params))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("removing someone else's friendship: status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+
+	rec = httptest.NewRecorder()
+	RemoveFriend(store, events.NewBus())(rec, newRequest(http.MethodDelete, bob, "", params))
+	if rec.Code != http.StatusOK {
+		t.Errorf("removing own friendship: status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if ok, _ := store.Friends().AreFriends(context.Background(), alice, bob); ok {
+		t.Error("alice and bob are still friends")
+	}
+}
+
+func TestDismissFriendSuggestionForUnknownUser(t *testing.T) {
+	store := repository.NewMemoryStore()
+	alice := putUser(store, "alice")
+
+	rec := httptest.NewRecorder()
+	params := map[string]string{"id": uuid.New().String()}
+	DismissFriendSuggestion(nil, store)(rec, newRequest(http.MethodPost, alice, "", params))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/saint0x/file-storage-app/backend/internal/db"
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+	"github.com/saint0x/file-storage-app/backend/internal/services/auth"
+	"github.com/saint0x/file-storage-app/backend/internal/services/notifications"
+	"github.com/saint0x/file-storage-app/backend/internal/services/websocket"
+	"github.com/saint0x/file-storage-app/backend/pkg/errors"
+	"github.com/saint0x/file-storage-app/backend/pkg/utils"
+)
+
+// GetNotifications lists the caller's notifications, newest first. It accepts
+// cursor and limit for pagination, and unread=true to skip read ones.
+func GetNotifications(client *db.SQLiteClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := auth.GetUserIDFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+			return
+		}
+
+		q := r.URL.Query()
+		limit := 50
+		if v := q.Get("limit"); v != "" {
+			limit, err = strconv.Atoi(v)
+			if err != nil || limit < 1 || limit > 200 {
+				utils.RespondError(w, errors.BadRequest("limit must be between 1 and 200"))
+				return
+			}
+		}
+
+		list, next, err := client.GetNotifications(userID, q.Get("unread") == "true", q.Get("cursor"), limit)
+		if err == db.ErrInvalidCursor {
+			utils.RespondError(w, errors.BadRequest("Invalid cursor"))
+			return
+		}
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to fetch notifications"))
+			return
+		}
+
+		unread, err := client.CountUnreadNotifications(userID)
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to fetch notifications"))
+			return
+		}
+
+		utils.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"notifications": list,
+			"next_cursor":   next,
+			"unread_count":  unread,
+		})
+	}
+}
+
+func GetUnreadNotificationCount(db *db.SQLiteClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := auth.GetUserIDFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+			return
+		}
+
+		unread, err := db.CountUnreadNotifications(userID)
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to count notifications"))
+			return
+		}
+
+		utils.RespondJSON(w, http.StatusOK, map[string]int{"unread_count": unread})
+	}
+}
+
+// MarkNotificationRead marks a notification as read and tells the caller's
+// other sessions so their badges stay in step.
+func MarkNotificationRead(db *db.SQLiteClient, hub *websocket.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := auth.GetUserIDFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+			return
+		}
+
+		notificationID := chi.URLParam(r, "id")
+		err = db.MarkNotificationRead(userID, notificationID)
+		if err == sql.ErrNoRows {
+			utils.RespondError(w, errors.NotFound("Notification not found"))
+			return
+		}
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to mark notification as read"))
+			return
+		}
+
+		respondNotificationsRead(w, db, hub, userID, []string{notificationID})
+	}
+}
+
+func MarkAllNotificationsRead(db *db.SQLiteClient, hub *websocket.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := auth.GetUserIDFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+			return
+		}
+
+		if _, err := db.MarkAllNotificationsRead(userID); err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to mark notifications as read"))
+			return
+		}
+
+		respondNotificationsRead(w, db, hub, userID, nil)
+	}
+}
+
+// respondNotificationsRead reports the new unread count to the caller and
+// their connected clients. A nil ids means every notification was read.
+func respondNotificationsRead(w http.ResponseWriter, db *db.SQLiteClient, hub *websocket.Hub, userID string, ids []string) {
+	unread, err := db.CountUnreadNotifications(userID)
+	if err != nil {
+		utils.RespondError(w, errors.InternalServerError("Failed to count notifications"))
+		return
+	}
+
+	hub.SendToUser(userID, websocket.NotificationsRead, map[string]interface{}{
+		"ids":          ids,
+		"all":          ids == nil,
+		"unread_count": unread,
+	})
+	utils.RespondJSON(w, http.StatusOK, map[string]int{"unread_count": unread})
+}
+
+// GetNotificationPreferences returns the caller's preference for every
+// notification type.
+func GetNotificationPreferences(db *db.SQLiteClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := auth.GetUserIDFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+			return
+		}
+
+		preferences, err := notificationPreferences(db, userID)
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to fetch notification preferences"))
+			return
+		}
+		utils.RespondJSON(w, http.StatusOK, preferences)
+	}
+}
+
+// notificationPreferenceUpdate changes one notification type. Fields that
+// are left out keep their current value.
+type notificationPreferenceUpdate struct {
+	Type  string            `json:"type"`
+	InApp *bool             `json:"in_app"`
+	Email *models.EmailMode `json:"email"`
+}
+
+// UpdateNotificationPreferences applies the changes in the request body, a
+// list of {"type", "in_app", "email"} objects, and returns all of the
+// caller's preferences.
+func UpdateNotificationPreferences(db *db.SQLiteClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := auth.GetUserIDFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+			return
+		}
+
+		var updates []notificationPreferenceUpdate
+		if err := json.NewDecoder(r.Body).Decode(&updates); err != nil {
+			utils.RespondError(w, errors.BadRequest("Invalid request body"))
+			return
+		}
+
+		current, err := notificationPreferences(db, userID)
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to fetch notification preferences"))
+			return
+		}
+		byType := make(map[string]models.NotificationPreference, len(current))
+		for _, preference := range current {
+			byType[preference.Type] = preference
+		}
+
+		changed := make([]models.NotificationPreference, 0, len(updates))
+		for _, update := range updates {
+			preference, ok := byType[update.Type]
+			if !ok {
+				utils.RespondError(w, errors.BadRequest("Unknown notification type: "+update.Type))
+				return
+			}
+			if update.InApp != nil {
+				preference.InApp = *update.InApp
+			}
+			if update.Email != nil {
+				if !update.Email.Valid() {
+					utils.RespondError(w, errors.BadRequest("email must be off, immediate or digest"))
+					return
+				}
+				preference.Email = *update.Email
+			}
+			byType[update.Type] = preference
+			changed = append(changed, preference)
+		}
+
+		if err := db.SetNotificationPreferences(userID, changed); err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to update notification preferences"))
+			return
+		}
+
+		preferences, err := notificationPreferences(db, userID)
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to fetch notification preferences"))
+			return
+		}
+		utils.RespondJSON(w, http.StatusOK, preferences)
+	}
+}
+
+// notificationPreferences fills in the defaults for types userID has not set.
+func notificationPreferences(db *db.SQLiteClient, userID string) ([]models.NotificationPreference, error) {
+	stored, err := db.GetNotificationPreferences(userID)
+	if err != nil {
+		return nil, err
+	}
+	byType := make(map[string]models.NotificationPreference, len(stored))
+	for _, preference := range stored {
+		byType[preference.Type] = preference
+	}
+
+	preferences := make([]models.NotificationPreference, 0, len(notifications.Types))
+	for _, t := range notifications.Types {
+		preference, ok := byType[string(t)]
+		if !ok {
+			preference = models.DefaultNotificationPreference(string(t))
+		}
+		preferences = append(preferences, preference)
+	}
+	return preferences, nil
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/saint0x/file-storage-app/backend/internal/services/auth"
+	"github.com/saint0x/file-storage-app/backend/internal/services/websocket"
+	"github.com/saint0x/file-storage-app/backend/pkg/errors"
+	"github.com/saint0x/file-storage-app/backend/pkg/utils"
+)
+
+// GetPresence lists the users currently viewing a file or folder, given as a
+// topic such as file:<id> or folder:<id>. Only viewers connected to this
+// server instance are listed; clients of a multi-instance deployment should
+// follow the topic's presence updates instead.
+func GetPresence(hub *websocket.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := auth.GetUserIDFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+			return
+		}
+
+		topic := r.URL.Query().Get("topic")
+		viewers, err := hub.Viewers(userID, topic)
+		switch err {
+		case nil:
+		case websocket.ErrPresenceTopic, websocket.ErrUnknownTopic:
+			utils.RespondError(w, errors.BadRequest("topic must be file:<id> or folder:<id>"))
+			return
+		case websocket.ErrForbiddenTopic:
+			utils.RespondError(w, errors.NotFound("Item not found or not accessible"))
+			return
+		default:
+			log.Printf("Error fetching presence for %s: %v", topic, err)
+			utils.RespondError(w, errors.InternalServerError("Failed to fetch presence"))
+			return
+		}
+		utils.RespondJSON(w, http.StatusOK, viewers)
+	}
+}
@@ -2,16 +2,40 @@ package handlers
 
 import (
 	"net/http"
+	"strconv"
 
 	"github.com/saint0x/file-storage-app/backend/internal/db"
+	"github.com/saint0x/file-storage-app/backend/internal/services/auth"
+	"github.com/saint0x/file-storage-app/backend/pkg/errors"
 	"github.com/saint0x/file-storage-app/backend/pkg/utils"
 )
 
+// SearchFiles matches every word of q against the names, content types and
+// folder paths of the files the caller can access.
 func SearchFiles(db *db.SQLiteClient) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := auth.GetUserIDFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+			return
+		}
+
 		query := r.URL.Query().Get("q")
-		// Implement file search logic
-		utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "Search files", "query": query})
+		limit := 50
+		if v := r.URL.Query().Get("limit"); v != "" {
+			limit, err = strconv.Atoi(v)
+			if err != nil || limit < 1 || limit > 200 {
+				utils.RespondError(w, errors.BadRequest("limit must be between 1 and 200"))
+				return
+			}
+		}
+
+		files, err := db.SearchFiles(userID, query, limit)
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to search files"))
+			return
+		}
+		utils.RespondJSON(w, http.StatusOK, files)
 	}
 }
 
@@ -0,0 +1,411 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/saint0x/file-storage-app/backend/internal/db"
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+	"github.com/saint0x/file-storage-app/backend/internal/services/ai"
+	"github.com/saint0x/file-storage-app/backend/internal/services/auth"
+	"github.com/saint0x/file-storage-app/backend/pkg/errors"
+	"github.com/saint0x/file-storage-app/backend/pkg/utils"
+)
+
+var tagColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+func GetTags(db *db.SQLiteClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := auth.GetUserIDFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+			return
+		}
+
+		tags, err := db.GetTags(userID)
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to fetch tags"))
+			return
+		}
+		utils.RespondJSON(w, http.StatusOK, tags)
+	}
+}
+
+func CreateTag(db *db.SQLiteClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := auth.GetUserIDFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+			return
+		}
+
+		var req struct {
+			Name  string `json:"name"`
+			Color string `json:"color"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.RespondError(w, errors.BadRequest("Invalid request body"))
+			return
+		}
+		req.Name = strings.TrimSpace(req.Name)
+		if req.Name == "" {
+			utils.RespondError(w, errors.BadRequest("Tag name is required"))
+			return
+		}
+		if req.Color != "" && !tagColorPattern.MatchString(req.Color) {
+			utils.RespondError(w, errors.BadRequest("Tag color must be a hex color such as #ff8800"))
+			return
+		}
+
+		tag, err := db.CreateTag(models.Tag{UserID: userID, Name: req.Name, Color: req.Color})
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to create tag"))
+			return
+		}
+		utils.RespondJSON(w, http.StatusCreated, tag)
+	}
+}
+
+// UpdateTag renames or recolors a tag. Renaming onto an existing tag name is
+// rejected; use MergeTags to combine tags.
+func UpdateTag(db *db.SQLiteClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := auth.GetUserIDFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+			return
+		}
+
+		var req struct {
+			Name  string `json:"name"`
+			Color string `json:"color"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.RespondError(w, errors.BadRequest("Invalid request body"))
+			return
+		}
+		if req.Color != "" && !tagColorPattern.MatchString(req.Color) {
+			utils.RespondError(w, errors.BadRequest("Tag color must be a hex color such as #ff8800"))
+			return
+		}
+
+		tagID := chi.URLParam(r, "id")
+		err = db.UpdateTag(userID, tagID, strings.TrimSpace(req.Name), req.Color)
+		if err == sql.ErrNoRows {
+			utils.RespondError(w, errors.NotFound("Tag not found"))
+			return
+		}
+		if err != nil {
+			utils.RespondError(w, errors.BadRequest("Failed to update tag; a tag with this name may already exist"))
+			return
+		}
+
+		tag, err := db.GetTag(userID, tagID)
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to fetch tag"))
+			return
+		}
+		utils.RespondJSON(w, http.StatusOK, tag)
+	}
+}
+
+func DeleteTag(db *db.SQLiteClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := auth.GetUserIDFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+			return
+		}
+
+		err = db.DeleteTag(userID, chi.URLParam(r, "id"))
+		if err == sql.ErrNoRows {
+			utils.RespondError(w, errors.NotFound("Tag not found"))
+			return
+		}
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to delete tag"))
+			return
+		}
+		utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "Tag deleted successfully"})
+	}
+}
+
+func MergeTags(db *db.SQLiteClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := auth.GetUserIDFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+			return
+		}
+
+		var req struct {
+			SourceIDs []string `json:"source_ids"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.SourceIDs) == 0 {
+			utils.RespondError(w, errors.BadRequest("Invalid request body"))
+			return
+		}
+
+		tagID := chi.URLParam(r, "id")
+		for _, sourceID := range req.SourceIDs {
+			if sourceID == tagID {
+				utils.RespondError(w, errors.BadRequest("A tag cannot be merged into itself"))
+				return
+			}
+		}
+		err = db.MergeTags(userID, tagID, req.SourceIDs)
+		if err == sql.ErrNoRows {
+			utils.RespondError(w, errors.NotFound("Tag not found"))
+			return
+		}
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to merge tags"))
+			return
+		}
+
+		tag, err := db.GetTag(userID, tagID)
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to fetch tag"))
+			return
+		}
+		utils.RespondJSON(w, http.StatusOK, tag)
+	}
+}
+
+type bulkTagRequest struct {
+	TagIDs  []string `json:"tag_ids"`
+	FileIDs []string `json:"file_ids"`
+}
+
+func BulkTagFiles(db *db.SQLiteClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := auth.GetUserIDFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+			return
+		}
+
+		var req bulkTagRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.TagIDs) == 0 || len(req.FileIDs) == 0 {
+			utils.RespondError(w, errors.BadRequest("Invalid request body"))
+			return
+		}
+
+		for _, fileID := range req.FileIDs {
+			canAccess, err := db.UserCanAccessFile(userID, fileID)
+			if err != nil {
+				utils.RespondError(w, errors.InternalServerError("Failed to check file access"))
+				return
+			}
+			if !canAccess {
+				utils.RespondError(w, errors.NotFound("File not found or not accessible"))
+				return
+			}
+		}
+
+		if err := db.TagFiles(userID, req.TagIDs, req.FileIDs, "user"); err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to tag files"))
+			return
+		}
+		utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "Files tagged successfully"})
+	}
+}
+
+func BulkUntagFiles(db *db.SQLiteClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := auth.GetUserIDFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+			return
+		}
+
+		var req bulkTagRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.TagIDs) == 0 || len(req.FileIDs) == 0 {
+			utils.RespondError(w, errors.BadRequest("Invalid request body"))
+			return
+		}
+
+		if err := db.UntagFiles(userID, req.TagIDs, req.FileIDs); err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to untag files"))
+			return
+		}
+		utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "Files untagged successfully"})
+	}
+}
+
+func GetFilesByTag(db *db.SQLiteClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := auth.GetUserIDFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+			return
+		}
+
+		pagination, err := utils.NewPaginationFromRequest(r.URL.Query().Get("page"), r.URL.Query().Get("page_size"))
+		if err != nil {
+			utils.RespondError(w, err)
+			return
+		}
+
+		files, totalCount, err := db.GetFilesByTag(userID, chi.URLParam(r, "id"), pagination.PageSize, pagination.CalculateOffset())
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to fetch tagged files"))
+			return
+		}
+
+		response := map[string]interface{}{
+			"files":      files,
+			"pagination": utils.CalculatePagination(totalCount, pagination.Page, pagination.PageSize),
+		}
+		utils.RespondJSON(w, http.StatusOK, response)
+	}
+}
+
+// SuggestTags asks the AI processor for tags on a file and stores them as
+// pending suggestions for the user to accept or reject.
+func SuggestTags(db *db.SQLiteClient, aiProcessor *ai.Processor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := auth.GetUserIDFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+			return
+		}
+
+		var req struct {
+			FileID string `json:"file_id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.FileID == "" {
+			utils.RespondError(w, errors.BadRequest("Invalid request body"))
+			return
+		}
+
+		canAccess, err := db.UserCanAccessFile(userID, req.FileID)
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to check file access"))
+			return
+		}
+		if !canAccess {
+			utils.RespondError(w, errors.NotFound("File not found or not accessible"))
+			return
+		}
+
+		files, err := db.GetFilesByIDs([]string{req.FileID})
+		if err != nil || len(files) == 0 {
+			utils.RespondError(w, errors.InternalServerError("Failed to fetch file"))
+			return
+		}
+
+		tags, err := db.GetTags(userID)
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to fetch tags"))
+			return
+		}
+		existing := make([]string, len(tags))
+		for i, tag := range tags {
+			existing[i] = tag.Name
+		}
+
+		names, err := aiProcessor.SuggestTags(r.Context(), files[0], existing)
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to suggest tags"))
+			return
+		}
+
+		if _, err := db.CreateTagSuggestions(userID, req.FileID, names); err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to save tag suggestions"))
+			return
+		}
+
+		suggestions, err := db.GetTagSuggestions(userID, req.FileID)
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to fetch tag suggestions"))
+			return
+		}
+		utils.RespondJSON(w, http.StatusOK, suggestions)
+	}
+}
+
+func GetTagSuggestions(db *db.SQLiteClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := auth.GetUserIDFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+			return
+		}
+
+		fileID := r.URL.Query().Get("file_id")
+		if fileID == "" {
+			utils.RespondError(w, errors.BadRequest("file_id is required"))
+			return
+		}
+
+		suggestions, err := db.GetTagSuggestions(userID, fileID)
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to fetch tag suggestions"))
+			return
+		}
+		utils.RespondJSON(w, http.StatusOK, suggestions)
+	}
+}
+
+// AcceptTagSuggestion applies a suggested tag to its file, creating the tag
+// if the user does not have one with that name yet.
+func AcceptTagSuggestion(db *db.SQLiteClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := auth.GetUserIDFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+			return
+		}
+
+		suggestion, err := db.GetTagSuggestion(userID, chi.URLParam(r, "id"))
+		if err != nil {
+			utils.RespondError(w, errors.NotFound("Tag suggestion not found"))
+			return
+		}
+		if suggestion.Status != models.TagSuggestionPending {
+			utils.RespondError(w, errors.BadRequest("Tag suggestion has already been reviewed"))
+			return
+		}
+
+		tag, err := db.GetOrCreateTag(userID, suggestion.Name)
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to create tag"))
+			return
+		}
+		if err := db.TagFiles(userID, []string{tag.ID}, []string{suggestion.FileID}, "ai"); err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to tag file"))
+			return
+		}
+		if err := db.SetTagSuggestionStatus(userID, suggestion.ID, models.TagSuggestionAccepted); err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to update tag suggestion"))
+			return
+		}
+		utils.RespondJSON(w, http.StatusOK, tag)
+	}
+}
+
+func RejectTagSuggestion(db *db.SQLiteClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := auth.GetUserIDFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+			return
+		}
+
+		err = db.SetTagSuggestionStatus(userID, chi.URLParam(r, "id"), models.TagSuggestionRejected)
+		if err == sql.ErrNoRows {
+			utils.RespondError(w, errors.NotFound("Tag suggestion not found"))
+			return
+		}
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to reject tag suggestion"))
+			return
+		}
+		utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "Tag suggestion rejected"})
+	}
+}
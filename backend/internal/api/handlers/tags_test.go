@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/saint0x/file-storage-app/backend/internal/db/dbtest"
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+)
+
+func TestBulkTagFilesChecksAccess(t *testing.T) {
+	client := dbtest.New(t)
+	alice := dbtest.CreateUser(t, client, "alice")
+	bob := dbtest.CreateUser(t, client, "bob")
+	tag, err := client.CreateTag(models.Tag{UserID: alice, Name: "work"})
+	if err != nil {
+		t.Fatalf("CreateTag failed: %v", err)
+	}
+	own := dbtest.CreateFile(t, client, alice)
+	other := dbtest.CreateFile(t, client, bob)
+
+	tests := []struct {
+		name    string
+		fileIDs string
+		want    int
+	}{
+		{"a file alice cannot access", `["` + own + `", "` + other + `"]`, http.StatusNotFound},
+		{"her own file", `["` + own + `"]`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			body := `{"tag_ids": ["` + tag.ID + `"], "file_ids": ` + tt.fileIDs + `}`
+			BulkTagFiles(client)(rec, newRequest(http.MethodPost, alice, body, nil))
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+
+	_, total, err := client.GetFilesByTag(alice, tag.ID, 10, 0)
+	if err != nil || total != 1 {
+		t.Errorf("GetFilesByTag() total = %d, %v, want only alice's file tagged", total, err)
+	}
+}
+
+func TestMergeTagsIntoSource(t *testing.T) {
+	client := dbtest.New(t)
+	alice := dbtest.CreateUser(t, client, "alice")
+	tag, err := client.CreateTag(models.Tag{UserID: alice, Name: "work"})
+	if err != nil {
+		t.Fatalf("CreateTag failed: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	body := `{"source_ids": ["` + tag.ID + `"]}`
+	MergeTags(client)(rec, newRequest(http.MethodPost, alice, body, map[string]string{"id": tag.ID}))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d: %s", rec.Code, http.StatusBadRequest, rec.Body)
+	}
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/saint0x/file-storage-app/backend/internal/db"
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+	"github.com/saint0x/file-storage-app/backend/internal/services/auth"
+	"github.com/saint0x/file-storage-app/backend/pkg/errors"
+	"github.com/saint0x/file-storage-app/backend/pkg/utils"
+)
+
+// maxTokenNameLength bounds the name of a personal access token.
+const maxTokenNameLength = 100
+
+func ListTokens(db *db.SQLiteClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := auth.GetUserIDFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+			return
+		}
+
+		tokens, err := db.GetPersonalAccessTokens(userID)
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to fetch tokens"))
+			return
+		}
+		utils.RespondJSON(w, http.StatusOK, tokens)
+	}
+}
+
+// CreateToken issues a personal access token. The response includes the
+// token itself, which is not shown again. Tokens can only be created from a
+// session, so that a leaked token cannot be used to mint more.
+func CreateToken(db *db.SQLiteClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, err := auth.GetCurrentUserFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+			return
+		}
+		if user.TokenID != "" {
+			utils.RespondError(w, errors.Forbidden("Tokens cannot create other tokens"))
+			return
+		}
+
+		var req struct {
+			Name      string              `json:"name"`
+			Scopes    []models.TokenScope `json:"scopes"`
+			ExpiresAt *time.Time          `json:"expires_at"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.RespondError(w, errors.BadRequest("Invalid request body"))
+			return
+		}
+		req.Name = strings.TrimSpace(req.Name)
+		if req.Name == "" || len(req.Name) > maxTokenNameLength {
+			utils.RespondError(w, errors.BadRequest("name is required and must be at most 100 characters"))
+			return
+		}
+		if len(req.Scopes) == 0 {
+			utils.RespondError(w, errors.BadRequest("At least one scope is required"))
+			return
+		}
+		for _, scope := range req.Scopes {
+			if !scope.Valid() {
+				utils.RespondError(w, errors.BadRequest("Unknown scope: "+string(scope)))
+				return
+			}
+		}
+		if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+			utils.RespondError(w, errors.BadRequest("expires_at must be in the future"))
+			return
+		}
+
+		secret, hash, prefix, err := auth.NewPersonalAccessToken()
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to create token"))
+			return
+		}
+		token, err := db.CreatePersonalAccessToken(models.PersonalAccessToken{
+			UserID:    user.ID,
+			Name:      req.Name,
+			Prefix:    prefix,
+			Scopes:    req.Scopes,
+			ExpiresAt: req.ExpiresAt,
+		}, hash)
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to create token"))
+			return
+		}
+		token.Token = secret
+		utils.RespondJSON(w, http.StatusCreated, token)
+	}
+}
+
+func RevokeToken(db *db.SQLiteClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := auth.GetUserIDFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+			return
+		}
+
+		err = db.RevokePersonalAccessToken(userID, chi.URLParam(r, "id"))
+		if err == sql.ErrNoRows {
+			utils.RespondError(w, errors.NotFound("Token not found"))
+			return
+		}
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to revoke token"))
+			return
+		}
+		utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "Token revoked successfully"})
+	}
+}
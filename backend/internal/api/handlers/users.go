@@ -3,85 +3,126 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"github.com/saint0x/file-storage-app/backend/internal/db"
 	"github.com/saint0x/file-storage-app/backend/internal/models"
+	"github.com/saint0x/file-storage-app/backend/internal/repository"
+	"github.com/saint0x/file-storage-app/backend/internal/services/auth"
+	"github.com/saint0x/file-storage-app/backend/pkg/errors"
+	"github.com/saint0x/file-storage-app/backend/pkg/utils"
 )
 
-func GetUsers(db *db.SQLiteClient) http.HandlerFunc {
+// GetUser returns a user's profile. Users can only see their own;
+// administrators can see anyone's.
+func GetUser(repos repository.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		rows, err := db.DB.Query("SELECT * FROM users")
-		if err != nil {
-			http.Error(w, "Failed to fetch users", http.StatusInternalServerError)
+		userID, ok := ownProfileID(w, r)
+		if !ok {
 			return
 		}
-		defer rows.Close()
 
-		var users []models.User
-		for rows.Next() {
-			var u models.User
-			err := rows.Scan(&u.ID, &u.ClerkID, &u.Email, &u.Username, &u.FirstName, &u.LastName, &u.CreatedAt, &u.UpdatedAt)
-			if err != nil {
-				http.Error(w, "Failed to scan user", http.StatusInternalServerError)
-				return
-			}
-			users = append(users, u)
+		user, err := repos.Users().Get(r.Context(), userID)
+		if err == repository.ErrNotFound {
+			utils.RespondError(w, errors.NotFound("User not found"))
+			return
 		}
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(users)
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to fetch user"))
+			return
+		}
+		utils.RespondJSON(w, http.StatusOK, user)
 	}
 }
 
-func GetUser(db *db.SQLiteClient) http.HandlerFunc {
+// UpdateUser changes a user's username and name. Users can only change
+// their own; changes administrators make to other users are audited.
+func UpdateUser(db *db.SQLiteClient, repos repository.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		id := chi.URLParam(r, "id")
-		userID, err := uuid.Parse(id)
-		if err != nil {
-			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		userID, ok := ownProfileID(w, r)
+		if !ok {
 			return
 		}
 
-		var user models.User
-		err = db.DB.QueryRow("SELECT * FROM users WHERE id = ?", userID).Scan(
-			&user.ID, &user.ClerkID, &user.Email, &user.Username, &user.FirstName, &user.LastName, &user.CreatedAt, &user.UpdatedAt,
-		)
-		if err != nil {
-			http.Error(w, "User not found", http.StatusNotFound)
+		var req struct {
+			Username  string `json:"username"`
+			FirstName string `json:"first_name"`
+			LastName  string `json:"last_name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.RespondError(w, errors.BadRequest("Invalid request body"))
+			return
+		}
+		req.Username = strings.TrimSpace(req.Username)
+		if req.Username == "" {
+			utils.RespondError(w, errors.BadRequest("username is required"))
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(user)
-	}
-}
-
-func UpdateUser(db *db.SQLiteClient) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		id := chi.URLParam(r, "id")
-		userID, err := uuid.Parse(id)
+		id, err := uuid.Parse(userID)
 		if err != nil {
-			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			utils.RespondError(w, errors.NotFound("User not found"))
+			return
+		}
+		err = repos.Users().UpdateProfile(r.Context(), models.User{
+			ID:        id,
+			Username:  req.Username,
+			FirstName: req.FirstName,
+			LastName:  req.LastName,
+		})
+		if err == repository.ErrConflict {
+			utils.RespondError(w, errors.BadRequest("Username is taken"))
+			return
+		}
+		if err == repository.ErrNotFound {
+			utils.RespondError(w, errors.NotFound("User not found"))
 			return
 		}
-
-		var updateData models.User
-		err = json.NewDecoder(r.Body).Decode(&updateData)
 		if err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			utils.RespondError(w, errors.InternalServerError("Failed to update user"))
 			return
 		}
 
-		_, err = db.DB.Exec("UPDATE users SET username = ?, first_name = ?, last_name = ? WHERE id = ?",
-			updateData.Username, updateData.FirstName, updateData.LastName, userID)
+		if actorID, _ := auth.GetUserIDFromContext(r.Context()); actorID != userID {
+			recordAudit(db, actorID, models.AuditUserUpdated, "user", userID, req)
+		}
+		utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "User updated successfully"})
+	}
+}
+
+// ownProfileID returns the user ID in the URL if the caller may manage that
+// user, and responds with an error otherwise.
+func ownProfileID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	user, err := auth.GetCurrentUserFromContext(r.Context())
+	if err != nil {
+		utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+		return "", false
+	}
+	id := chi.URLParam(r, "id")
+	if id == "me" {
+		return user.ID, true
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		utils.RespondError(w, errors.BadRequest("Invalid user ID"))
+		return "", false
+	}
+	if id != user.ID && !user.IsAdmin() {
+		utils.RespondError(w, errors.Forbidden("You can only access your own profile"))
+		return "", false
+	}
+	return id, true
+}
+
+// GetCurrentUser returns the user the request was authenticated as.
+func GetCurrentUser() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, err := auth.GetCurrentUserFromContext(r.Context())
 		if err != nil {
-			http.Error(w, "Failed to update user", http.StatusInternalServerError)
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
 			return
 		}
-
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(map[string]string{"message": "User updated successfully"})
+		utils.RespondJSON(w, http.StatusOK, user)
 	}
 }
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/saint0x/file-storage-app/backend/internal/repository"
+)
+
+func TestGetUser(t *testing.T) {
+	store := repository.NewMemoryStore()
+	alice := putUser(store, "alice")
+	bob := putUser(store, "bob")
+
+	tests := []struct {
+		name string
+		id   string
+		want int
+	}{
+		{"me", "me", http.StatusOK},
+		{"own ID", alice, http.StatusOK},
+		{"someone else", bob, http.StatusForbidden},
+		{"invalid ID", "bob", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			GetUser(store)(rec, newRequest(http.MethodGet, alice, "", map[string]string{"id": tt.id}))
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateUserRejectsTakenUsername(t *testing.T) {
+	store := repository.NewMemoryStore()
+	alice := putUser(store, "alice")
+	putUser(store, "bob")
+	params := map[string]string{"id": "me"}
+
+	rec := httptest.NewRecorder()
+	UpdateUser(nil, store)(rec, newRequest(http.MethodPut, alice, `{"username": "bob"}`, params))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("taking bob's username: status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	rec = httptest.NewRecorder()
+	UpdateUser(nil, store)(rec, newRequest(http.MethodPut, alice, `{"username": "ally", "first_name": "Alice"}`, params))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	user, err := store.Users().Get(context.Background(), alice)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if user.Username != "ally" || user.FirstName != "Alice" {
+		t.Errorf("user = %+v, want username ally and first name Alice", user)
+	}
+}
@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/saint0x/file-storage-app/backend/internal/db"
+	"github.com/saint0x/file-storage-app/backend/internal/events"
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+	"github.com/saint0x/file-storage-app/backend/internal/services/auth"
+	"github.com/saint0x/file-storage-app/backend/internal/services/webhooks"
+	"github.com/saint0x/file-storage-app/backend/pkg/errors"
+	"github.com/saint0x/file-storage-app/backend/pkg/utils"
+)
+
+// webhookRequest creates or changes a webhook. On update, fields that are
+// left out keep their value, and an empty folder_id or collection_id removes
+// that filter.
+type webhookRequest struct {
+	URL          *string  `json:"url"`
+	EventTypes   []string `json:"event_types"`
+	FolderID     *string  `json:"folder_id"`
+	CollectionID *string  `json:"collection_id"`
+	Active       *bool    `json:"active"`
+}
+
+func ListWebhooks(db *db.SQLiteClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := auth.GetUserIDFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+			return
+		}
+
+		list, err := db.GetWebhooks(userID)
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to fetch webhooks"))
+			return
+		}
+		for i := range list {
+			list[i].Secret = ""
+		}
+		utils.RespondJSON(w, http.StatusOK, list)
+	}
+}
+
+// CreateWebhook registers a webhook. The response includes the secret used
+// to sign its deliveries, which is not shown again.
+func CreateWebhook(db *db.SQLiteClient, dispatcher *webhooks.Dispatcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := auth.GetUserIDFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+			return
+		}
+
+		var req webhookRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.RespondError(w, errors.BadRequest("Invalid request body"))
+			return
+		}
+		if req.URL == nil || req.EventTypes == nil {
+			utils.RespondError(w, errors.BadRequest("url and event_types are required"))
+			return
+		}
+
+		webhook := models.Webhook{UserID: userID}
+		if !applyWebhookRequest(w, r.Context(), db, dispatcher, userID, &webhook, req) {
+			return
+		}
+		webhook.Secret, err = webhooks.NewSecret()
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to create webhook"))
+			return
+		}
+
+		webhook, err = db.CreateWebhook(webhook)
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to create webhook"))
+			return
+		}
+		utils.RespondJSON(w, http.StatusCreated, webhook)
+	}
+}
+
+func GetWebhook(db *db.SQLiteClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		webhook, ok := ownWebhook(w, r, db)
+		if !ok {
+			return
+		}
+		webhook.Secret = ""
+		utils.RespondJSON(w, http.StatusOK, webhook)
+	}
+}
+
+// UpdateWebhook changes a webhook. Setting active to true re-enables a
+// webhook that was disabled after repeated failures.
+func UpdateWebhook(db *db.SQLiteClient, dispatcher *webhooks.Dispatcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		webhook, ok := ownWebhook(w, r, db)
+		if !ok {
+			return
+		}
+
+		var req webhookRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.RespondError(w, errors.BadRequest("Invalid request body"))
+			return
+		}
+		if !applyWebhookRequest(w, r.Context(), db, dispatcher, webhook.UserID, &webhook, req) {
+			return
+		}
+
+		if err := db.UpdateWebhook(webhook); err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to update webhook"))
+			return
+		}
+		webhook, err := db.GetWebhook(webhook.UserID, webhook.ID)
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to fetch webhook"))
+			return
+		}
+		webhook.Secret = ""
+		utils.RespondJSON(w, http.StatusOK, webhook)
+	}
+}
+
+func DeleteWebhook(db *db.SQLiteClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := auth.GetUserIDFromContext(r.Context())
+		if err != nil {
+			utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+			return
+		}
+
+		err = db.DeleteWebhook(userID, chi.URLParam(r, "id"))
+		if err == sql.ErrNoRows {
+			utils.RespondError(w, errors.NotFound("Webhook not found"))
+			return
+		}
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to delete webhook"))
+			return
+		}
+		utils.RespondJSON(w, http.StatusOK, map[string]string{"message": "Webhook deleted successfully"})
+	}
+}
+
+// GetWebhookDeliveries returns a webhook's latest deliveries, newest first,
+// up to limit (default 50).
+func GetWebhookDeliveries(db *db.SQLiteClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		webhook, ok := ownWebhook(w, r, db)
+		if !ok {
+			return
+		}
+
+		limit := 50
+		if v := r.URL.Query().Get("limit"); v != "" {
+			var err error
+			limit, err = strconv.Atoi(v)
+			if err != nil || limit < 1 || limit > 200 {
+				utils.RespondError(w, errors.BadRequest("limit must be between 1 and 200"))
+				return
+			}
+		}
+
+		deliveries, err := db.GetWebhookDeliveries(webhook.ID, limit)
+		if err != nil {
+			utils.RespondError(w, errors.InternalServerError("Failed to fetch webhook deliveries"))
+			return
+		}
+		utils.RespondJSON(w, http.StatusOK, deliveries)
+	}
+}
+
+// TestWebhook sends a test event to a webhook and returns the delivery.
+func TestWebhook(db *db.SQLiteClient, dispatcher *webhooks.Dispatcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		webhook, ok := ownWebhook(w, r, db)
+		if !ok {
+			return
+		}
+
+		delivery, err := dispatcher.SendTest(r.Context(), webhook)
+		if err != nil {
+			log.Printf("Error sending test event to webhook %s: %v", webhook.ID, err)
+			utils.RespondError(w, errors.InternalServerError("Failed to send test event"))
+			return
+		}
+		utils.RespondJSON(w, http.StatusOK, delivery)
+	}
+}
+
+// ownWebhook fetches the webhook named in the URL, writing an error response
+// and returning false unless it belongs to the caller.
+func ownWebhook(w http.ResponseWriter, r *http.Request, db *db.SQLiteClient) (models.Webhook, bool) {
+	userID, err := auth.GetUserIDFromContext(r.Context())
+	if err != nil {
+		utils.RespondError(w, errors.Unauthorized("User not authenticated"))
+		return models.Webhook{}, false
+	}
+
+	webhook, err := db.GetWebhook(userID, chi.URLParam(r, "id"))
+	if err == sql.ErrNoRows {
+		utils.RespondError(w, errors.NotFound("Webhook not found"))
+		return models.Webhook{}, false
+	}
+	if err != nil {
+		utils.RespondError(w, errors.InternalServerError("Failed to fetch webhook"))
+		return models.Webhook{}, false
+	}
+	return webhook, true
+}
+
+// applyWebhookRequest validates a request and copies it onto webhook,
+// writing an error response and returning false if it is invalid.
+func applyWebhookRequest(w http.ResponseWriter, ctx context.Context, db *db.SQLiteClient, dispatcher *webhooks.Dispatcher,
+	userID string, webhook *models.Webhook, req webhookRequest) bool {
+	if req.URL != nil {
+		err := dispatcher.CheckURL(ctx, *req.URL)
+		switch {
+		case err == webhooks.ErrInvalidURL || err == webhooks.ErrBlockedAddress:
+			utils.RespondError(w, errors.BadRequest(err.Error()))
+			return false
+		case err != nil:
+			utils.RespondError(w, errors.BadRequest("url host could not be resolved"))
+			return false
+		}
+		webhook.URL = *req.URL
+	}
+
+	if req.EventTypes != nil {
+		if len(req.EventTypes) == 0 {
+			utils.RespondError(w, errors.BadRequest("event_types must not be empty"))
+			return false
+		}
+		for _, t := range req.EventTypes {
+			if !events.Type(t).Valid() {
+				utils.RespondError(w, errors.BadRequest("Unknown event type: "+t))
+				return false
+			}
+		}
+		webhook.EventTypes = req.EventTypes
+	}
+
+	if req.FolderID != nil {
+		webhook.FolderID = nil
+		if *req.FolderID != "" {
+			owns, err := db.UserCanAccessFolder(userID, *req.FolderID)
+			if err != nil {
+				utils.RespondError(w, errors.InternalServerError("Failed to check folder access"))
+				return false
+			}
+			if !owns {
+				utils.RespondError(w, errors.NotFound("Folder not found"))
+				return false
+			}
+			webhook.FolderID = req.FolderID
+		}
+	}
+
+	if req.CollectionID != nil {
+		webhook.CollectionID = nil
+		if *req.CollectionID != "" {
+			if !requireCollectionRole(w, db, *req.CollectionID, userID, models.CollectionRoleViewer) {
+				return false
+			}
+			webhook.CollectionID = req.CollectionID
+		}
+	}
+
+	if req.Active != nil {
+		webhook.Active = *req.Active
+	}
+	return true
+}
@@ -1,37 +1,102 @@
 package apimiddleware
 
 import (
+	"errors"
+	"log"
 	"net/http"
-	"strings"
 
+	"github.com/saint0x/file-storage-app/backend/internal/models"
 	"github.com/saint0x/file-storage-app/backend/internal/services/auth"
 )
 
-func AuthMiddleware(authService *auth.ClerkService) func(next http.Handler) http.Handler {
+// AuthMiddleware rejects requests without a bearer token that users accepts,
+// and puts the internal user it belongs to in the context of the rest.
+// Personal access tokens need the admin scope; use ScopedAuthMiddleware for
+// routes that narrower scopes cover.
+func AuthMiddleware(users auth.UserAuthenticator) func(next http.Handler) http.Handler {
+	return ScopedAuthMiddleware(users, models.ScopeAdmin, models.ScopeAdmin)
+}
+
+// ScopedAuthMiddleware is AuthMiddleware for routes that personal access
+// tokens may call with readScope for GET and HEAD requests, or writeScope
+// for the rest.
+func ScopedAuthMiddleware(users auth.UserAuthenticator, readScope, writeScope models.TokenScope) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			authHeader := r.Header.Get("Authorization")
-			if authHeader == "" {
-				http.Error(w, "Missing Authorization header", http.StatusUnauthorized)
+			token, err := auth.ExtractBearerToken(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
 				return
 			}
 
-			parts := strings.SplitN(authHeader, " ", 2)
-			if len(parts) != 2 || parts[0] != "Bearer" {
-				http.Error(w, "Invalid Authorization header format", http.StatusUnauthorized)
+			user, err := users.AuthenticateUser(r.Context(), token)
+			if errors.Is(err, auth.ErrUnauthenticated) {
+				http.Error(w, "Invalid token", http.StatusUnauthorized)
+				return
+			}
+			if errors.Is(err, auth.ErrAccountSuspended) {
+				http.Error(w, "Account suspended", http.StatusForbidden)
+				return
+			}
+			if errors.Is(err, auth.ErrProviderUnavailable) {
+				log.Printf("Error verifying token: %v", err)
+				http.Error(w, "Identity provider unavailable", http.StatusServiceUnavailable)
 				return
 			}
-
-			token := parts[1]
-			userID, err := authService.ValidateAndExtractUserID(r.Context(), token)
 			if err != nil {
-				http.Error(w, "Invalid token", http.StatusUnauthorized)
+				log.Printf("Error resolving user: %v", err)
+				http.Error(w, "Failed to resolve user", http.StatusInternalServerError)
+				return
+			}
+
+			scope := writeScope
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				scope = readScope
+			}
+			if !user.HasScope(scope) {
+				http.Error(w, "Token lacks the "+string(scope)+" scope", http.StatusForbidden)
 				return
 			}
 
-			// Add the user ID to the request context
-			ctx := auth.SetUserIDInContext(r.Context(), userID)
+			// Add the user to the request context
+			ctx := auth.SetCurrentUserInContext(r.Context(), user)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
+
+// RequireScope additionally requires scope of personal access tokens, after
+// one of the auth middlewares has run.
+func RequireScope(scope models.TokenScope) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, err := auth.GetCurrentUserFromContext(r.Context())
+			if err != nil {
+				http.Error(w, "User not authenticated", http.StatusUnauthorized)
+				return
+			}
+			if !user.HasScope(scope) {
+				http.Error(w, "Token lacks the "+string(scope)+" scope", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireAdmin rejects users who are not administrators, after one of the
+// auth middlewares has run.
+func RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, err := auth.GetCurrentUserFromContext(r.Context())
+		if err != nil {
+			http.Error(w, "User not authenticated", http.StatusUnauthorized)
+			return
+		}
+		if !user.IsAdmin() {
+			http.Error(w, "Administrator access required", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
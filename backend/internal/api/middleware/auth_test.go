@@ -0,0 +1,150 @@
+package apimiddleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+	"github.com/saint0x/file-storage-app/backend/internal/services/auth"
+)
+
+// fakeUsers authenticates every token as user, or fails with err.
+type fakeUsers struct {
+	user auth.CurrentUser
+	err  error
+}
+
+func (u fakeUsers) AuthenticateUser(ctx context.Context, token string) (auth.CurrentUser, error) {
+	return u.user, u.err
+}
+
+func TestScopedAuthMiddlewareStatus(t *testing.T) {
+	session := auth.CurrentUser{ID: "user-1"}
+	readToken := auth.CurrentUser{ID: "user-1", TokenID: "pat-1", Scopes: []models.TokenScope{models.ScopeFilesRead}}
+	writeToken := auth.CurrentUser{ID: "user-1", TokenID: "pat-2", Scopes: []models.TokenScope{models.ScopeFilesWrite}}
+	sharingToken := auth.CurrentUser{ID: "user-1", TokenID: "pat-3", Scopes: []models.TokenScope{models.ScopeSharing}}
+
+	tests := []struct {
+		name   string
+		users  fakeUsers
+		method string
+		header string
+		want   int
+	}{
+		{"session", fakeUsers{user: session}, http.MethodPost, "Bearer token", http.StatusOK},
+		{"missing token", fakeUsers{user: session}, http.MethodGet, "", http.StatusUnauthorized},
+		{"rejected token", fakeUsers{err: fmt.Errorf("%w: %v", auth.ErrUnauthenticated, auth.ErrTokenExpired)}, http.MethodGet, "Bearer token", http.StatusUnauthorized},
+		{"suspended", fakeUsers{err: auth.ErrAccountSuspended}, http.MethodGet, "Bearer token", http.StatusForbidden},
+		{"provider unavailable", fakeUsers{err: fmt.Errorf("failed to verify token: %w", auth.ErrProviderUnavailable)}, http.MethodGet, "Bearer token", http.StatusServiceUnavailable},
+		{"lookup failure", fakeUsers{err: errors.New("database is locked")}, http.MethodGet, "Bearer token", http.StatusInternalServerError},
+		{"token read scope", fakeUsers{user: readToken}, http.MethodGet, "Bearer token", http.StatusOK},
+		{"token lacks write scope", fakeUsers{user: readToken}, http.MethodPost, "Bearer token", http.StatusForbidden},
+		{"write scope writes", fakeUsers{user: writeToken}, http.MethodPost, "Bearer token", http.StatusOK},
+		{"write scope reads", fakeUsers{user: writeToken}, http.MethodGet, "Bearer token", http.StatusOK},
+		{"token lacks read scope", fakeUsers{user: sharingToken}, http.MethodGet, "Bearer token", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := ScopedAuthMiddleware(tt.users, models.ScopeFilesRead, models.ScopeFilesWrite)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if _, err := auth.GetUserIDFromContext(r.Context()); err != nil {
+					t.Errorf("handler ran without a user in the context: %v", err)
+				}
+			}))
+			req := httptest.NewRequest(tt.method, "/files", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestScopedAuthMiddlewareProfileUpdates(t *testing.T) {
+	tests := []struct {
+		name   string
+		scopes []models.TokenScope
+		want   int
+	}{
+		{"profile scope", []models.TokenScope{models.ScopeProfile}, http.StatusOK},
+		{"admin scope", []models.TokenScope{models.ScopeAdmin}, http.StatusOK},
+		{"files scopes", []models.TokenScope{models.ScopeFilesRead, models.ScopeFilesWrite}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := fakeUsers{user: auth.CurrentUser{ID: "user-1", TokenID: "pat-1", Scopes: tt.scopes}}
+			handler := ScopedAuthMiddleware(users, models.ScopeFilesRead, models.ScopeProfile)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			req := httptest.NewRequest(http.MethodPut, "/users/user-1", nil)
+			req.Header.Set("Authorization", "Bearer token")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	tests := []struct {
+		name string
+		user *auth.CurrentUser
+		want int
+	}{
+		{"no user", nil, http.StatusUnauthorized},
+		{"session", &auth.CurrentUser{ID: "user-1"}, http.StatusOK},
+		{"token with the scope", &auth.CurrentUser{ID: "user-1", TokenID: "pat-1", Scopes: []models.TokenScope{models.ScopeSharing}}, http.StatusOK},
+		{"admin token", &auth.CurrentUser{ID: "user-1", TokenID: "pat-1", Scopes: []models.TokenScope{models.ScopeAdmin}}, http.StatusOK},
+		{"token without the scope", &auth.CurrentUser{ID: "user-1", TokenID: "pat-1", Scopes: []models.TokenScope{models.ScopeFilesWrite}}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ran := false
+			handler := RequireScope(models.ScopeSharing)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { ran = true }))
+			req := httptest.NewRequest(http.MethodPost, "/files/1/share", nil)
+			if tt.user != nil {
+				req = req.WithContext(auth.SetCurrentUserInContext(req.Context(), *tt.user))
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if ran != (tt.want == http.StatusOK) {
+				t.Errorf("handler ran = %v with status %d", ran, rec.Code)
+			}
+		})
+	}
+}
+
+func TestRequireAdmin(t *testing.T) {
+	tests := []struct {
+		name string
+		user *auth.CurrentUser
+		want int
+	}{
+		{"no user", nil, http.StatusUnauthorized},
+		{"regular user", &auth.CurrentUser{ID: "user-1", Role: models.RoleUser}, http.StatusForbidden},
+		{"administrator", &auth.CurrentUser{ID: "user-1", Role: models.RoleAdmin}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/admin/users", nil)
+			if tt.user != nil {
+				req = req.WithContext(auth.SetCurrentUserInContext(req.Context(), *tt.user))
+			}
+			rec := httptest.NewRecorder()
+			RequireAdmin(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+)
+
+// GetOwnedFiles returns every file that belongs to userID.
+func (c *SQLiteClient) GetOwnedFiles(userID string) ([]models.File, error) {
+	rows, err := c.reads.Query(`
+		SELECT id, user_id, folder_id, collection_id, key, name, content_type, size, uploaded_at, created_at, updated_at
+		FROM files WHERE user_id = ?
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []models.File
+	for rows.Next() {
+		var f models.File
+		if err := rows.Scan(&f.ID, &f.UserID, &f.FolderID, &f.CollectionID, &f.Key, &f.Name, &f.ContentType, &f.Size,
+			&f.UploadedAt, &f.CreatedAt, &f.UpdatedAt); err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, rows.Err()
+}
+
+// DeleteFileRecords deletes fileID along with its shares, tags, collection
+// entries, search index entry and comments.
+func (c *SQLiteClient) DeleteFileRecords(fileID string) error {
+	tx, err := c.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statements := []string{
+		`DELETE FROM comment_revisions WHERE comment_id IN (SELECT id FROM comments WHERE target_type = 'file' AND target_id = ?)`,
+		`DELETE FROM comment_mentions WHERE comment_id IN (SELECT id FROM comments WHERE target_type = 'file' AND target_id = ?)`,
+		`DELETE FROM comments WHERE target_type = 'file' AND target_id = ?`,
+		`DELETE FROM shared_files WHERE file_id = ?`,
+		`DELETE FROM file_category_associations WHERE file_id = ?`,
+		`DELETE FROM tag_suggestions WHERE file_id = ?`,
+		`DELETE FROM collection_files WHERE file_id = ?`,
+		`DELETE FROM file_search_index WHERE file_id = ?`,
+		`DELETE FROM files WHERE id = ?`,
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement, fileID); err != nil {
+			return fmt.Errorf("failed to delete file records: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// DeleteUserData deletes userID and everything else that belongs to them
+// other than files, which must be deleted first with DeleteFileRecords once
+// their storage objects are gone. Comments the user left on other people's
+// items are kept as deleted placeholders without an author so that replies
+// stay threaded. Foreign keys are not enforced while the rows are deleted,
+// and are checked before the deletion commits. Audit logs are kept as the
+// record of what administrators did.
+func (c *SQLiteClient) DeleteUserData(userID string) error {
+	tx, restore, err := beginWithoutForeignKeys(c.DB)
+	if err != nil {
+		return err
+	}
+	defer restore()
+	defer tx.Rollback()
+
+	now := time.Now()
+	statements := []struct {
+		query string
+		args  []interface{}
+	}{
+		// Threads on the user's folders, and the user's own comments elsewhere
+		{`DELETE FROM comment_revisions WHERE comment_id IN (
+			SELECT id FROM comments WHERE target_type = 'folder' AND target_id IN (SELECT id FROM folders WHERE user_id = ?)
+		) OR comment_id IN (SELECT id FROM comments WHERE user_id = ?)`, []interface{}{userID, userID}},
+		{`DELETE FROM comment_mentions WHERE user_id = ? OR comment_id IN (
+			SELECT id FROM comments WHERE target_type = 'folder' AND target_id IN (SELECT id FROM folders WHERE user_id = ?)
+		) OR comment_id IN (SELECT id FROM comments WHERE user_id = ?)`, []interface{}{userID, userID, userID}},
+		{`DELETE FROM comments WHERE target_type = 'folder' AND target_id IN (SELECT id FROM folders WHERE user_id = ?)`, []interface{}{userID}},
+		{`UPDATE comments SET user_id = NULL, body = '', deleted_at = COALESCE(deleted_at, ?), updated_at = ? WHERE user_id = ?`, []interface{}{now, now, userID}},
+		{`UPDATE comments SET resolved_by = NULL WHERE resolved_by = ?`, []interface{}{userID}},
+
+		// Collections the user owns or belongs to
+		{`DELETE FROM collection_files WHERE collection_id IN (SELECT id FROM collections WHERE user_id = ?)`, []interface{}{userID}},
+		{`DELETE FROM collection_members WHERE user_id = ? OR collection_id IN (SELECT id FROM collections WHERE user_id = ?)`, []interface{}{userID, userID}},
+		{`UPDATE files SET collection_id = NULL WHERE collection_id IN (SELECT id FROM collections WHERE user_id = ?)`, []interface{}{userID}},
+		{`DELETE FROM webhooks WHERE collection_id IN (SELECT id FROM collections WHERE user_id = ?)`, []interface{}{userID}},
+		{`DELETE FROM collections WHERE user_id = ?`, []interface{}{userID}},
+
+		// Folders and tags
+		{`DELETE FROM folders WHERE user_id = ?`, []interface{}{userID}},
+		{`DELETE FROM file_category_associations WHERE category_id IN (SELECT id FROM file_categories WHERE user_id = ?)`, []interface{}{userID}},
+		{`DELETE FROM file_categories WHERE user_id = ?`, []interface{}{userID}},
+		{`DELETE FROM tag_suggestions WHERE user_id = ?`, []interface{}{userID}},
+
+		// Friendships and sharing
+		{`DELETE FROM friends WHERE user_id = ? OR friend_id = ?`, []interface{}{userID, userID}},
+		{`DELETE FROM friend_contexts WHERE user_id = ? OR friend_id = ?`, []interface{}{userID, userID}},
+		{`DELETE FROM friend_likes WHERE user_id = ? OR friend_id = ?`, []interface{}{userID, userID}},
+		{`DELETE FROM friend_suggestion_dismissals WHERE user_id = ? OR dismissed_user_id = ?`, []interface{}{userID, userID}},
+		{`DELETE FROM shared_files WHERE shared_by = ? OR shared_with = ?`, []interface{}{userID, userID}},
+
+		// Activity, notifications and realtime updates
+		{`DELETE FROM activity_log WHERE user_id = ?`, []interface{}{userID}},
+		{`DELETE FROM notifications WHERE user_id = ? OR actor_id = ?`, []interface{}{userID, userID}},
+		{`DELETE FROM notification_preferences WHERE user_id = ?`, []interface{}{userID}},
+		{`DELETE FROM email_settings WHERE user_id = ?`, []interface{}{userID}},
+		{`DELETE FROM email_digest_items WHERE user_id = ?`, []interface{}{userID}},
+		{`DELETE FROM email_queue WHERE user_id = ?`, []interface{}{userID}},
+		{`DELETE FROM user_events WHERE user_id = ?`, []interface{}{userID}},
+		{`DELETE FROM user_event_sequences WHERE user_id = ?`, []interface{}{userID}},
+		{`DELETE FROM file_search_index WHERE user_id = ?`, []interface{}{userID}},
+		{`DELETE FROM webhook_deliveries WHERE webhook_id IN (SELECT id FROM webhooks WHERE user_id = ?)`, []interface{}{userID}},
+		{`DELETE FROM webhooks WHERE user_id = ?`, []interface{}{userID}},
+
+		// Identity
+		{`DELETE FROM organization_memberships WHERE user_id = ?`, []interface{}{userID}},
+		{`DELETE FROM ended_sessions WHERE user_id = ?`, []interface{}{userID}},
+		{`DELETE FROM personal_access_tokens WHERE user_id = ?`, []interface{}{userID}},
+		{`DELETE FROM user_identities WHERE user_id = ?`, []interface{}{userID}},
+		{`DELETE FROM users WHERE id = ?`, []interface{}{userID}},
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement.query, statement.args...); err != nil {
+			return fmt.Errorf("failed to delete user data: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// SyncUserProfile updates userID from the profile their identity provider
+// reports. Names are always updated; the email address only if it is
+// verified and not used by another account, and the username only if it is
+// not taken.
+func (c *SQLiteClient) SyncUserProfile(userID string, profile models.User, emailVerified bool) error {
+	tx, err := c.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	if _, err := tx.Exec(`UPDATE users SET first_name = ?, last_name = ?, updated_at = ? WHERE id = ?`,
+		profile.FirstName, profile.LastName, now, userID); err != nil {
+		return err
+	}
+	if profile.Email != "" && emailVerified {
+		if _, err := tx.Exec(`
+			UPDATE users SET email = ?, updated_at = ?
+			WHERE id = ? AND NOT EXISTS (SELECT 1 FROM users WHERE email = ? AND id != ?)
+		`, profile.Email, now, userID, profile.Email, userID); err != nil {
+			return err
+		}
+	}
+	if profile.Username != "" {
+		if _, err := tx.Exec(`
+			UPDATE users SET username = ?, updated_at = ?
+			WHERE id = ? AND NOT EXISTS (SELECT 1 FROM users WHERE username = ? AND id != ?)
+		`, profile.Username, now, userID, profile.Username, userID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,126 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+)
+
+// ErrInvalidCursor is returned for a feed cursor that was not produced by a
+// previous page.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// RecordActivity appends an entry to the activity log.
+func (c *SQLiteClient) RecordActivity(activity models.Activity) error {
+	if activity.ID == "" {
+		activity.ID = uuid.New().String()
+	}
+	_, err := c.writes.Exec(`
+		INSERT INTO activity_log (id, user_id, action_type, action_details, target_type, target_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, activity.ID, activity.ActorID, activity.Verb, string(activity.Metadata), activity.TargetType, activity.TargetID, activity.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record activity: %w", err)
+	}
+	return nil
+}
+
+// GetActivity returns a page of userID's own activity, newest first, and the
+// cursor of the next page, which is empty on the last page.
+func (c *SQLiteClient) GetActivity(userID string, filter models.ActivityFilter) ([]models.Activity, string, error) {
+	return c.queryActivity("a.user_id = ?", []interface{}{userID}, filter)
+}
+
+// GetSharedActivity returns a page of other users' activity on files shared
+// with userID, newest first.
+func (c *SQLiteClient) GetSharedActivity(userID string, filter models.ActivityFilter) ([]models.Activity, string, error) {
+	return c.queryActivity(`a.user_id != ? AND a.target_type = 'file'
+		AND a.target_id IN (SELECT file_id FROM shared_files WHERE shared_with = ?)`,
+		[]interface{}{userID, userID}, filter)
+}
+
+// queryActivity pages through activity_log by rowid, which increases with
+// every insert and so gives a stable order for cursors.
+func (c *SQLiteClient) queryActivity(scope string, args []interface{}, filter models.ActivityFilter) ([]models.Activity, string, error) {
+	conditions := []string{scope}
+
+	if filter.Cursor != "" {
+		before, err := strconv.ParseInt(filter.Cursor, 10, 64)
+		if err != nil || before <= 0 {
+			return nil, "", ErrInvalidCursor
+		}
+		conditions = append(conditions, "a.rowid < ?")
+		args = append(args, before)
+	}
+	if len(filter.Verbs) > 0 {
+		conditions = append(conditions, "a.action_type IN ("+placeholders(len(filter.Verbs))+")")
+		args = append(args, stringArgs(filter.Verbs)...)
+	}
+	if filter.TargetType != "" {
+		conditions = append(conditions, "a.target_type = ?")
+		args = append(args, filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		conditions = append(conditions, "a.target_id = ?")
+		args = append(args, filter.TargetID)
+	}
+	if filter.From != nil {
+		conditions = append(conditions, "a.created_at >= ?")
+		args = append(args, *filter.From)
+	}
+	if filter.To != nil {
+		conditions = append(conditions, "a.created_at < ?")
+		args = append(args, *filter.To)
+	}
+
+	// Fetch one extra row to learn whether there is another page.
+	args = append(args, filter.Limit+1)
+	rows, err := c.reads.Query(`
+		SELECT a.rowid, a.id, a.user_id, a.action_type, a.action_details, a.target_type, a.target_id, a.created_at
+		FROM activity_log a
+		WHERE `+strings.Join(conditions, " AND ")+`
+		ORDER BY a.rowid DESC
+		LIMIT ?
+	`, args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to fetch activity: %w", err)
+	}
+	defer rows.Close()
+
+	activities := []models.Activity{}
+	var rowIDs []int64
+	for rows.Next() {
+		var activity models.Activity
+		var rowID int64
+		var metadata, targetType, targetID sql.NullString
+		err := rows.Scan(&rowID, &activity.ID, &activity.ActorID, &activity.Verb, &metadata, &targetType, &targetID, &activity.CreatedAt)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to scan activity row: %w", err)
+		}
+		activity.TargetType = targetType.String
+		activity.TargetID = targetID.String
+		if metadata.Valid && json.Valid([]byte(metadata.String)) {
+			activity.Metadata = json.RawMessage(metadata.String)
+		} else {
+			activity.Metadata = json.RawMessage("null")
+		}
+		activities = append(activities, activity)
+		rowIDs = append(rowIDs, rowID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	var next string
+	if len(activities) > filter.Limit {
+		activities = activities[:filter.Limit]
+		next = strconv.FormatInt(rowIDs[filter.Limit-1], 10)
+	}
+	return activities, next, nil
+}
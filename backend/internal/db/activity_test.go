@@ -0,0 +1,114 @@
+package db
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+)
+
+func TestGetActivityPagesAndFilters(t *testing.T) {
+	client := newTestDB(t)
+	alice := createUser(t, client, "alice")
+	bob := createUser(t, client, "bob")
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	record := func(actorID, verb, targetType, targetID string, day int) {
+		t.Helper()
+		err := client.RecordActivity(models.Activity{
+			ActorID: actorID, Verb: verb, TargetType: targetType, TargetID: targetID,
+			Metadata: json.RawMessage(`{}`), CreatedAt: base.AddDate(0, 0, day),
+		})
+		if err != nil {
+			t.Fatalf("RecordActivity failed: %v", err)
+		}
+	}
+	record(alice, "file.created", "file", "f1", 0)
+	record(alice, "file.updated", "file", "f1", 1)
+	record(bob, "file.created", "file", "f2", 1)
+	record(alice, "collection.created", "collection", "c1", 2)
+	record(alice, "file.deleted", "file", "f1", 3)
+
+	verbs := func(activities []models.Activity) []string {
+		got := []string{}
+		for _, a := range activities {
+			got = append(got, a.Verb)
+		}
+		return got
+	}
+
+	page, next, err := client.GetActivity(alice, models.ActivityFilter{Limit: 3})
+	if err != nil {
+		t.Fatalf("GetActivity failed: %v", err)
+	}
+	if want := []string{"file.deleted", "collection.created", "file.updated"}; !reflect.DeepEqual(verbs(page), want) || next == "" {
+		t.Fatalf("first page = %v, next %q, want %v and a cursor", verbs(page), next, want)
+	}
+	page, next, err = client.GetActivity(alice, models.ActivityFilter{Limit: 3, Cursor: next})
+	if err != nil {
+		t.Fatalf("GetActivity failed: %v", err)
+	}
+	if want := []string{"file.created"}; !reflect.DeepEqual(verbs(page), want) || next != "" {
+		t.Errorf("last page = %v, next %q, want %v and no cursor", verbs(page), next, want)
+	}
+
+	from, to := base.AddDate(0, 0, 1), base.AddDate(0, 0, 3)
+	tests := []struct {
+		name   string
+		filter models.ActivityFilter
+		want   []string
+	}{
+		{"verbs", models.ActivityFilter{Verbs: []string{"file.created", "file.deleted"}}, []string{"file.deleted", "file.created"}},
+		{"target type", models.ActivityFilter{TargetType: "collection"}, []string{"collection.created"}},
+		{"target", models.ActivityFilter{TargetID: "f1", Verbs: []string{"file.updated"}}, []string{"file.updated"}},
+		{"date range", models.ActivityFilter{From: &from, To: &to}, []string{"collection.created", "file.updated"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.filter.Limit = 10
+			page, _, err := client.GetActivity(alice, tt.filter)
+			if err != nil {
+				t.Fatalf("GetActivity failed: %v", err)
+			}
+			if !reflect.DeepEqual(verbs(page), tt.want) {
+				t.Errorf("activity = %v, want %v", verbs(page), tt.want)
+			}
+		})
+	}
+
+	for _, cursor := range []string{"abc", "0", "-5"} {
+		if _, _, err := client.GetActivity(alice, models.ActivityFilter{Limit: 10, Cursor: cursor}); err != ErrInvalidCursor {
+			t.Errorf("GetActivity() with cursor %q error = %v, want %v", cursor, err, ErrInvalidCursor)
+		}
+	}
+}
+
+func TestGetSharedActivity(t *testing.T) {
+	client := newTestDB(t)
+	alice := createUser(t, client, "alice")
+	bob := createUser(t, client, "bob")
+	shared := createFile(t, client, bob)
+	private := createFile(t, client, bob)
+	if _, err := client.ShareFileWithFriends(shared, bob, []string{alice}); err != nil {
+		t.Fatalf("failed to share file: %v", err)
+	}
+	for _, a := range []models.Activity{
+		{ActorID: bob, Verb: "file.updated", TargetType: "file", TargetID: shared},
+		{ActorID: bob, Verb: "file.updated", TargetType: "file", TargetID: private},
+		{ActorID: alice, Verb: "file.updated", TargetType: "file", TargetID: shared},
+	} {
+		a.CreatedAt = time.Now()
+		if err := client.RecordActivity(a); err != nil {
+			t.Fatalf("RecordActivity failed: %v", err)
+		}
+	}
+
+	page, _, err := client.GetSharedActivity(alice, models.ActivityFilter{Limit: 10})
+	if err != nil {
+		t.Fatalf("GetSharedActivity failed: %v", err)
+	}
+	if len(page) != 1 || page[0].ActorID != bob || page[0].TargetID != shared {
+		t.Errorf("shared activity = %+v, want only bob's update to the shared file", page)
+	}
+}
@@ -0,0 +1,245 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+)
+
+// ListUsers returns up to limit users after skipping offset, oldest first.
+// A non-empty query matches usernames and email addresses containing it.
+func (c *SQLiteClient) ListUsers(query string, limit, offset int) ([]models.User, error) {
+	where := "1 = 1"
+	args := []interface{}{}
+	if query != "" {
+		where = "(u.username LIKE ? ESCAPE '\\' OR u.email LIKE ? ESCAPE '\\')"
+		pattern := "%" + escapeLike(query) + "%"
+		args = append(args, pattern, pattern)
+	}
+	args = append(args, limit, offset)
+
+	rows, err := c.reads.Query(`
+		SELECT `+userColumns+`
+		FROM users u
+		WHERE `+where+`
+		ORDER BY u.created_at, u.id
+		LIMIT ? OFFSET ?
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch users: %w", err)
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user row: %w", err)
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// SetUserRole changes userID's role, or returns sql.ErrNoRows.
+func (c *SQLiteClient) SetUserRole(userID string, role models.UserRole) error {
+	result, err := c.writes.Exec(`UPDATE users SET role = ?, updated_at = ? WHERE id = ?`, role, time.Now(), userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SetUserSuspended suspends or reinstates userID, or returns sql.ErrNoRows.
+func (c *SQLiteClient) SetUserSuspended(userID string, suspended bool) error {
+	var suspendedAt interface{}
+	if suspended {
+		suspendedAt = time.Now()
+	}
+	result, err := c.writes.Exec(`UPDATE users SET suspended_at = ?, updated_at = ? WHERE id = ?`, suspendedAt, time.Now(), userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GrantAdminRole makes the users with the given email addresses admins, and
+// returns how many were changed.
+func (c *SQLiteClient) GrantAdminRole(emails []string) (int64, error) {
+	if len(emails) == 0 {
+		return 0, nil
+	}
+	args := append([]interface{}{models.RoleAdmin, time.Now()}, stringArgs(emails)...)
+	result, err := c.writes.Exec(`
+		UPDATE users SET role = ?, updated_at = ?
+		WHERE role != 'admin' AND email IN (`+placeholders(len(emails))+`)
+	`, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetStorageUsage returns the users storing the most bytes, up to limit,
+// along with the totals across all users.
+func (c *SQLiteClient) GetStorageUsage(limit int) ([]models.StorageUsage, models.StorageUsage, error) {
+	var total models.StorageUsage
+	err := c.reads.QueryRow(`SELECT COUNT(*), COALESCE(SUM(size), 0) FROM files`).Scan(&total.FileCount, &total.TotalBytes)
+	if err != nil {
+		return nil, total, err
+	}
+
+	rows, err := c.reads.Query(`
+		SELECT u.id, u.username, u.email, COUNT(f.id), COALESCE(SUM(f.size), 0)
+		FROM users u
+		JOIN files f ON f.user_id = u.id
+		GROUP BY u.id
+		ORDER BY SUM(f.size) DESC
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, total, fmt.Errorf("failed to fetch storage usage: %w", err)
+	}
+	defer rows.Close()
+
+	usage := []models.StorageUsage{}
+	for rows.Next() {
+		var u models.StorageUsage
+		if err := rows.Scan(&u.UserID, &u.Username, &u.Email, &u.FileCount, &u.TotalBytes); err != nil {
+			return nil, total, err
+		}
+		usage = append(usage, u)
+	}
+	return usage, total, rows.Err()
+}
+
+// GetUserStorageUsage returns how much userID stores.
+func (c *SQLiteClient) GetUserStorageUsage(userID string) (models.StorageUsage, error) {
+	usage := models.StorageUsage{UserID: userID}
+	err := c.reads.QueryRow(`
+		SELECT u.username, u.email, COUNT(f.id), COALESCE(SUM(f.size), 0)
+		FROM users u
+		LEFT JOIN files f ON f.user_id = u.id
+		WHERE u.id = ?
+		GROUP BY u.id
+	`, userID).Scan(&usage.Username, &usage.Email, &usage.FileCount, &usage.TotalBytes)
+	return usage, err
+}
+
+// RecordAuditLog appends an entry to the audit log.
+func (c *SQLiteClient) RecordAuditLog(entry models.AuditLog) error {
+	if entry.ID == "" {
+		entry.ID = uuid.New().String()
+	}
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	metadata := entry.Metadata
+	if metadata == nil {
+		metadata = json.RawMessage("null")
+	}
+	_, err := c.writes.Exec(`
+		INSERT INTO audit_logs (id, actor_id, action, target_type, target_id, metadata, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, entry.ID, entry.ActorID, entry.Action, entry.TargetType, entry.TargetID, string(metadata), entry.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record audit log: %w", err)
+	}
+	return nil
+}
+
+// GetAuditLogs returns a page of the audit log, newest first, and the cursor
+// of the next page. An actorID narrows it to one administrator; the filter's
+// verbs match actions.
+func (c *SQLiteClient) GetAuditLogs(actorID string, filter models.ActivityFilter) ([]models.AuditLog, string, error) {
+	conditions := []string{"1 = 1"}
+	args := []interface{}{}
+
+	if actorID != "" {
+		conditions = append(conditions, "actor_id = ?")
+		args = append(args, actorID)
+	}
+	if filter.Cursor != "" {
+		before, err := strconv.ParseInt(filter.Cursor, 10, 64)
+		if err != nil || before <= 0 {
+			return nil, "", ErrInvalidCursor
+		}
+		conditions = append(conditions, "rowid < ?")
+		args = append(args, before)
+	}
+	if len(filter.Verbs) > 0 {
+		conditions = append(conditions, "action IN ("+placeholders(len(filter.Verbs))+")")
+		args = append(args, stringArgs(filter.Verbs)...)
+	}
+	if filter.TargetType != "" {
+		conditions = append(conditions, "target_type = ?")
+		args = append(args, filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		conditions = append(conditions, "target_id = ?")
+		args = append(args, filter.TargetID)
+	}
+	if filter.From != nil {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, *filter.From)
+	}
+	if filter.To != nil {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, *filter.To)
+	}
+
+	// Fetch one extra row to learn whether there is another page.
+	args = append(args, filter.Limit+1)
+	rows, err := c.reads.Query(`
+		SELECT rowid, id, actor_id, action, target_type, target_id, metadata, created_at
+		FROM audit_logs
+		WHERE `+strings.Join(conditions, " AND ")+`
+		ORDER BY rowid DESC
+		LIMIT ?
+	`, args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to fetch audit logs: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []models.AuditLog{}
+	var rowIDs []int64
+	for rows.Next() {
+		var entry models.AuditLog
+		var rowID int64
+		var metadata sql.NullString
+		err := rows.Scan(&rowID, &entry.ID, &entry.ActorID, &entry.Action, &entry.TargetType, &entry.TargetID, &metadata, &entry.CreatedAt)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to scan audit log row: %w", err)
+		}
+		if metadata.Valid && json.Valid([]byte(metadata.String)) {
+			entry.Metadata = json.RawMessage(metadata.String)
+		} else {
+			entry.Metadata = json.RawMessage("null")
+		}
+		entries = append(entries, entry)
+		rowIDs = append(rowIDs, rowID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	var next string
+	if len(entries) > filter.Limit {
+		entries = entries[:filter.Limit]
+		next = strconv.FormatInt(rowIDs[filter.Limit-1], 10)
+	}
+	return entries, next, nil
+}
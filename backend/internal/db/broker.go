@@ -0,0 +1,60 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+)
+
+func (c *SQLiteClient) InsertBrokerMessage(msg models.BrokerMessage) error {
+	_, err := c.writes.Exec("INSERT INTO broker_messages (topic, type, seq, data, created_at) VALUES (?, ?, ?, ?, ?)",
+		msg.Topic, msg.Type, msg.Seq, string(msg.Data), msg.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert broker message: %w", err)
+	}
+	return nil
+}
+
+// GetLatestBrokerMessageID returns the ID of the newest broker message, or 0
+// if there are none.
+func (c *SQLiteClient) GetLatestBrokerMessageID() (int64, error) {
+	var id int64
+	err := c.reads.QueryRow("SELECT COALESCE(MAX(id), 0) FROM broker_messages").Scan(&id)
+	return id, err
+}
+
+// GetBrokerMessagesAfter returns up to limit messages with an ID greater
+// than afterID, oldest first.
+func (c *SQLiteClient) GetBrokerMessagesAfter(afterID int64, limit int) ([]models.BrokerMessage, error) {
+	rows, err := c.reads.Query(`
+		SELECT id, topic, type, seq, data, created_at
+		FROM broker_messages
+		WHERE id > ?
+		ORDER BY id
+		LIMIT ?
+	`, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch broker messages: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []models.BrokerMessage
+	for rows.Next() {
+		var msg models.BrokerMessage
+		var data string
+		if err := rows.Scan(&msg.ID, &msg.Topic, &msg.Type, &msg.Seq, &data, &msg.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan broker message row: %w", err)
+		}
+		msg.Data = json.RawMessage(data)
+		messages = append(messages, msg)
+	}
+	return messages, rows.Err()
+}
+
+// PruneBrokerMessages deletes messages published before cutoff.
+func (c *SQLiteClient) PruneBrokerMessages(cutoff time.Time) error {
+	_, err := c.writes.Exec("DELETE FROM broker_messages WHERE created_at < ?", cutoff)
+	return err
+}
@@ -0,0 +1,68 @@
+package db
+
+import "time"
+
+// clerkEventRetention is how long processed Clerk webhook event IDs are
+// remembered. Svix stops retrying a message well within it.
+const clerkEventRetention = 7 * 24 * time.Hour
+
+// endedSessionRetention is how long ended sessions are remembered. Session
+// tokens expire long before it.
+const endedSessionRetention = 24 * time.Hour
+
+// ClerkEventProcessed reports whether the Clerk webhook event eventID has
+// already been handled.
+func (c *SQLiteClient) ClerkEventProcessed(eventID string) (bool, error) {
+	var processed bool
+	err := c.reads.QueryRow(`SELECT EXISTS (SELECT 1 FROM clerk_webhook_events WHERE id = ?)`, eventID).Scan(&processed)
+	return processed, err
+}
+
+// MarkClerkEventProcessed records that the Clerk webhook event eventID has
+// been handled, and forgets events older than clerkEventRetention.
+func (c *SQLiteClient) MarkClerkEventProcessed(eventID, eventType string) error {
+	now := time.Now()
+	if _, err := c.writes.Exec(`INSERT OR IGNORE INTO clerk_webhook_events (id, type, processed_at) VALUES (?, ?, ?)`,
+		eventID, eventType, now); err != nil {
+		return err
+	}
+	_, err := c.writes.Exec(`DELETE FROM clerk_webhook_events WHERE processed_at < ?`, now.Add(-clerkEventRetention))
+	return err
+}
+
+// EndSession records that sessionID of userID has ended, so that tokens
+// already issued for it are no longer accepted.
+func (c *SQLiteClient) EndSession(sessionID, userID string) error {
+	now := time.Now()
+	if _, err := c.writes.Exec(`INSERT OR IGNORE INTO ended_sessions (session_id, user_id, ended_at) VALUES (?, ?, ?)`,
+		sessionID, userID, now); err != nil {
+		return err
+	}
+	_, err := c.writes.Exec(`DELETE FROM ended_sessions WHERE ended_at < ?`, now.Add(-endedSessionRetention))
+	return err
+}
+
+// SessionEnded reports whether sessionID has ended.
+func (c *SQLiteClient) SessionEnded(sessionID string) (bool, error) {
+	var ended bool
+	err := c.reads.QueryRow(`SELECT EXISTS (SELECT 1 FROM ended_sessions WHERE session_id = ?)`, sessionID).Scan(&ended)
+	return ended, err
+}
+
+// SaveOrganizationMembership creates or updates a membership of userID in
+// a Clerk organization.
+func (c *SQLiteClient) SaveOrganizationMembership(membershipID, organizationID, organizationName, userID, role string) error {
+	now := time.Now()
+	_, err := c.writes.Exec(`
+		INSERT INTO organization_memberships (id, organization_id, organization_name, user_id, role, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET organization_name = excluded.organization_name, role = excluded.role, updated_at = excluded.updated_at
+	`, membershipID, organizationID, organizationName, userID, role, now, now)
+	return err
+}
+
+// DeleteOrganizationMembership removes a membership, if it exists.
+func (c *SQLiteClient) DeleteOrganizationMembership(membershipID string) error {
+	_, err := c.writes.Exec(`DELETE FROM organization_memberships WHERE id = ?`, membershipID)
+	return err
+}
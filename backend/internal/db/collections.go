@@ -0,0 +1,284 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+)
+
+// GetCollectionsForUser returns collections owned by userID along with those
+// shared with them through membership, each annotated with userID's role.
+func (c *SQLiteClient) GetCollectionsForUser(userID string) ([]models.Collection, error) {
+	rows, err := c.reads.Query(`
+		SELECT c.id, c.user_id, c.name, c.description, c.rule, c.created_at, c.updated_at,
+			   CASE WHEN c.user_id = ? THEN 'owner' ELSE cm.role END
+		FROM collections c
+		LEFT JOIN collection_members cm ON cm.collection_id = c.id AND cm.user_id = ?
+		WHERE c.user_id = ? OR cm.user_id IS NOT NULL
+		ORDER BY c.created_at DESC
+	`, userID, userID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch collections: %w", err)
+	}
+	defer rows.Close()
+
+	var collections []models.Collection
+	for rows.Next() {
+		var col models.Collection
+		var description, rule sql.NullString
+		err := rows.Scan(&col.ID, &col.UserID, &col.Name, &description, &rule, &col.CreatedAt, &col.UpdatedAt, &col.Role)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan collection row: %w", err)
+		}
+		col.Description = description.String
+		if col.Rule, err = parseCollectionRule(rule); err != nil {
+			return nil, err
+		}
+		collections = append(collections, col)
+	}
+	return collections, rows.Err()
+}
+
+func (c *SQLiteClient) GetCollection(collectionID string) (models.Collection, error) {
+	var col models.Collection
+	var description, rule sql.NullString
+	err := c.reads.QueryRow(`
+		SELECT id, user_id, name, description, rule, created_at, updated_at
+		FROM collections WHERE id = ?
+	`, collectionID).Scan(&col.ID, &col.UserID, &col.Name, &description, &rule, &col.CreatedAt, &col.UpdatedAt)
+	if err != nil {
+		return models.Collection{}, err
+	}
+	col.Description = description.String
+	if col.Rule, err = parseCollectionRule(rule); err != nil {
+		return models.Collection{}, err
+	}
+	return col, nil
+}
+
+// GetCollectionRole returns userID's effective role on a collection, or
+// sql.ErrNoRows if they are neither its owner nor a member.
+func (c *SQLiteClient) GetCollectionRole(collectionID, userID string) (models.CollectionRole, error) {
+	var role sql.NullString
+	err := c.reads.QueryRow(`
+		SELECT CASE WHEN c.user_id = ? THEN 'owner' ELSE cm.role END
+		FROM collections c
+		LEFT JOIN collection_members cm ON cm.collection_id = c.id AND cm.user_id = ?
+		WHERE c.id = ?
+	`, userID, userID, collectionID).Scan(&role)
+	if err == nil && !role.Valid {
+		return "", sql.ErrNoRows
+	}
+	if err != nil {
+		return "", err
+	}
+	return models.CollectionRole(role.String), nil
+}
+
+func (c *SQLiteClient) GetCollectionMembers(collectionID string) ([]models.CollectionMember, error) {
+	rows, err := c.reads.Query(`
+		SELECT cm.collection_id, cm.user_id, COALESCE(u.username, ''), cm.role, cm.added_by, cm.created_at, cm.updated_at
+		FROM collection_members cm
+		LEFT JOIN users u ON u.id = cm.user_id
+		WHERE cm.collection_id = ?
+		ORDER BY cm.created_at
+	`, collectionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch collection members: %w", err)
+	}
+	defer rows.Close()
+
+	members := []models.CollectionMember{}
+	for rows.Next() {
+		var m models.CollectionMember
+		err := rows.Scan(&m.CollectionID, &m.UserID, &m.Username, &m.Role, &m.AddedBy, &m.CreatedAt, &m.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan collection member row: %w", err)
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+func (c *SQLiteClient) AddCollectionMember(collectionID, userID string, role models.CollectionRole, addedBy string) error {
+	now := time.Now()
+	_, err := c.writes.Exec(`
+		INSERT INTO collection_members (collection_id, user_id, role, added_by, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (collection_id, user_id) DO UPDATE SET role = excluded.role, updated_at = excluded.updated_at
+	`, collectionID, userID, role, addedBy, now, now)
+	return err
+}
+
+func (c *SQLiteClient) UpdateCollectionMemberRole(collectionID, userID string, role models.CollectionRole) error {
+	result, err := c.writes.Exec("UPDATE collection_members SET role = ?, updated_at = ? WHERE collection_id = ? AND user_id = ?",
+		role, time.Now(), collectionID, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (c *SQLiteClient) RemoveCollectionMember(collectionID, userID string) error {
+	_, err := c.writes.Exec("DELETE FROM collection_members WHERE collection_id = ? AND user_id = ?", collectionID, userID)
+	return err
+}
+
+// GetCollectionFiles returns the files in a collection in their curated
+// order, as viewerID sees them. Files are listed for every member if whoever
+// added them owns them; a file that was shared with them is only listed for
+// viewers it is currently shared with too.
+func (c *SQLiteClient) GetCollectionFiles(collectionID, viewerID string) ([]models.CollectionFile, error) {
+	rows, err := c.reads.Query(`
+		SELECT f.id, f.user_id, f.folder_id, f.key, f.name, f.content_type, f.size, f.uploaded_at, f.created_at, f.updated_at,
+			   cf.position, cf.added_by, cf.added_at
+		FROM collection_files cf
+		JOIN files f ON f.id = cf.file_id
+		WHERE cf.collection_id = ?
+		  AND (f.user_id IN (cf.added_by, ?) OR f.id IN (SELECT file_id FROM shared_files WHERE shared_with = ?))
+		ORDER BY cf.position, cf.added_at
+	`, collectionID, viewerID, viewerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch collection files: %w", err)
+	}
+	defer rows.Close()
+
+	files := []models.CollectionFile{}
+	for rows.Next() {
+		var cf models.CollectionFile
+		err := rows.Scan(&cf.ID, &cf.UserID, &cf.FolderID, &cf.Key, &cf.Name, &cf.ContentType, &cf.Size,
+			&cf.UploadedAt, &cf.CreatedAt, &cf.UpdatedAt, &cf.Position, &cf.AddedBy, &cf.AddedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan collection file row: %w", err)
+		}
+		files = append(files, cf)
+	}
+	return files, rows.Err()
+}
+
+// AddFileToCollection appends a file to the end of a collection. Adding a file
+// that is already in the collection is a no-op.
+func (c *SQLiteClient) AddFileToCollection(collectionID, fileID, addedBy string) error {
+	_, err := c.writes.Exec(`
+		INSERT OR IGNORE INTO collection_files (collection_id, file_id, position, added_by, added_at)
+		SELECT ?, ?, COALESCE(MAX(position), 0) + 1, ?, ?
+		FROM collection_files WHERE collection_id = ?
+	`, collectionID, fileID, addedBy, time.Now(), collectionID)
+	return err
+}
+
+// GetCollectionFileAddedBy returns who added a file to a collection.
+func (c *SQLiteClient) GetCollectionFileAddedBy(collectionID, fileID string) (string, error) {
+	var addedBy string
+	err := c.reads.QueryRow("SELECT added_by FROM collection_files WHERE collection_id = ? AND file_id = ?",
+		collectionID, fileID).Scan(&addedBy)
+	return addedBy, err
+}
+
+func (c *SQLiteClient) RemoveFileFromCollection(collectionID, fileID string) error {
+	_, err := c.writes.Exec("DELETE FROM collection_files WHERE collection_id = ? AND file_id = ?", collectionID, fileID)
+	return err
+}
+
+// ReorderCollectionFiles assigns positions to files in the order given. Files
+// in the collection that are not listed keep their relative order after the
+// listed ones.
+func (c *SQLiteClient) ReorderCollectionFiles(collectionID string, fileIDs []string) error {
+	tx, err := c.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	offset := len(fileIDs)
+	if _, err := tx.Exec("UPDATE collection_files SET position = position + ? WHERE collection_id = ?", offset, collectionID); err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare("UPDATE collection_files SET position = ? WHERE collection_id = ? AND file_id = ?")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for i, fileID := range fileIDs {
+		if _, err := stmt.Exec(i+1, collectionID, fileID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// DeleteCollection removes a collection together with its memberships and file associations.
+func (c *SQLiteClient) DeleteCollection(collectionID, ownerID string) error {
+	tx, err := c.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The rows that refer to the collection go after it.
+	if _, err := tx.Exec("PRAGMA defer_foreign_keys = ON"); err != nil {
+		return err
+	}
+	result, err := tx.Exec("DELETE FROM collections WHERE id = ? AND user_id = ?", collectionID, ownerID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	if _, err := tx.Exec("DELETE FROM collection_members WHERE collection_id = ?", collectionID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM collection_files WHERE collection_id = ?", collectionID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE files SET collection_id = NULL WHERE collection_id = ?", collectionID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UserCanAccessFile reports whether userID owns fileID or has had it shared with them.
+func (c *SQLiteClient) UserCanAccessFile(userID, fileID string) (bool, error) {
+	var exists bool
+	err := c.reads.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM files WHERE id = ? AND user_id = ?
+			UNION ALL
+			SELECT 1 FROM shared_files WHERE file_id = ? AND shared_with = ?
+		)
+	`, fileID, userID, fileID, userID).Scan(&exists)
+	return exists, err
+}
+
+// GetCollectionAudience returns the owner and members of a collection.
+func (c *SQLiteClient) GetCollectionAudience(collectionID string) ([]string, error) {
+	rows, err := c.reads.Query(`
+		SELECT user_id FROM collections WHERE id = ?
+		UNION
+		SELECT user_id FROM collection_members WHERE collection_id = ?
+	`, collectionID, collectionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch collection audience: %w", err)
+	}
+	defer rows.Close()
+
+	var userIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, id)
+	}
+	return userIDs, rows.Err()
+}
@@ -0,0 +1,101 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+)
+
+func createCollection(t *testing.T, client *SQLiteClient, ownerID string) string {
+	t.Helper()
+	id := uuid.New().String()
+	if _, err := client.DB.Exec(`INSERT INTO collections (id, user_id, name) VALUES (?, ?, 'Reports')`, id, ownerID); err != nil {
+		t.Fatalf("failed to create collection: %v", err)
+	}
+	return id
+}
+
+func TestGetCollectionRole(t *testing.T) {
+	client := newTestDB(t)
+	alice := createUser(t, client, "alice")
+	bob := createUser(t, client, "bob")
+	carol := createUser(t, client, "carol")
+	collectionID := createCollection(t, client, alice)
+	if err := client.AddCollectionMember(collectionID, bob, models.CollectionRoleContributor, alice); err != nil {
+		t.Fatalf("AddCollectionMember failed: %v", err)
+	}
+
+	for userID, want := range map[string]models.CollectionRole{alice: models.CollectionRoleOwner, bob: models.CollectionRoleContributor} {
+		role, err := client.GetCollectionRole(collectionID, userID)
+		if err != nil || role != want {
+			t.Errorf("GetCollectionRole() = %q, %v, want %q", role, err, want)
+		}
+	}
+	if _, err := client.GetCollectionRole(collectionID, carol); err != sql.ErrNoRows {
+		t.Errorf("GetCollectionRole() for a non-member error = %v, want %v", err, sql.ErrNoRows)
+	}
+	if _, err := client.GetCollectionRole(uuid.New().String(), alice); err != sql.ErrNoRows {
+		t.Errorf("GetCollectionRole() for a missing collection error = %v, want %v", err, sql.ErrNoRows)
+	}
+}
+
+func TestGetCollectionFilesFollowsShares(t *testing.T) {
+	client := newTestDB(t)
+	alice := createUser(t, client, "alice")
+	bob := createUser(t, client, "bob")
+	carol := createUser(t, client, "carol")
+	erin := createUser(t, client, "erin")
+	collectionID := createCollection(t, client, alice)
+	for _, member := range []string{bob, carol} {
+		if err := client.AddCollectionMember(collectionID, member, models.CollectionRoleContributor, alice); err != nil {
+			t.Fatalf("AddCollectionMember failed: %v", err)
+		}
+	}
+
+	// bob adds one of his files and one erin shared with him
+	own := createFile(t, client, bob)
+	shared := createFile(t, client, erin)
+	if _, err := client.ShareFileWithFriends(shared, erin, []string{bob}); err != nil {
+		t.Fatalf("failed to share file: %v", err)
+	}
+	for _, fileID := range []string{own, shared} {
+		if err := client.AddFileToCollection(collectionID, fileID, bob); err != nil {
+			t.Fatalf("AddFileToCollection failed: %v", err)
+		}
+	}
+
+	visible := func(viewerID string) []string {
+		t.Helper()
+		files, err := client.GetCollectionFiles(collectionID, viewerID)
+		if err != nil {
+			t.Fatalf("GetCollectionFiles failed: %v", err)
+		}
+		var ids []string
+		for _, f := range files {
+			ids = append(ids, f.ID.String())
+		}
+		return ids
+	}
+
+	if got := visible(bob); len(got) != 2 {
+		t.Errorf("bob sees %v, want both files", got)
+	}
+	if got := visible(alice); len(got) != 1 || got[0] != own {
+		t.Errorf("alice sees %v, want only bob's own file", got)
+	}
+	if _, err := client.ShareFileWithFriends(shared, erin, []string{carol}); err != nil {
+		t.Fatalf("failed to share file: %v", err)
+	}
+	if got := visible(carol); len(got) != 2 {
+		t.Errorf("carol sees %v, want both files once erin shared with her too", got)
+	}
+
+	if _, err := client.UnshareFile(shared, []string{bob}); err != nil {
+		t.Fatalf("UnshareFile failed: %v", err)
+	}
+	if got := visible(bob); len(got) != 1 || got[0] != own {
+		t.Errorf("bob sees %v after the share was revoked, want only his own file", got)
+	}
+}
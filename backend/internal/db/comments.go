@@ -0,0 +1,343 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+)
+
+const commentColumns = `
+	c.id, c.target_type, c.target_id, COALESCE(c.user_id, ''), COALESCE(u.username, ''), c.parent_id, c.body,
+	c.resolved_at, c.resolved_by, c.edited_at, c.deleted_at, c.created_at, c.updated_at,
+	COALESCE((SELECT GROUP_CONCAT(cm.user_id) FROM comment_mentions cm WHERE cm.comment_id = c.id), '')`
+
+// CreateComment stores a comment and the users it mentions.
+func (c *SQLiteClient) CreateComment(comment models.Comment) (models.Comment, error) {
+	comment.ID = uuid.New().String()
+	comment.CreatedAt = time.Now()
+	comment.UpdatedAt = comment.CreatedAt
+
+	tx, err := c.DB.Begin()
+	if err != nil {
+		return models.Comment{}, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO comments (id, target_type, target_id, user_id, parent_id, body, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, comment.ID, comment.TargetType, comment.TargetID, comment.UserID, comment.ParentID, comment.Body, comment.CreatedAt, comment.UpdatedAt)
+	if err != nil {
+		return models.Comment{}, err
+	}
+
+	if err := replaceCommentMentions(tx, comment.ID, comment.Mentions); err != nil {
+		return models.Comment{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.Comment{}, err
+	}
+	return c.GetComment(comment.ID)
+}
+
+func (c *SQLiteClient) GetComment(commentID string) (models.Comment, error) {
+	row := c.reads.QueryRow(`SELECT `+commentColumns+`
+		FROM comments c
+		LEFT JOIN users u ON u.id = c.user_id
+		WHERE c.id = ?
+	`, commentID)
+	return scanComment(row)
+}
+
+// GetComments returns the discussion on an item as a list of top-level
+// comments with their replies nested beneath them, oldest first.
+func (c *SQLiteClient) GetComments(targetType models.CommentTargetType, targetID string) ([]models.Comment, error) {
+	rows, err := c.reads.Query(`SELECT `+commentColumns+`
+		FROM comments c
+		LEFT JOIN users u ON u.id = c.user_id
+		WHERE c.target_type = ? AND c.target_id = ?
+		ORDER BY c.created_at
+	`, targetType, targetID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch comments: %w", err)
+	}
+	defer rows.Close()
+
+	var all []models.Comment
+	for rows.Next() {
+		comment, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, comment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating comment rows: %w", err)
+	}
+
+	return buildCommentThreads(all), nil
+}
+
+// UpdateCommentBody replaces a comment's body and mentions, keeping the
+// previous body as a revision.
+func (c *SQLiteClient) UpdateCommentBody(commentID, body string, mentions []string) error {
+	tx, err := c.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	_, err = tx.Exec(`
+		INSERT INTO comment_revisions (id, comment_id, body, created_at)
+		SELECT ?, id, body, ? FROM comments WHERE id = ?
+	`, uuid.New().String(), now, commentID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE comments SET body = ?, edited_at = ?, updated_at = ? WHERE id = ? AND deleted_at IS NULL",
+		body, now, now, commentID)
+	if err != nil {
+		return err
+	}
+
+	if err := replaceCommentMentions(tx, commentID, mentions); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteComment soft-deletes a comment so replies keep their place in the
+// thread. The body is preserved as a revision.
+func (c *SQLiteClient) DeleteComment(commentID string) error {
+	tx, err := c.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	_, err = tx.Exec(`
+		INSERT INTO comment_revisions (id, comment_id, body, created_at)
+		SELECT ?, id, body, ? FROM comments WHERE id = ? AND deleted_at IS NULL
+	`, uuid.New().String(), now, commentID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE comments SET body = '', deleted_at = ?, updated_at = ? WHERE id = ?", now, now, commentID)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM comment_mentions WHERE comment_id = ?", commentID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SetCommentResolved marks a thread resolved by userID, or reopens it.
+func (c *SQLiteClient) SetCommentResolved(commentID, userID string, resolved bool) error {
+	var err error
+	if resolved {
+		_, err = c.writes.Exec("UPDATE comments SET resolved_at = ?, resolved_by = ?, updated_at = ? WHERE id = ?",
+			time.Now(), userID, time.Now(), commentID)
+	} else {
+		_, err = c.writes.Exec("UPDATE comments SET resolved_at = NULL, resolved_by = NULL, updated_at = ? WHERE id = ?",
+			time.Now(), commentID)
+	}
+	return err
+}
+
+func (c *SQLiteClient) GetCommentRevisions(commentID string) ([]models.CommentRevision, error) {
+	rows, err := c.reads.Query(`
+		SELECT id, comment_id, body, created_at
+		FROM comment_revisions
+		WHERE comment_id = ?
+		ORDER BY created_at
+	`, commentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch comment revisions: %w", err)
+	}
+	defer rows.Close()
+
+	revisions := []models.CommentRevision{}
+	for rows.Next() {
+		var rev models.CommentRevision
+		if err := rows.Scan(&rev.ID, &rev.CommentID, &rev.Body, &rev.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan comment revision row: %w", err)
+		}
+		revisions = append(revisions, rev)
+	}
+	return revisions, rows.Err()
+}
+
+// ResolveMentionedFriends maps usernames to the IDs of userID's accepted
+// friends. Unknown usernames and non-friends are dropped.
+func (c *SQLiteClient) ResolveMentionedFriends(userID string, usernames []string) ([]string, error) {
+	if len(usernames) == 0 {
+		return nil, nil
+	}
+	query := `
+		SELECT u.id FROM users u
+		JOIN friends f ON f.status = 'accepted'
+			AND ((f.user_id = ? AND f.friend_id = u.id) OR (f.friend_id = ? AND f.user_id = u.id))
+		WHERE u.username IN (` + placeholders(len(usernames)) + `)
+		GROUP BY u.id
+	`
+	args := append([]interface{}{userID, userID}, stringArgs(usernames)...)
+	rows, err := c.reads.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve mentions: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// UserCanAccessFolder reports whether userID owns folderID.
+func (c *SQLiteClient) UserCanAccessFolder(userID, folderID string) (bool, error) {
+	var exists bool
+	err := c.reads.QueryRow("SELECT EXISTS (SELECT 1 FROM folders WHERE id = ? AND user_id = ?)", folderID, userID).Scan(&exists)
+	return exists, err
+}
+
+// GetTargetAudience returns every user who can see a file or folder: its
+// owner and, for files, everyone it has been shared with.
+func (c *SQLiteClient) GetTargetAudience(targetType models.CommentTargetType, targetID string) ([]string, error) {
+	var query string
+	var args []interface{}
+	switch targetType {
+	case models.CommentTargetFile:
+		query = "SELECT user_id FROM files WHERE id = ? UNION SELECT shared_with FROM shared_files WHERE file_id = ?"
+		args = []interface{}{targetID, targetID}
+	case models.CommentTargetFolder:
+		query = "SELECT user_id FROM folders WHERE id = ?"
+		args = []interface{}{targetID}
+	default:
+		return nil, fmt.Errorf("unknown target type %q", targetType)
+	}
+
+	rows, err := c.reads.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch audience: %w", err)
+	}
+	defer rows.Close()
+
+	var userIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, id)
+	}
+	return userIDs, rows.Err()
+}
+
+// GetTargetOwner returns the owner of a file or folder.
+func (c *SQLiteClient) GetTargetOwner(targetType models.CommentTargetType, targetID string) (string, error) {
+	var query string
+	switch targetType {
+	case models.CommentTargetFile:
+		query = "SELECT user_id FROM files WHERE id = ?"
+	case models.CommentTargetFolder:
+		query = "SELECT user_id FROM folders WHERE id = ?"
+	default:
+		return "", fmt.Errorf("unknown target type %q", targetType)
+	}
+
+	var ownerID string
+	err := c.reads.QueryRow(query, targetID).Scan(&ownerID)
+	return ownerID, err
+}
+
+func replaceCommentMentions(tx *sql.Tx, commentID string, mentions []string) error {
+	if _, err := tx.Exec("DELETE FROM comment_mentions WHERE comment_id = ?", commentID); err != nil {
+		return err
+	}
+	for _, userID := range mentions {
+		if _, err := tx.Exec("INSERT OR IGNORE INTO comment_mentions (comment_id, user_id) VALUES (?, ?)", commentID, userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanComment(row rowScanner) (models.Comment, error) {
+	var comment models.Comment
+	var parentID, resolvedBy sql.NullString
+	var resolvedAt, editedAt, deletedAt sql.NullTime
+	var mentions string
+	err := row.Scan(&comment.ID, &comment.TargetType, &comment.TargetID, &comment.UserID, &comment.Username, &parentID, &comment.Body,
+		&resolvedAt, &resolvedBy, &editedAt, &deletedAt, &comment.CreatedAt, &comment.UpdatedAt, &mentions)
+	if err != nil {
+		return models.Comment{}, err
+	}
+
+	if parentID.Valid {
+		comment.ParentID = &parentID.String
+	}
+	if resolvedBy.Valid {
+		comment.ResolvedBy = &resolvedBy.String
+	}
+	if resolvedAt.Valid {
+		comment.ResolvedAt = &resolvedAt.Time
+	}
+	if editedAt.Valid {
+		comment.EditedAt = &editedAt.Time
+	}
+	if deletedAt.Valid {
+		comment.DeletedAt = &deletedAt.Time
+	}
+	comment.Mentions = []string{}
+	if mentions != "" {
+		comment.Mentions = strings.Split(mentions, ",")
+	}
+	return comment, nil
+}
+
+func buildCommentThreads(all []models.Comment) []models.Comment {
+	children := make(map[string][]models.Comment)
+	var roots []models.Comment
+	for _, comment := range all {
+		if comment.ParentID == nil {
+			roots = append(roots, comment)
+		} else {
+			children[*comment.ParentID] = append(children[*comment.ParentID], comment)
+		}
+	}
+
+	var attach func(comment models.Comment) models.Comment
+	attach = func(comment models.Comment) models.Comment {
+		for _, child := range children[comment.ID] {
+			comment.Replies = append(comment.Replies, attach(child))
+		}
+		return comment
+	}
+
+	threads := make([]models.Comment, 0, len(roots))
+	for _, root := range roots {
+		threads = append(threads, attach(root))
+	}
+	return threads
+}
@@ -0,0 +1,63 @@
+// Package dbtest opens migrated SQLite databases for tests in other
+// packages, and creates the rows most of them start from.
+package dbtest
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/saint0x/file-storage-app/backend/internal/db"
+)
+
+// New returns a migrated database in a temporary directory, closed when the
+// test ends.
+func New(t testing.TB) *db.SQLiteClient {
+	t.Helper()
+	return Open(t, t.TempDir()+"/test.sqlite")
+}
+
+// Open opens and migrates the database at path, closing it when the test
+// ends. Opening the same path twice gives two clients of one database.
+func Open(t testing.TB, path string) *db.SQLiteClient {
+	t.Helper()
+	client, err := db.NewSQLiteClient(path)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	migrator, err := db.NewMigrator(client.DB)
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+	if _, err := migrator.Up(-1); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	return client
+}
+
+// CreateUser inserts a user called name and returns their ID.
+func CreateUser(t testing.TB, client *db.SQLiteClient, name string) string {
+	t.Helper()
+	id := uuid.New().String()
+	_, err := client.DB.Exec(`INSERT INTO users (id, email, username) VALUES (?, ?, ?)`, id, name+"@example.com", name)
+	if err != nil {
+		t.Fatalf("failed to create user %s: %v", name, err)
+	}
+	return id
+}
+
+// CreateFile inserts a file owned by ownerID and returns its ID, which is
+// also its storage key.
+func CreateFile(t testing.TB, client *db.SQLiteClient, ownerID string) string {
+	t.Helper()
+	id := uuid.New().String()
+	_, err := client.DB.Exec(`
+		INSERT INTO files (id, user_id, key, name, content_type, size, uploaded_at)
+		VALUES (?, ?, ?, 'report.pdf', 'application/pdf', 1, CURRENT_TIMESTAMP)
+	`, id, ownerID, id)
+	if err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	return id
+}
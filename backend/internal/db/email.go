@@ -0,0 +1,281 @@
+package db
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+)
+
+// GetEmailSettings returns userID's email settings, creating the defaults
+// (a daily digest) the first time they are needed.
+func (c *SQLiteClient) GetEmailSettings(userID string) (models.EmailSettings, error) {
+	token, err := newUnsubscribeToken()
+	if err != nil {
+		return models.EmailSettings{}, err
+	}
+	// The first digest is due a full period after the settings are created,
+	// not as soon as the first notification arrives.
+	_, err = c.writes.Exec(`
+		INSERT INTO email_settings (user_id, digest_frequency, unsubscribe_token, last_digest_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (user_id) DO NOTHING
+	`, userID, models.DigestDaily, token, time.Now())
+	if err != nil {
+		return models.EmailSettings{}, fmt.Errorf("failed to create email settings: %w", err)
+	}
+
+	settings := models.EmailSettings{UserID: userID}
+	var lastDigestAt sql.NullTime
+	err = c.reads.QueryRow(`
+		SELECT digest_frequency, unsubscribed, unsubscribe_token, last_digest_at
+		FROM email_settings WHERE user_id = ?
+	`, userID).Scan(&settings.DigestFrequency, &settings.Unsubscribed, &settings.UnsubscribeToken, &lastDigestAt)
+	if err != nil {
+		return models.EmailSettings{}, err
+	}
+	if lastDigestAt.Valid {
+		settings.LastDigestAt = &lastDigestAt.Time
+	}
+	return settings, nil
+}
+
+// UpdateEmailSettings changes userID's digest frequency and whether they are
+// unsubscribed from all email.
+func (c *SQLiteClient) UpdateEmailSettings(userID string, frequency models.DigestFrequency, unsubscribed bool) error {
+	if _, err := c.GetEmailSettings(userID); err != nil {
+		return err
+	}
+	_, err := c.writes.Exec(`
+		UPDATE email_settings SET digest_frequency = ?, unsubscribed = ?, updated_at = ?
+		WHERE user_id = ?
+	`, frequency, unsubscribed, time.Now(), userID)
+	return err
+}
+
+// UnsubscribeByToken unsubscribes the user an unsubscribe link was sent to.
+// It returns sql.ErrNoRows if the token is unknown.
+func (c *SQLiteClient) UnsubscribeByToken(token string) error {
+	result, err := c.writes.Exec("UPDATE email_settings SET unsubscribed = 1, updated_at = ? WHERE unsubscribe_token = ?",
+		time.Now(), token)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func newUnsubscribeToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate unsubscribe token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// AddDigestItem holds a notification for userID's next digest. An event is
+// only added once per user.
+func (c *SQLiteClient) AddDigestItem(item models.DigestItem) error {
+	if item.ID == "" {
+		item.ID = uuid.New().String()
+	}
+	if item.CreatedAt.IsZero() {
+		item.CreatedAt = time.Now()
+	}
+	_, err := c.writes.Exec(`
+		INSERT INTO email_digest_items (id, user_id, event_id, type, actor_id, target_type, target_id, data, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (user_id, event_id) DO NOTHING
+	`, item.ID, item.UserID, item.EventID, item.Type, item.ActorID, item.TargetType, item.TargetID, string(item.Data), item.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to add digest item: %w", err)
+	}
+	return nil
+}
+
+// GetUsersDueForDigest returns the subscribed users with pending digest items
+// whose last digest at the given frequency was sent at or before since.
+func (c *SQLiteClient) GetUsersDueForDigest(frequency models.DigestFrequency, since time.Time) ([]string, error) {
+	rows, err := c.reads.Query(`
+		SELECT s.user_id FROM email_settings s
+		WHERE s.digest_frequency = ? AND s.unsubscribed = 0
+		  AND (s.last_digest_at IS NULL OR s.last_digest_at <= ?)
+		  AND EXISTS (SELECT 1 FROM email_digest_items i WHERE i.user_id = s.user_id AND i.sent_at IS NULL)
+	`, frequency, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIDs []string
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs, rows.Err()
+}
+
+// GetPendingDigestItems returns the items waiting for userID's next digest,
+// oldest first.
+func (c *SQLiteClient) GetPendingDigestItems(userID string) ([]models.DigestItem, error) {
+	rows, err := c.reads.Query(`
+		SELECT id, user_id, event_id, type, actor_id, target_type, target_id, data, created_at
+		FROM email_digest_items
+		WHERE user_id = ? AND sent_at IS NULL
+		ORDER BY created_at, rowid
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.DigestItem
+	for rows.Next() {
+		var item models.DigestItem
+		var data string
+		err := rows.Scan(&item.ID, &item.UserID, &item.EventID, &item.Type, &item.ActorID, &item.TargetType, &item.TargetID, &data, &item.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		item.Data = json.RawMessage(data)
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// CompleteDigest queues a user's digest email, marks the items it covers as
+// sent and records when it was sent, all at once so that no item is sent
+// twice or lost.
+func (c *SQLiteClient) CompleteDigest(email models.QueuedEmail, itemIDs []string, at time.Time) error {
+	tx, err := c.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := enqueueEmail(tx, email); err != nil {
+		return err
+	}
+	if len(itemIDs) > 0 {
+		args := append([]interface{}{at}, stringArgs(itemIDs)...)
+		_, err = tx.Exec("UPDATE email_digest_items SET sent_at = ? WHERE id IN ("+placeholders(len(itemIDs))+")", args...)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec("UPDATE email_settings SET last_digest_at = ? WHERE user_id = ?", at, email.UserID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// PruneDigestItems deletes digest items sent before the given time.
+func (c *SQLiteClient) PruneDigestItems(before time.Time) (int64, error) {
+	result, err := c.writes.Exec("DELETE FROM email_digest_items WHERE sent_at IS NOT NULL AND sent_at < ?", before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// EnqueueEmail adds an email to the delivery queue unless one with the same
+// dedupe key was already queued.
+func (c *SQLiteClient) EnqueueEmail(email models.QueuedEmail) error {
+	return enqueueEmail(c.DB, email)
+}
+
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+func enqueueEmail(e execer, email models.QueuedEmail) error {
+	if email.ID == "" {
+		email.ID = uuid.New().String()
+	}
+	now := time.Now()
+	if email.CreatedAt.IsZero() {
+		email.CreatedAt = now
+	}
+	if email.NextAttemptAt.IsZero() {
+		email.NextAttemptAt = now
+	}
+	headers, err := json.Marshal(email.Headers)
+	if err != nil {
+		return fmt.Errorf("failed to encode email headers: %w", err)
+	}
+	_, err = e.Exec(`
+		INSERT INTO email_queue (id, user_id, dedupe_key, to_address, subject, text_body, html_body, headers, status, next_attempt_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (dedupe_key) DO NOTHING
+	`, email.ID, email.UserID, email.DedupeKey, email.To, email.Subject, email.TextBody, email.HTMLBody, string(headers),
+		models.EmailPending, email.NextAttemptAt, email.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to queue email: %w", err)
+	}
+	return nil
+}
+
+// GetDueEmails returns up to limit pending emails whose next attempt is due.
+func (c *SQLiteClient) GetDueEmails(now time.Time, limit int) ([]models.QueuedEmail, error) {
+	rows, err := c.reads.Query(`
+		SELECT id, user_id, dedupe_key, to_address, subject, text_body, html_body, headers, status, attempts, next_attempt_at, created_at
+		FROM email_queue
+		WHERE status = ? AND next_attempt_at <= ?
+		ORDER BY next_attempt_at
+		LIMIT ?
+	`, models.EmailPending, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var emails []models.QueuedEmail
+	for rows.Next() {
+		var email models.QueuedEmail
+		var headers string
+		err := rows.Scan(&email.ID, &email.UserID, &email.DedupeKey, &email.To, &email.Subject, &email.TextBody, &email.HTMLBody,
+			&headers, &email.Status, &email.Attempts, &email.NextAttemptAt, &email.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(headers), &email.Headers); err != nil {
+			return nil, fmt.Errorf("failed to decode headers of email %s: %w", email.ID, err)
+		}
+		emails = append(emails, email)
+	}
+	return emails, rows.Err()
+}
+
+// MarkEmailSent records a successful delivery.
+func (c *SQLiteClient) MarkEmailSent(emailID string, at time.Time) error {
+	_, err := c.writes.Exec(`
+		UPDATE email_queue SET status = ?, attempts = attempts + 1, sent_at = ?, last_error = NULL
+		WHERE id = ?
+	`, models.EmailSent, at, emailID)
+	return err
+}
+
+// MarkEmailAttemptFailed records a failed delivery. The email is retried at
+// nextAttempt, or given up on if nextAttempt is nil.
+func (c *SQLiteClient) MarkEmailAttemptFailed(emailID, lastError string, nextAttempt *time.Time) error {
+	status := models.EmailPending
+	if nextAttempt == nil {
+		status = models.EmailFailed
+	}
+	_, err := c.writes.Exec(`
+		UPDATE email_queue SET status = ?, attempts = attempts + 1, last_error = ?, next_attempt_at = COALESCE(?, next_attempt_at)
+		WHERE id = ?
+	`, status, lastError, nextAttempt, emailID)
+	return err
+}
@@ -0,0 +1,117 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+)
+
+// AppendUserEvent records an update in userID's event log under the next
+// sequence number. Sequence numbers keep increasing even after old events
+// are pruned.
+func (c *SQLiteClient) AppendUserEvent(userID, eventType, topic string, data interface{}) (models.UserEvent, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return models.UserEvent{}, fmt.Errorf("failed to encode event data: %w", err)
+	}
+
+	tx, err := c.DB.Begin()
+	if err != nil {
+		return models.UserEvent{}, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO user_event_sequences (user_id, last_seq) VALUES (?, 1)
+		ON CONFLICT(user_id) DO UPDATE SET last_seq = last_seq + 1
+	`, userID)
+	if err != nil {
+		return models.UserEvent{}, fmt.Errorf("failed to allocate event sequence: %w", err)
+	}
+
+	event := models.UserEvent{
+		UserID:    userID,
+		Type:      eventType,
+		Topic:     topic,
+		Data:      payload,
+		CreatedAt: time.Now(),
+	}
+	if err := tx.QueryRow("SELECT last_seq FROM user_event_sequences WHERE user_id = ?", userID).Scan(&event.Seq); err != nil {
+		return models.UserEvent{}, err
+	}
+
+	_, err = tx.Exec("INSERT INTO user_events (user_id, seq, type, topic, data, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		event.UserID, event.Seq, event.Type, event.Topic, string(event.Data), event.CreatedAt)
+	if err != nil {
+		return models.UserEvent{}, fmt.Errorf("failed to record event: %w", err)
+	}
+
+	return event, tx.Commit()
+}
+
+// GetUserEventsSince returns up to limit events after afterSeq, oldest first.
+// complete is false when events after afterSeq have already been pruned, or
+// afterSeq is ahead of the log, in which case the client must resync.
+func (c *SQLiteClient) GetUserEventsSince(userID string, afterSeq int64, limit int) (events []models.UserEvent, complete bool, err error) {
+	var lastSeq, minSeq int64
+	err = c.reads.QueryRow(`
+		SELECT
+			COALESCE((SELECT last_seq FROM user_event_sequences WHERE user_id = ?), 0),
+			COALESCE((SELECT MIN(seq) FROM user_events WHERE user_id = ?), 0)
+	`, userID, userID).Scan(&lastSeq, &minSeq)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read event log bounds: %w", err)
+	}
+
+	if afterSeq > lastSeq {
+		return nil, false, nil
+	}
+	if afterSeq == lastSeq {
+		return []models.UserEvent{}, true, nil
+	}
+	if minSeq == 0 || afterSeq+1 < minSeq {
+		return nil, false, nil
+	}
+
+	rows, err := c.reads.Query(`
+		SELECT user_id, seq, type, topic, data, created_at
+		FROM user_events
+		WHERE user_id = ? AND seq > ?
+		ORDER BY seq
+		LIMIT ?
+	`, userID, afterSeq, limit)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to fetch events: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event models.UserEvent
+		var data string
+		if err := rows.Scan(&event.UserID, &event.Seq, &event.Type, &event.Topic, &data, &event.CreatedAt); err != nil {
+			return nil, false, fmt.Errorf("failed to scan event row: %w", err)
+		}
+		event.Data = json.RawMessage(data)
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	// Hitting the limit before the end of the log is treated like a gap.
+	if len(events) == 0 || events[len(events)-1].Seq < lastSeq {
+		return nil, false, nil
+	}
+	return events, true, nil
+}
+
+// PruneUserEvents deletes events recorded before cutoff.
+func (c *SQLiteClient) PruneUserEvents(cutoff time.Time) (int64, error) {
+	result, err := c.writes.Exec("DELETE FROM user_events WHERE created_at < ?", cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune events: %w", err)
+	}
+	return result.RowsAffected()
+}
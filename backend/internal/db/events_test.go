@@ -0,0 +1,67 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUserEventReplay(t *testing.T) {
+	client := newTestDB(t)
+	alice := createUser(t, client, "alice")
+	bob := createUser(t, client, "bob")
+	for i := 0; i < 3; i++ {
+		if _, err := client.AppendUserEvent(alice, "file_created", "files", map[string]int{"n": i}); err != nil {
+			t.Fatalf("AppendUserEvent failed: %v", err)
+		}
+	}
+	if event, err := client.AppendUserEvent(bob, "file_created", "files", nil); err != nil || event.Seq != 1 {
+		t.Fatalf("AppendUserEvent() for another user = seq %d, %v, want 1", event.Seq, err)
+	}
+
+	tests := []struct {
+		name         string
+		afterSeq     int64
+		limit        int
+		wantSeqs     []int64
+		wantComplete bool
+	}{
+		{"from the start", 0, 10, []int64{1, 2, 3}, true},
+		{"from the middle", 1, 10, []int64{2, 3}, true},
+		{"up to date", 3, 10, []int64{}, true},
+		{"ahead of the log", 5, 10, nil, false},
+		{"more than the limit", 0, 2, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, complete, err := client.GetUserEventsSince(alice, tt.afterSeq, tt.limit)
+			if err != nil {
+				t.Fatalf("GetUserEventsSince failed: %v", err)
+			}
+			if complete != tt.wantComplete {
+				t.Errorf("complete = %v, want %v", complete, tt.wantComplete)
+			}
+			if len(events) != len(tt.wantSeqs) {
+				t.Fatalf("got %d events, want %d", len(events), len(tt.wantSeqs))
+			}
+			for i, event := range events {
+				if event.Seq != tt.wantSeqs[i] || event.UserID != alice {
+					t.Errorf("event %d = seq %d for %s, want seq %d for alice", i, event.Seq, event.UserID, tt.wantSeqs[i])
+				}
+			}
+		})
+	}
+
+	if n, err := client.PruneUserEvents(time.Now().Add(time.Minute)); err != nil || n != 4 {
+		t.Fatalf("PruneUserEvents() = %d, %v, want 4", n, err)
+	}
+	if _, complete, err := client.GetUserEventsSince(alice, 1, 10); err != nil || complete {
+		t.Errorf("GetUserEventsSince() after pruning = complete %v, %v, want a resync", complete, err)
+	}
+	event, err := client.AppendUserEvent(alice, "file_deleted", "files", nil)
+	if err != nil || event.Seq != 4 {
+		t.Fatalf("AppendUserEvent() after pruning = seq %d, %v, want 4", event.Seq, err)
+	}
+	if events, complete, err := client.GetUserEventsSince(alice, 3, 10); err != nil || !complete || len(events) != 1 {
+		t.Errorf("GetUserEventsSince() after the pruned range = %d events, complete %v, %v, want the new event", len(events), complete, err)
+	}
+}
@@ -0,0 +1,47 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+)
+
+// GetOwnedFile returns fileID if it belongs to userID, or sql.ErrNoRows.
+func (c *SQLiteClient) GetOwnedFile(userID, fileID string) (models.File, error) {
+	var f models.File
+	err := c.reads.QueryRow(`
+		SELECT id, user_id, folder_id, collection_id, key, name, content_type, size, uploaded_at, created_at, updated_at
+		FROM files WHERE id = ? AND user_id = ?
+	`, fileID, userID).Scan(&f.ID, &f.UserID, &f.FolderID, &f.CollectionID, &f.Key, &f.Name, &f.ContentType, &f.Size,
+		&f.UploadedAt, &f.CreatedAt, &f.UpdatedAt)
+	return f, err
+}
+
+// MoveFile puts a file in folderID, or at the root if folderID is nil.
+func (c *SQLiteClient) MoveFile(fileID string, folderID *string) error {
+	_, err := c.writes.Exec("UPDATE files SET folder_id = ?, updated_at = ? WHERE id = ?", folderID, time.Now(), fileID)
+	return err
+}
+
+// UnshareFile stops sharing fileID with userIDs and returns those it was
+// actually shared with.
+func (c *SQLiteClient) UnshareFile(fileID string, userIDs []string) ([]string, error) {
+	tx, err := c.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var revoked []string
+	for _, userID := range userIDs {
+		result, err := tx.Exec("DELETE FROM shared_files WHERE file_id = ? AND shared_with = ?", fileID, userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unshare file: %w", err)
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			revoked = append(revoked, userID)
+		}
+	}
+	return revoked, tx.Commit()
+}
@@ -0,0 +1,140 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+)
+
+// Weights applied to each signal when ranking friend suggestions.
+const (
+	mutualFriendWeight = 3
+	sharedFileWeight   = 2
+	friendLikeWeight   = 1
+)
+
+// GetFriendSuggestions ranks users who are not yet connected to userID by the
+// number of mutual friends, files both users have access to, and likes from
+// userID's friends. Users with any existing friendship row (pending, accepted
+// or blocked) and previously dismissed suggestions are excluded.
+func (c *SQLiteClient) GetFriendSuggestions(userID string, limit int) ([]models.FriendSuggestion, error) {
+	query := `
+		WITH my_friends AS (
+			SELECT CASE WHEN user_id = :user_id THEN friend_id ELSE user_id END AS id
+			FROM friends
+			WHERE (user_id = :user_id OR friend_id = :user_id) AND status = 'accepted'
+		),
+		excluded AS (
+			SELECT CASE WHEN user_id = :user_id THEN friend_id ELSE user_id END AS id
+			FROM friends
+			WHERE user_id = :user_id OR friend_id = :user_id
+			UNION
+			SELECT dismissed_user_id FROM friend_suggestion_dismissals WHERE user_id = :user_id
+			UNION
+			SELECT :user_id
+		),
+		mutuals AS (
+			SELECT CASE WHEN f.user_id = mf.id THEN f.friend_id ELSE f.user_id END AS candidate,
+				   COUNT(DISTINCT mf.id) AS n
+			FROM friends f
+			JOIN my_friends mf ON f.user_id = mf.id OR f.friend_id = mf.id
+			WHERE f.status = 'accepted'
+			GROUP BY candidate
+		),
+		file_access AS (
+			SELECT id AS file_id, user_id FROM files
+			UNION
+			SELECT file_id, shared_with FROM shared_files
+		),
+		shared AS (
+			SELECT theirs.user_id AS candidate, COUNT(DISTINCT theirs.file_id) AS n
+			FROM file_access mine
+			JOIN file_access theirs ON theirs.file_id = mine.file_id AND theirs.user_id != mine.user_id
+			WHERE mine.user_id = :user_id
+			GROUP BY theirs.user_id
+		),
+		likes AS (
+			SELECT fl.friend_id AS candidate, COUNT(DISTINCT fl.user_id) AS n
+			FROM friend_likes fl
+			JOIN my_friends mf ON fl.user_id = mf.id
+			GROUP BY fl.friend_id
+		),
+		candidates AS (
+			SELECT candidate FROM mutuals
+			UNION SELECT candidate FROM shared
+			UNION SELECT candidate FROM likes
+		)
+		SELECT c.candidate, u.username, u.first_name, u.last_name,
+			   COALESCE(m.n, 0), COALESCE(s.n, 0), COALESCE(l.n, 0),
+			   COALESCE(m.n, 0) * :mutual_weight + COALESCE(s.n, 0) * :shared_weight + COALESCE(l.n, 0) * :like_weight AS score
+		FROM candidates c
+		LEFT JOIN users u ON u.id = c.candidate
+		LEFT JOIN mutuals m ON m.candidate = c.candidate
+		LEFT JOIN shared s ON s.candidate = c.candidate
+		LEFT JOIN likes l ON l.candidate = c.candidate
+		WHERE c.candidate NOT IN (SELECT id FROM excluded)
+		ORDER BY score DESC, COALESCE(m.n, 0) DESC, c.candidate
+		LIMIT :limit
+	`
+	rows, err := c.reads.Query(query,
+		sql.Named("user_id", userID),
+		sql.Named("mutual_weight", mutualFriendWeight),
+		sql.Named("shared_weight", sharedFileWeight),
+		sql.Named("like_weight", friendLikeWeight),
+		sql.Named("limit", limit),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch friend suggestions: %w", err)
+	}
+	defer rows.Close()
+
+	suggestions := []models.FriendSuggestion{}
+	for rows.Next() {
+		var s models.FriendSuggestion
+		var username, firstName, lastName sql.NullString
+		err := rows.Scan(&s.UserID, &username, &firstName, &lastName, &s.MutualFriends, &s.SharedFiles, &s.Likes, &s.Score)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan friend suggestion row: %w", err)
+		}
+		s.Username = username.String
+		s.FirstName = firstName.String
+		s.LastName = lastName.String
+		s.Reasons = suggestionReasons(s)
+		suggestions = append(suggestions, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating friend suggestion rows: %w", err)
+	}
+
+	return suggestions, nil
+}
+
+// DismissFriendSuggestion hides dismissedUserID from userID's suggestions.
+func (c *SQLiteClient) DismissFriendSuggestion(userID, dismissedUserID string) error {
+	_, err := c.writes.Exec("INSERT OR IGNORE INTO friend_suggestion_dismissals (user_id, dismissed_user_id) VALUES (?, ?)",
+		userID, dismissedUserID)
+	return err
+}
+
+func suggestionReasons(s models.FriendSuggestion) []string {
+	var reasons []string
+	if s.MutualFriends > 0 {
+		reasons = append(reasons, pluralize(s.MutualFriends, "mutual friend", "mutual friends"))
+	}
+	if s.SharedFiles > 0 {
+		reasons = append(reasons, pluralize(s.SharedFiles, "shared file", "shared files")+" in common")
+	}
+	if s.Likes > 0 {
+		reasons = append(reasons, fmt.Sprintf("Liked by %d of your friends", s.Likes))
+	}
+	return reasons
+}
+
+func pluralize(n int, singular, plural string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, singular)
+	}
+	return fmt.Sprintf("%d %s", n, plural)
+}
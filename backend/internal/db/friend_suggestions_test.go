@@ -0,0 +1,86 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func createFriendship(t *testing.T, client *SQLiteClient, userID, friendID, status string) {
+	t.Helper()
+	_, err := client.DB.Exec(`INSERT INTO friends (id, user_id, friend_id, status) VALUES (?, ?, ?, ?)`,
+		uuid.New().String(), userID, friendID, status)
+	if err != nil {
+		t.Fatalf("failed to create friendship: %v", err)
+	}
+}
+
+func TestGetFriendSuggestions(t *testing.T) {
+	client := newTestDB(t)
+	alice := createUser(t, client, "alice")
+	bob := createUser(t, client, "bob")
+	carol := createUser(t, client, "carol")
+	createFriendship(t, client, alice, bob, "accepted")
+	createFriendship(t, client, carol, alice, "accepted")
+
+	// Friends of two of alice's friends
+	dave := createUser(t, client, "dave")
+	createFriendship(t, client, bob, dave, "accepted")
+	createFriendship(t, client, dave, carol, "accepted")
+	// Shares a file with alice
+	erin := createUser(t, client, "erin")
+	if _, err := client.ShareFileWithFriends(createFile(t, client, erin), erin, []string{alice}); err != nil {
+		t.Fatalf("failed to share file: %v", err)
+	}
+	// Liked by one of alice's friends
+	frank := createUser(t, client, "frank")
+	if err := client.LikeFriend(bob, frank); err != nil {
+		t.Fatalf("failed to like friend: %v", err)
+	}
+	// Dismissed, already asked and only a pending friend of a friend
+	gina := createUser(t, client, "gina")
+	createFriendship(t, client, bob, gina, "accepted")
+	if err := client.DismissFriendSuggestion(alice, gina); err != nil {
+		t.Fatalf("DismissFriendSuggestion failed: %v", err)
+	}
+	henry := createUser(t, client, "henry")
+	createFriendship(t, client, carol, henry, "accepted")
+	createFriendship(t, client, alice, henry, "pending")
+	ivan := createUser(t, client, "ivan")
+	createFriendship(t, client, bob, ivan, "pending")
+
+	suggestions, err := client.GetFriendSuggestions(alice, 10)
+	if err != nil {
+		t.Fatalf("GetFriendSuggestions failed: %v", err)
+	}
+	var got []string
+	for _, s := range suggestions {
+		got = append(got, s.Username)
+	}
+	if want := []string{"dave", "erin", "frank"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("suggestions = %v, want %v", got, want)
+	}
+
+	top := suggestions[0]
+	if top.UserID != dave || top.MutualFriends != 2 || top.Score != 2*mutualFriendWeight {
+		t.Errorf("top suggestion = %+v, want dave with 2 mutual friends", top)
+	}
+	if want := []string{"2 mutual friends"}; !reflect.DeepEqual(top.Reasons, want) {
+		t.Errorf("reasons = %v, want %v", top.Reasons, want)
+	}
+	if s := suggestions[1]; s.UserID != erin || s.SharedFiles != 1 || s.Score != sharedFileWeight {
+		t.Errorf("second suggestion = %+v, want erin with 1 shared file", s)
+	}
+	if s := suggestions[2]; s.UserID != frank || s.Likes != 1 || s.Score != friendLikeWeight {
+		t.Errorf("third suggestion = %+v, want frank with 1 like", s)
+	}
+
+	limited, err := client.GetFriendSuggestions(alice, 1)
+	if err != nil {
+		t.Fatalf("GetFriendSuggestions failed: %v", err)
+	}
+	if len(limited) != 1 || limited[0].UserID != dave {
+		t.Errorf("limited suggestions = %+v, want only dave", limited)
+	}
+}
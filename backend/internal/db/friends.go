@@ -0,0 +1,26 @@
+package db
+
+import "github.com/saint0x/file-storage-app/backend/internal/models"
+
+// AreFriends reports whether two users have an accepted friendship.
+func (c *SQLiteClient) AreFriends(userID, otherUserID string) (bool, error) {
+	var exists bool
+	err := c.reads.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM friends
+			WHERE status = 'accepted'
+			  AND ((user_id = ? AND friend_id = ?) OR (user_id = ? AND friend_id = ?))
+		)
+	`, userID, otherUserID, otherUserID, userID).Scan(&exists)
+	return exists, err
+}
+
+// GetFriendship returns a friends row that userID is part of.
+func (c *SQLiteClient) GetFriendship(friendshipID, userID string) (models.Friend, error) {
+	var f models.Friend
+	err := c.reads.QueryRow(`
+		SELECT id, user_id, friend_id, status, created_at, updated_at
+		FROM friends WHERE id = ? AND (user_id = ? OR friend_id = ?)
+	`, friendshipID, userID, userID).Scan(&f.ID, &f.UserID, &f.FriendID, &f.Status, &f.CreatedAt, &f.UpdatedAt)
+	return f, err
+}
@@ -0,0 +1,146 @@
+package db
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+)
+
+// ErrEmailInUse is returned when provisioning a user whose email address
+// already belongs to another account that may not be linked automatically.
+var ErrEmailInUse = errors.New("email address belongs to another account")
+
+const userColumns = `u.id, u.email, u.username, COALESCE(u.first_name, ''), COALESCE(u.last_name, ''), u.role, u.suspended_at, u.created_at, u.updated_at`
+
+// GetUserByIdentity returns the user that subject at provider is linked to,
+// or sql.ErrNoRows if the identity has not been seen before.
+func (c *SQLiteClient) GetUserByIdentity(provider, subject string) (models.User, error) {
+	return scanUser(c.reads.QueryRow(`
+		SELECT `+userColumns+`
+		FROM user_identities i
+		JOIN users u ON u.id = i.user_id
+		WHERE i.provider = ? AND i.subject = ?
+	`, provider, subject))
+}
+
+// ProvisionUser links subject at provider to a user and returns that user.
+// When linkByEmail is set and an account with the same email address already
+// exists, the identity is linked to it; otherwise a new user is created from
+// profile, with a random suffix added to its username if that is taken.
+// Provisioning an identity that is already linked returns the linked user.
+func (c *SQLiteClient) ProvisionUser(provider, subject string, profile models.User, linkByEmail bool) (models.User, error) {
+	tx, err := c.DB.Begin()
+	if err != nil {
+		return models.User{}, err
+	}
+	defer tx.Rollback()
+
+	user, err := scanUser(tx.QueryRow(`
+		SELECT `+userColumns+`
+		FROM user_identities i
+		JOIN users u ON u.id = i.user_id
+		WHERE i.provider = ? AND i.subject = ?
+	`, provider, subject))
+	if err == nil {
+		return user, nil
+	}
+	if err != sql.ErrNoRows {
+		return models.User{}, err
+	}
+
+	user, err = scanUser(tx.QueryRow(`SELECT `+userColumns+` FROM users u WHERE u.email = ?`, profile.Email))
+	switch {
+	case err == nil && !linkByEmail:
+		return models.User{}, ErrEmailInUse
+	case err == sql.ErrNoRows:
+		user, err = createProvisionedUser(tx, profile)
+		if err != nil {
+			return models.User{}, err
+		}
+	case err != nil:
+		return models.User{}, err
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO user_identities (provider, subject, user_id, created_at) VALUES (?, ?, ?, ?)
+	`, provider, subject, user.ID.String(), time.Now()); err != nil {
+		return models.User{}, fmt.Errorf("failed to link identity: %w", err)
+	}
+	return user, tx.Commit()
+}
+
+func createProvisionedUser(tx *sql.Tx, profile models.User) (models.User, error) {
+	username, err := availableUsername(tx, profile.Username)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	user := profile
+	user.ID = uuid.New()
+	user.Username = username
+	user.Role = models.RoleUser
+	user.SuspendedAt = nil
+	user.CreatedAt = time.Now()
+	user.UpdatedAt = user.CreatedAt
+	_, err = tx.Exec(`
+		INSERT INTO users (id, email, username, first_name, last_name, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, user.ID.String(), user.Email, user.Username, user.FirstName, user.LastName, user.CreatedAt, user.UpdatedAt)
+	if err != nil {
+		return models.User{}, fmt.Errorf("failed to create user: %w", err)
+	}
+	return user, nil
+}
+
+// availableUsername returns base if no user has it yet, or base with a
+// short random suffix otherwise.
+func availableUsername(tx *sql.Tx, base string) (string, error) {
+	base = strings.TrimSpace(base)
+	if base == "" {
+		base = "user"
+	}
+	candidate := base
+	for i := 0; i < 5; i++ {
+		var taken bool
+		if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM users WHERE username = ?)`, candidate).Scan(&taken); err != nil {
+			return "", err
+		}
+		if !taken {
+			return candidate, nil
+		}
+		suffix := make([]byte, 3)
+		if _, err := rand.Read(suffix); err != nil {
+			return "", err
+		}
+		candidate = base + "-" + hex.EncodeToString(suffix)
+	}
+	return "", fmt.Errorf("no username available for %q", base)
+}
+
+func scanUser(row rowScanner) (models.User, error) {
+	var user models.User
+	var id string
+	var suspendedAt sql.NullTime
+	err := row.Scan(&id, &user.Email, &user.Username, &user.FirstName, &user.LastName, &user.Role, &suspendedAt,
+		&user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		return models.User{}, err
+	}
+	if suspendedAt.Valid {
+		user.SuspendedAt = &suspendedAt.Time
+	}
+	user.ID, err = uuid.Parse(id)
+	return user, err
+}
+
+// GetUserByID returns userID, or sql.ErrNoRows.
+func (c *SQLiteClient) GetUserByID(userID string) (models.User, error) {
+	return scanUser(c.reads.QueryRow(`SELECT `+userColumns+` FROM users u WHERE u.id = ?`, userID))
+}
@@ -0,0 +1,361 @@
+package db
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Markers that separate the two halves of a migration file.
+const (
+	upMarker   = "-- Up migration"
+	downMarker = "-- Down migration"
+)
+
+var migrationName = regexp.MustCompile(`^(\d+)_(\w+)\.sql$`)
+
+// ErrChecksumMismatch is returned when a migration that has been applied was
+// edited afterwards. Applied migrations must not change; add a new one.
+var ErrChecksumMismatch = errors.New("applied migration has changed")
+
+// ErrUnknownMigration is returned when the database has a migration applied
+// that this build does not know about, usually because it was migrated by a
+// newer build.
+var ErrUnknownMigration = errors.New("database has an unknown migration applied")
+
+// Migration is one numbered schema change, read from migrations/NNN_name.sql.
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	HasDown  bool
+	Checksum string
+}
+
+// MigrationStatus is a migration along with whether, and how, it was
+// applied to the database.
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+	// Modified is set when the applied checksum differs from the file's.
+	Modified bool
+	// Unknown is set for applied migrations missing from this build.
+	Unknown bool
+}
+
+// Migrator applies the embedded migrations to a database, recording each in
+// the schema_migrations table.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// NewMigrator returns a Migrator for db, creating schema_migrations if it
+// does not exist yet.
+func NewMigrator(db *sql.DB) (*Migrator, error) {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			checksum TEXT NOT NULL,
+			applied_at TIMESTAMP NOT NULL
+		)
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// LoadMigrations returns the embedded migrations in version order.
+func LoadMigrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migrations: %w", err)
+	}
+
+	var migrations []Migration
+	seen := map[int]string{}
+	for _, entry := range entries {
+		match := migrationName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migration %s is not named NNN_name.sql", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("migrations %s and %s have the same version", other, entry.Name())
+		}
+		seen[version] = entry.Name()
+
+		content, err := migrationFiles.ReadFile(path.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+		m, err := parseMigration(version, match[2], string(content))
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", entry.Name(), err)
+		}
+		migrations = append(migrations, m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// parseMigration splits a migration file into its up and down halves. A file
+// without a down marker cannot be rolled back; an empty down half is a no-op.
+func parseMigration(version int, name, content string) (Migration, error) {
+	sum := sha256.Sum256([]byte(content))
+	m := Migration{Version: version, Name: name, Checksum: hex.EncodeToString(sum[:])}
+
+	up := content
+	if i := strings.Index(content, downMarker); i >= 0 {
+		up = content[:i]
+		m.Down = strings.TrimSpace(content[i+len(downMarker):])
+		m.HasDown = true
+	}
+	m.Up = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(up), upMarker))
+	if m.Up == "" {
+		return m, errors.New("up migration is empty")
+	}
+	return m, nil
+}
+
+// Status returns every migration known to this build or applied to the
+// database, in version order.
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []MigrationStatus
+	for _, migration := range m.migrations {
+		status := MigrationStatus{Migration: migration}
+		if a, ok := applied[migration.Version]; ok {
+			appliedAt := a.AppliedAt
+			status.AppliedAt = &appliedAt
+			status.Modified = a.Checksum != migration.Checksum
+			delete(applied, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, a := range applied {
+		appliedAt := a.AppliedAt
+		statuses = append(statuses, MigrationStatus{
+			Migration: Migration{Version: a.Version, Name: a.Name, Checksum: a.Checksum},
+			AppliedAt: &appliedAt,
+			Unknown:   true,
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// Version returns the highest applied version, or -1 if none is.
+func (m *Migrator) Version() (int, error) {
+	var version sql.NullInt64
+	if err := m.db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, err
+	}
+	if !version.Valid {
+		return -1, nil
+	}
+	return int(version.Int64), nil
+}
+
+// Up applies pending migrations up to and including target, or all of them
+// if target is negative, and returns the ones it applied. Each migration
+// runs in its own transaction.
+func (m *Migrator) Up(target int) ([]Migration, error) {
+	if err := m.verify(); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, migration := range m.migrations {
+		if target >= 0 && migration.Version > target {
+			break
+		}
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		ran, err := m.apply(migration)
+		if err != nil {
+			return done, err
+		}
+		if ran {
+			done = append(done, migration)
+		}
+	}
+	return done, nil
+}
+
+// apply runs migration's up half. Recording the version first takes the
+// write lock, so when several servers start at once only one of them runs
+// it; apply reports false for the others. Foreign keys are not enforced
+// while it runs, since migrations drop and rebuild tables.
+func (m *Migrator) apply(migration Migration) (bool, error) {
+	tx, restore, err := beginWithoutForeignKeys(m.db)
+	if err != nil {
+		return false, err
+	}
+	defer restore()
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT OR IGNORE INTO schema_migrations (version, name, checksum, applied_at)
+		VALUES (?, ?, ?, ?)
+	`, migration.Version, migration.Name, migration.Checksum, time.Now())
+	if err != nil {
+		return false, fmt.Errorf("failed to record migration %03d: %w", migration.Version, err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	if _, err := tx.Exec(migration.Up); err != nil {
+		return false, fmt.Errorf("migration %03d_%s failed: %w", migration.Version, migration.Name, err)
+	}
+	return true, tx.Commit()
+}
+
+// Down rolls back the most recently applied steps migrations, newest first,
+// and returns the ones it rolled back.
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	if err := m.verify(); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		if !migration.HasDown {
+			return done, fmt.Errorf("migration %03d_%s cannot be rolled back", migration.Version, migration.Name)
+		}
+		if err := m.revert(migration); err != nil {
+			return done, err
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+func (m *Migrator) revert(migration Migration) error {
+	tx, restore, err := beginWithoutForeignKeys(m.db)
+	if err != nil {
+		return err
+	}
+	defer restore()
+	defer tx.Rollback()
+
+	if migration.Down != "" {
+		if _, err := tx.Exec(migration.Down); err != nil {
+			return fmt.Errorf("rolling back migration %03d_%s failed: %w", migration.Version, migration.Name, err)
+		}
+	}
+	if _, err := tx.Exec(`DELETE FROM schema_migrations WHERE version = ?`, migration.Version); err != nil {
+		return fmt.Errorf("failed to unrecord migration %03d: %w", migration.Version, err)
+	}
+	return tx.Commit()
+}
+
+// Baseline records every migration up to and including version as applied
+// without running it, for databases whose schema was created before
+// migrations were tracked.
+func (m *Migrator) Baseline(version int) ([]Migration, error) {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var done []Migration
+	for _, migration := range m.migrations {
+		if migration.Version > version {
+			break
+		}
+		result, err := tx.Exec(`
+			INSERT OR IGNORE INTO schema_migrations (version, name, checksum, applied_at)
+			VALUES (?, ?, ?, ?)
+		`, migration.Version, migration.Name, migration.Checksum, time.Now())
+		if err != nil {
+			return nil, fmt.Errorf("failed to record migration %03d: %w", migration.Version, err)
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			done = append(done, migration)
+		}
+	}
+	return done, tx.Commit()
+}
+
+// verify refuses to migrate a database whose applied migrations do not
+// match this build's.
+func (m *Migrator) verify() error {
+	statuses, err := m.Status()
+	if err != nil {
+		return err
+	}
+	for _, status := range statuses {
+		if status.Modified {
+			return fmt.Errorf("%w: %03d_%s", ErrChecksumMismatch, status.Version, status.Name)
+		}
+		if status.Unknown {
+			return fmt.Errorf("%w: %03d_%s", ErrUnknownMigration, status.Version, status.Name)
+		}
+	}
+	return nil
+}
+
+type appliedMigration struct {
+	Version   int
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+func (m *Migrator) applied() (map[int]appliedMigration, error) {
+	rows, err := m.db.Query(`SELECT version, name, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := map[int]appliedMigration{}
+	for rows.Next() {
+		var a appliedMigration
+		if err := rows.Scan(&a.Version, &a.Name, &a.Checksum, &a.AppliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan applied migration: %w", err)
+		}
+		applied[a.Version] = a
+	}
+	return applied, rows.Err()
+}
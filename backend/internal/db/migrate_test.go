@@ -0,0 +1,176 @@
+package db
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// newTestMigrator returns a Migrator for an empty database that applies
+// migrations instead of the embedded ones.
+func newTestMigrator(t *testing.T, migrations ...Migration) *Migrator {
+	t.Helper()
+	client, err := NewSQLiteClientWithOptions(t.TempDir()+"/test.sqlite", DefaultSQLiteOptions())
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	migrator, err := NewMigrator(client.DB)
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+	migrator.migrations = migrations
+	return migrator
+}
+
+func testMigration(t *testing.T, version int, name, content string) Migration {
+	t.Helper()
+	m, err := parseMigration(version, name, content)
+	if err != nil {
+		t.Fatalf("parseMigration failed: %v", err)
+	}
+	return m
+}
+
+func tableExists(t *testing.T, m *Migrator, name string) bool {
+	t.Helper()
+	var exists bool
+	if err := m.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = ?)`, name).Scan(&exists); err != nil {
+		t.Fatalf("failed to check for table %s: %v", name, err)
+	}
+	return exists
+}
+
+func TestParseMigration(t *testing.T) {
+	m, err := parseMigration(1, "create_notes", "-- Up migration\nCREATE TABLE notes (id TEXT);\n\n-- Down migration\nDROP TABLE notes;\n")
+	if err != nil {
+		t.Fatalf("parseMigration failed: %v", err)
+	}
+	if m.Up != "CREATE TABLE notes (id TEXT);" || m.Down != "DROP TABLE notes;" || !m.HasDown {
+		t.Errorf("parseMigration() = %+v, want both halves", m)
+	}
+
+	m, err = parseMigration(2, "irreversible", "-- Up migration\nDROP TABLE notes;\n")
+	if err != nil || m.HasDown {
+		t.Errorf("parseMigration() without a down half = %+v, %v, want HasDown unset", m, err)
+	}
+	m, err = parseMigration(3, "noop_down", "-- Up migration\nSELECT 1;\n-- Down migration\n")
+	if err != nil || !m.HasDown || m.Down != "" {
+		t.Errorf("parseMigration() with an empty down half = %+v, %v, want a no-op rollback", m, err)
+	}
+	if _, err := parseMigration(4, "empty", "-- Up migration\n\n-- Down migration\nSELECT 1;"); err == nil {
+		t.Error("parseMigration() accepted an empty up half")
+	}
+}
+
+func TestMigratorUpAndDown(t *testing.T) {
+	migrator := newTestMigrator(t,
+		testMigration(t, 1, "create_notes", "-- Up migration\nCREATE TABLE notes (id TEXT);\n-- Down migration\nDROP TABLE notes;"),
+		testMigration(t, 2, "create_labels", "-- Up migration\nCREATE TABLE labels (id TEXT);\n-- Down migration\nDROP TABLE labels;"),
+		testMigration(t, 3, "irreversible", "-- Up migration\nCREATE TABLE archive (id TEXT);"),
+	)
+
+	if done, err := migrator.Up(1); err != nil || len(done) != 1 {
+		t.Fatalf("Up(1) = %d migrations, %v, want 1", len(done), err)
+	}
+	if version, _ := migrator.Version(); version != 1 || tableExists(t, migrator, "labels") {
+		t.Fatalf("version %d after Up(1), want 1 without later tables", version)
+	}
+	if done, err := migrator.Up(-1); err != nil || len(done) != 2 {
+		t.Fatalf("Up(-1) = %d migrations, %v, want the remaining 2", len(done), err)
+	}
+
+	done, err := migrator.Down(1)
+	if err == nil || !strings.Contains(err.Error(), "cannot be rolled back") || len(done) != 0 {
+		t.Fatalf("Down(1) over an irreversible migration = %d, %v, want an error", len(done), err)
+	}
+	if !tableExists(t, migrator, "archive") {
+		t.Error("the irreversible migration's table was dropped")
+	}
+
+	migrator.migrations = migrator.migrations[:2]
+	migrator.db.Exec(`DELETE FROM schema_migrations WHERE version = 3`)
+	if done, err := migrator.Down(2); err != nil || len(done) != 2 || done[0].Version != 2 {
+		t.Fatalf("Down(2) = %+v, %v, want versions 2 and 1 rolled back", done, err)
+	}
+	if version, _ := migrator.Version(); version != -1 || tableExists(t, migrator, "notes") {
+		t.Errorf("version %d after rolling everything back, want -1 without tables", version)
+	}
+}
+
+func TestMigratorRollsBackFailedMigration(t *testing.T) {
+	migrator := newTestMigrator(t,
+		testMigration(t, 1, "create_notes", "-- Up migration\nCREATE TABLE notes (id TEXT);\n-- Down migration\nDROP TABLE notes;"),
+		testMigration(t, 2, "broken", "-- Up migration\nCREATE TABLE labels (id TEXT);\nINSERT INTO missing VALUES (1);"),
+	)
+
+	done, err := migrator.Up(-1)
+	if err == nil || len(done) != 1 {
+		t.Fatalf("Up() = %d migrations, %v, want the first applied and an error", len(done), err)
+	}
+	if version, _ := migrator.Version(); version != 1 {
+		t.Errorf("version = %d after a failed migration, want 1", version)
+	}
+	if tableExists(t, migrator, "labels") {
+		t.Error("the failed migration's changes were kept")
+	}
+}
+
+func TestMigratorRefusesChangedHistory(t *testing.T) {
+	notes := testMigration(t, 1, "create_notes", "-- Up migration\nCREATE TABLE notes (id TEXT);\n-- Down migration\nDROP TABLE notes;")
+
+	t.Run("edited migration", func(t *testing.T) {
+		migrator := newTestMigrator(t, notes)
+		if _, err := migrator.Up(-1); err != nil {
+			t.Fatalf("Up failed: %v", err)
+		}
+		migrator.migrations = []Migration{testMigration(t, 1, "create_notes", "-- Up migration\nCREATE TABLE notes (id TEXT, body TEXT);")}
+
+		statuses, err := migrator.Status()
+		if err != nil || len(statuses) != 1 || !statuses[0].Modified {
+			t.Errorf("Status() = %+v, %v, want the migration marked modified", statuses, err)
+		}
+		if _, err := migrator.Up(-1); !errors.Is(err, ErrChecksumMismatch) {
+			t.Errorf("Up() error = %v, want %v", err, ErrChecksumMismatch)
+		}
+		if _, err := migrator.Down(1); !errors.Is(err, ErrChecksumMismatch) {
+			t.Errorf("Down() error = %v, want %v", err, ErrChecksumMismatch)
+		}
+	})
+
+	t.Run("unknown migration", func(t *testing.T) {
+		migrator := newTestMigrator(t, notes)
+		if _, err := migrator.db.Exec(`INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (9, 'future', 'x', CURRENT_TIMESTAMP)`); err != nil {
+			t.Fatalf("failed to record migration: %v", err)
+		}
+
+		statuses, err := migrator.Status()
+		if err != nil || len(statuses) != 2 || !statuses[1].Unknown {
+			t.Errorf("Status() = %+v, %v, want the applied migration marked unknown", statuses, err)
+		}
+		if _, err := migrator.Up(-1); !errors.Is(err, ErrUnknownMigration) {
+			t.Errorf("Up() error = %v, want %v", err, ErrUnknownMigration)
+		}
+		if tableExists(t, migrator, "notes") {
+			t.Error("Up ran migrations on a database migrated by a newer build")
+		}
+	})
+}
+
+func TestMigratorBaseline(t *testing.T) {
+	migrator := newTestMigrator(t,
+		testMigration(t, 1, "create_notes", "-- Up migration\nCREATE TABLE notes (id TEXT);"),
+		testMigration(t, 2, "create_labels", "-- Up migration\nCREATE TABLE labels (id TEXT);"),
+	)
+
+	if done, err := migrator.Baseline(1); err != nil || len(done) != 1 {
+		t.Fatalf("Baseline(1) = %d migrations, %v, want 1", len(done), err)
+	}
+	if tableExists(t, migrator, "notes") {
+		t.Error("Baseline ran the migration")
+	}
+	if done, err := migrator.Up(-1); err != nil || len(done) != 1 || done[0].Version != 2 {
+		t.Errorf("Up() after Baseline = %+v, %v, want only version 2", done, err)
+	}
+}
@@ -1,3 +1,4 @@
+-- Up migration
 -- Users Table
 CREATE TABLE IF NOT EXISTS users (
     id TEXT PRIMARY KEY,
@@ -79,14 +80,12 @@ CREATE TABLE IF NOT EXISTS files (
     uploaded_at TIMESTAMP NOT NULL,
     created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    b2_file_id TEXT,
     FOREIGN KEY (user_id) REFERENCES users(id),
     FOREIGN KEY (folder_id) REFERENCES folders(id),
     FOREIGN KEY (collection_id) REFERENCES collections(id)
 );
 
--- Add this line to the files table definition
-ALTER TABLE files ADD COLUMN b2_file_id TEXT;
-
 -- File Categories Table
 CREATE TABLE IF NOT EXISTS file_categories (
     id TEXT PRIMARY KEY,
@@ -124,4 +123,17 @@ CREATE TABLE IF NOT EXISTS activity_log (
     action_details TEXT,
     created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
     FOREIGN KEY (user_id) REFERENCES users(id)
-);
\ No newline at end of file
+);
+
+-- Down migration
+DROP TABLE IF EXISTS activity_log;
+DROP TABLE IF EXISTS shared_files;
+DROP TABLE IF EXISTS file_category_associations;
+DROP TABLE IF EXISTS file_categories;
+DROP TABLE IF EXISTS files;
+DROP TABLE IF EXISTS folders;
+DROP TABLE IF EXISTS collections;
+DROP TABLE IF EXISTS friend_likes;
+DROP TABLE IF EXISTS friend_contexts;
+DROP TABLE IF EXISTS friends;
+DROP TABLE IF EXISTS users;
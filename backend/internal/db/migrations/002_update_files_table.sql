@@ -15,4 +15,4 @@ CREATE TABLE IF NOT EXISTS files (
 );
 
 -- Down migration
-DROP TABLE IF EXISTS files;
\ No newline at end of file
+-- files is part of the initial schema and is dropped by rolling back 000.
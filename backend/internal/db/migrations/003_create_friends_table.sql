@@ -11,4 +11,4 @@ CREATE TABLE IF NOT EXISTS friends (
 );
 
 -- Down migration
-DROP TABLE IF EXISTS friends;
\ No newline at end of file
+-- friends is part of the initial schema and is dropped by rolling back 000.
@@ -0,0 +1,12 @@
+-- Up migration
+CREATE TABLE IF NOT EXISTS friend_suggestion_dismissals (
+    user_id TEXT NOT NULL,
+    dismissed_user_id TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, dismissed_user_id),
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    FOREIGN KEY (dismissed_user_id) REFERENCES users(id)
+);
+
+-- Down migration
+DROP TABLE IF EXISTS friend_suggestion_dismissals;
@@ -0,0 +1,39 @@
+-- Up migration
+CREATE TABLE IF NOT EXISTS collection_members (
+    collection_id TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+    role TEXT CHECK(role IN ('viewer', 'contributor', 'admin')) NOT NULL,
+    added_by TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (collection_id, user_id),
+    FOREIGN KEY (collection_id) REFERENCES collections(id),
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    FOREIGN KEY (added_by) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS collection_files (
+    collection_id TEXT NOT NULL,
+    file_id TEXT NOT NULL,
+    position INTEGER NOT NULL,
+    added_by TEXT NOT NULL,
+    added_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (collection_id, file_id),
+    FOREIGN KEY (collection_id) REFERENCES collections(id),
+    FOREIGN KEY (file_id) REFERENCES files(id),
+    FOREIGN KEY (added_by) REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_collection_members_user_id ON collection_members(user_id);
+CREATE INDEX IF NOT EXISTS idx_collection_files_file_id ON collection_files(file_id);
+
+INSERT OR IGNORE INTO collection_files (collection_id, file_id, position, added_by, added_at)
+SELECT collection_id, id, ROW_NUMBER() OVER (PARTITION BY collection_id ORDER BY created_at), user_id, created_at
+FROM files
+WHERE collection_id IS NOT NULL;
+
+-- Down migration
+DROP INDEX IF EXISTS idx_collection_files_file_id;
+DROP INDEX IF EXISTS idx_collection_members_user_id;
+DROP TABLE IF EXISTS collection_files;
+DROP TABLE IF EXISTS collection_members;
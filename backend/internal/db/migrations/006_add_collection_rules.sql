@@ -0,0 +1,5 @@
+-- Up migration
+ALTER TABLE collections ADD COLUMN rule TEXT;
+
+-- Down migration
+ALTER TABLE collections DROP COLUMN rule;
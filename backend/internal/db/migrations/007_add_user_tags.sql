@@ -0,0 +1,58 @@
+-- Up migration
+CREATE TABLE new_file_categories (
+    id TEXT PRIMARY KEY,
+    user_id TEXT,
+    name TEXT NOT NULL,
+    color TEXT NOT NULL DEFAULT '#9ca3af',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, name),
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+INSERT INTO new_file_categories (id, user_id, name, created_at, updated_at)
+SELECT id, NULL, name, created_at, updated_at
+FROM file_categories;
+
+DROP TABLE file_categories;
+ALTER TABLE new_file_categories RENAME TO file_categories;
+
+ALTER TABLE file_category_associations ADD COLUMN source TEXT NOT NULL DEFAULT 'user';
+
+CREATE TABLE IF NOT EXISTS tag_suggestions (
+    id TEXT PRIMARY KEY,
+    file_id TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    status TEXT CHECK(status IN ('pending', 'accepted', 'rejected')) NOT NULL DEFAULT 'pending',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (file_id, user_id, name),
+    FOREIGN KEY (file_id) REFERENCES files(id),
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_file_categories_user_id ON file_categories(user_id);
+CREATE INDEX IF NOT EXISTS idx_file_category_associations_category_id ON file_category_associations(category_id);
+
+-- Down migration
+DROP INDEX IF EXISTS idx_file_category_associations_category_id;
+DROP INDEX IF EXISTS idx_file_categories_user_id;
+DROP TABLE IF EXISTS tag_suggestions;
+
+ALTER TABLE file_category_associations DROP COLUMN source;
+
+CREATE TABLE old_file_categories (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO old_file_categories (id, name, created_at, updated_at)
+SELECT id, name, created_at, updated_at
+FROM file_categories
+WHERE user_id IS NULL;
+
+DROP TABLE file_categories;
+ALTER TABLE old_file_categories RENAME TO file_categories;
@@ -0,0 +1,44 @@
+-- Up migration
+CREATE TABLE IF NOT EXISTS comments (
+    id TEXT PRIMARY KEY,
+    target_type TEXT CHECK(target_type IN ('file', 'folder')) NOT NULL,
+    target_id TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+    parent_id TEXT,
+    body TEXT NOT NULL,
+    resolved_at TIMESTAMP,
+    resolved_by TEXT,
+    edited_at TIMESTAMP,
+    deleted_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    FOREIGN KEY (parent_id) REFERENCES comments(id),
+    FOREIGN KEY (resolved_by) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS comment_revisions (
+    id TEXT PRIMARY KEY,
+    comment_id TEXT NOT NULL,
+    body TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (comment_id) REFERENCES comments(id)
+);
+
+CREATE TABLE IF NOT EXISTS comment_mentions (
+    comment_id TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+    PRIMARY KEY (comment_id, user_id),
+    FOREIGN KEY (comment_id) REFERENCES comments(id),
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_comments_target ON comments(target_type, target_id);
+CREATE INDEX IF NOT EXISTS idx_comment_revisions_comment_id ON comment_revisions(comment_id);
+
+-- Down migration
+DROP INDEX IF EXISTS idx_comment_revisions_comment_id;
+DROP INDEX IF EXISTS idx_comments_target;
+DROP TABLE IF EXISTS comment_mentions;
+DROP TABLE IF EXISTS comment_revisions;
+DROP TABLE IF EXISTS comments;
@@ -0,0 +1,24 @@
+-- Up migration
+CREATE TABLE IF NOT EXISTS user_event_sequences (
+    user_id TEXT PRIMARY KEY,
+    last_seq INTEGER NOT NULL DEFAULT 0,
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS user_events (
+    user_id TEXT NOT NULL,
+    seq INTEGER NOT NULL,
+    type TEXT NOT NULL,
+    topic TEXT NOT NULL,
+    data TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, seq),
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_events_created_at ON user_events(created_at);
+
+-- Down migration
+DROP INDEX IF EXISTS idx_user_events_created_at;
+DROP TABLE IF EXISTS user_events;
+DROP TABLE IF EXISTS user_event_sequences;
@@ -0,0 +1,15 @@
+-- Up migration
+CREATE TABLE IF NOT EXISTS broker_messages (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    topic TEXT NOT NULL,
+    type TEXT NOT NULL,
+    seq INTEGER NOT NULL DEFAULT 0,
+    data TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_broker_messages_created_at ON broker_messages(created_at);
+
+-- Down migration
+DROP INDEX IF EXISTS idx_broker_messages_created_at;
+DROP TABLE IF EXISTS broker_messages;
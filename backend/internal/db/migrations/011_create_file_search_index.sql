@@ -0,0 +1,18 @@
+-- Up migration
+CREATE TABLE IF NOT EXISTS file_search_index (
+    file_id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    terms TEXT NOT NULL,
+    indexed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (file_id) REFERENCES files(id),
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_file_search_index_user_id ON file_search_index(user_id);
+
+INSERT OR IGNORE INTO file_search_index (file_id, user_id, terms)
+SELECT id, user_id, LOWER(name || ' ' || content_type) FROM files;
+
+-- Down migration
+DROP INDEX IF EXISTS idx_file_search_index_user_id;
+DROP TABLE IF EXISTS file_search_index;
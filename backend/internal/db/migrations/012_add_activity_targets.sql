@@ -0,0 +1,12 @@
+-- Up migration
+ALTER TABLE activity_log ADD COLUMN target_type TEXT;
+ALTER TABLE activity_log ADD COLUMN target_id TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_activity_log_user_id ON activity_log(user_id);
+CREATE INDEX IF NOT EXISTS idx_activity_log_target ON activity_log(target_type, target_id);
+
+-- Down migration
+DROP INDEX IF EXISTS idx_activity_log_target;
+DROP INDEX IF EXISTS idx_activity_log_user_id;
+ALTER TABLE activity_log DROP COLUMN target_id;
+ALTER TABLE activity_log DROP COLUMN target_type;
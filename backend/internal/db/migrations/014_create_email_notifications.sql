@@ -0,0 +1,58 @@
+-- Up migration
+ALTER TABLE notification_preferences ADD COLUMN email TEXT NOT NULL DEFAULT 'digest';
+
+CREATE TABLE IF NOT EXISTS email_settings (
+    user_id TEXT PRIMARY KEY,
+    digest_frequency TEXT NOT NULL DEFAULT 'daily',
+    unsubscribed BOOLEAN NOT NULL DEFAULT 0,
+    unsubscribe_token TEXT UNIQUE NOT NULL,
+    last_digest_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS email_digest_items (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    event_id TEXT NOT NULL,
+    type TEXT NOT NULL,
+    actor_id TEXT NOT NULL,
+    target_type TEXT NOT NULL,
+    target_id TEXT NOT NULL,
+    data TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    sent_at TIMESTAMP,
+    UNIQUE (user_id, event_id),
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_digest_items_pending ON email_digest_items(user_id, sent_at);
+
+CREATE TABLE IF NOT EXISTS email_queue (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    dedupe_key TEXT UNIQUE NOT NULL,
+    to_address TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    text_body TEXT NOT NULL,
+    html_body TEXT NOT NULL,
+    headers TEXT NOT NULL DEFAULT '{}',
+    status TEXT NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_error TEXT,
+    sent_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_queue_due ON email_queue(status, next_attempt_at);
+
+-- Down migration
+DROP INDEX IF EXISTS idx_email_queue_due;
+DROP TABLE IF EXISTS email_queue;
+DROP INDEX IF EXISTS idx_email_digest_items_pending;
+DROP TABLE IF EXISTS email_digest_items;
+DROP TABLE IF EXISTS email_settings;
+ALTER TABLE notification_preferences DROP COLUMN email;
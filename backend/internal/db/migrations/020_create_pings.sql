@@ -0,0 +1,12 @@
+-- Up migration
+CREATE TABLE IF NOT EXISTS pings (
+    id TEXT PRIMARY KEY,
+    client_id TEXT NOT NULL,
+    timestamp TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_pings_client_id ON pings(client_id);
+
+-- Down migration
+DROP INDEX IF EXISTS idx_pings_client_id;
+DROP TABLE IF EXISTS pings;
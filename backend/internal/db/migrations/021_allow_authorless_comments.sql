@@ -0,0 +1,63 @@
+-- Up migration
+-- Comments left by deleted users stay as placeholders without an author.
+CREATE TABLE new_comments (
+    id TEXT PRIMARY KEY,
+    target_type TEXT CHECK(target_type IN ('file', 'folder')) NOT NULL,
+    target_id TEXT NOT NULL,
+    user_id TEXT,
+    parent_id TEXT,
+    body TEXT NOT NULL,
+    resolved_at TIMESTAMP,
+    resolved_by TEXT,
+    edited_at TIMESTAMP,
+    deleted_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL,
+    FOREIGN KEY (parent_id) REFERENCES comments(id),
+    FOREIGN KEY (resolved_by) REFERENCES users(id) ON DELETE SET NULL
+);
+
+INSERT INTO new_comments (id, target_type, target_id, user_id, parent_id, body, resolved_at, resolved_by, edited_at, deleted_at, created_at, updated_at)
+SELECT id, target_type, target_id, user_id, parent_id, body, resolved_at, resolved_by, edited_at, deleted_at, created_at, updated_at
+FROM comments;
+
+DROP INDEX IF EXISTS idx_comments_target;
+DROP TABLE comments;
+ALTER TABLE new_comments RENAME TO comments;
+
+CREATE INDEX IF NOT EXISTS idx_comments_target ON comments(target_type, target_id);
+
+-- Down migration
+DELETE FROM comment_revisions WHERE comment_id IN (SELECT id FROM comments WHERE user_id IS NULL);
+DELETE FROM comment_mentions WHERE comment_id IN (SELECT id FROM comments WHERE user_id IS NULL);
+UPDATE comments SET parent_id = NULL WHERE parent_id IN (SELECT id FROM comments WHERE user_id IS NULL);
+
+CREATE TABLE old_comments (
+    id TEXT PRIMARY KEY,
+    target_type TEXT CHECK(target_type IN ('file', 'folder')) NOT NULL,
+    target_id TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+    parent_id TEXT,
+    body TEXT NOT NULL,
+    resolved_at TIMESTAMP,
+    resolved_by TEXT,
+    edited_at TIMESTAMP,
+    deleted_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    FOREIGN KEY (parent_id) REFERENCES comments(id),
+    FOREIGN KEY (resolved_by) REFERENCES users(id)
+);
+
+INSERT INTO old_comments (id, target_type, target_id, user_id, parent_id, body, resolved_at, resolved_by, edited_at, deleted_at, created_at, updated_at)
+SELECT id, target_type, target_id, user_id, parent_id, body, resolved_at, resolved_by, edited_at, deleted_at, created_at, updated_at
+FROM comments
+WHERE user_id IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_comments_target;
+DROP TABLE comments;
+ALTER TABLE old_comments RENAME TO comments;
+
+CREATE INDEX IF NOT EXISTS idx_comments_target ON comments(target_type, target_id);
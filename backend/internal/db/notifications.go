@@ -0,0 +1,186 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+)
+
+const notificationColumns = `rowid, id, user_id, type, actor_id, target_type, target_id, data, read_at, created_at`
+
+// CreateNotification stores a new unread notification and returns it.
+func (c *SQLiteClient) CreateNotification(notification models.Notification) (models.Notification, error) {
+	if notification.ID == "" {
+		notification.ID = uuid.New().String()
+	}
+	notification.ReadAt = nil
+	if notification.CreatedAt.IsZero() {
+		notification.CreatedAt = time.Now()
+	}
+	if notification.Data == nil {
+		notification.Data = json.RawMessage("{}")
+	}
+
+	_, err := c.writes.Exec(`
+		INSERT INTO notifications (id, user_id, type, actor_id, target_type, target_id, data, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, notification.ID, notification.UserID, notification.Type, notification.ActorID,
+		notification.TargetType, notification.TargetID, string(notification.Data), notification.CreatedAt)
+	if err != nil {
+		return models.Notification{}, fmt.Errorf("failed to create notification: %w", err)
+	}
+	return notification, nil
+}
+
+// GetNotifications returns a page of userID's notifications, newest first,
+// and the cursor of the next page, which is empty on the last page.
+func (c *SQLiteClient) GetNotifications(userID string, unreadOnly bool, cursor string, limit int) ([]models.Notification, string, error) {
+	query := `SELECT ` + notificationColumns + ` FROM notifications WHERE user_id = ?`
+	args := []interface{}{userID}
+	if unreadOnly {
+		query += ` AND read_at IS NULL`
+	}
+	if cursor != "" {
+		before, err := strconv.ParseInt(cursor, 10, 64)
+		if err != nil || before <= 0 {
+			return nil, "", ErrInvalidCursor
+		}
+		query += ` AND rowid < ?`
+		args = append(args, before)
+	}
+	query += ` ORDER BY rowid DESC LIMIT ?`
+	args = append(args, limit+1)
+
+	rows, err := c.reads.Query(query, args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to fetch notifications: %w", err)
+	}
+	defer rows.Close()
+
+	notifications := []models.Notification{}
+	var rowIDs []int64
+	for rows.Next() {
+		var notification models.Notification
+		var rowID int64
+		var data string
+		var readAt sql.NullTime
+		err := rows.Scan(&rowID, &notification.ID, &notification.UserID, &notification.Type, &notification.ActorID,
+			&notification.TargetType, &notification.TargetID, &data, &readAt, &notification.CreatedAt)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to scan notification row: %w", err)
+		}
+		notification.Data = json.RawMessage(data)
+		if readAt.Valid {
+			notification.ReadAt = &readAt.Time
+		}
+		notifications = append(notifications, notification)
+		rowIDs = append(rowIDs, rowID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	var next string
+	if len(notifications) > limit {
+		notifications = notifications[:limit]
+		next = strconv.FormatInt(rowIDs[limit-1], 10)
+	}
+	return notifications, next, nil
+}
+
+// CountUnreadNotifications returns how many of userID's notifications are
+// unread.
+func (c *SQLiteClient) CountUnreadNotifications(userID string) (int, error) {
+	var count int
+	err := c.reads.QueryRow("SELECT COUNT(*) FROM notifications WHERE user_id = ? AND read_at IS NULL", userID).Scan(&count)
+	return count, err
+}
+
+// MarkNotificationRead marks one of userID's notifications as read. It
+// returns sql.ErrNoRows if userID has no such notification.
+func (c *SQLiteClient) MarkNotificationRead(userID, notificationID string) error {
+	result, err := c.writes.Exec(`
+		UPDATE notifications SET read_at = COALESCE(read_at, ?)
+		WHERE id = ? AND user_id = ?
+	`, time.Now(), notificationID, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// MarkAllNotificationsRead marks every unread notification of userID as read
+// and returns how many there were.
+func (c *SQLiteClient) MarkAllNotificationsRead(userID string) (int64, error) {
+	result, err := c.writes.Exec("UPDATE notifications SET read_at = ? WHERE user_id = ? AND read_at IS NULL", time.Now(), userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetNotificationPreferences returns the preferences userID has set. Types
+// without a stored preference use the defaults.
+func (c *SQLiteClient) GetNotificationPreferences(userID string) ([]models.NotificationPreference, error) {
+	rows, err := c.reads.Query("SELECT type, in_app, email FROM notification_preferences WHERE user_id = ? ORDER BY type", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var preferences []models.NotificationPreference
+	for rows.Next() {
+		var preference models.NotificationPreference
+		if err := rows.Scan(&preference.Type, &preference.InApp, &preference.Email); err != nil {
+			return nil, err
+		}
+		preferences = append(preferences, preference)
+	}
+	return preferences, rows.Err()
+}
+
+// SetNotificationPreferences stores preferences for userID, replacing any
+// previous preference for the same types.
+func (c *SQLiteClient) SetNotificationPreferences(userID string, preferences []models.NotificationPreference) error {
+	tx, err := c.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO notification_preferences (user_id, type, in_app, email) VALUES (?, ?, ?, ?)
+		ON CONFLICT (user_id, type) DO UPDATE SET in_app = excluded.in_app, email = excluded.email
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, preference := range preferences {
+		if _, err := stmt.Exec(userID, preference.Type, preference.InApp, preference.Email); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetNotificationPreference returns userID's preference for notificationType,
+// or the default if they have not set one.
+func (c *SQLiteClient) GetNotificationPreference(userID, notificationType string) (models.NotificationPreference, error) {
+	preference := models.DefaultNotificationPreference(notificationType)
+	err := c.reads.QueryRow("SELECT in_app, email FROM notification_preferences WHERE user_id = ? AND type = ?",
+		userID, notificationType).Scan(&preference.InApp, &preference.Email)
+	if err != nil && err != sql.ErrNoRows {
+		return preference, err
+	}
+	return preference, nil
+}
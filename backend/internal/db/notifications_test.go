@@ -0,0 +1,51 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+)
+
+func TestNotificationReadState(t *testing.T) {
+	client := newTestDB(t)
+	alice := createUser(t, client, "alice")
+	bob := createUser(t, client, "bob")
+	var ids []string
+	for i := 0; i < 3; i++ {
+		n, err := client.CreateNotification(models.Notification{UserID: alice, Type: "share.granted", ActorID: bob})
+		if err != nil {
+			t.Fatalf("CreateNotification failed: %v", err)
+		}
+		ids = append(ids, n.ID)
+	}
+
+	if err := client.MarkNotificationRead(bob, ids[0]); err != sql.ErrNoRows {
+		t.Errorf("MarkNotificationRead() of another user's notification error = %v, want %v", err, sql.ErrNoRows)
+	}
+	if err := client.MarkNotificationRead(alice, ids[0]); err != nil {
+		t.Fatalf("MarkNotificationRead failed: %v", err)
+	}
+	if count, err := client.CountUnreadNotifications(alice); err != nil || count != 2 {
+		t.Errorf("CountUnreadNotifications() = %d, %v, want 2", count, err)
+	}
+
+	page, next, err := client.GetNotifications(alice, true, "", 1)
+	if err != nil || len(page) != 1 || page[0].ID != ids[2] || next == "" {
+		t.Fatalf("first unread page = %v, next %q, %v, want the newest notification and a cursor", page, next, err)
+	}
+	page, next, err = client.GetNotifications(alice, true, next, 1)
+	if err != nil || len(page) != 1 || page[0].ID != ids[1] || next != "" {
+		t.Errorf("last unread page = %v, next %q, %v, want the remaining unread notification", page, next, err)
+	}
+	if _, _, err := client.GetNotifications(alice, false, "nope", 1); err != ErrInvalidCursor {
+		t.Errorf("GetNotifications() with a bad cursor error = %v, want %v", err, ErrInvalidCursor)
+	}
+
+	if n, err := client.MarkAllNotificationsRead(alice); err != nil || n != 2 {
+		t.Errorf("MarkAllNotificationsRead() = %d, %v, want 2", n, err)
+	}
+	if count, _ := client.CountUnreadNotifications(alice); count != 0 {
+		t.Errorf("%d notifications still unread", count)
+	}
+}
@@ -0,0 +1,14 @@
+package db
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RecordPing records that clientID answered a keepalive ping.
+func (c *SQLiteClient) RecordPing(clientID string) error {
+	_, err := c.writes.Exec("INSERT INTO pings (id, client_id, timestamp) VALUES (?, ?, ?)",
+		uuid.New().String(), clientID, time.Now())
+	return err
+}
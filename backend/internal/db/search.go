@@ -0,0 +1,80 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+)
+
+// IndexFile refreshes the search terms of a file: its name, content type
+// and the names of the folders it sits in.
+func (c *SQLiteClient) IndexFile(fileID string) error {
+	_, err := c.writes.Exec(`
+		WITH RECURSIVE ancestors(id, name, parent_id) AS (
+			SELECT fo.id, fo.name, fo.parent_id FROM folders fo JOIN files f ON f.folder_id = fo.id WHERE f.id = ?
+			UNION ALL
+			SELECT fo.id, fo.name, fo.parent_id FROM folders fo JOIN ancestors a ON fo.id = a.parent_id
+		)
+		INSERT INTO file_search_index (file_id, user_id, terms, indexed_at)
+		SELECT f.id, f.user_id,
+			LOWER(f.name || ' ' || f.content_type || ' ' || COALESCE((SELECT GROUP_CONCAT(name, ' ') FROM ancestors), '')),
+			?
+		FROM files f WHERE f.id = ?
+		ON CONFLICT(file_id) DO UPDATE SET user_id = excluded.user_id, terms = excluded.terms, indexed_at = excluded.indexed_at
+	`, fileID, time.Now(), fileID)
+	if err != nil {
+		return fmt.Errorf("failed to index file: %w", err)
+	}
+	return nil
+}
+
+func (c *SQLiteClient) RemoveFileFromIndex(fileID string) error {
+	_, err := c.writes.Exec("DELETE FROM file_search_index WHERE file_id = ?", fileID)
+	return err
+}
+
+// SearchFiles returns files userID can access whose search terms contain
+// every word of query, most recently uploaded first.
+func (c *SQLiteClient) SearchFiles(userID, query string, limit int) ([]models.File, error) {
+	words := strings.Fields(strings.ToLower(query))
+	if len(words) == 0 {
+		return []models.File{}, nil
+	}
+
+	conditions := []string{
+		"f.id IN (SELECT id FROM files WHERE user_id = ? UNION SELECT file_id FROM shared_files WHERE shared_with = ?)",
+	}
+	args := []interface{}{userID, userID}
+	for _, word := range words {
+		conditions = append(conditions, "si.terms LIKE ? ESCAPE '\\'")
+		args = append(args, "%"+escapeLike(word)+"%")
+	}
+	args = append(args, limit)
+
+	rows, err := c.reads.Query(`
+		SELECT f.id, f.user_id, f.folder_id, f.key, f.name, f.content_type, f.size, f.uploaded_at, f.created_at, f.updated_at
+		FROM file_search_index si
+		JOIN files f ON f.id = si.file_id
+		WHERE `+strings.Join(conditions, " AND ")+`
+		ORDER BY f.uploaded_at DESC
+		LIMIT ?
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search files: %w", err)
+	}
+	defer rows.Close()
+
+	files := []models.File{}
+	for rows.Next() {
+		var f models.File
+		err := rows.Scan(&f.ID, &f.UserID, &f.FolderID, &f.Key, &f.Name, &f.ContentType, &f.Size,
+			&f.UploadedAt, &f.CreatedAt, &f.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan file row: %w", err)
+		}
+		files = append(files, f)
+	}
+	return files, rows.Err()
+}
@@ -0,0 +1,164 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+)
+
+// GetSmartCollectionFiles evaluates a smart collection's rule for viewerID,
+// newest uploads first. The rule matches files the owner can access, but
+// files shared with the owner are only listed for viewers they were shared
+// with too.
+func (c *SQLiteClient) GetSmartCollectionFiles(collection models.Collection, viewerID string) ([]models.CollectionFile, error) {
+	ownerID := collection.UserID.String()
+	where, args := collectionRuleFilter(*collection.Rule, ownerID)
+	rows, err := c.reads.Query(`
+		SELECT f.id, f.user_id, f.folder_id, f.key, f.name, f.content_type, f.size, f.uploaded_at, f.created_at, f.updated_at
+		FROM files f
+		WHERE `+where+`
+		  AND (f.user_id IN (?, ?) OR f.id IN (SELECT file_id FROM shared_files WHERE shared_with = ?))
+		ORDER BY f.uploaded_at DESC
+	`, append(args, ownerID, viewerID, viewerID)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate collection rule: %w", err)
+	}
+	defer rows.Close()
+
+	files := []models.CollectionFile{}
+	for rows.Next() {
+		var cf models.CollectionFile
+		err := rows.Scan(&cf.ID, &cf.UserID, &cf.FolderID, &cf.Key, &cf.Name, &cf.ContentType, &cf.Size,
+			&cf.UploadedAt, &cf.CreatedAt, &cf.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan collection file row: %w", err)
+		}
+		cf.Position = len(files) + 1
+		cf.AddedBy = cf.UserID.String()
+		cf.AddedAt = cf.UploadedAt
+		files = append(files, cf)
+	}
+	return files, rows.Err()
+}
+
+// GetSmartCollectionsMatchingFile returns the smart collections owned by
+// ownerID whose rules match fileID.
+func (c *SQLiteClient) GetSmartCollectionsMatchingFile(ownerID, fileID string) ([]models.Collection, error) {
+	rows, err := c.reads.Query(`
+		SELECT id, user_id, name, description, rule, created_at, updated_at
+		FROM collections WHERE user_id = ? AND rule IS NOT NULL
+	`, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch smart collections: %w", err)
+	}
+
+	var candidates []models.Collection
+	for rows.Next() {
+		var col models.Collection
+		var description, rule sql.NullString
+		if err := rows.Scan(&col.ID, &col.UserID, &col.Name, &description, &rule, &col.CreatedAt, &col.UpdatedAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan collection row: %w", err)
+		}
+		col.Description = description.String
+		if col.Rule, err = parseCollectionRule(rule); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		candidates = append(candidates, col)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var matching []models.Collection
+	for _, col := range candidates {
+		where, args := collectionRuleFilter(*col.Rule, ownerID)
+		var matches bool
+		err := c.reads.QueryRow("SELECT EXISTS (SELECT 1 FROM files f WHERE f.id = ? AND "+where+")",
+			append([]interface{}{fileID}, args...)...).Scan(&matches)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate collection rule: %w", err)
+		}
+		if matches {
+			matching = append(matching, col)
+		}
+	}
+	return matching, nil
+}
+
+// collectionRuleFilter translates a rule into a WHERE clause over files
+// aliased as f, restricted to files ownerID owns or has had shared with them.
+func collectionRuleFilter(rule models.CollectionRule, ownerID string) (string, []interface{}) {
+	conditions := []string{
+		"f.id IN (SELECT id FROM files WHERE user_id = ? UNION SELECT file_id FROM shared_files WHERE shared_with = ?)",
+	}
+	args := []interface{}{ownerID, ownerID}
+
+	if rule.ContentType != "" {
+		if strings.HasSuffix(rule.ContentType, "/") {
+			conditions = append(conditions, "f.content_type LIKE ? ESCAPE '\\'")
+			args = append(args, escapeLike(rule.ContentType)+"%")
+		} else {
+			conditions = append(conditions, "f.content_type = ?")
+			args = append(args, rule.ContentType)
+		}
+	}
+	if rule.Category != "" {
+		conditions = append(conditions, `EXISTS (
+			SELECT 1 FROM file_category_associations fca
+			JOIN file_categories fc ON fc.id = fca.category_id
+			WHERE fca.file_id = f.id AND fc.name = ? AND fc.user_id IS NULL)`)
+		args = append(args, rule.Category)
+	}
+	if rule.Tag != "" {
+		conditions = append(conditions, `EXISTS (
+			SELECT 1 FROM file_category_associations fca
+			JOIN file_categories fc ON fc.id = fca.category_id
+			WHERE fca.file_id = f.id AND fc.name = ? AND fc.user_id = ?)`)
+		args = append(args, rule.Tag, ownerID)
+	}
+	if rule.UploadedFrom != nil {
+		conditions = append(conditions, "f.uploaded_at >= ?")
+		args = append(args, *rule.UploadedFrom)
+	}
+	if rule.UploadedTo != nil {
+		conditions = append(conditions, "f.uploaded_at < ?")
+		args = append(args, *rule.UploadedTo)
+	}
+	if rule.OwnerID != "" {
+		conditions = append(conditions, "f.user_id = ?")
+		args = append(args, rule.OwnerID)
+	}
+	if rule.FolderID != "" {
+		conditions = append(conditions, `f.folder_id IN (
+			WITH RECURSIVE subtree(id) AS (
+				SELECT ?
+				UNION ALL
+				SELECT fo.id FROM folders fo JOIN subtree s ON fo.parent_id = s.id
+			)
+			SELECT id FROM subtree)`)
+		args = append(args, rule.FolderID)
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+func parseCollectionRule(rule sql.NullString) (*models.CollectionRule, error) {
+	if !rule.Valid || rule.String == "" {
+		return nil, nil
+	}
+	var r models.CollectionRule
+	if err := json.Unmarshal([]byte(rule.String), &r); err != nil {
+		return nil, fmt.Errorf("failed to parse collection rule: %w", err)
+	}
+	return &r, nil
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,151 @@
+package db
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+)
+
+// insertFile creates a file called name with the given type, upload time
+// and folder, which may be empty.
+func insertFile(t *testing.T, client *SQLiteClient, ownerID, name, contentType string, uploadedAt time.Time, folderID string) string {
+	t.Helper()
+	id := uuid.New().String()
+	_, err := client.DB.Exec(`
+		INSERT INTO files (id, user_id, folder_id, key, name, content_type, size, uploaded_at)
+		VALUES (?, ?, NULLIF(?, ''), ?, ?, ?, 1, ?)
+	`, id, ownerID, folderID, id, name, contentType, uploadedAt)
+	if err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	return id
+}
+
+func insertFolder(t *testing.T, client *SQLiteClient, ownerID, parentID string) string {
+	t.Helper()
+	id := uuid.New().String()
+	_, err := client.DB.Exec(`INSERT INTO folders (id, user_id, name, parent_id) VALUES (?, ?, 'Folder', NULLIF(?, ''))`, id, ownerID, parentID)
+	if err != nil {
+		t.Fatalf("failed to create folder: %v", err)
+	}
+	return id
+}
+
+func TestSmartCollectionRules(t *testing.T) {
+	client := newTestDB(t)
+	alice := createUser(t, client, "alice")
+	bob := createUser(t, client, "bob")
+	carol := createUser(t, client, "carol")
+	day := func(month, d int) time.Time { return time.Date(2026, time.Month(month), d, 12, 0, 0, 0, time.UTC) }
+
+	projects := insertFolder(t, client, alice, "")
+	drafts := insertFolder(t, client, alice, projects)
+	insertFile(t, client, alice, "photo.png", "image/png", day(1, 10), projects)
+	insertFile(t, client, alice, "plan.pdf", "application/pdf", day(2, 10), drafts)
+	insertFile(t, client, alice, "scan.jpg", "image/jpeg", day(3, 10), "")
+	shared := insertFile(t, client, bob, "bob.png", "image/png", day(4, 10), "")
+	insertFile(t, client, bob, "private.png", "image/png", day(5, 10), "")
+	if _, err := client.ShareFileWithFriends(shared, bob, []string{alice}); err != nil {
+		t.Fatalf("failed to share file: %v", err)
+	}
+
+	urgent, err := client.CreateTag(models.Tag{UserID: alice, Name: "urgent"})
+	if err != nil {
+		t.Fatalf("CreateTag failed: %v", err)
+	}
+	bobsUrgent, err := client.CreateTag(models.Tag{UserID: bob, Name: "urgent"})
+	if err != nil {
+		t.Fatalf("CreateTag failed: %v", err)
+	}
+	photo := fileNamed(t, client, alice, "photo.png")
+	scan := fileNamed(t, client, alice, "scan.jpg")
+	if err := client.TagFiles(alice, []string{urgent.ID}, []string{photo}, "manual"); err != nil {
+		t.Fatalf("TagFiles failed: %v", err)
+	}
+	if err := client.TagFiles(bob, []string{bobsUrgent.ID}, []string{scan}, "manual"); err != nil {
+		t.Fatalf("TagFiles failed: %v", err)
+	}
+
+	from, to := day(2, 1), day(3, 31)
+	tests := []struct {
+		name   string
+		rule   models.CollectionRule
+		viewer string
+		want   []string
+	}{
+		{"content type prefix", models.CollectionRule{ContentType: "image/"}, alice, []string{"bob.png", "scan.jpg", "photo.png"}},
+		{"exact content type", models.CollectionRule{ContentType: "application/pdf"}, alice, []string{"plan.pdf"}},
+		{"folder and its subfolders", models.CollectionRule{FolderID: projects}, alice, []string{"plan.pdf", "photo.png"}},
+		{"upload window", models.CollectionRule{UploadedFrom: &from, UploadedTo: &to}, alice, []string{"scan.jpg", "plan.pdf"}},
+		{"owner", models.CollectionRule{OwnerID: bob}, alice, []string{"bob.png"}},
+		{"owner's tag only", models.CollectionRule{Tag: "urgent"}, alice, []string{"photo.png"}},
+		{"combined", models.CollectionRule{ContentType: "image/", FolderID: projects}, alice, []string{"photo.png"}},
+		{"member without the share", models.CollectionRule{ContentType: "image/"}, carol, []string{"scan.jpg", "photo.png"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := tt.rule
+			collection := models.Collection{UserID: uuid.MustParse(alice), Rule: &rule}
+			files, err := client.GetSmartCollectionFiles(collection, tt.viewer)
+			if err != nil {
+				t.Fatalf("GetSmartCollectionFiles failed: %v", err)
+			}
+			got := []string{}
+			for _, f := range files {
+				got = append(got, f.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("files = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetSmartCollectionsMatchingFile(t *testing.T) {
+	client := newTestDB(t)
+	alice := createUser(t, client, "alice")
+	photo := insertFile(t, client, alice, "photo.png", "image/png", time.Now(), "")
+
+	rules := map[string]*models.CollectionRule{
+		"Images":    {ContentType: "image/"},
+		"Documents": {ContentType: "application/pdf"},
+		"Manual":    nil,
+	}
+	for name, rule := range rules {
+		var value interface{}
+		if rule != nil {
+			value = *rule
+		}
+		_, err := client.DB.Exec(`INSERT INTO collections (id, user_id, name, rule) VALUES (?, ?, ?, ?)`,
+			uuid.New().String(), alice, name, value)
+		if err != nil {
+			t.Fatalf("failed to create collection: %v", err)
+		}
+	}
+
+	matching, err := client.GetSmartCollectionsMatchingFile(alice, photo)
+	if err != nil {
+		t.Fatalf("GetSmartCollectionsMatchingFile failed: %v", err)
+	}
+	var names []string
+	for _, c := range matching {
+		names = append(names, c.Name)
+	}
+	sort.Strings(names)
+	if want := []string{"Images"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("matching collections = %v, want %v", names, want)
+	}
+}
+
+func fileNamed(t *testing.T, client *SQLiteClient, ownerID, name string) string {
+	t.Helper()
+	var id string
+	if err := client.DB.QueryRow(`SELECT id FROM files WHERE user_id = ? AND name = ?`, ownerID, name).Scan(&id); err != nil {
+		t.Fatalf("failed to find file %s: %v", name, err)
+	}
+	return id
+}
@@ -1,8 +1,12 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"log"
+	"strconv"
 	"strings"
 	"time"
 
@@ -11,36 +15,313 @@ import (
 	"github.com/saint0x/file-storage-app/backend/internal/models"
 )
 
+// SQLiteClient is the application's database. Writes go through a single
+// connection, the embedded DB, so they queue in Go instead of failing with
+// "database is locked"; reads use a separate pool.
 type SQLiteClient struct {
 	*sql.DB
+	reader *sql.DB
+	writes *stmtCache
+	reads  *stmtCache
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// SQLiteOptions tunes how the database is opened and maintained.
+type SQLiteOptions struct {
+	// JournalMode is the journal_mode pragma. WAL lets reads run while a
+	// write is in progress.
+	JournalMode string
+	// BusyTimeout is how long a connection waits for a lock before giving
+	// up with "database is locked".
+	BusyTimeout time.Duration
+	// ForeignKeys enforces foreign key constraints.
+	ForeignKeys bool
+	// ReadConnections is the size of the read pool. Zero means no limit.
+	ReadConnections int
+	// StatementCacheSize is how many prepared statements each pool keeps.
+	// Zero disables caching.
+	StatementCacheSize int
+	// OptimizeInterval is how often PRAGMA optimize runs. Zero disables it.
+	OptimizeInterval time.Duration
+	// VacuumInterval is how often an incremental vacuum returns up to
+	// VacuumPages free pages, or all of them if VacuumPages is zero, to the
+	// filesystem. Zero disables it. Enabling it on an existing database
+	// without auto-vacuum rewrites the file once, on the next start.
+	VacuumInterval time.Duration
+	VacuumPages    int
 }
 
+// DefaultSQLiteOptions returns the options NewSQLiteClient uses.
+func DefaultSQLiteOptions() SQLiteOptions {
+	return SQLiteOptions{
+		JournalMode:        "WAL",
+		BusyTimeout:        5 * time.Second,
+		ForeignKeys:        true,
+		ReadConnections:    4,
+		StatementCacheSize: 128,
+		OptimizeInterval:   time.Hour,
+		VacuumInterval:     time.Hour,
+		VacuumPages:        1000,
+	}
+}
+
+// NewSQLiteClient opens the database at dbPath with DefaultSQLiteOptions. It
+// does not create or migrate the schema; see Migrator.
 func NewSQLiteClient(dbPath string) (*SQLiteClient, error) {
-	db, err := sql.Open("sqlite3", dbPath)
+	return NewSQLiteClientWithOptions(dbPath, DefaultSQLiteOptions())
+}
+
+// NewSQLiteClientWithOptions opens the database at dbPath with options.
+func NewSQLiteClientWithOptions(dbPath string, options SQLiteOptions) (*SQLiteClient, error) {
+	writer, err := openSQLite(dbPath, options, "_txlock=immediate")
+	if err != nil {
+		return nil, err
+	}
+	writer.SetMaxOpenConns(1)
+
+	if options.VacuumInterval > 0 {
+		if _, err := enableIncrementalVacuum(writer); err != nil {
+			writer.Close()
+			return nil, err
+		}
+	}
+
+	// Every connection to an in-memory database is a database of its own,
+	// so reads have to share the writer.
+	reader := writer
+	if !isMemoryDB(dbPath) {
+		reader, err = openSQLite(dbPath, options, "_query_only=1")
+		if err != nil {
+			writer.Close()
+			return nil, err
+		}
+		reader.SetMaxOpenConns(options.ReadConnections)
+		reader.SetMaxIdleConns(options.ReadConnections)
+	}
+
+	c := &SQLiteClient{
+		DB:     writer,
+		reader: reader,
+		writes: newStmtCache(writer, options.StatementCacheSize),
+		reads:  newStmtCache(reader, options.StatementCacheSize),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go c.maintain(options)
+	return c, nil
+}
+
+// Reader returns the read pool, for packages that query the database
+// directly. Writes must go through the embedded DB.
+func (c *SQLiteClient) Reader() *sql.DB {
+	return c.reader
+}
+
+// openSQLite opens dbPath with options applied to every connection, along
+// with any extra connection parameters.
+func openSQLite(dbPath string, options SQLiteOptions, extra ...string) (*sql.DB, error) {
+	params := append([]string{
+		"_busy_timeout=" + strconv.FormatInt(options.BusyTimeout.Milliseconds(), 10),
+		"_foreign_keys=" + strconv.FormatBool(options.ForeignKeys),
+	}, extra...)
+	if options.JournalMode != "" {
+		params = append(params, "_journal_mode="+options.JournalMode)
+	}
+	separator := "?"
+	if strings.Contains(dbPath, "?") {
+		separator = "&"
+	}
+
+	db, err := sql.Open("sqlite3", dbPath+separator+strings.Join(params, "&"))
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %v", err)
 	}
 
 	if err := db.Ping(); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to ping database: %v", err)
 	}
 
-	if err := initSchema(db); err != nil {
-		return nil, fmt.Errorf("failed to initialize schema: %v", err)
+	return db, nil
+}
+
+// ErrForeignKeyViolation is returned when a transaction run without foreign
+// key enforcement would leave rows referring to ones that do not exist.
+var ErrForeignKeyViolation = errors.New("foreign key constraint failed")
+
+// checkedTx is a transaction run without foreign key enforcement, which
+// checks the constraints itself before it commits.
+type checkedTx struct {
+	*sql.Tx
+}
+
+// Commit commits the transaction if PRAGMA foreign_key_check finds no
+// violations, and rolls it back otherwise.
+func (tx checkedTx) Commit() error {
+	if err := tx.checkForeignKeys(); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Tx.Commit()
+}
+
+func (tx checkedTx) checkForeignKeys() error {
+	rows, err := tx.Query(`PRAGMA foreign_key_check`)
+	if err != nil {
+		return fmt.Errorf("failed to check foreign keys: %w", err)
 	}
+	defer rows.Close()
 
-	return &SQLiteClient{DB: db}, nil
+	if rows.Next() {
+		var table, parent string
+		var rowID sql.NullInt64
+		var fkID int
+		if err := rows.Scan(&table, &rowID, &parent, &fkID); err != nil {
+			return fmt.Errorf("failed to check foreign keys: %w", err)
+		}
+		return fmt.Errorf("%w: %s row %d refers to a missing %s row", ErrForeignKeyViolation, table, rowID.Int64, parent)
+	}
+	return rows.Err()
 }
 
-func initSchema(db *sql.DB) error {
-	_, err := db.Exec(`
-		CREATE TABLE IF NOT EXISTS users (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			name TEXT NOT NULL,
-			email TEXT UNIQUE NOT NULL
-		)
-	`)
-	return err
+// beginWithoutForeignKeys starts a transaction on a connection with foreign
+// key enforcement switched off, which SQLite only allows outside a
+// transaction. restore switches it back on and releases the connection, and
+// must be called once the transaction is over. Rows left dangling are
+// reported by Commit, which then rolls the transaction back.
+func beginWithoutForeignKeys(db *sql.DB) (tx checkedTx, restore func(), err error) {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return checkedTx{}, nil, err
+	}
+	var enforced bool
+	if err := conn.QueryRowContext(ctx, `PRAGMA foreign_keys`).Scan(&enforced); err != nil {
+		conn.Close()
+		return checkedTx{}, nil, err
+	}
+	if enforced {
+		if _, err := conn.ExecContext(ctx, `PRAGMA foreign_keys = OFF`); err != nil {
+			conn.Close()
+			return checkedTx{}, nil, err
+		}
+	}
+	restore = func() {
+		if enforced {
+			conn.ExecContext(ctx, `PRAGMA foreign_keys = ON`)
+		}
+		conn.Close()
+	}
+
+	sqlTx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		restore()
+		return checkedTx{}, nil, err
+	}
+	return checkedTx{sqlTx}, restore, nil
+}
+
+func isMemoryDB(dbPath string) bool {
+	return dbPath == "" || strings.HasPrefix(dbPath, ":memory:") || strings.Contains(dbPath, "mode=memory")
+}
+
+// auto_vacuum modes, as PRAGMA auto_vacuum reports them.
+const (
+	autoVacuumFull        = 1
+	autoVacuumIncremental = 2
+)
+
+// enableIncrementalVacuum switches db to incremental auto-vacuum and reports
+// whether that took a VACUUM. A database with full auto-vacuum switches
+// straight away; one without only does once it has been vacuumed, which
+// rewrites the whole file while holding the write lock. That happens on the
+// first start after vacuuming is enabled, and not again once the mode has
+// changed.
+func enableIncrementalVacuum(db *sql.DB) (bool, error) {
+	var mode int
+	if err := db.QueryRow(`PRAGMA auto_vacuum`).Scan(&mode); err != nil {
+		return false, fmt.Errorf("failed to read auto_vacuum: %v", err)
+	}
+	if mode == autoVacuumIncremental {
+		return false, nil
+	}
+	if _, err := db.Exec(`PRAGMA auto_vacuum = INCREMENTAL`); err != nil {
+		return false, fmt.Errorf("failed to enable incremental vacuum: %v", err)
+	}
+	if mode == autoVacuumFull {
+		return false, nil
+	}
+
+	var tables int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master`).Scan(&tables); err != nil {
+		return false, fmt.Errorf("failed to read schema: %v", err)
+	}
+	if tables > 0 {
+		log.Printf("Rebuilding database for incremental vacuum; this only happens once")
+	}
+	if _, err := db.Exec(`VACUUM`); err != nil {
+		return false, fmt.Errorf("failed to enable incremental vacuum: %v", err)
+	}
+	if err := db.QueryRow(`PRAGMA auto_vacuum`).Scan(&mode); err != nil {
+		return true, fmt.Errorf("failed to read auto_vacuum: %v", err)
+	}
+	if mode != autoVacuumIncremental {
+		return true, fmt.Errorf("failed to enable incremental vacuum: auto_vacuum is still %d", mode)
+	}
+	return true, nil
+}
+
+// maintain runs PRAGMA optimize and incremental vacuums until Close.
+func (c *SQLiteClient) maintain(options SQLiteOptions) {
+	defer close(c.done)
+
+	ticker := func(interval time.Duration) <-chan time.Time {
+		if interval <= 0 {
+			return nil
+		}
+		t := time.NewTicker(interval)
+		go func() {
+			<-c.stop
+			t.Stop()
+		}()
+		return t.C
+	}
+	optimize := ticker(options.OptimizeInterval)
+	vacuum := ticker(options.VacuumInterval)
+
+	for {
+		select {
+		case <-optimize:
+			if _, err := c.DB.Exec(`PRAGMA optimize`); err != nil {
+				log.Printf("Error optimizing database: %v", err)
+			}
+		case <-vacuum:
+			if _, err := c.DB.Exec(`PRAGMA incremental_vacuum(` + strconv.Itoa(options.VacuumPages) + `)`); err != nil {
+				log.Printf("Error vacuuming database: %v", err)
+			}
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+// Close stops maintenance, runs a last PRAGMA optimize and closes both
+// pools.
+func (c *SQLiteClient) Close() error {
+	close(c.stop)
+	<-c.done
+
+	c.reads.close()
+	c.writes.close()
+	if _, err := c.DB.Exec(`PRAGMA optimize`); err != nil {
+		log.Printf("Error optimizing database: %v", err)
+	}
+	if c.reader != c.DB {
+		c.reader.Close()
+	}
+	return c.DB.Close()
 }
 
 func (c *SQLiteClient) GetFilesByIDs(fileIDs []string) ([]models.File, error) {
@@ -51,7 +332,7 @@ func (c *SQLiteClient) GetFilesByIDs(fileIDs []string) ([]models.File, error) {
 		args[i] = id
 	}
 
-	rows, err := c.DB.Query(query, args...)
+	rows, err := c.reads.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -78,7 +359,7 @@ func (c *SQLiteClient) CreateFolder(folder models.Folder) (string, error) {
 	folder.CreatedAt = time.Now()
 	folder.UpdatedAt = time.Now()
 
-	_, err := c.DB.Exec(`
+	_, err := c.writes.Exec(`
 		INSERT INTO folders (id, user_id, name, description, parent_id, created_at, updated_at)
 		VALUES (?, ?, ?, ?, ?, ?, ?)
 	`, folder.ID, folder.UserID, folder.Name, folder.Description, folder.ParentID, folder.CreatedAt, folder.UpdatedAt)
@@ -91,14 +372,15 @@ func (c *SQLiteClient) CreateFolder(folder models.Folder) (string, error) {
 }
 
 func (c *SQLiteClient) UpdateFileFolder(fileName string, folderID string) error {
-	_, err := c.DB.Exec("UPDATE files SET folder_id = ? WHERE name = ?", folderID, fileName)
+	_, err := c.writes.Exec("UPDATE files SET folder_id = ? WHERE name = ?", folderID, fileName)
 	return err
 }
 
 // Add these methods to the SQLiteClient struct
 
-func (c *SQLiteClient) GetFileCategories() ([]string, error) {
-	rows, err := c.DB.Query("SELECT name FROM file_categories")
+// GetFileCategories returns the global categories plus userID's own tags.
+func (c *SQLiteClient) GetFileCategories(userID string) ([]string, error) {
+	rows, err := c.reads.Query("SELECT name FROM file_categories WHERE user_id IS NULL OR user_id = ? ORDER BY name", userID)
 	if err != nil {
 		return nil, err
 	}
@@ -115,15 +397,16 @@ func (c *SQLiteClient) GetFileCategories() ([]string, error) {
 	return categories, nil
 }
 
-func (c *SQLiteClient) GetFilesByCategory(categoryName string) ([]models.File, error) {
+func (c *SQLiteClient) GetFilesByCategory(userID, categoryName string) ([]models.File, error) {
 	query := `
-		SELECT f.id, f.user_id, f.name, f.content_type
+		SELECT DISTINCT f.id, f.user_id, f.name, f.content_type
 		FROM files f
 		JOIN file_category_associations fca ON f.id = fca.file_id
 		JOIN file_categories fc ON fca.category_id = fc.id
-		WHERE fc.name = ?
+		WHERE fc.name = ? AND (fc.user_id IS NULL OR fc.user_id = ?)
+		  AND f.id IN (SELECT id FROM files WHERE user_id = ? UNION SELECT file_id FROM shared_files WHERE shared_with = ?)
 	`
-	rows, err := c.DB.Query(query, categoryName)
+	rows, err := c.reads.Query(query, categoryName, userID, userID, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -156,7 +439,7 @@ func (c *SQLiteClient) GetFileDetails(fileID string) (models.FileDetails, error)
 
 	var details models.FileDetails
 	var idStr, userIDStr string
-	err := c.DB.QueryRow(query, fileID).Scan(
+	err := c.reads.QueryRow(query, fileID).Scan(
 		&idStr, &userIDStr, &details.Name, &details.ContentType, &details.Key, &details.Size,
 		&details.UploadedAt, &details.CreatedAt, &details.UpdatedAt,
 		&details.CollectionName, &details.FolderName,
@@ -172,55 +455,37 @@ func (c *SQLiteClient) GetFileDetails(fileID string) (models.FileDetails, error)
 	return details, nil
 }
 
-func (c *SQLiteClient) ShareFileWithFriends(fileID string, friendIDs []string) error {
+// ShareFileWithFriends shares fileID on behalf of sharedBy and returns the
+// friends it was not already shared with.
+func (c *SQLiteClient) ShareFileWithFriends(fileID, sharedBy string, friendIDs []string) ([]string, error) {
 	tx, err := c.DB.Begin()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer tx.Rollback()
 
-	stmt, err := tx.Prepare("INSERT INTO shared_files (id, file_id, shared_by, shared_with) VALUES (?, ?, ?, ?)")
+	stmt, err := tx.Prepare(`
+		INSERT INTO shared_files (id, file_id, shared_by, shared_with)
+		SELECT ?, ?, ?, ?
+		WHERE NOT EXISTS (SELECT 1 FROM shared_files WHERE file_id = ? AND shared_with = ?)
+	`)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer stmt.Close()
 
+	var shared []string
 	for _, friendID := range friendIDs {
-		_, err := stmt.Exec(uuid.New().String(), fileID, fileID, friendID)
-		if err != nil {
-			return err
-		}
-	}
-
-	return tx.Commit()
-}
-
-func (c *SQLiteClient) GetSharedWithMeFiles(userID string) ([]models.File, error) {
-	query := `
-		SELECT f.id, f.user_id, f.name, f.content_type
-		FROM files f
-		JOIN shared_files sf ON f.id = sf.file_id
-		WHERE sf.shared_with = ?
-	`
-	rows, err := c.DB.Query(query, userID)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var files []models.File
-	for rows.Next() {
-		var file models.File
-		var idStr, userIDStr string
-		err := rows.Scan(&idStr, &userIDStr, &file.Name, &file.ContentType)
+		result, err := stmt.Exec(uuid.New().String(), fileID, sharedBy, friendID, fileID, friendID)
 		if err != nil {
 			return nil, err
 		}
-		file.ID, _ = uuid.Parse(idStr)
-		file.UserID, _ = uuid.Parse(userIDStr)
-		files = append(files, file)
+		if n, _ := result.RowsAffected(); n > 0 {
+			shared = append(shared, friendID)
+		}
 	}
-	return files, nil
+
+	return shared, tx.Commit()
 }
 
 func (c *SQLiteClient) GetOrganizedFileStructure(userID string) (models.FileStructure, error) {
@@ -239,7 +504,7 @@ func (c *SQLiteClient) GetOrganizedFileStructure(userID string) (models.FileStru
 		LEFT JOIN files f ON f.folder_id = ft.id
 		ORDER BY ft.level, ft.name, f.name
 	`
-	rows, err := c.DB.Query(query, userID)
+	rows, err := c.reads.Query(query, userID)
 	if err != nil {
 		return models.FileStructure{}, err
 	}
@@ -283,7 +548,7 @@ func (c *SQLiteClient) GetOrganizedFileStructure(userID string) (models.FileStru
 
 func (c *SQLiteClient) GetFriendContexts(friendID string) ([]string, error) {
 	query := "SELECT context FROM friend_contexts WHERE friend_id = ?"
-	rows, err := c.DB.Query(query, friendID)
+	rows, err := c.reads.Query(query, friendID)
 	if err != nil {
 		return nil, err
 	}
@@ -301,82 +566,32 @@ func (c *SQLiteClient) GetFriendContexts(friendID string) ([]string, error) {
 }
 
 func (c *SQLiteClient) AddFriendContext(userID, friendID, context string) error {
-	_, err := c.DB.Exec("INSERT INTO friend_contexts (id, user_id, friend_id, context) VALUES (?, ?, ?, ?)",
+	_, err := c.writes.Exec("INSERT INTO friend_contexts (id, user_id, friend_id, context) VALUES (?, ?, ?, ?)",
 		uuid.New().String(), userID, friendID, context)
 	return err
 }
 
 func (c *SQLiteClient) RemoveFriendContext(userID, friendID, context string) error {
-	_, err := c.DB.Exec("DELETE FROM friend_contexts WHERE user_id = ? AND friend_id = ? AND context = ?",
+	_, err := c.writes.Exec("DELETE FROM friend_contexts WHERE user_id = ? AND friend_id = ? AND context = ?",
 		userID, friendID, context)
 	return err
 }
 
 func (c *SQLiteClient) LikeFriend(userID, friendID string) error {
-	_, err := c.DB.Exec("INSERT INTO friend_likes (id, user_id, friend_id) VALUES (?, ?, ?)",
+	_, err := c.writes.Exec("INSERT INTO friend_likes (id, user_id, friend_id) VALUES (?, ?, ?)",
 		uuid.New().String(), userID, friendID)
 	return err
 }
 
 func (c *SQLiteClient) UnlikeFriend(userID, friendID string) error {
-	_, err := c.DB.Exec("DELETE FROM friend_likes WHERE user_id = ? AND friend_id = ?", userID, friendID)
-	return err
-}
-
-// Add this new struct to represent activity
-type Activity struct {
-	ID            string
-	UserID        string
-	ActionType    string
-	ActionDetails string
-	CreatedAt     time.Time
-}
-
-// Add this method to the SQLiteClient struct
-func (c *SQLiteClient) GetRecentActivity(userID string) ([]Activity, error) {
-	query := `
-		SELECT id, user_id, action_type, action_details, created_at
-		FROM activity_log
-		WHERE user_id = ?
-		ORDER BY created_at DESC
-		LIMIT 50
-	`
-	rows, err := c.DB.Query(query, userID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch recent activity: %w", err)
-	}
-	defer rows.Close()
-
-	var activities []Activity
-	for rows.Next() {
-		var activity Activity
-		err := rows.Scan(&activity.ID, &activity.UserID, &activity.ActionType, &activity.ActionDetails, &activity.CreatedAt)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan activity row: %w", err)
-		}
-		activities = append(activities, activity)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating activity rows: %w", err)
-	}
-
-	return activities, nil
-}
-
-// Update the CreateFile function to include the b2_file_id
-func (c *SQLiteClient) CreateFile(file models.File) error {
-	_, err := c.DB.Exec(`
-		INSERT INTO files (id, user_id, folder_id, collection_id, key, name, content_type, size, uploaded_at, created_at, updated_at, b2_file_id)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`, file.ID, file.UserID, file.FolderID, file.CollectionID, file.Key, file.Name, file.ContentType, file.Size, file.UploadedAt, file.CreatedAt, file.UpdatedAt, file.B2FileID)
+	_, err := c.writes.Exec("DELETE FROM friend_likes WHERE user_id = ? AND friend_id = ?", userID, friendID)
 	return err
 }
 
 // Update the GetFileByID function to include the b2_file_id
 func (c *SQLiteClient) GetFileByID(id string) (models.File, error) {
 	var file models.File
-	err := c.DB.QueryRow(`
+	err := c.reads.QueryRow(`
 		SELECT id, user_id, folder_id, collection_id, key, name, content_type, size, uploaded_at, created_at, updated_at, b2_file_id
 		FROM files WHERE id = ?
 	`, id).Scan(&file.ID, &file.UserID, &file.FolderID, &file.CollectionID, &file.Key, &file.Name, &file.ContentType, &file.Size, &file.UploadedAt, &file.CreatedAt, &file.UpdatedAt, &file.B2FileID)
@@ -0,0 +1,292 @@
+package db
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+)
+
+func newTestDB(t *testing.T) *SQLiteClient {
+	t.Helper()
+	return openTestDB(t, t.TempDir()+"/test.sqlite", DefaultSQLiteOptions())
+}
+
+// openTestDB opens and migrates the database at path with options, and
+// closes it when the test ends.
+func openTestDB(t *testing.T, path string, options SQLiteOptions) *SQLiteClient {
+	t.Helper()
+	client, err := NewSQLiteClientWithOptions(path, options)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	migrator, err := NewMigrator(client.DB)
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+	if _, err := migrator.Up(-1); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	return client
+}
+
+func createUser(t *testing.T, client *SQLiteClient, name string) string {
+	t.Helper()
+	id := uuid.New().String()
+	_, err := client.DB.Exec(`INSERT INTO users (id, email, username) VALUES (?, ?, ?)`, id, name+"@example.com", name)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	return id
+}
+
+func createFile(t *testing.T, client *SQLiteClient, ownerID string) string {
+	t.Helper()
+	id := uuid.New().String()
+	_, err := client.DB.Exec(`
+		INSERT INTO files (id, user_id, key, name, content_type, size, uploaded_at)
+		VALUES (?, ?, ?, 'report.pdf', 'application/pdf', 1, CURRENT_TIMESTAMP)
+	`, id, ownerID, id)
+	if err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	return id
+}
+
+func TestStmtCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	client := newTestDB(t)
+	cache := newStmtCache(client.reader, 2)
+	t.Cleanup(cache.close)
+
+	queries := []string{`SELECT 1`, `SELECT 2`, `SELECT 1`, `SELECT 3`}
+	for _, query := range queries {
+		var n int
+		if err := cache.QueryRow(query).Scan(&n); err != nil {
+			t.Fatalf("QueryRow(%q) failed: %v", query, err)
+		}
+	}
+
+	if n := cache.lru.Len(); n != 2 {
+		t.Fatalf("cache holds %d statements, want 2", n)
+	}
+	if _, ok := cache.stmts[`SELECT 2`]; ok {
+		t.Error("least recently used statement was not evicted")
+	}
+	for _, query := range []string{`SELECT 1`, `SELECT 3`} {
+		if _, ok := cache.stmts[query]; !ok {
+			t.Errorf("statement %q was evicted", query)
+		}
+	}
+}
+
+func TestStmtCacheKeepsEvictedStatementsOpenForRows(t *testing.T) {
+	client := newTestDB(t)
+	cache := newStmtCache(client.reader, 1)
+	t.Cleanup(cache.close)
+
+	rows, err := cache.Query(`SELECT 1 UNION ALL SELECT 2`)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	defer rows.Close()
+
+	// Evict the statement the rows were read from.
+	if _, err := cache.Exec(`SELECT 3`); err != nil {
+		t.Fatalf("Exec failed: %v", err)
+	}
+
+	var got []int
+	for rows.Next() {
+		var n int
+		if err := rows.Scan(&n); err != nil {
+			t.Fatalf("Scan failed: %v", err)
+		}
+		got = append(got, n)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("reading rows of an evicted statement failed: %v", err)
+	}
+	if len(got) != 2 {
+		t.Errorf("read %v, want [1 2]", got)
+	}
+}
+
+func TestBeginWithoutForeignKeysChecksBeforeCommit(t *testing.T) {
+	client := newTestDB(t)
+	userID := createUser(t, client, "alice")
+
+	tx, restore, err := beginWithoutForeignKeys(client.DB)
+	if err != nil {
+		t.Fatalf("failed to begin: %v", err)
+	}
+	if _, err := tx.Exec(`INSERT INTO folders (id, user_id, name) VALUES (?, ?, 'Orphans')`, uuid.New().String(), uuid.New().String()); err != nil {
+		t.Fatalf("failed to insert folder: %v", err)
+	}
+	if _, err := tx.Exec(`UPDATE users SET first_name = 'Alice' WHERE id = ?`, userID); err != nil {
+		t.Fatalf("failed to update user: %v", err)
+	}
+	err = tx.Commit()
+	restore()
+	if !errors.Is(err, ErrForeignKeyViolation) {
+		t.Fatalf("Commit() error = %v, want %v", err, ErrForeignKeyViolation)
+	}
+
+	var folders int
+	var firstName *string
+	if err := client.DB.QueryRow(`SELECT COUNT(*) FROM folders`).Scan(&folders); err != nil {
+		t.Fatalf("failed to count folders: %v", err)
+	}
+	if err := client.DB.QueryRow(`SELECT first_name FROM users WHERE id = ?`, userID).Scan(&firstName); err != nil {
+		t.Fatalf("failed to read user: %v", err)
+	}
+	if folders != 0 || firstName != nil {
+		t.Error("transaction with a dangling row was committed")
+	}
+
+	// Enforcement is back on for the connection once restored.
+	_, err = client.DB.Exec(`INSERT INTO folders (id, user_id, name) VALUES (?, ?, 'Orphans')`, uuid.New().String(), uuid.New().String())
+	if err == nil {
+		t.Error("foreign keys are not enforced after restore")
+	}
+}
+
+func TestMigrationsRollBack(t *testing.T) {
+	client := newTestDB(t)
+	migrator, err := NewMigrator(client.DB)
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+	down, err := migrator.Down(len(migrator.migrations))
+	if err != nil {
+		t.Fatalf("Down failed: %v", err)
+	}
+	if len(down) != len(migrator.migrations) {
+		t.Errorf("rolled back %d migrations, want %d", len(down), len(migrator.migrations))
+	}
+	if _, err := migrator.Up(-1); err != nil {
+		t.Fatalf("Up after Down failed: %v", err)
+	}
+}
+
+func TestEnableIncrementalVacuum(t *testing.T) {
+	options := DefaultSQLiteOptions()
+	options.VacuumInterval = 0
+	path := t.TempDir() + "/test.sqlite"
+
+	// A database created without auto-vacuum
+	openTestDB(t, path, options)
+
+	writer, err := openSQLite(path, options)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer writer.Close()
+
+	for i, want := range []bool{true, false} {
+		vacuumed, err := enableIncrementalVacuum(writer)
+		if err != nil {
+			t.Fatalf("enableIncrementalVacuum failed: %v", err)
+		}
+		if vacuumed != want {
+			t.Errorf("call %d vacuumed = %v, want %v", i+1, vacuumed, want)
+		}
+		var mode int
+		if err := writer.QueryRow(`PRAGMA auto_vacuum`).Scan(&mode); err != nil {
+			t.Fatalf("failed to read auto_vacuum: %v", err)
+		}
+		if mode != autoVacuumIncremental {
+			t.Errorf("auto_vacuum = %d, want %d", mode, autoVacuumIncremental)
+		}
+	}
+}
+
+func TestNewDatabaseUsesIncrementalVacuum(t *testing.T) {
+	client := newTestDB(t)
+	var mode int
+	if err := client.DB.QueryRow(`PRAGMA auto_vacuum`).Scan(&mode); err != nil {
+		t.Fatalf("failed to read auto_vacuum: %v", err)
+	}
+	if mode != autoVacuumIncremental {
+		t.Errorf("auto_vacuum = %d, want %d", mode, autoVacuumIncremental)
+	}
+}
+
+func TestRecordPing(t *testing.T) {
+	client := newTestDB(t)
+	if err := client.RecordPing("client-1"); err != nil {
+		t.Fatalf("RecordPing failed: %v", err)
+	}
+	var count int
+	if err := client.DB.QueryRow(`SELECT COUNT(*) FROM pings WHERE client_id = ?`, "client-1").Scan(&count); err != nil {
+		t.Fatalf("failed to count pings: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("recorded %d pings, want 1", count)
+	}
+}
+
+func TestDeleteUserData(t *testing.T) {
+	client := newTestDB(t)
+	alice := createUser(t, client, "alice")
+	bob := createUser(t, client, "bob")
+	fileID := createFile(t, client, bob)
+
+	question, err := client.CreateComment(models.Comment{TargetType: models.CommentTargetFile, TargetID: fileID, UserID: bob, Body: "Thoughts?"})
+	if err != nil {
+		t.Fatalf("failed to create comment: %v", err)
+	}
+	answer, err := client.CreateComment(models.Comment{TargetType: models.CommentTargetFile, TargetID: fileID, UserID: alice, ParentID: &question.ID, Body: "Looks good"})
+	if err != nil {
+		t.Fatalf("failed to create comment: %v", err)
+	}
+	if err := client.SetCommentResolved(question.ID, alice, true); err != nil {
+		t.Fatalf("failed to resolve comment: %v", err)
+	}
+	if _, err := client.CreatePersonalAccessToken(models.PersonalAccessToken{UserID: alice, Name: "script", Scopes: []models.TokenScope{models.ScopeFilesRead}}, "hash"); err != nil {
+		t.Fatalf("failed to create token: %v", err)
+	}
+
+	if err := client.DeleteUserData(alice); err != nil {
+		t.Fatalf("DeleteUserData failed: %v", err)
+	}
+
+	var tokens int
+	if err := client.DB.QueryRow(`SELECT COUNT(*) FROM personal_access_tokens WHERE user_id = ?`, alice).Scan(&tokens); err != nil {
+		t.Fatalf("failed to count tokens: %v", err)
+	}
+	if tokens != 0 {
+		t.Errorf("%d personal access tokens were left behind", tokens)
+	}
+
+	comments, err := client.GetComments(models.CommentTargetFile, fileID)
+	if err != nil {
+		t.Fatalf("GetComments failed: %v", err)
+	}
+	var placeholder *models.Comment
+	for i := range comments {
+		for j := range comments[i].Replies {
+			if comments[i].Replies[j].ID == answer.ID {
+				placeholder = &comments[i].Replies[j]
+			}
+		}
+		if comments[i].ID == answer.ID {
+			placeholder = &comments[i]
+		}
+	}
+	if placeholder == nil {
+		t.Fatal("reply by the deleted user was removed, want a placeholder")
+	}
+	if placeholder.UserID != "" || placeholder.Body != "" || placeholder.DeletedAt == nil {
+		t.Errorf("placeholder = %+v, want an empty deleted comment without an author", placeholder)
+	}
+	resolved, err := client.GetComment(question.ID)
+	if err != nil {
+		t.Fatalf("GetComment failed: %v", err)
+	}
+	if resolved.ResolvedBy != nil {
+		t.Errorf("comment is still resolved by the deleted user %s", *resolved.ResolvedBy)
+	}
+}
@@ -0,0 +1,116 @@
+package db
+
+import (
+	"container/list"
+	"database/sql"
+	"sync"
+)
+
+// stmtCache runs queries against a pool through prepared statements, so
+// SQLite parses each query once rather than on every call. It keeps the
+// size most recently used statements; an evicted statement is closed once
+// the calls using it return. Rows still open on it keep it alive until they
+// are closed.
+type stmtCache struct {
+	db    *sql.DB
+	size  int
+	mu    sync.Mutex
+	stmts map[string]*list.Element
+	// lru holds *cachedStmt, most recently used first.
+	lru *list.List
+}
+
+type cachedStmt struct {
+	query   string
+	stmt    *sql.Stmt
+	users   int
+	evicted bool
+}
+
+func newStmtCache(db *sql.DB, size int) *stmtCache {
+	return &stmtCache{db: db, size: size, stmts: map[string]*list.Element{}, lru: list.New()}
+}
+
+// acquire returns the cached statement for query, preparing it if needed,
+// or nil if it should run unprepared. A statement it returns must be
+// handed back to release.
+func (sc *stmtCache) acquire(query string) *cachedStmt {
+	if sc.size <= 0 {
+		return nil
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if elem, ok := sc.stmts[query]; ok {
+		sc.lru.MoveToFront(elem)
+		cs := elem.Value.(*cachedStmt)
+		cs.users++
+		return cs
+	}
+	// A query that fails to prepare runs unprepared, to report its error.
+	stmt, err := sc.db.Prepare(query)
+	if err != nil {
+		return nil
+	}
+	cs := &cachedStmt{query: query, stmt: stmt, users: 1}
+	sc.stmts[query] = sc.lru.PushFront(cs)
+	for sc.lru.Len() > sc.size {
+		sc.evict(sc.lru.Back())
+	}
+	return cs
+}
+
+// evict drops elem from the cache, closing its statement unless a call is
+// still using it.
+func (sc *stmtCache) evict(elem *list.Element) {
+	cs := sc.lru.Remove(elem).(*cachedStmt)
+	delete(sc.stmts, cs.query)
+	cs.evicted = true
+	if cs.users == 0 {
+		cs.stmt.Close()
+	}
+}
+
+func (sc *stmtCache) release(cs *cachedStmt) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	cs.users--
+	if cs.evicted && cs.users == 0 {
+		cs.stmt.Close()
+	}
+}
+
+func (sc *stmtCache) Exec(query string, args ...interface{}) (sql.Result, error) {
+	if cs := sc.acquire(query); cs != nil {
+		defer sc.release(cs)
+		return cs.stmt.Exec(args...)
+	}
+	return sc.db.Exec(query, args...)
+}
+
+func (sc *stmtCache) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	if cs := sc.acquire(query); cs != nil {
+		defer sc.release(cs)
+		return cs.stmt.Query(args...)
+	}
+	return sc.db.Query(query, args...)
+}
+
+func (sc *stmtCache) QueryRow(query string, args ...interface{}) *sql.Row {
+	if cs := sc.acquire(query); cs != nil {
+		defer sc.release(cs)
+		return cs.stmt.QueryRow(args...)
+	}
+	return sc.db.QueryRow(query, args...)
+}
+
+func (sc *stmtCache) close() {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	for sc.lru.Len() > 0 {
+		sc.evict(sc.lru.Back())
+	}
+}
@@ -0,0 +1,375 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+)
+
+// DefaultTagColor is used when a tag is created without a color.
+const DefaultTagColor = "#9ca3af"
+
+func (c *SQLiteClient) GetTags(userID string) ([]models.Tag, error) {
+	rows, err := c.reads.Query(`
+		SELECT fc.id, fc.user_id, fc.name, fc.color, COUNT(fca.file_id), fc.created_at, fc.updated_at
+		FROM file_categories fc
+		LEFT JOIN file_category_associations fca ON fca.category_id = fc.id
+		WHERE fc.user_id = ?
+		GROUP BY fc.id
+		ORDER BY fc.name
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := []models.Tag{}
+	for rows.Next() {
+		var t models.Tag
+		if err := rows.Scan(&t.ID, &t.UserID, &t.Name, &t.Color, &t.FileCount, &t.CreatedAt, &t.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan tag row: %w", err)
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
+
+func (c *SQLiteClient) GetTag(userID, tagID string) (models.Tag, error) {
+	var t models.Tag
+	err := c.reads.QueryRow(`
+		SELECT fc.id, fc.user_id, fc.name, fc.color, COUNT(fca.file_id), fc.created_at, fc.updated_at
+		FROM file_categories fc
+		LEFT JOIN file_category_associations fca ON fca.category_id = fc.id
+		WHERE fc.id = ? AND fc.user_id = ?
+		GROUP BY fc.id
+	`, tagID, userID).Scan(&t.ID, &t.UserID, &t.Name, &t.Color, &t.FileCount, &t.CreatedAt, &t.UpdatedAt)
+	return t, err
+}
+
+func (c *SQLiteClient) CreateTag(tag models.Tag) (models.Tag, error) {
+	tag.ID = uuid.New().String()
+	tag.CreatedAt = time.Now()
+	tag.UpdatedAt = tag.CreatedAt
+	if tag.Color == "" {
+		tag.Color = DefaultTagColor
+	}
+
+	_, err := c.writes.Exec(`
+		INSERT INTO file_categories (id, user_id, name, color, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, tag.ID, tag.UserID, tag.Name, tag.Color, tag.CreatedAt, tag.UpdatedAt)
+	if err != nil {
+		return models.Tag{}, err
+	}
+	return tag, nil
+}
+
+// GetOrCreateTag returns userID's tag with the given name, creating it if needed.
+func (c *SQLiteClient) GetOrCreateTag(userID, name string) (models.Tag, error) {
+	var t models.Tag
+	err := c.reads.QueryRow(`
+		SELECT id, user_id, name, color, created_at, updated_at
+		FROM file_categories WHERE user_id = ? AND name = ?
+	`, userID, name).Scan(&t.ID, &t.UserID, &t.Name, &t.Color, &t.CreatedAt, &t.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return c.CreateTag(models.Tag{UserID: userID, Name: name})
+	}
+	return t, err
+}
+
+// UpdateTag renames and/or recolors a tag. Empty fields are left unchanged.
+func (c *SQLiteClient) UpdateTag(userID, tagID, name, color string) error {
+	result, err := c.writes.Exec(`
+		UPDATE file_categories
+		SET name = COALESCE(NULLIF(?, ''), name), color = COALESCE(NULLIF(?, ''), color), updated_at = ?
+		WHERE id = ? AND user_id = ?
+	`, name, color, time.Now(), tagID, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (c *SQLiteClient) DeleteTag(userID, tagID string) error {
+	tx, err := c.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The tag's associations go after it.
+	if _, err := tx.Exec("PRAGMA defer_foreign_keys = ON"); err != nil {
+		return err
+	}
+	result, err := tx.Exec("DELETE FROM file_categories WHERE id = ? AND user_id = ?", tagID, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	if _, err := tx.Exec("DELETE FROM file_category_associations WHERE category_id = ?", tagID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ErrMergeIntoSource is returned when a tag is merged into itself.
+var ErrMergeIntoSource = errors.New("tag cannot be merged into itself")
+
+// MergeTags moves every file tagged with one of sourceIDs onto targetID and
+// deletes the source tags. All tags must belong to userID, and targetID may
+// not be one of the sources. Repeated source IDs are merged once.
+func (c *SQLiteClient) MergeTags(userID, targetID string, sourceIDs []string) error {
+	ids := []string{targetID}
+	seen := map[string]bool{targetID: true}
+	for _, sourceID := range sourceIDs {
+		if sourceID == targetID {
+			return ErrMergeIntoSource
+		}
+		if !seen[sourceID] {
+			seen[sourceID] = true
+			ids = append(ids, sourceID)
+		}
+	}
+	sourceIDs = ids[1:]
+
+	tx, err := c.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var owned int
+	err = tx.QueryRow("SELECT COUNT(*) FROM file_categories WHERE user_id = ? AND id IN ("+placeholders(len(ids))+")",
+		append([]interface{}{userID}, stringArgs(ids)...)...).Scan(&owned)
+	if err != nil {
+		return err
+	}
+	if owned != len(ids) {
+		return sql.ErrNoRows
+	}
+
+	for _, sourceID := range sourceIDs {
+		_, err := tx.Exec(`
+			INSERT OR IGNORE INTO file_category_associations (file_id, category_id, source)
+			SELECT file_id, ?, source FROM file_category_associations WHERE category_id = ?
+		`, targetID, sourceID)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM file_category_associations WHERE category_id = ?", sourceID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM file_categories WHERE id = ?", sourceID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// TagFiles applies every tag in tagIDs to every file in fileIDs. Tags that
+// do not belong to userID are ignored.
+func (c *SQLiteClient) TagFiles(userID string, tagIDs, fileIDs []string, source string) error {
+	tx, err := c.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT OR IGNORE INTO file_category_associations (file_id, category_id, source)
+		SELECT ?, id, ? FROM file_categories WHERE id = ? AND user_id = ?
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, tagID := range tagIDs {
+		for _, fileID := range fileIDs {
+			if _, err := stmt.Exec(fileID, source, tagID, userID); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UntagFiles removes every tag in tagIDs from every file in fileIDs.
+func (c *SQLiteClient) UntagFiles(userID string, tagIDs, fileIDs []string) error {
+	if len(tagIDs) == 0 || len(fileIDs) == 0 {
+		return nil
+	}
+	query := `
+		DELETE FROM file_category_associations
+		WHERE file_id IN (` + placeholders(len(fileIDs)) + `)
+		  AND category_id IN (SELECT id FROM file_categories WHERE user_id = ? AND id IN (` + placeholders(len(tagIDs)) + `))
+	`
+	args := stringArgs(fileIDs)
+	args = append(args, userID)
+	args = append(args, stringArgs(tagIDs)...)
+	_, err := c.writes.Exec(query, args...)
+	return err
+}
+
+// GetFilesByTag returns one page of the files carrying a tag, newest first,
+// together with the total number of tagged files. Files tagged while they
+// were shared with userID are left out once the share is revoked.
+func (c *SQLiteClient) GetFilesByTag(userID, tagID string, limit, offset int) ([]models.File, int, error) {
+	var total int
+	err := c.reads.QueryRow(`
+		SELECT COUNT(*)
+		FROM files f
+		JOIN file_category_associations fca ON fca.file_id = f.id
+		JOIN file_categories fc ON fc.id = fca.category_id
+		WHERE fc.id = ? AND fc.user_id = ?
+		  AND (f.user_id = ? OR f.id IN (SELECT file_id FROM shared_files WHERE shared_with = ?))
+	`, tagID, userID, userID, userID).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count tagged files: %w", err)
+	}
+
+	rows, err := c.reads.Query(`
+		SELECT f.id, f.user_id, f.folder_id, f.key, f.name, f.content_type, f.size, f.uploaded_at, f.created_at, f.updated_at
+		FROM files f
+		JOIN file_category_associations fca ON fca.file_id = f.id
+		JOIN file_categories fc ON fc.id = fca.category_id
+		WHERE fc.id = ? AND fc.user_id = ?
+		  AND (f.user_id = ? OR f.id IN (SELECT file_id FROM shared_files WHERE shared_with = ?))
+		ORDER BY f.uploaded_at DESC
+		LIMIT ? OFFSET ?
+	`, tagID, userID, userID, userID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch tagged files: %w", err)
+	}
+	defer rows.Close()
+
+	files := []models.File{}
+	for rows.Next() {
+		var f models.File
+		err := rows.Scan(&f.ID, &f.UserID, &f.FolderID, &f.Key, &f.Name, &f.ContentType, &f.Size, &f.UploadedAt, &f.CreatedAt, &f.UpdatedAt)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan tagged file row: %w", err)
+		}
+		files = append(files, f)
+	}
+	return files, total, rows.Err()
+}
+
+// CreateTagSuggestions records pending suggestions for a file. Names the user
+// already uses on the file or has previously been suggested are skipped.
+func (c *SQLiteClient) CreateTagSuggestions(userID, fileID string, names []string) ([]models.TagSuggestion, error) {
+	tx, err := c.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT OR IGNORE INTO tag_suggestions (id, file_id, user_id, name, status, created_at, updated_at)
+		SELECT ?, ?, ?, ?, 'pending', ?, ?
+		WHERE NOT EXISTS (
+			SELECT 1 FROM file_category_associations fca
+			JOIN file_categories fc ON fc.id = fca.category_id
+			WHERE fca.file_id = ? AND fc.user_id = ? AND fc.name = ?
+		)
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var suggestions []models.TagSuggestion
+	now := time.Now()
+	for _, name := range names {
+		s := models.TagSuggestion{
+			ID:        uuid.New().String(),
+			FileID:    fileID,
+			UserID:    userID,
+			Name:      name,
+			Status:    models.TagSuggestionPending,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		result, err := stmt.Exec(s.ID, s.FileID, s.UserID, s.Name, s.CreatedAt, s.UpdatedAt, fileID, userID, name)
+		if err != nil {
+			return nil, err
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			suggestions = append(suggestions, s)
+		}
+	}
+
+	return suggestions, tx.Commit()
+}
+
+func (c *SQLiteClient) GetTagSuggestions(userID, fileID string) ([]models.TagSuggestion, error) {
+	rows, err := c.reads.Query(`
+		SELECT id, file_id, user_id, name, status, created_at, updated_at
+		FROM tag_suggestions
+		WHERE user_id = ? AND file_id = ? AND status = 'pending'
+		ORDER BY created_at
+	`, userID, fileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch tag suggestions: %w", err)
+	}
+	defer rows.Close()
+
+	suggestions := []models.TagSuggestion{}
+	for rows.Next() {
+		var s models.TagSuggestion
+		if err := rows.Scan(&s.ID, &s.FileID, &s.UserID, &s.Name, &s.Status, &s.CreatedAt, &s.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan tag suggestion row: %w", err)
+		}
+		suggestions = append(suggestions, s)
+	}
+	return suggestions, rows.Err()
+}
+
+func (c *SQLiteClient) GetTagSuggestion(userID, suggestionID string) (models.TagSuggestion, error) {
+	var s models.TagSuggestion
+	err := c.reads.QueryRow(`
+		SELECT id, file_id, user_id, name, status, created_at, updated_at
+		FROM tag_suggestions WHERE id = ? AND user_id = ?
+	`, suggestionID, userID).Scan(&s.ID, &s.FileID, &s.UserID, &s.Name, &s.Status, &s.CreatedAt, &s.UpdatedAt)
+	return s, err
+}
+
+func (c *SQLiteClient) SetTagSuggestionStatus(userID, suggestionID string, status models.TagSuggestionStatus) error {
+	result, err := c.writes.Exec("UPDATE tag_suggestions SET status = ?, updated_at = ? WHERE id = ? AND user_id = ? AND status = 'pending'",
+		status, time.Now(), suggestionID, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func placeholders(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	return "?" + strings.Repeat(",?", n-1)
+}
+
+func stringArgs(values []string) []interface{} {
+	args := make([]interface{}, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return args
+}
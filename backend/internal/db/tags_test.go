@@ -0,0 +1,90 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+)
+
+func createTag(t *testing.T, client *SQLiteClient, userID, name string) string {
+	t.Helper()
+	tag, err := client.CreateTag(models.Tag{UserID: userID, Name: name})
+	if err != nil {
+		t.Fatalf("CreateTag failed: %v", err)
+	}
+	return tag.ID
+}
+
+func TestMergeTags(t *testing.T) {
+	client := newTestDB(t)
+	alice := createUser(t, client, "alice")
+	bob := createUser(t, client, "bob")
+	work := createTag(t, client, alice, "work")
+	job := createTag(t, client, alice, "job")
+	office := createTag(t, client, alice, "office")
+	bobsTag := createTag(t, client, bob, "work")
+	report := createFile(t, client, alice)
+	memo := createFile(t, client, alice)
+	if err := client.TagFiles(alice, []string{job}, []string{report, memo}, "user"); err != nil {
+		t.Fatalf("TagFiles failed: %v", err)
+	}
+	if err := client.TagFiles(alice, []string{office, work}, []string{memo}, "user"); err != nil {
+		t.Fatalf("TagFiles failed: %v", err)
+	}
+
+	if err := client.MergeTags(alice, work, []string{job, work}); err != ErrMergeIntoSource {
+		t.Errorf("MergeTags() into one of its sources error = %v, want %v", err, ErrMergeIntoSource)
+	}
+	if err := client.MergeTags(alice, work, []string{job, bobsTag}); err != sql.ErrNoRows {
+		t.Errorf("MergeTags() with another user's tag error = %v, want %v", err, sql.ErrNoRows)
+	}
+	if _, err := client.GetTag(alice, job); err != nil {
+		t.Fatalf("source tag was removed by a rejected merge: %v", err)
+	}
+
+	if err := client.MergeTags(alice, work, []string{job, office, job}); err != nil {
+		t.Fatalf("MergeTags() with a repeated source failed: %v", err)
+	}
+	for _, id := range []string{job, office} {
+		if _, err := client.GetTag(alice, id); err != sql.ErrNoRows {
+			t.Errorf("GetTag() for merged tag error = %v, want %v", err, sql.ErrNoRows)
+		}
+	}
+	files, total, err := client.GetFilesByTag(alice, work, 10, 0)
+	if err != nil {
+		t.Fatalf("GetFilesByTag failed: %v", err)
+	}
+	if total != 2 || len(files) != 2 {
+		t.Errorf("merged tag has %d files (total %d), want 2", len(files), total)
+	}
+}
+
+func TestGetFilesByTagFollowsShares(t *testing.T) {
+	client := newTestDB(t)
+	alice := createUser(t, client, "alice")
+	bob := createUser(t, client, "bob")
+	tag := createTag(t, client, alice, "inbox")
+	own := createFile(t, client, alice)
+	shared := createFile(t, client, bob)
+	if _, err := client.ShareFileWithFriends(shared, bob, []string{alice}); err != nil {
+		t.Fatalf("failed to share file: %v", err)
+	}
+	if err := client.TagFiles(alice, []string{tag}, []string{own, shared}, "user"); err != nil {
+		t.Fatalf("TagFiles failed: %v", err)
+	}
+
+	if _, total, err := client.GetFilesByTag(alice, tag, 10, 0); err != nil || total != 2 {
+		t.Fatalf("GetFilesByTag() total = %d, %v, want 2", total, err)
+	}
+	if _, err := client.UnshareFile(shared, []string{alice}); err != nil {
+		t.Fatalf("UnshareFile failed: %v", err)
+	}
+	files, total, err := client.GetFilesByTag(alice, tag, 10, 0)
+	if err != nil {
+		t.Fatalf("GetFilesByTag failed: %v", err)
+	}
+	if total != 1 || len(files) != 1 || files[0].ID.String() != own {
+		t.Errorf("GetFilesByTag() after the share was revoked = %v (total %d), want only alice's file", files, total)
+	}
+}
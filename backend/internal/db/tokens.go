@@ -0,0 +1,118 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+)
+
+const tokenColumns = `id, user_id, name, prefix, scopes, expires_at, last_used_at, revoked_at, created_at`
+
+// tokenTouchInterval limits how often a token's last use is written.
+const tokenTouchInterval = time.Minute
+
+// CreatePersonalAccessToken stores a new token under the hash of its secret
+// and returns it.
+func (c *SQLiteClient) CreatePersonalAccessToken(token models.PersonalAccessToken, tokenHash string) (models.PersonalAccessToken, error) {
+	token.ID = uuid.New().String()
+	token.CreatedAt = time.Now()
+	token.LastUsedAt = nil
+	token.RevokedAt = nil
+
+	_, err := c.writes.Exec(`
+		INSERT INTO personal_access_tokens (id, user_id, name, token_hash, prefix, scopes, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, token.ID, token.UserID, token.Name, tokenHash, token.Prefix, joinScopes(token.Scopes), token.ExpiresAt, token.CreatedAt)
+	if err != nil {
+		return models.PersonalAccessToken{}, fmt.Errorf("failed to create token: %w", err)
+	}
+	return token, nil
+}
+
+// GetPersonalAccessTokens returns userID's tokens, newest first.
+func (c *SQLiteClient) GetPersonalAccessTokens(userID string) ([]models.PersonalAccessToken, error) {
+	rows, err := c.reads.Query(`SELECT `+tokenColumns+` FROM personal_access_tokens WHERE user_id = ? ORDER BY created_at DESC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch tokens: %w", err)
+	}
+	defer rows.Close()
+
+	tokens := []models.PersonalAccessToken{}
+	for rows.Next() {
+		token, err := scanToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+// GetPersonalAccessTokenByHash returns the token whose secret hashes to
+// tokenHash, or sql.ErrNoRows.
+func (c *SQLiteClient) GetPersonalAccessTokenByHash(tokenHash string) (models.PersonalAccessToken, error) {
+	return scanToken(c.reads.QueryRow(`SELECT `+tokenColumns+` FROM personal_access_tokens WHERE token_hash = ?`, tokenHash))
+}
+
+// RevokePersonalAccessToken revokes one of userID's tokens, or returns
+// sql.ErrNoRows if userID has no such unrevoked token.
+func (c *SQLiteClient) RevokePersonalAccessToken(userID, tokenID string) error {
+	result, err := c.writes.Exec(`
+		UPDATE personal_access_tokens SET revoked_at = ? WHERE id = ? AND user_id = ? AND revoked_at IS NULL
+	`, time.Now(), tokenID, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// TouchPersonalAccessToken records that tokenID was just used. Uses within
+// a minute of the last recorded one are not written.
+func (c *SQLiteClient) TouchPersonalAccessToken(tokenID string) error {
+	now := time.Now()
+	_, err := c.writes.Exec(`
+		UPDATE personal_access_tokens SET last_used_at = ?
+		WHERE id = ? AND (last_used_at IS NULL OR last_used_at < ?)
+	`, now, tokenID, now.Add(-tokenTouchInterval))
+	return err
+}
+
+func scanToken(row rowScanner) (models.PersonalAccessToken, error) {
+	var token models.PersonalAccessToken
+	var scopes string
+	var expiresAt, lastUsedAt, revokedAt sql.NullTime
+	err := row.Scan(&token.ID, &token.UserID, &token.Name, &token.Prefix, &scopes, &expiresAt, &lastUsedAt, &revokedAt, &token.CreatedAt)
+	if err != nil {
+		return models.PersonalAccessToken{}, err
+	}
+	for _, scope := range strings.Split(scopes, ",") {
+		if scope != "" {
+			token.Scopes = append(token.Scopes, models.TokenScope(scope))
+		}
+	}
+	if expiresAt.Valid {
+		token.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		token.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Time
+	}
+	return token, nil
+}
+
+func joinScopes(scopes []models.TokenScope) string {
+	s := make([]string, len(scopes))
+	for i, scope := range scopes {
+		s[i] = string(scope)
+	}
+	return strings.Join(s, ",")
+}
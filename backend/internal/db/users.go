@@ -0,0 +1,16 @@
+package db
+
+// GetUsername returns the username of userID, or an empty string if the
+// user has none.
+func (c *SQLiteClient) GetUsername(userID string) (string, error) {
+	var username string
+	err := c.reads.QueryRow("SELECT COALESCE(username, '') FROM users WHERE id = ?", userID).Scan(&username)
+	return username, err
+}
+
+// GetUserEmail returns the email address of userID.
+func (c *SQLiteClient) GetUserEmail(userID string) (string, error) {
+	var email string
+	err := c.reads.QueryRow("SELECT email FROM users WHERE id = ?", userID).Scan(&email)
+	return email, err
+}
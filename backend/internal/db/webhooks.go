@@ -0,0 +1,298 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+)
+
+const webhookColumns = `id, user_id, url, secret, event_types, folder_id, collection_id, active, consecutive_failures, disabled_at, created_at, updated_at`
+
+// CreateWebhook stores a new active webhook and returns it.
+func (c *SQLiteClient) CreateWebhook(webhook models.Webhook) (models.Webhook, error) {
+	webhook.ID = uuid.New().String()
+	webhook.Active = true
+	webhook.ConsecutiveFailures = 0
+	webhook.DisabledAt = nil
+	webhook.CreatedAt = time.Now()
+	webhook.UpdatedAt = webhook.CreatedAt
+
+	_, err := c.writes.Exec(`
+		INSERT INTO webhooks (id, user_id, url, secret, event_types, folder_id, collection_id, active, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, 1, ?, ?)
+	`, webhook.ID, webhook.UserID, webhook.URL, webhook.Secret, strings.Join(webhook.EventTypes, ","),
+		webhook.FolderID, webhook.CollectionID, webhook.CreatedAt, webhook.UpdatedAt)
+	if err != nil {
+		return models.Webhook{}, fmt.Errorf("failed to create webhook: %w", err)
+	}
+	return webhook, nil
+}
+
+// GetWebhooks returns userID's webhooks, oldest first.
+func (c *SQLiteClient) GetWebhooks(userID string) ([]models.Webhook, error) {
+	return c.queryWebhooks(`SELECT `+webhookColumns+` FROM webhooks WHERE user_id = ? ORDER BY created_at`, userID)
+}
+
+// GetWebhook returns one of userID's webhooks, or sql.ErrNoRows if userID
+// has no such webhook.
+func (c *SQLiteClient) GetWebhook(userID, webhookID string) (models.Webhook, error) {
+	webhooks, err := c.queryWebhooks(`SELECT `+webhookColumns+` FROM webhooks WHERE id = ? AND user_id = ?`, webhookID, userID)
+	if err != nil {
+		return models.Webhook{}, err
+	}
+	if len(webhooks) == 0 {
+		return models.Webhook{}, sql.ErrNoRows
+	}
+	return webhooks[0], nil
+}
+
+// GetWebhookByID returns a webhook whoever owns it.
+func (c *SQLiteClient) GetWebhookByID(webhookID string) (models.Webhook, error) {
+	webhooks, err := c.queryWebhooks(`SELECT `+webhookColumns+` FROM webhooks WHERE id = ?`, webhookID)
+	if err != nil {
+		return models.Webhook{}, err
+	}
+	if len(webhooks) == 0 {
+		return models.Webhook{}, sql.ErrNoRows
+	}
+	return webhooks[0], nil
+}
+
+// GetActiveWebhooksForEvent returns the active webhooks subscribed to
+// eventType.
+func (c *SQLiteClient) GetActiveWebhooksForEvent(eventType string) ([]models.Webhook, error) {
+	return c.queryWebhooks(`SELECT `+webhookColumns+` FROM webhooks
+		WHERE active = 1 AND (',' || event_types || ',') LIKE ('%,' || ? || ',%')`, eventType)
+}
+
+func (c *SQLiteClient) queryWebhooks(query string, args ...interface{}) ([]models.Webhook, error) {
+	rows, err := c.reads.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	webhooks := []models.Webhook{}
+	for rows.Next() {
+		var webhook models.Webhook
+		var eventTypes string
+		var folderID, collectionID sql.NullString
+		var disabledAt sql.NullTime
+		err := rows.Scan(&webhook.ID, &webhook.UserID, &webhook.URL, &webhook.Secret, &eventTypes, &folderID, &collectionID,
+			&webhook.Active, &webhook.ConsecutiveFailures, &disabledAt, &webhook.CreatedAt, &webhook.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook row: %w", err)
+		}
+		webhook.EventTypes = strings.Split(eventTypes, ",")
+		if folderID.Valid {
+			webhook.FolderID = &folderID.String
+		}
+		if collectionID.Valid {
+			webhook.CollectionID = &collectionID.String
+		}
+		if disabledAt.Valid {
+			webhook.DisabledAt = &disabledAt.Time
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, rows.Err()
+}
+
+// UpdateWebhook saves a webhook's URL, event types, filters and whether it
+// is active. Reactivating a webhook clears its failure count.
+func (c *SQLiteClient) UpdateWebhook(webhook models.Webhook) error {
+	result, err := c.writes.Exec(`
+		UPDATE webhooks SET url = ?, event_types = ?, folder_id = ?, collection_id = ?, active = ?,
+			consecutive_failures = CASE WHEN ? AND active = 0 THEN 0 ELSE consecutive_failures END,
+			disabled_at = CASE WHEN ? THEN NULL ELSE disabled_at END,
+			updated_at = ?
+		WHERE id = ? AND user_id = ?
+	`, webhook.URL, strings.Join(webhook.EventTypes, ","), webhook.FolderID, webhook.CollectionID, webhook.Active,
+		webhook.Active, webhook.Active, time.Now(), webhook.ID, webhook.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteWebhook deletes one of userID's webhooks and its delivery log. It
+// returns sql.ErrNoRows if userID has no such webhook.
+func (c *SQLiteClient) DeleteWebhook(userID, webhookID string) error {
+	tx, err := c.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM webhooks WHERE id = ? AND user_id = ?", webhookID, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	if _, err := tx.Exec("DELETE FROM webhook_deliveries WHERE webhook_id = ?", webhookID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// EnqueueWebhookDelivery queues an event for a webhook. An event is only
+// queued once per webhook; inserted is false if it already was.
+func (c *SQLiteClient) EnqueueWebhookDelivery(delivery models.WebhookDelivery) (models.WebhookDelivery, bool, error) {
+	delivery.ID = uuid.New().String()
+	delivery.Status = models.WebhookDeliveryPending
+	delivery.CreatedAt = time.Now()
+	if delivery.NextAttemptAt.IsZero() {
+		delivery.NextAttemptAt = delivery.CreatedAt
+	}
+
+	result, err := c.writes.Exec(`
+		INSERT INTO webhook_deliveries (id, webhook_id, event_id, event_type, payload, status, next_attempt_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (webhook_id, event_id) DO NOTHING
+	`, delivery.ID, delivery.WebhookID, delivery.EventID, delivery.EventType, string(delivery.Payload),
+		delivery.Status, delivery.NextAttemptAt, delivery.CreatedAt)
+	if err != nil {
+		return models.WebhookDelivery{}, false, fmt.Errorf("failed to queue webhook delivery: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	return delivery, n > 0, nil
+}
+
+const webhookDeliveryColumns = `d.id, d.webhook_id, d.event_id, d.event_type, d.payload, d.status, d.attempts, d.next_attempt_at,
+	d.response_status, d.response_body, d.last_error, d.created_at, d.completed_at`
+
+// GetDueWebhookDeliveries returns up to limit pending deliveries to active
+// webhooks whose next attempt is due.
+func (c *SQLiteClient) GetDueWebhookDeliveries(now time.Time, limit int) ([]models.WebhookDelivery, error) {
+	return c.queryWebhookDeliveries(`SELECT `+webhookDeliveryColumns+`
+		FROM webhook_deliveries d
+		JOIN webhooks w ON w.id = d.webhook_id
+		WHERE d.status = ? AND d.next_attempt_at <= ? AND w.active = 1
+		ORDER BY d.next_attempt_at
+		LIMIT ?
+	`, models.WebhookDeliveryPending, now, limit)
+}
+
+// GetWebhookDeliveries returns the latest deliveries to a webhook, newest
+// first.
+func (c *SQLiteClient) GetWebhookDeliveries(webhookID string, limit int) ([]models.WebhookDelivery, error) {
+	return c.queryWebhookDeliveries(`SELECT `+webhookDeliveryColumns+`
+		FROM webhook_deliveries d
+		WHERE d.webhook_id = ?
+		ORDER BY d.created_at DESC, d.rowid DESC
+		LIMIT ?
+	`, webhookID, limit)
+}
+
+func (c *SQLiteClient) queryWebhookDeliveries(query string, args ...interface{}) ([]models.WebhookDelivery, error) {
+	rows, err := c.reads.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		var delivery models.WebhookDelivery
+		var payload string
+		var responseStatus sql.NullInt64
+		var responseBody, lastError sql.NullString
+		var completedAt sql.NullTime
+		err := rows.Scan(&delivery.ID, &delivery.WebhookID, &delivery.EventID, &delivery.EventType, &payload, &delivery.Status,
+			&delivery.Attempts, &delivery.NextAttemptAt, &responseStatus, &responseBody, &lastError, &delivery.CreatedAt, &completedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery row: %w", err)
+		}
+		delivery.Payload = json.RawMessage(payload)
+		if responseStatus.Valid {
+			status := int(responseStatus.Int64)
+			delivery.ResponseStatus = &status
+		}
+		delivery.ResponseBody = responseBody.String
+		delivery.LastError = lastError.String
+		if completedAt.Valid {
+			delivery.CompletedAt = &completedAt.Time
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// RecordWebhookAttempt saves the outcome of an attempt to deliver to a
+// webhook, whose Status, Attempts, NextAttemptAt, response and error the
+// caller has already updated. A success resets the webhook's failure count;
+// a failure increments it and disables the webhook once it reaches
+// maxFailures. disabled reports whether this attempt disabled the webhook.
+func (c *SQLiteClient) RecordWebhookAttempt(delivery models.WebhookDelivery, maxFailures int) (disabled bool, err error) {
+	tx, err := c.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if err := updateWebhookDelivery(tx, delivery); err != nil {
+		return false, err
+	}
+
+	if delivery.LastError == "" {
+		_, err = tx.Exec("UPDATE webhooks SET consecutive_failures = 0 WHERE id = ?", delivery.WebhookID)
+	} else {
+		var result sql.Result
+		_, err = tx.Exec("UPDATE webhooks SET consecutive_failures = consecutive_failures + 1 WHERE id = ?", delivery.WebhookID)
+		if err == nil {
+			result, err = tx.Exec(`
+				UPDATE webhooks SET active = 0, disabled_at = ?, updated_at = ?
+				WHERE id = ? AND active = 1 AND consecutive_failures >= ?
+			`, time.Now(), time.Now(), delivery.WebhookID, maxFailures)
+		}
+		if err == nil {
+			n, _ := result.RowsAffected()
+			disabled = n > 0
+		}
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to update webhook failure count: %w", err)
+	}
+	return disabled, tx.Commit()
+}
+
+// UpdateWebhookDelivery saves the outcome of a delivery attempt without
+// touching the webhook's failure count.
+func (c *SQLiteClient) UpdateWebhookDelivery(delivery models.WebhookDelivery) error {
+	return updateWebhookDelivery(c.DB, delivery)
+}
+
+func updateWebhookDelivery(e execer, delivery models.WebhookDelivery) error {
+	_, err := e.Exec(`
+		UPDATE webhook_deliveries SET status = ?, attempts = ?, next_attempt_at = ?, response_status = ?,
+			response_body = ?, last_error = ?, completed_at = ?
+		WHERE id = ?
+	`, delivery.Status, delivery.Attempts, delivery.NextAttemptAt, delivery.ResponseStatus,
+		delivery.ResponseBody, nullString(delivery.LastError), delivery.CompletedAt, delivery.ID)
+	if err != nil {
+		return fmt.Errorf("failed to record webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// FileInCollection reports whether fileID has been added to collectionID.
+func (c *SQLiteClient) FileInCollection(collectionID, fileID string) (bool, error) {
+	var exists bool
+	err := c.reads.QueryRow("SELECT EXISTS (SELECT 1 FROM collection_files WHERE collection_id = ? AND file_id = ?)",
+		collectionID, fileID).Scan(&exists)
+	return exists, err
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
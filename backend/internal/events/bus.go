@@ -0,0 +1,81 @@
+package events
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Handler reacts to a published event.
+type Handler func(ctx context.Context, event Event)
+
+type subscription struct {
+	types   map[Type]bool
+	handler Handler
+}
+
+// Bus delivers published events to its subscribers.
+type Bus struct {
+	mu            sync.RWMutex
+	subscriptions []subscription
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe registers handler for the given event types, or for every event
+// if none are given.
+func (b *Bus) Subscribe(handler Handler, types ...Type) {
+	sub := subscription{handler: handler}
+	if len(types) > 0 {
+		sub.types = make(map[Type]bool, len(types))
+		for _, t := range types {
+			sub.types[t] = true
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscriptions = append(b.subscriptions, sub)
+}
+
+// Publish stamps the event with an ID and time and hands it to each
+// matching subscriber in turn, in the order they subscribed. Handlers run
+// synchronously, so they see events in the order they were published; a
+// handler that panics is logged and does not affect the others.
+func (b *Bus) Publish(ctx context.Context, event Event) {
+	if event.ID == "" {
+		event.ID = uuid.New().String()
+	}
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+
+	// Handlers must not be cut short when the request that caused the event
+	// finishes.
+	ctx = context.WithoutCancel(ctx)
+
+	b.mu.RLock()
+	subscriptions := b.subscriptions
+	b.mu.RUnlock()
+
+	for _, sub := range subscriptions {
+		if sub.types != nil && !sub.types[event.Type] {
+			continue
+		}
+		deliver(ctx, sub.handler, event)
+	}
+}
+
+func deliver(ctx context.Context, handler Handler, event Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Event handler panicked on %s %s: %v", event.Type, event.ID, r)
+		}
+	}()
+	handler(ctx, event)
+}
@@ -0,0 +1,148 @@
+// Package events carries domain events from the handlers that perform
+// mutations to the subsystems that react to them, such as the websocket hub
+// and the search indexer.
+package events
+
+import (
+	"time"
+
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+)
+
+// Type identifies a kind of domain event.
+type Type string
+
+const (
+	FileCreated Type = "file.created"
+	FileUpdated Type = "file.updated"
+	FileMoved   Type = "file.moved"
+	FileDeleted Type = "file.deleted"
+
+	// Folders are only created, by AI organization; the API cannot rename,
+	// move or delete them, so there are no events for that yet.
+	FolderCreated Type = "folder.created"
+
+	ShareGranted Type = "share.granted"
+	ShareRevoked Type = "share.revoked"
+
+	FriendRequested Type = "friend.requested"
+	FriendAccepted  Type = "friend.accepted"
+	FriendBlocked   Type = "friend.blocked"
+	FriendRemoved   Type = "friend.removed"
+
+	CollectionCreated        Type = "collection.created"
+	CollectionUpdated        Type = "collection.updated"
+	CollectionDeleted        Type = "collection.deleted"
+	CollectionMemberAdded    Type = "collection.member_added"
+	CollectionMemberUpdated  Type = "collection.member_updated"
+	CollectionMemberRemoved  Type = "collection.member_removed"
+	CollectionFilesAdded     Type = "collection.files_added"
+	CollectionFilesRemoved   Type = "collection.files_removed"
+	CollectionFilesReordered Type = "collection.files_reordered"
+
+	CommentCreated  Type = "comment.created"
+	CommentUpdated  Type = "comment.updated"
+	CommentDeleted  Type = "comment.deleted"
+	CommentResolved Type = "comment.resolved"
+)
+
+// Types lists every event type.
+var Types = []Type{
+	FileCreated, FileUpdated, FileMoved, FileDeleted,
+	FolderCreated,
+	ShareGranted, ShareRevoked,
+	FriendRequested, FriendAccepted, FriendBlocked, FriendRemoved,
+	CollectionCreated, CollectionUpdated, CollectionDeleted,
+	CollectionMemberAdded, CollectionMemberUpdated, CollectionMemberRemoved,
+	CollectionFilesAdded, CollectionFilesRemoved, CollectionFilesReordered,
+	CommentCreated, CommentUpdated, CommentDeleted, CommentResolved,
+}
+
+// Valid reports whether t is a known event type.
+func (t Type) Valid() bool {
+	for _, known := range Types {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Event is something that happened to a file, folder, share, friendship,
+// collection or comment.
+type Event struct {
+	ID      string `json:"id"`
+	Type    Type   `json:"type"`
+	ActorID string `json:"actor_id"`
+
+	// Users who could see the subject of the event when it happened. It is
+	// captured by the publisher because the subject may no longer exist when
+	// the event is handled.
+	Audience []string `json:"audience"`
+
+	OccurredAt time.Time `json:"occurred_at"`
+
+	// One of the payload types below, matching Type.
+	Payload interface{} `json:"payload"`
+}
+
+// FilePayload accompanies FileCreated, FileUpdated and FileDeleted.
+type FilePayload struct {
+	File models.File `json:"file"`
+}
+
+// FileMovedPayload accompanies FileMoved. A nil folder ID is the root.
+type FileMovedPayload struct {
+	File         models.File `json:"file"`
+	FromFolderID *string     `json:"from_folder_id"`
+	ToFolderID   *string     `json:"to_folder_id"`
+}
+
+// FolderPayload accompanies FolderCreated.
+type FolderPayload struct {
+	Folder models.Folder `json:"folder"`
+}
+
+// SharePayload accompanies ShareGranted and ShareRevoked.
+type SharePayload struct {
+	FileID   string   `json:"file_id"`
+	FileName string   `json:"file_name"`
+	OwnerID  string   `json:"owner_id"`
+	UserIDs  []string `json:"user_ids"`
+}
+
+// FriendPayload accompanies the friend events. UserID sent the request.
+type FriendPayload struct {
+	FriendshipID string `json:"friendship_id"`
+	UserID       string `json:"user_id"`
+	FriendID     string `json:"friend_id"`
+	Status       string `json:"status"`
+}
+
+// CollectionPayload accompanies CollectionCreated, CollectionUpdated and
+// CollectionDeleted.
+type CollectionPayload struct {
+	Collection models.Collection `json:"collection"`
+}
+
+// CollectionMemberPayload accompanies CollectionMemberAdded,
+// CollectionMemberUpdated and CollectionMemberRemoved.
+type CollectionMemberPayload struct {
+	CollectionID string                `json:"collection_id"`
+	UserID       string                `json:"user_id"`
+	Role         models.CollectionRole `json:"role,omitempty"`
+}
+
+// CollectionFilesPayload accompanies CollectionFilesAdded,
+// CollectionFilesRemoved and CollectionFilesReordered.
+type CollectionFilesPayload struct {
+	CollectionID string   `json:"collection_id"`
+	FileIDs      []string `json:"file_ids"`
+}
+
+// CommentPayload accompanies the comment events. Mentioned lists users newly
+// mentioned by this change.
+type CommentPayload struct {
+	Comment   models.Comment `json:"comment"`
+	Mentioned []string       `json:"mentioned,omitempty"`
+}
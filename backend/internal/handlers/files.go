@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/saint0x/file-storage-app/backend/internal/services/auth"
 	"github.com/saint0x/file-storage-app/backend/internal/services/storage"
 	"github.com/saint0x/file-storage-app/backend/internal/services/websocket"
 )
@@ -29,8 +30,10 @@ func UploadFile(b2Service *storage.B2Service, hub *websocket.Hub) http.HandlerFu
 		}
 
 		// After successful upload
-		fileInfo := map[string]string{"key": key, "name": header.Filename}
-		hub.BroadcastUpdate("file_uploaded", fileInfo)
+		if userID, err := auth.GetUserIDFromContext(r.Context()); err == nil {
+			fileInfo := map[string]string{"key": key, "name": header.Filename}
+			hub.SendToUser(userID, websocket.FileUploaded, fileInfo)
+		}
 
 		w.WriteHeader(http.StatusCreated)
 		json.NewEncoder(w).Encode(map[string]string{"key": key})
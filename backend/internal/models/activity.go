@@ -0,0 +1,31 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Activity is an entry in the activity log: ActorID performed Verb (an event
+// type such as "file.created") on a target.
+type Activity struct {
+	ID         string          `json:"id"`
+	ActorID    string          `json:"actor_id"`
+	Verb       string          `json:"verb"`
+	TargetType string          `json:"target_type"`
+	TargetID   string          `json:"target_id"`
+	Metadata   json.RawMessage `json:"metadata"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// ActivityFilter narrows an activity feed. Zero values match everything.
+type ActivityFilter struct {
+	Verbs      []string
+	TargetType string
+	TargetID   string
+	From       *time.Time
+	To         *time.Time
+
+	// Cursor continues a previous page; Limit bounds the page size.
+	Cursor string
+	Limit  int
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Audit log actions, recorded for every administrative change.
+const (
+	AuditUserSuspended   = "user.suspended"
+	AuditUserUnsuspended = "user.unsuspended"
+	AuditUserRoleChanged = "user.role_changed"
+	AuditUserUpdated     = "user.updated"
+	AuditFileDeleted     = "file.deleted"
+	AuditCommentDeleted  = "comment.deleted"
+)
+
+// AuditLog records an administrator's action on a target.
+type AuditLog struct {
+	ID         string          `json:"id"`
+	ActorID    string          `json:"actor_id"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetID   string          `json:"target_id"`
+	Metadata   json.RawMessage `json:"metadata"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
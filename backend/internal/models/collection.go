@@ -1,16 +1,92 @@
 package models
 
 import (
+	"database/sql/driver"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// CollectionRole is the access level a user has on a collection.
+type CollectionRole string
+
+const (
+	CollectionRoleViewer      CollectionRole = "viewer"
+	CollectionRoleContributor CollectionRole = "contributor"
+	CollectionRoleAdmin       CollectionRole = "admin"
+	// CollectionRoleOwner is reported for the collection's creator and cannot be assigned.
+	CollectionRoleOwner CollectionRole = "owner"
+)
+
+var collectionRoleRank = map[CollectionRole]int{
+	CollectionRoleViewer:      1,
+	CollectionRoleContributor: 2,
+	CollectionRoleAdmin:       3,
+	CollectionRoleOwner:       4,
+}
+
+// Valid reports whether the role can be assigned to a collection member.
+func (r CollectionRole) Valid() bool {
+	return r == CollectionRoleViewer || r == CollectionRoleContributor || r == CollectionRoleAdmin
+}
+
+// Allows reports whether the role grants at least the required access level.
+func (r CollectionRole) Allows(required CollectionRole) bool {
+	rank, ok := collectionRoleRank[r]
+	return ok && rank >= collectionRoleRank[required]
+}
+
 type Collection struct {
-	ID          uuid.UUID `json:"id"`
-	UserID      uuid.UUID `json:"user_id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          uuid.UUID       `json:"id"`
+	UserID      uuid.UUID       `json:"user_id"`
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Rule        *CollectionRule `json:"rule,omitempty"` // nil for manually curated collections
+	Role        CollectionRole  `json:"role,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// IsSmart reports whether the collection's contents are computed from a rule.
+func (c Collection) IsSmart() bool {
+	return c.Rule != nil
+}
+
+// CollectionRule is a saved filter that defines the contents of a smart
+// collection. Empty fields do not constrain the result; all set fields must match.
+type CollectionRule struct {
+	ContentType  string     `json:"content_type,omitempty"` // exact type, or a prefix ending in "/" such as "image/"
+	Category     string     `json:"category,omitempty"`
+	Tag          string     `json:"tag,omitempty"`
+	UploadedFrom *time.Time `json:"uploaded_from,omitempty"`
+	UploadedTo   *time.Time `json:"uploaded_to,omitempty"`
+	OwnerID      string     `json:"owner_id,omitempty"`
+	FolderID     string     `json:"folder_id,omitempty"` // matches the folder and all of its descendants
+}
+
+// Value stores the rule as JSON.
+func (r CollectionRule) Value() (driver.Value, error) {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+type CollectionMember struct {
+	CollectionID string         `json:"collection_id"`
+	UserID       string         `json:"user_id"`
+	Username     string         `json:"username"`
+	Role         CollectionRole `json:"role"`
+	AddedBy      string         `json:"added_by"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+}
+
+type CollectionFile struct {
+	File
+	Position int       `json:"position"`
+	AddedBy  string    `json:"added_by"`
+	AddedAt  time.Time `json:"added_at"`
 }
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+)
+
+// CommentTargetType is the kind of item a comment is attached to.
+type CommentTargetType string
+
+const (
+	CommentTargetFile   CommentTargetType = "file"
+	CommentTargetFolder CommentTargetType = "folder"
+)
+
+func (t CommentTargetType) Valid() bool {
+	return t == CommentTargetFile || t == CommentTargetFolder
+}
+
+type Comment struct {
+	ID         string            `json:"id"`
+	TargetType CommentTargetType `json:"target_type"`
+	TargetID   string            `json:"target_id"`
+	UserID     string            `json:"user_id"`
+	Username   string            `json:"username"`
+	ParentID   *string           `json:"parent_id,omitempty"`
+	Body       string            `json:"body"`
+	Mentions   []string          `json:"mentions"`
+	ResolvedAt *time.Time        `json:"resolved_at,omitempty"`
+	ResolvedBy *string           `json:"resolved_by,omitempty"`
+	EditedAt   *time.Time        `json:"edited_at,omitempty"`
+	DeletedAt  *time.Time        `json:"deleted_at,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+	Replies    []Comment         `json:"replies,omitempty"`
+}
+
+// CommentRevision is a previous body of an edited comment.
+type CommentRevision struct {
+	ID        string    `json:"id"`
+	CommentID string    `json:"comment_id"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
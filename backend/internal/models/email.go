@@ -0,0 +1,94 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// EmailMode is how a user wants to be emailed about a type of notification.
+type EmailMode string
+
+const (
+	EmailOff       EmailMode = "off"
+	EmailImmediate EmailMode = "immediate"
+	EmailDigest    EmailMode = "digest"
+)
+
+func (m EmailMode) Valid() bool {
+	return m == EmailOff || m == EmailImmediate || m == EmailDigest
+}
+
+// DigestFrequency is how often a user receives their digest email.
+type DigestFrequency string
+
+const (
+	DigestOff    DigestFrequency = "off"
+	DigestDaily  DigestFrequency = "daily"
+	DigestWeekly DigestFrequency = "weekly"
+)
+
+func (f DigestFrequency) Valid() bool {
+	return f == DigestOff || f == DigestDaily || f == DigestWeekly
+}
+
+// Interval is the time between two digests, or zero if digests are off.
+func (f DigestFrequency) Interval() time.Duration {
+	switch f {
+	case DigestDaily:
+		return 24 * time.Hour
+	case DigestWeekly:
+		return 7 * 24 * time.Hour
+	}
+	return 0
+}
+
+// EmailSettings are a user's email options. Unsubscribed stops all email
+// until the user turns it back on.
+type EmailSettings struct {
+	UserID           string          `json:"user_id"`
+	DigestFrequency  DigestFrequency `json:"digest_frequency"`
+	Unsubscribed     bool            `json:"unsubscribed"`
+	UnsubscribeToken string          `json:"-"`
+	LastDigestAt     *time.Time      `json:"last_digest_at,omitempty"`
+}
+
+// DigestItem is a notification waiting to be sent in a user's next digest.
+type DigestItem struct {
+	ID         string          `json:"id"`
+	UserID     string          `json:"user_id"`
+	EventID    string          `json:"event_id"`
+	Type       string          `json:"type"`
+	ActorID    string          `json:"actor_id"`
+	TargetType string          `json:"target_type"`
+	TargetID   string          `json:"target_id"`
+	Data       json.RawMessage `json:"data"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// EmailStatus is the delivery state of a queued email.
+type EmailStatus string
+
+const (
+	EmailPending EmailStatus = "pending"
+	EmailSent    EmailStatus = "sent"
+	EmailFailed  EmailStatus = "failed"
+)
+
+// QueuedEmail is a rendered email waiting in the delivery queue. Emails with
+// the same DedupeKey are only queued once.
+type QueuedEmail struct {
+	ID            string            `json:"id"`
+	UserID        string            `json:"user_id"`
+	DedupeKey     string            `json:"dedupe_key"`
+	To            string            `json:"to"`
+	Subject       string            `json:"subject"`
+	TextBody      string            `json:"text_body"`
+	HTMLBody      string            `json:"html_body"`
+	Headers       map[string]string `json:"headers"`
+	Status        EmailStatus       `json:"status"`
+	Attempts      int               `json:"attempts"`
+	NextAttemptAt time.Time         `json:"next_attempt_at"`
+	LastError     string            `json:"last_error,omitempty"`
+	SentAt        *time.Time        `json:"sent_at,omitempty"`
+	CreatedAt     time.Time         `json:"created_at"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// UserEvent is a realtime update recorded in a user's event log so that
+// clients can catch up after reconnecting.
+type UserEvent struct {
+	UserID    string          `json:"user_id"`
+	Seq       int64           `json:"seq"`
+	Type      string          `json:"type"`
+	Topic     string          `json:"topic"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// BrokerMessage is a realtime update passed between server instances
+// through the database.
+type BrokerMessage struct {
+	ID        int64           `json:"id"`
+	Topic     string          `json:"topic"`
+	Type      string          `json:"type"`
+	Seq       int64           `json:"seq"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"created_at"`
+}
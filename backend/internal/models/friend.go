@@ -14,3 +14,15 @@ type Friend struct {
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
+
+type FriendSuggestion struct {
+	UserID        string   `json:"user_id"`
+	Username      string   `json:"username"`
+	FirstName     string   `json:"first_name"`
+	LastName      string   `json:"last_name"`
+	MutualFriends int      `json:"mutual_friends"`
+	SharedFiles   int      `json:"shared_files"`
+	Likes         int      `json:"likes"`
+	Score         int      `json:"score"`
+	Reasons       []string `json:"reasons"`
+}
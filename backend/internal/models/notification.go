@@ -0,0 +1,34 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Notification tells UserID that ActorID did something that concerns them.
+// Type is the domain event type, such as "share.granted".
+type Notification struct {
+	ID         string          `json:"id"`
+	UserID     string          `json:"user_id"`
+	Type       string          `json:"type"`
+	ActorID    string          `json:"actor_id"`
+	TargetType string          `json:"target_type"`
+	TargetID   string          `json:"target_id"`
+	Data       json.RawMessage `json:"data"`
+	ReadAt     *time.Time      `json:"read_at,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// NotificationPreference records how a user wants to hear about one type of
+// notification.
+type NotificationPreference struct {
+	Type  string    `json:"type"`
+	InApp bool      `json:"in_app"`
+	Email EmailMode `json:"email"`
+}
+
+// DefaultNotificationPreference is the preference of a user who has not set
+// one for notificationType: shown in the app and included in digests.
+func DefaultNotificationPreference(notificationType string) NotificationPreference {
+	return NotificationPreference{Type: notificationType, InApp: true, Email: EmailDigest}
+}
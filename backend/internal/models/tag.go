@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+)
+
+// Tag is a user-owned label stored alongside the global file categories.
+type Tag struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	Name      string    `json:"name"`
+	Color     string    `json:"color"`
+	FileCount int       `json:"file_count"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type TagSuggestionStatus string
+
+const (
+	TagSuggestionPending  TagSuggestionStatus = "pending"
+	TagSuggestionAccepted TagSuggestionStatus = "accepted"
+	TagSuggestionRejected TagSuggestionStatus = "rejected"
+)
+
+// TagSuggestion is an AI-proposed tag awaiting the user's decision.
+type TagSuggestion struct {
+	ID        string              `json:"id"`
+	FileID    string              `json:"file_id"`
+	UserID    string              `json:"user_id"`
+	Name      string              `json:"name"`
+	Status    TagSuggestionStatus `json:"status"`
+	CreatedAt time.Time           `json:"created_at"`
+	UpdatedAt time.Time           `json:"updated_at"`
+}
@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+// TokenScope limits what a personal access token may be used for.
+type TokenScope string
+
+const (
+	ScopeFilesRead TokenScope = "files:read"
+	// ScopeFilesWrite also grants ScopeFilesRead.
+	ScopeFilesWrite TokenScope = "files:write"
+	ScopeSharing    TokenScope = "sharing"
+	// ScopeProfile lets a token change its owner's profile.
+	ScopeProfile TokenScope = "profile"
+	// ScopeAdmin lets a token do anything its owner can.
+	ScopeAdmin TokenScope = "admin"
+)
+
+// Valid reports whether the scope can be granted to a token.
+func (s TokenScope) Valid() bool {
+	return s == ScopeFilesRead || s == ScopeFilesWrite || s == ScopeSharing || s == ScopeProfile || s == ScopeAdmin
+}
+
+// PersonalAccessToken lets scripts call the API as its owner without a
+// browser session. Only a hash of the token is stored; Prefix identifies it
+// in listings.
+type PersonalAccessToken struct {
+	ID         string       `json:"id"`
+	UserID     string       `json:"user_id"`
+	Name       string       `json:"name"`
+	Prefix     string       `json:"prefix"`
+	Scopes     []TokenScope `json:"scopes"`
+	ExpiresAt  *time.Time   `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time   `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time   `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time    `json:"created_at"`
+
+	// Token is the secret itself. It is only shown when the token is created.
+	Token string `json:"token,omitempty"`
+}
+
+// Usable reports whether the token has neither been revoked nor expired.
+func (t PersonalAccessToken) Usable(now time.Time) bool {
+	return t.RevokedAt == nil && (t.ExpiresAt == nil || now.Before(*t.ExpiresAt))
+}
+
+// HasScope reports whether the token grants scope.
+func (t PersonalAccessToken) HasScope(scope TokenScope) bool {
+	for _, s := range t.Scopes {
+		if s == scope || s == ScopeAdmin || (s == ScopeFilesWrite && scope == ScopeFilesRead) {
+			return true
+		}
+	}
+	return false
+}
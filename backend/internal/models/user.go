@@ -6,13 +6,37 @@ import (
 	"github.com/google/uuid"
 )
 
+// UserRole is what a user is allowed to do across the whole app.
+type UserRole string
+
+const (
+	RoleUser  UserRole = "user"
+	RoleAdmin UserRole = "admin"
+)
+
+// Valid reports whether the role can be assigned to a user.
+func (r UserRole) Valid() bool {
+	return r == RoleUser || r == RoleAdmin
+}
+
 type User struct {
-	ID        uuid.UUID `json:"id"`
-	ClerkID   string    `json:"clerk_id"`
-	Email     string    `json:"email"`
-	Username  string    `json:"username"`
-	FirstName string    `json:"first_name"`
-	LastName  string    `json:"last_name"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID          uuid.UUID  `json:"id"`
+	ClerkID     string     `json:"clerk_id"`
+	Email       string     `json:"email"`
+	Username    string     `json:"username"`
+	FirstName   string     `json:"first_name"`
+	LastName    string     `json:"last_name"`
+	Role        UserRole   `json:"role"`
+	SuspendedAt *time.Time `json:"suspended_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// StorageUsage is how much a user stores.
+type StorageUsage struct {
+	UserID     string `json:"user_id"`
+	Username   string `json:"username"`
+	Email      string `json:"email"`
+	FileCount  int64  `json:"file_count"`
+	TotalBytes int64  `json:"total_bytes"`
 }
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Webhook posts the events its owner can see to URL. A webhook with a
+// FolderID or CollectionID only receives events about that folder or
+// collection and what is in it.
+type Webhook struct {
+	ID           string     `json:"id"`
+	UserID       string     `json:"user_id"`
+	URL          string     `json:"url"`
+	EventTypes   []string   `json:"event_types"`
+	FolderID     *string    `json:"folder_id,omitempty"`
+	CollectionID *string    `json:"collection_id,omitempty"`
+	Active       bool       `json:"active"`
+	DisabledAt   *time.Time `json:"disabled_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+
+	// Secret signs deliveries. It is only shown when the webhook is created.
+	Secret string `json:"secret,omitempty"`
+
+	// ConsecutiveFailures counts failed deliveries since the last success;
+	// the webhook is disabled when it reaches the limit.
+	ConsecutiveFailures int `json:"consecutive_failures"`
+}
+
+// WebhookDeliveryStatus is the state of a webhook delivery.
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliverySucceeded WebhookDeliveryStatus = "succeeded"
+	WebhookDeliveryFailed    WebhookDeliveryStatus = "failed"
+)
+
+// WebhookDelivery is one event sent, or being sent, to a webhook, with the
+// outcome of the latest attempt.
+type WebhookDelivery struct {
+	ID             string                `json:"id"`
+	WebhookID      string                `json:"webhook_id"`
+	EventID        string                `json:"event_id"`
+	EventType      string                `json:"event_type"`
+	Payload        json.RawMessage       `json:"payload"`
+	Status         WebhookDeliveryStatus `json:"status"`
+	Attempts       int                   `json:"attempts"`
+	NextAttemptAt  time.Time             `json:"next_attempt_at"`
+	ResponseStatus *int                  `json:"response_status,omitempty"`
+	ResponseBody   string                `json:"response_body,omitempty"`
+	LastError      string                `json:"last_error,omitempty"`
+	CreatedAt      time.Time             `json:"created_at"`
+	CompletedAt    *time.Time            `json:"completed_at,omitempty"`
+}
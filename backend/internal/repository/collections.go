@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+)
+
+const collectionColumns = `id, user_id, name, description, rule, created_at, updated_at`
+
+type collectionRepo struct {
+	s *sqlStore
+}
+
+func scanCollection(row rowScanner) (models.Collection, error) {
+	var c models.Collection
+	var description, rule sql.NullString
+	err := row.Scan(&c.ID, &c.UserID, &c.Name, &description, &rule, &c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		return c, notFound(err)
+	}
+	c.Description = description.String
+	if rule.Valid && rule.String != "" {
+		c.Rule = &models.CollectionRule{}
+		if err := json.Unmarshal([]byte(rule.String), c.Rule); err != nil {
+			return c, fmt.Errorf("failed to parse collection rule: %w", err)
+		}
+	}
+	return c, nil
+}
+
+func (r collectionRepo) Get(ctx context.Context, id string) (models.Collection, error) {
+	return scanCollection(r.s.q.QueryRowContext(ctx, `SELECT `+collectionColumns+` FROM collections WHERE id = ?`, id))
+}
+
+func (r collectionRepo) ListOwned(ctx context.Context, userID string) ([]models.Collection, error) {
+	rows, err := r.s.q.QueryContext(ctx, `SELECT `+collectionColumns+` FROM collections WHERE user_id = ? ORDER BY created_at, id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collections := []models.Collection{}
+	for rows.Next() {
+		c, err := scanCollection(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan collection row: %w", err)
+		}
+		c.Role = models.CollectionRoleOwner
+		collections = append(collections, c)
+	}
+	return collections, rows.Err()
+}
+
+func (r collectionRepo) Create(ctx context.Context, collection models.Collection) error {
+	_, err := r.s.q.ExecContext(ctx, `
+		INSERT INTO collections (`+collectionColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, collection.ID, collection.UserID, collection.Name, collection.Description, collection.Rule,
+		collection.CreatedAt, collection.UpdatedAt)
+	return err
+}
+
+func (r collectionRepo) Update(ctx context.Context, collection models.Collection) error {
+	return affected(r.s.q.ExecContext(ctx, `
+		UPDATE collections SET name = ?, description = ?, rule = ?, updated_at = ?
+		WHERE id = ?
+	`, collection.Name, collection.Description, collection.Rule, time.Now(), collection.ID))
+}
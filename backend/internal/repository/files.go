@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+)
+
+const fileColumns = `id, user_id, folder_id, collection_id, key, name, content_type, size, uploaded_at, created_at, updated_at, b2_file_id`
+
+type fileRepo struct {
+	s *sqlStore
+}
+
+func scanFile(row rowScanner) (models.File, error) {
+	var f models.File
+	var b2FileID sql.NullString
+	err := row.Scan(&f.ID, &f.UserID, &f.FolderID, &f.CollectionID, &f.Key, &f.Name, &f.ContentType, &f.Size,
+		&f.UploadedAt, &f.CreatedAt, &f.UpdatedAt, &b2FileID)
+	f.B2FileID = b2FileID.String
+	return f, notFound(err)
+}
+
+func scanFiles(rows *sql.Rows, err error) ([]models.File, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	files := []models.File{}
+	for rows.Next() {
+		f, err := scanFile(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan file row: %w", err)
+		}
+		files = append(files, f)
+	}
+	return files, rows.Err()
+}
+
+func (r fileRepo) Get(ctx context.Context, id string) (models.File, error) {
+	return scanFile(r.s.q.QueryRowContext(ctx, `SELECT `+fileColumns+` FROM files WHERE id = ?`, id))
+}
+
+func (r fileRepo) GetOwned(ctx context.Context, userID, id string) (models.File, error) {
+	return scanFile(r.s.q.QueryRowContext(ctx, `SELECT `+fileColumns+` FROM files WHERE id = ? AND user_id = ?`, id, userID))
+}
+
+func (r fileRepo) ListByUser(ctx context.Context, userID string, limit, offset int) ([]models.File, error) {
+	return scanFiles(r.s.q.QueryContext(ctx, `
+		SELECT `+fileColumns+`
+		FROM files WHERE user_id = ?
+		ORDER BY created_at, id
+		LIMIT ? OFFSET ?
+	`, userID, limit, offset))
+}
+
+func (r fileRepo) CountByUser(ctx context.Context, userID string) (int, error) {
+	var count int
+	err := r.s.q.QueryRowContext(ctx, `SELECT COUNT(*) FROM files WHERE user_id = ?`, userID).Scan(&count)
+	return count, err
+}
+
+func (r fileRepo) Create(ctx context.Context, file models.File) error {
+	_, err := r.s.q.ExecContext(ctx, `
+		INSERT INTO files (`+fileColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, file.ID, file.UserID, file.FolderID, file.CollectionID, file.Key, file.Name, file.ContentType, file.Size,
+		file.UploadedAt, file.CreatedAt, file.UpdatedAt, file.B2FileID)
+	return err
+}
+
+func (r fileRepo) Rename(ctx context.Context, id, name string) error {
+	return affected(r.s.q.ExecContext(ctx, `UPDATE files SET name = ?, updated_at = ? WHERE id = ?`, name, time.Now(), id))
+}
+
+func (r fileRepo) Move(ctx context.Context, id string, folderID *string) error {
+	return affected(r.s.q.ExecContext(ctx, `UPDATE files SET folder_id = ?, updated_at = ? WHERE id = ?`, folderID, time.Now(), id))
+}
+
+// fileDependents deletes the rows that refer to a file, before the file
+// itself.
+var fileDependents = []string{
+	`DELETE FROM comment_revisions WHERE comment_id IN (SELECT id FROM comments WHERE target_type = 'file' AND target_id = ?)`,
+	`DELETE FROM comment_mentions WHERE comment_id IN (SELECT id FROM comments WHERE target_type = 'file' AND target_id = ?)`,
+	`DELETE FROM comments WHERE target_type = 'file' AND target_id = ?`,
+	`DELETE FROM shared_files WHERE file_id = ?`,
+	`DELETE FROM file_category_associations WHERE file_id = ?`,
+	`DELETE FROM tag_suggestions WHERE file_id = ?`,
+	`DELETE FROM collection_files WHERE file_id = ?`,
+	`DELETE FROM file_search_index WHERE file_id = ?`,
+}
+
+func (r fileRepo) Delete(ctx context.Context, id string) error {
+	return r.s.inTx(ctx, func(tx *sqlStore) error {
+		for _, statement := range fileDependents {
+			if _, err := tx.q.ExecContext(ctx, statement, id); err != nil {
+				return fmt.Errorf("failed to delete file records: %w", err)
+			}
+		}
+		return affected(tx.q.ExecContext(ctx, `DELETE FROM files WHERE id = ?`, id))
+	})
+}
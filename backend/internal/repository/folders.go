@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+)
+
+const folderColumns = `id, user_id, name, description, parent_id, created_at, updated_at`
+
+type folderRepo struct {
+	s *sqlStore
+}
+
+func scanFolder(row rowScanner) (models.Folder, error) {
+	var f models.Folder
+	var description sql.NullString
+	err := row.Scan(&f.ID, &f.UserID, &f.Name, &description, &f.ParentID, &f.CreatedAt, &f.UpdatedAt)
+	f.Description = description.String
+	return f, notFound(err)
+}
+
+func (r folderRepo) Get(ctx context.Context, id string) (models.Folder, error) {
+	return scanFolder(r.s.q.QueryRowContext(ctx, `SELECT `+folderColumns+` FROM folders WHERE id = ?`, id))
+}
+
+func (r folderRepo) ListByUser(ctx context.Context, userID string) ([]models.Folder, error) {
+	rows, err := r.s.q.QueryContext(ctx, `SELECT `+folderColumns+` FROM folders WHERE user_id = ? ORDER BY name, created_at, id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	folders := []models.Folder{}
+	for rows.Next() {
+		f, err := scanFolder(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan folder row: %w", err)
+		}
+		folders = append(folders, f)
+	}
+	return folders, rows.Err()
+}
+
+func (r folderRepo) Create(ctx context.Context, folder models.Folder) error {
+	_, err := r.s.q.ExecContext(ctx, `
+		INSERT INTO folders (`+folderColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, folder.ID, folder.UserID, folder.Name, folder.Description, folder.ParentID, folder.CreatedAt, folder.UpdatedAt)
+	return err
+}
+
+func (r folderRepo) Update(ctx context.Context, folder models.Folder) error {
+	return affected(r.s.q.ExecContext(ctx, `
+		UPDATE folders SET name = ?, description = ?, parent_id = ?, updated_at = ?
+		WHERE id = ?
+	`, folder.Name, folder.Description, folder.ParentID, time.Now(), folder.ID))
+}
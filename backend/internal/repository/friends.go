@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+)
+
+const friendColumns = `id, user_id, friend_id, status, created_at, updated_at`
+
+type friendRepo struct {
+	s *sqlStore
+}
+
+func scanFriend(row rowScanner) (models.Friend, error) {
+	var f models.Friend
+	err := row.Scan(&f.ID, &f.UserID, &f.FriendID, &f.Status, &f.CreatedAt, &f.UpdatedAt)
+	return f, notFound(err)
+}
+
+func (r friendRepo) Get(ctx context.Context, id, userID string) (models.Friend, error) {
+	return scanFriend(r.s.q.QueryRowContext(ctx, `
+		SELECT `+friendColumns+`
+		FROM friends WHERE id = ? AND (user_id = ? OR friend_id = ?)
+	`, id, userID, userID))
+}
+
+func (r friendRepo) ListByUser(ctx context.Context, userID string) ([]models.Friend, error) {
+	rows, err := r.s.q.QueryContext(ctx, `
+		SELECT `+friendColumns+`
+		FROM friends WHERE user_id = ? OR friend_id = ?
+		ORDER BY created_at, id
+	`, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	friends := []models.Friend{}
+	for rows.Next() {
+		f, err := scanFriend(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan friend row: %w", err)
+		}
+		friends = append(friends, f)
+	}
+	return friends, rows.Err()
+}
+
+func (r friendRepo) Create(ctx context.Context, friend models.Friend) error {
+	_, err := r.s.q.ExecContext(ctx, `
+		INSERT INTO friends (`+friendColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, friend.ID, friend.UserID, friend.FriendID, friend.Status, friend.CreatedAt, friend.UpdatedAt)
+	return err
+}
+
+func (r friendRepo) UpdateStatus(ctx context.Context, id, status string) error {
+	return affected(r.s.q.ExecContext(ctx, `UPDATE friends SET status = ?, updated_at = ? WHERE id = ?`, status, time.Now(), id))
+}
+
+func (r friendRepo) Delete(ctx context.Context, id string) error {
+	return affected(r.s.q.ExecContext(ctx, `DELETE FROM friends WHERE id = ?`, id))
+}
+
+func (r friendRepo) AreFriends(ctx context.Context, userID, otherUserID string) (bool, error) {
+	var exists bool
+	err := r.s.q.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM friends
+			WHERE status = 'accepted'
+			  AND ((user_id = ? AND friend_id = ?) OR (user_id = ? AND friend_id = ?))
+		)
+	`, userID, otherUserID, otherUserID, userID).Scan(&exists)
+	return exists, err
+}
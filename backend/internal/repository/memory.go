@@ -0,0 +1,498 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+)
+
+// MemoryStore is a Store that keeps everything in memory, for handler
+// tests. Units of work run one at a time and roll back by restoring a
+// snapshot, so they are not isolated from calls made outside them.
+type MemoryStore struct {
+	mu   *sync.Mutex
+	txMu *sync.Mutex
+	data *memoryData
+	inTx bool
+}
+
+type memoryData struct {
+	files       map[string]models.File
+	folders     map[string]models.Folder
+	collections map[string]models.Collection
+	shares      []memoryShare
+	friends     map[string]models.Friend
+	users       map[string]models.User
+}
+
+type memoryShare struct {
+	fileID     string
+	sharedBy   string
+	sharedWith string
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		mu:   &sync.Mutex{},
+		txMu: &sync.Mutex{},
+		data: &memoryData{
+			files:       map[string]models.File{},
+			folders:     map[string]models.Folder{},
+			collections: map[string]models.Collection{},
+			friends:     map[string]models.Friend{},
+			users:       map[string]models.User{},
+		},
+	}
+}
+
+// PutUser adds or replaces a user, which the Store itself cannot create.
+func (s *MemoryStore) PutUser(user models.User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.users[user.ID.String()] = user
+}
+
+func (s *MemoryStore) Files() FileRepo             { return memoryFiles{s} }
+func (s *MemoryStore) Folders() FolderRepo         { return memoryFolders{s} }
+func (s *MemoryStore) Collections() CollectionRepo { return memoryCollections{s} }
+func (s *MemoryStore) Shares() ShareRepo           { return memoryShares{s} }
+func (s *MemoryStore) Friends() FriendRepo         { return memoryFriends{s} }
+func (s *MemoryStore) Users() UserRepo             { return memoryUsers{s} }
+
+func (s *MemoryStore) WithinTx(ctx context.Context, fn func(Store) error) error {
+	if s.inTx {
+		return fn(s)
+	}
+	s.txMu.Lock()
+	defer s.txMu.Unlock()
+
+	snapshot := s.snapshot()
+	tx := &MemoryStore{mu: s.mu, txMu: s.txMu, data: s.data, inTx: true}
+	if err := fn(tx); err != nil {
+		s.mu.Lock()
+		*s.data = snapshot
+		s.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (s *MemoryStore) snapshot() memoryData {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return memoryData{
+		files:       copyMap(s.data.files),
+		folders:     copyMap(s.data.folders),
+		collections: copyMap(s.data.collections),
+		shares:      append([]memoryShare(nil), s.data.shares...),
+		friends:     copyMap(s.data.friends),
+		users:       copyMap(s.data.users),
+	}
+}
+
+func copyMap[V any](m map[string]V) map[string]V {
+	c := make(map[string]V, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// sorted returns the values of m that keep accepts, oldest first and then
+// by ID, like the SQL repositories.
+func sorted[V any](m map[string]V, keep func(V) bool, createdAt func(V) time.Time) []V {
+	ids := []string{}
+	for id, v := range m {
+		if keep(v) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := createdAt(m[ids[i]]), createdAt(m[ids[j]])
+		if !a.Equal(b) {
+			return a.Before(b)
+		}
+		return ids[i] < ids[j]
+	})
+	values := make([]V, len(ids))
+	for i, id := range ids {
+		values[i] = m[id]
+	}
+	return values
+}
+
+func parseNullUUID(id *string) (uuid.NullUUID, error) {
+	if id == nil {
+		return uuid.NullUUID{}, nil
+	}
+	parsed, err := uuid.Parse(*id)
+	return uuid.NullUUID{UUID: parsed, Valid: err == nil}, err
+}
+
+type memoryFiles struct{ s *MemoryStore }
+
+func (r memoryFiles) Get(ctx context.Context, id string) (models.File, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	f, ok := r.s.data.files[id]
+	if !ok {
+		return models.File{}, ErrNotFound
+	}
+	return f, nil
+}
+
+func (r memoryFiles) GetOwned(ctx context.Context, userID, id string) (models.File, error) {
+	f, err := r.Get(ctx, id)
+	if err == nil && f.UserID.String() != userID {
+		return models.File{}, ErrNotFound
+	}
+	return f, err
+}
+
+func (r memoryFiles) ListByUser(ctx context.Context, userID string, limit, offset int) ([]models.File, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	files := sorted(r.s.data.files,
+		func(f models.File) bool { return f.UserID.String() == userID },
+		func(f models.File) time.Time { return f.CreatedAt })
+	if offset >= len(files) {
+		return []models.File{}, nil
+	}
+	files = files[offset:]
+	if len(files) > limit {
+		files = files[:limit]
+	}
+	return files, nil
+}
+
+func (r memoryFiles) CountByUser(ctx context.Context, userID string) (int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	count := 0
+	for _, f := range r.s.data.files {
+		if f.UserID.String() == userID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r memoryFiles) Create(ctx context.Context, file models.File) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if _, ok := r.s.data.files[file.ID.String()]; ok {
+		return ErrConflict
+	}
+	r.s.data.files[file.ID.String()] = file
+	return nil
+}
+
+func (r memoryFiles) Rename(ctx context.Context, id, name string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	f, ok := r.s.data.files[id]
+	if !ok {
+		return ErrNotFound
+	}
+	f.Name = name
+	f.UpdatedAt = time.Now()
+	r.s.data.files[id] = f
+	return nil
+}
+
+func (r memoryFiles) Move(ctx context.Context, id string, folderID *string) error {
+	folder, err := parseNullUUID(folderID)
+	if err != nil {
+		return err
+	}
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	f, ok := r.s.data.files[id]
+	if !ok {
+		return ErrNotFound
+	}
+	f.FolderID = folder
+	f.UpdatedAt = time.Now()
+	r.s.data.files[id] = f
+	return nil
+}
+
+func (r memoryFiles) Delete(ctx context.Context, id string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if _, ok := r.s.data.files[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.s.data.files, id)
+	shares := r.s.data.shares[:0]
+	for _, share := range r.s.data.shares {
+		if share.fileID != id {
+			shares = append(shares, share)
+		}
+	}
+	r.s.data.shares = shares
+	return nil
+}
+
+type memoryFolders struct{ s *MemoryStore }
+
+func (r memoryFolders) Get(ctx context.Context, id string) (models.Folder, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	f, ok := r.s.data.folders[id]
+	if !ok {
+		return models.Folder{}, ErrNotFound
+	}
+	return f, nil
+}
+
+func (r memoryFolders) ListByUser(ctx context.Context, userID string) ([]models.Folder, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	folders := sorted(r.s.data.folders,
+		func(f models.Folder) bool { return f.UserID.String() == userID },
+		func(f models.Folder) time.Time { return f.CreatedAt })
+	sort.SliceStable(folders, func(i, j int) bool { return folders[i].Name < folders[j].Name })
+	return folders, nil
+}
+
+func (r memoryFolders) Create(ctx context.Context, folder models.Folder) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if _, ok := r.s.data.folders[folder.ID.String()]; ok {
+		return ErrConflict
+	}
+	r.s.data.folders[folder.ID.String()] = folder
+	return nil
+}
+
+func (r memoryFolders) Update(ctx context.Context, folder models.Folder) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	f, ok := r.s.data.folders[folder.ID.String()]
+	if !ok {
+		return ErrNotFound
+	}
+	f.Name = folder.Name
+	f.Description = folder.Description
+	f.ParentID = folder.ParentID
+	f.UpdatedAt = time.Now()
+	r.s.data.folders[folder.ID.String()] = f
+	return nil
+}
+
+type memoryCollections struct{ s *MemoryStore }
+
+func (r memoryCollections) Get(ctx context.Context, id string) (models.Collection, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	c, ok := r.s.data.collections[id]
+	if !ok {
+		return models.Collection{}, ErrNotFound
+	}
+	c.Role = ""
+	return c, nil
+}
+
+func (r memoryCollections) ListOwned(ctx context.Context, userID string) ([]models.Collection, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	collections := sorted(r.s.data.collections,
+		func(c models.Collection) bool { return c.UserID.String() == userID },
+		func(c models.Collection) time.Time { return c.CreatedAt })
+	for i := range collections {
+		collections[i].Role = models.CollectionRoleOwner
+	}
+	return collections, nil
+}
+
+func (r memoryCollections) Create(ctx context.Context, collection models.Collection) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if _, ok := r.s.data.collections[collection.ID.String()]; ok {
+		return ErrConflict
+	}
+	r.s.data.collections[collection.ID.String()] = collection
+	return nil
+}
+
+func (r memoryCollections) Update(ctx context.Context, collection models.Collection) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	c, ok := r.s.data.collections[collection.ID.String()]
+	if !ok {
+		return ErrNotFound
+	}
+	c.Name = collection.Name
+	c.Description = collection.Description
+	c.Rule = collection.Rule
+	c.UpdatedAt = time.Now()
+	r.s.data.collections[collection.ID.String()] = c
+	return nil
+}
+
+type memoryShares struct{ s *MemoryStore }
+
+func (r memoryShares) Share(ctx context.Context, fileID, sharedBy, sharedWith string) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, share := range r.s.data.shares {
+		if share.fileID == fileID && share.sharedWith == sharedWith {
+			return false, nil
+		}
+	}
+	r.s.data.shares = append(r.s.data.shares, memoryShare{fileID: fileID, sharedBy: sharedBy, sharedWith: sharedWith})
+	return true, nil
+}
+
+func (r memoryShares) Unshare(ctx context.Context, fileID, sharedWith string) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for i, share := range r.s.data.shares {
+		if share.fileID == fileID && share.sharedWith == sharedWith {
+			r.s.data.shares = append(r.s.data.shares[:i:i], r.s.data.shares[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r memoryShares) ListRecipients(ctx context.Context, fileID string) ([]string, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	recipients := []string{}
+	for _, share := range r.s.data.shares {
+		if share.fileID == fileID {
+			recipients = append(recipients, share.sharedWith)
+		}
+	}
+	return recipients, nil
+}
+
+func (r memoryShares) ListSharedWith(ctx context.Context, userID string) ([]models.File, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	files := []models.File{}
+	for _, share := range r.s.data.shares {
+		if f, ok := r.s.data.files[share.fileID]; ok && share.sharedWith == userID {
+			files = append(files, f)
+		}
+	}
+	return files, nil
+}
+
+type memoryFriends struct{ s *MemoryStore }
+
+func (r memoryFriends) Get(ctx context.Context, id, userID string) (models.Friend, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	f, ok := r.s.data.friends[id]
+	if !ok || (f.UserID != userID && f.FriendID != userID) {
+		return models.Friend{}, ErrNotFound
+	}
+	return f, nil
+}
+
+func (r memoryFriends) ListByUser(ctx context.Context, userID string) ([]models.Friend, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return sorted(r.s.data.friends,
+		func(f models.Friend) bool { return f.UserID == userID || f.FriendID == userID },
+		func(f models.Friend) time.Time { return f.CreatedAt }), nil
+}
+
+func (r memoryFriends) Create(ctx context.Context, friend models.Friend) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if _, ok := r.s.data.friends[friend.ID.String()]; ok {
+		return ErrConflict
+	}
+	r.s.data.friends[friend.ID.String()] = friend
+	return nil
+}
+
+func (r memoryFriends) UpdateStatus(ctx context.Context, id, status string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	f, ok := r.s.data.friends[id]
+	if !ok {
+		return ErrNotFound
+	}
+	f.Status = status
+	f.UpdatedAt = time.Now()
+	r.s.data.friends[id] = f
+	return nil
+}
+
+func (r memoryFriends) Delete(ctx context.Context, id string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	if _, ok := r.s.data.friends[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.s.data.friends, id)
+	return nil
+}
+
+func (r memoryFriends) AreFriends(ctx context.Context, userID, otherUserID string) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, f := range r.s.data.friends {
+		if f.Status == "accepted" &&
+			((f.UserID == userID && f.FriendID == otherUserID) || (f.UserID == otherUserID && f.FriendID == userID)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type memoryUsers struct{ s *MemoryStore }
+
+func (r memoryUsers) Get(ctx context.Context, id string) (models.User, error) {
+	return r.find(func(u models.User) bool { return u.ID.String() == id })
+}
+
+func (r memoryUsers) GetByUsername(ctx context.Context, username string) (models.User, error) {
+	return r.find(func(u models.User) bool { return u.Username == username })
+}
+
+func (r memoryUsers) GetByEmail(ctx context.Context, email string) (models.User, error) {
+	return r.find(func(u models.User) bool { return u.Email == email })
+}
+
+func (r memoryUsers) find(match func(models.User) bool) (models.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	for _, u := range r.s.data.users {
+		if match(u) {
+			return u, nil
+		}
+	}
+	return models.User{}, ErrNotFound
+}
+
+func (r memoryUsers) UpdateProfile(ctx context.Context, user models.User) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	id := user.ID.String()
+	u, ok := r.s.data.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	for otherID, other := range r.s.data.users {
+		if otherID != id && other.Username == user.Username {
+			return ErrConflict
+		}
+	}
+	u.Username = user.Username
+	u.FirstName = user.FirstName
+	u.LastName = user.LastName
+	u.UpdatedAt = time.Now()
+	r.s.data.users[id] = u
+	return nil
+}
@@ -0,0 +1,105 @@
+// Package repository provides typed, context-aware access to the core
+// tables, behind interfaces that handlers can depend on instead of
+// *db.SQLiteClient. NewSQLiteStore implements them over a database and
+// NewMemoryStore in memory, for handler tests.
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+)
+
+// ErrNotFound is returned when a row does not exist, or is not visible to
+// the user it was looked up for.
+var ErrNotFound = errors.New("not found")
+
+// ErrConflict is returned when a change would duplicate a value that must
+// be unique, such as a username.
+var ErrConflict = errors.New("conflicts with an existing row")
+
+// FileRepo stores file metadata. The objects themselves live in storage.
+type FileRepo interface {
+	Get(ctx context.Context, id string) (models.File, error)
+	// GetOwned returns id only if it belongs to userID.
+	GetOwned(ctx context.Context, userID, id string) (models.File, error)
+	// ListByUser returns a page of userID's files, oldest first.
+	ListByUser(ctx context.Context, userID string, limit, offset int) ([]models.File, error)
+	CountByUser(ctx context.Context, userID string) (int, error)
+	Create(ctx context.Context, file models.File) error
+	Rename(ctx context.Context, id, name string) error
+	// Move puts a file in folderID, or at the root if folderID is nil.
+	Move(ctx context.Context, id string, folderID *string) error
+	// Delete removes a file along with its shares, tags, collection entries,
+	// search index entry and comments.
+	Delete(ctx context.Context, id string) error
+}
+
+type FolderRepo interface {
+	Get(ctx context.Context, id string) (models.Folder, error)
+	ListByUser(ctx context.Context, userID string) ([]models.Folder, error)
+	Create(ctx context.Context, folder models.Folder) error
+	// Update saves a folder's name, description and parent.
+	Update(ctx context.Context, folder models.Folder) error
+}
+
+// CollectionRepo stores collections. Membership and contents are managed
+// through *db.SQLiteClient.
+type CollectionRepo interface {
+	Get(ctx context.Context, id string) (models.Collection, error)
+	// ListOwned returns the collections userID created.
+	ListOwned(ctx context.Context, userID string) ([]models.Collection, error)
+	Create(ctx context.Context, collection models.Collection) error
+	// Update saves a collection's name, description and rule.
+	Update(ctx context.Context, collection models.Collection) error
+}
+
+type ShareRepo interface {
+	// Share shares fileID with sharedWith on behalf of sharedBy, and reports
+	// false if it already was.
+	Share(ctx context.Context, fileID, sharedBy, sharedWith string) (bool, error)
+	// Unshare reports false if fileID was not shared with sharedWith.
+	Unshare(ctx context.Context, fileID, sharedWith string) (bool, error)
+	// ListRecipients returns who fileID is shared with.
+	ListRecipients(ctx context.Context, fileID string) ([]string, error)
+	// ListSharedWith returns the files shared with userID.
+	ListSharedWith(ctx context.Context, userID string) ([]models.File, error)
+}
+
+type FriendRepo interface {
+	// Get returns a friendship only if userID is part of it.
+	Get(ctx context.Context, id, userID string) (models.Friend, error)
+	// ListByUser returns every friendship userID is part of, in any status.
+	ListByUser(ctx context.Context, userID string) ([]models.Friend, error)
+	Create(ctx context.Context, friend models.Friend) error
+	UpdateStatus(ctx context.Context, id, status string) error
+	Delete(ctx context.Context, id string) error
+	// AreFriends reports whether two users have an accepted friendship.
+	AreFriends(ctx context.Context, userID, otherUserID string) (bool, error)
+}
+
+type UserRepo interface {
+	Get(ctx context.Context, id string) (models.User, error)
+	GetByUsername(ctx context.Context, username string) (models.User, error)
+	GetByEmail(ctx context.Context, email string) (models.User, error)
+	// UpdateProfile saves a user's username, first name and last name. It
+	// returns ErrConflict if the username belongs to someone else.
+	UpdateProfile(ctx context.Context, user models.User) error
+}
+
+// Store hands out the repositories, and groups their changes into units of
+// work.
+type Store interface {
+	Files() FileRepo
+	Folders() FolderRepo
+	Collections() CollectionRepo
+	Shares() ShareRepo
+	Friends() FriendRepo
+	Users() UserRepo
+
+	// WithinTx runs fn as a unit of work: changes made through the Store
+	// passed to fn are committed together if it returns nil, and discarded
+	// otherwise. Calling WithinTx inside fn joins the same unit of work.
+	WithinTx(ctx context.Context, fn func(Store) error) error
+}
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+)
+
+type shareRepo struct {
+	s *sqlStore
+}
+
+func (r shareRepo) Share(ctx context.Context, fileID, sharedBy, sharedWith string) (bool, error) {
+	result, err := r.s.q.ExecContext(ctx, `
+		INSERT INTO shared_files (id, file_id, shared_by, shared_with)
+		SELECT ?, ?, ?, ?
+		WHERE NOT EXISTS (SELECT 1 FROM shared_files WHERE file_id = ? AND shared_with = ?)
+	`, uuid.New().String(), fileID, sharedBy, sharedWith, fileID, sharedWith)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+func (r shareRepo) Unshare(ctx context.Context, fileID, sharedWith string) (bool, error) {
+	result, err := r.s.q.ExecContext(ctx, `DELETE FROM shared_files WHERE file_id = ? AND shared_with = ?`, fileID, sharedWith)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+func (r shareRepo) ListRecipients(ctx context.Context, fileID string) ([]string, error) {
+	rows, err := r.s.q.QueryContext(ctx, `SELECT shared_with FROM shared_files WHERE file_id = ? ORDER BY created_at`, fileID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	recipients := []string{}
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return nil, fmt.Errorf("failed to scan share row: %w", err)
+		}
+		recipients = append(recipients, userID)
+	}
+	return recipients, rows.Err()
+}
+
+func (r shareRepo) ListSharedWith(ctx context.Context, userID string) ([]models.File, error) {
+	return scanFiles(r.s.q.QueryContext(ctx, `
+		SELECT `+columns(fileColumns, "f")+`
+		FROM files f
+		JOIN shared_files sf ON sf.file_id = f.id
+		WHERE sf.shared_with = ?
+		ORDER BY sf.created_at, f.id
+	`, userID))
+}
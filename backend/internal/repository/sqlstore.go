@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// querier is what the repositories need from either a *sql.DB or a *sql.Tx.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// pools sends queries to the read pool and everything else to the writer.
+type pools struct {
+	writer *sql.DB
+	reader *sql.DB
+}
+
+func (p pools) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return p.writer.ExecContext(ctx, query, args...)
+}
+
+func (p pools) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return p.reader.QueryContext(ctx, query, args...)
+}
+
+func (p pools) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return p.reader.QueryRowContext(ctx, query, args...)
+}
+
+// sqlStore implements Store over a database, or over one of its
+// transactions when tx is set.
+type sqlStore struct {
+	db *sql.DB
+	q  querier
+	tx *sql.Tx
+}
+
+// NewSQLiteStore returns a Store backed by a SQLite database that has been
+// migrated to the current schema. Writes and units of work go through
+// writer; other reads use reader, which may be the same pool.
+func NewSQLiteStore(writer, reader *sql.DB) Store {
+	return &sqlStore{db: writer, q: pools{writer: writer, reader: reader}}
+}
+
+func (s *sqlStore) Files() FileRepo             { return fileRepo{s} }
+func (s *sqlStore) Folders() FolderRepo         { return folderRepo{s} }
+func (s *sqlStore) Collections() CollectionRepo { return collectionRepo{s} }
+func (s *sqlStore) Shares() ShareRepo           { return shareRepo{s} }
+func (s *sqlStore) Friends() FriendRepo         { return friendRepo{s} }
+func (s *sqlStore) Users() UserRepo             { return userRepo{s} }
+
+func (s *sqlStore) WithinTx(ctx context.Context, fn func(Store) error) error {
+	return s.inTx(ctx, func(tx *sqlStore) error { return fn(tx) })
+}
+
+// inTx runs fn in a transaction, or in the current one if there is one.
+func (s *sqlStore) inTx(ctx context.Context, fn func(*sqlStore) error) error {
+	if s.tx != nil {
+		return fn(s)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(&sqlStore{db: s.db, q: tx, tx: tx}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// notFound turns sql.ErrNoRows into ErrNotFound.
+func notFound(err error) error {
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	return err
+}
+
+// affected returns ErrNotFound if result changed no rows.
+func affected(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// columns prefixes each of a comma-separated column list with alias.
+func columns(list, alias string) string {
+	cols := strings.Split(list, ",")
+	for i, col := range cols {
+		cols[i] = alias + "." + strings.TrimSpace(col)
+	}
+	return strings.Join(cols, ", ")
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+)
+
+const userColumns = `id, email, username, first_name, last_name, role, suspended_at, created_at, updated_at`
+
+type userRepo struct {
+	s *sqlStore
+}
+
+func scanUser(row rowScanner) (models.User, error) {
+	var u models.User
+	var firstName, lastName sql.NullString
+	var suspendedAt sql.NullTime
+	err := row.Scan(&u.ID, &u.Email, &u.Username, &firstName, &lastName, &u.Role, &suspendedAt, &u.CreatedAt, &u.UpdatedAt)
+	if err != nil {
+		return u, notFound(err)
+	}
+	u.FirstName = firstName.String
+	u.LastName = lastName.String
+	if suspendedAt.Valid {
+		u.SuspendedAt = &suspendedAt.Time
+	}
+	return u, nil
+}
+
+func (r userRepo) Get(ctx context.Context, id string) (models.User, error) {
+	return scanUser(r.s.q.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE id = ?`, id))
+}
+
+func (r userRepo) GetByUsername(ctx context.Context, username string) (models.User, error) {
+	return scanUser(r.s.q.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE username = ?`, username))
+}
+
+func (r userRepo) GetByEmail(ctx context.Context, email string) (models.User, error) {
+	return scanUser(r.s.q.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE email = ?`, email))
+}
+
+func (r userRepo) UpdateProfile(ctx context.Context, user models.User) error {
+	return r.s.inTx(ctx, func(tx *sqlStore) error {
+		var taken bool
+		err := tx.q.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE username = ? AND id != ?)`,
+			user.Username, user.ID).Scan(&taken)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return ErrConflict
+		}
+		return affected(tx.q.ExecContext(ctx, `
+			UPDATE users SET username = ?, first_name = ?, last_name = ?, updated_at = ?
+			WHERE id = ?
+		`, user.Username, user.FirstName, user.LastName, time.Now(), user.ID))
+	})
+}
@@ -0,0 +1,88 @@
+package activity
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/saint0x/file-storage-app/backend/internal/db"
+	"github.com/saint0x/file-storage-app/backend/internal/events"
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+)
+
+// Recorder writes every domain event to the activity log.
+type Recorder struct {
+	db *db.SQLiteClient
+}
+
+func NewRecorder(db *db.SQLiteClient) *Recorder {
+	return &Recorder{db: db}
+}
+
+// HandleEvent records the event as an activity of its actor.
+func (r *Recorder) HandleEvent(ctx context.Context, event events.Event) {
+	targetType, targetID, metadata := describe(event)
+	if targetType == "" {
+		return
+	}
+
+	encoded, err := json.Marshal(metadata)
+	if err != nil {
+		log.Printf("Error encoding activity metadata for %s: %v", event.ID, err)
+		return
+	}
+
+	err = r.db.RecordActivity(models.Activity{
+		ID:         event.ID,
+		ActorID:    event.ActorID,
+		Verb:       string(event.Type),
+		TargetType: targetType,
+		TargetID:   targetID,
+		Metadata:   encoded,
+		CreatedAt:  event.OccurredAt,
+	})
+	if err != nil {
+		log.Printf("Error recording activity for %s: %v", event.ID, err)
+	}
+}
+
+// describe picks the item an event is about and the details worth keeping
+// once that item has changed or gone.
+func describe(event events.Event) (targetType, targetID string, metadata map[string]interface{}) {
+	switch p := event.Payload.(type) {
+	case events.FilePayload:
+		return "file", p.File.ID.String(), map[string]interface{}{
+			"name":         p.File.Name,
+			"content_type": p.File.ContentType,
+			"size":         p.File.Size,
+		}
+	case events.FileMovedPayload:
+		return "file", p.File.ID.String(), map[string]interface{}{
+			"name":           p.File.Name,
+			"from_folder_id": p.FromFolderID,
+			"to_folder_id":   p.ToFolderID,
+		}
+	case events.FolderPayload:
+		return "folder", p.Folder.ID.String(), map[string]interface{}{"name": p.Folder.Name}
+	case events.SharePayload:
+		return "file", p.FileID, map[string]interface{}{"name": p.FileName, "user_ids": p.UserIDs}
+	case events.FriendPayload:
+		other := p.FriendID
+		if other == event.ActorID {
+			other = p.UserID
+		}
+		return "user", other, map[string]interface{}{"friendship_id": p.FriendshipID, "status": p.Status}
+	case events.CollectionPayload:
+		return "collection", p.Collection.ID.String(), map[string]interface{}{"name": p.Collection.Name}
+	case events.CollectionMemberPayload:
+		return "collection", p.CollectionID, map[string]interface{}{"user_id": p.UserID, "role": p.Role}
+	case events.CollectionFilesPayload:
+		return "collection", p.CollectionID, map[string]interface{}{"file_ids": p.FileIDs}
+	case events.CommentPayload:
+		return string(p.Comment.TargetType), p.Comment.TargetID, map[string]interface{}{
+			"comment_id": p.Comment.ID,
+			"parent_id":  p.Comment.ParentID,
+		}
+	}
+	return "", "", nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/saint0x/file-storage-app/backend/internal/models"
 	"github.com/sashabaranov/go-openai"
@@ -76,6 +77,60 @@ Respond with a JSON object containing an array of folders, each with a name and
 	return &result, nil
 }
 
+type TagSuggestionResponse struct {
+	Tags []string `json:"tags"`
+}
+
+// SuggestTags asks the model for short tags describing a file, preferring the
+// user's existing tags where they fit.
+func (p *Processor) SuggestTags(ctx context.Context, file models.File, existingTags []string) ([]string, error) {
+	prompt := fmt.Sprintf(`Suggest up to 5 short, lowercase tags that describe the following file:
+
+%s
+The user already uses these tags, reuse them when they apply: %s
+
+Respond with a JSON object containing an array of tags. For example:
+{
+  "tags": ["invoices", "2023", "taxes"]
+}`, formatFilesForPrompt([]models.File{file}), strings.Join(existingTags, ", "))
+
+	resp, err := p.client.CreateChatCompletion(
+		ctx,
+		openai.ChatCompletionRequest{
+			Model: openai.GPT4,
+			Messages: []openai.ChatCompletionMessage{
+				{
+					Role:    openai.ChatMessageRoleUser,
+					Content: prompt,
+				},
+			},
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error creating chat completion: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("AI response contained no choices")
+	}
+
+	var result TagSuggestionResponse
+	if err := json.Unmarshal([]byte(resp.Choices[0].Message.Content), &result); err != nil {
+		return nil, fmt.Errorf("error unmarshaling AI response: %w", err)
+	}
+
+	tags := make([]string, 0, len(result.Tags))
+	seen := make(map[string]bool)
+	for _, tag := range result.Tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
 func formatFilesForPrompt(files []models.File) string {
 	var result string
 	for _, file := range files {
@@ -0,0 +1,12 @@
+package auth
+
+import "context"
+
+// Authenticator verifies a bearer token and returns the provider's subject
+// for the user it was issued to. ClerkService authenticates Clerk session
+// tokens in production; DevAuthenticator authenticates locally signed tokens
+// for development and testing. identity.Resolver maps subjects to internal
+// users.
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (string, error)
+}
@@ -13,6 +13,25 @@ type ClerkService struct {
 	BaseURL    string
 	SecretKey  string
 	HTTPClient *http.Client
+
+	// verifier, when set, verifies session tokens locally instead of
+	// asking Clerk about each one.
+	verifier *JWTVerifier
+	// sessions, when set, reports sessions that ended before their tokens
+	// expired.
+	sessions SessionStore
+}
+
+// ErrSessionEnded is returned for tokens of a session that has been ended.
+var ErrSessionEnded = errors.New("session has ended")
+
+// ErrTokenRejected is returned for tokens the Clerk API does not accept.
+var ErrTokenRejected = errors.New("token rejected by Clerk")
+
+// SessionStore reports whether a session has ended, as learned from Clerk's
+// session webhooks.
+type SessionStore interface {
+	SessionEnded(sessionID string) (bool, error)
 }
 
 func NewClerkService() *ClerkService {
@@ -26,9 +45,27 @@ func (c *ClerkService) SetSecretKey(key string) {
 	c.SecretKey = key
 }
 
+// SetJWTVerifier makes the service verify session tokens offline with v.
+func (c *ClerkService) SetJWTVerifier(v *JWTVerifier) {
+	c.verifier = v
+}
+
+// SetSessionStore makes locally verified tokens be rejected once the session
+// they belong to has ended. Tokens verified by the Clerk API are already
+// checked against the session.
+func (c *ClerkService) SetSessionStore(sessions SessionStore) {
+	c.sessions = sessions
+}
+
+// SessionClaims are the claims of a Clerk session token.
 type SessionClaims struct {
-	Subject string `json:"sub"`
-	// Add other relevant claims as needed
+	Subject         string `json:"sub"`
+	Issuer          string `json:"iss"`
+	AuthorizedParty string `json:"azp,omitempty"`
+	SessionID       string `json:"sid,omitempty"`
+	ExpiresAt       int64  `json:"exp"`
+	NotBefore       int64  `json:"nbf,omitempty"`
+	IssuedAt        int64  `json:"iat,omitempty"`
 }
 
 type ContextKey string
@@ -37,7 +74,26 @@ const (
 	UserIDContextKey ContextKey = "user_id"
 )
 
-func (cs *ClerkService) ValidateAndExtractUserID(ctx context.Context, token string) (string, error) {
+// Authenticate verifies a session token and returns the Clerk user ID it was
+// issued to.
+func (cs *ClerkService) Authenticate(ctx context.Context, token string) (string, error) {
+	if cs.verifier != nil {
+		claims, err := cs.verifier.Verify(ctx, token)
+		if err != nil {
+			return "", err
+		}
+		if cs.sessions != nil && claims.SessionID != "" {
+			ended, err := cs.sessions.SessionEnded(claims.SessionID)
+			if err != nil {
+				return "", err
+			}
+			if ended {
+				return "", ErrSessionEnded
+			}
+		}
+		return claims.Subject, nil
+	}
+
 	req, err := http.NewRequestWithContext(ctx, "GET", cs.BaseURL+"/tokens/verify", nil)
 	if err != nil {
 		return "", err
@@ -50,12 +106,12 @@ func (cs *ClerkService) ValidateAndExtractUserID(ctx context.Context, token stri
 
 	resp, err := cs.HTTPClient.Do(req)
 	if err != nil {
-		return "", err
+		return "", fmt.Errorf("%w: %v", ErrProviderUnavailable, err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("failed to verify token: %s", resp.Status)
+	if err := verifyResponseError(resp); err != nil {
+		return "", err
 	}
 
 	var claims struct {
@@ -64,13 +120,17 @@ func (cs *ClerkService) ValidateAndExtractUserID(ctx context.Context, token stri
 		} `json:"data"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&claims); err != nil {
-		return "", err
+		return "", fmt.Errorf("%w: failed to decode token verification: %v", ErrProviderUnavailable, err)
 	}
 
 	return claims.Data.ID, nil
 }
 
 func (cs *ClerkService) VerifyToken(token string) (*SessionClaims, error) {
+	if cs.verifier != nil {
+		return cs.verifier.Verify(context.Background(), token)
+	}
+
 	req, err := http.NewRequest("GET", fmt.Sprintf("%s/tokens/verify", cs.BaseURL), nil)
 	if err != nil {
 		return nil, err
@@ -83,24 +143,38 @@ func (cs *ClerkService) VerifyToken(token string) (*SessionClaims, error) {
 
 	resp, err := cs.HTTPClient.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %v", ErrProviderUnavailable, err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to verify token: %s", resp.Status)
+	if err := verifyResponseError(resp); err != nil {
+		return nil, err
 	}
 
 	var claims SessionClaims
 	if err := json.NewDecoder(resp.Body).Decode(&claims); err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: failed to decode token verification: %v", ErrProviderUnavailable, err)
 	}
 
 	return &claims, nil
 }
 
+// verifyResponseError returns the error for a token verification response
+// that is not a success: ErrProviderUnavailable if Clerk failed or is rate
+// limiting us, and ErrTokenRejected if it turned the token down.
+func verifyResponseError(resp *http.Response) error {
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return nil
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+		return fmt.Errorf("%w: failed to verify token: %s", ErrProviderUnavailable, resp.Status)
+	default:
+		return fmt.Errorf("%w: %s", ErrTokenRejected, resp.Status)
+	}
+}
+
 func (cs *ClerkService) GetUser(ctx context.Context, userID string) (map[string]interface{}, error) {
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s/users/%s", cs.BaseURL, userID), nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/users/%s", cs.BaseURL, userID), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -109,22 +183,64 @@ func (cs *ClerkService) GetUser(ctx context.Context, userID string) (map[string]
 
 	resp, err := cs.HTTPClient.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %v", ErrProviderUnavailable, err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+		return nil, fmt.Errorf("%w: failed to get user: %s", ErrProviderUnavailable, resp.Status)
+	}
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("failed to get user: %s", resp.Status)
 	}
 
 	var user map[string]interface{}
 	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: failed to decode user: %v", ErrProviderUnavailable, err)
 	}
 
 	return user, nil
 }
 
+// Profile fetches the profile of a Clerk user. Only the primary email
+// address is used, and it is reported as verified only if Clerk has
+// verified it.
+func (cs *ClerkService) Profile(ctx context.Context, userID string) (Profile, error) {
+	user, err := cs.GetUser(ctx, userID)
+	if err != nil {
+		return Profile{}, err
+	}
+	return ClerkUserProfile(user), nil
+}
+
+// ClerkUserProfile extracts the profile from a Clerk user object, as
+// returned by the API or sent in a user webhook.
+func ClerkUserProfile(user map[string]interface{}) Profile {
+	profile := Profile{
+		Username:  stringField(user, "username"),
+		FirstName: stringField(user, "first_name"),
+		LastName:  stringField(user, "last_name"),
+	}
+	primaryID := stringField(user, "primary_email_address_id")
+	addresses, _ := user["email_addresses"].([]interface{})
+	for _, a := range addresses {
+		address, ok := a.(map[string]interface{})
+		if !ok || stringField(address, "id") != primaryID {
+			continue
+		}
+		profile.Email = stringField(address, "email_address")
+		if verification, ok := address["verification"].(map[string]interface{}); ok {
+			profile.EmailVerified = stringField(verification, "status") == "verified"
+		}
+	}
+	return profile
+}
+
+func stringField(m map[string]interface{}, key string) string {
+	s, _ := m[key].(string)
+	return s
+}
+
 func (cs *ClerkService) ListUsers(ctx context.Context) ([]map[string]interface{}, error) {
 	req, err := http.NewRequest("GET", fmt.Sprintf("%s/users", cs.BaseURL), nil)
 	if err != nil {
@@ -179,32 +295,3 @@ func ExtractBearerToken(r *http.Request) (string, error) {
 
 	return parts[1], nil
 }
-
-// AuthenticateUser authenticates a user using the provided token
-func (cs *ClerkService) AuthenticateUser(ctx context.Context, token string) (context.Context, error) {
-	userID, err := cs.ValidateAndExtractUserID(ctx, token)
-	if err != nil {
-		return ctx, err
-	}
-
-	return SetUserIDInContext(ctx, userID), nil
-}
-
-// Middleware for authenticating requests
-func (cs *ClerkService) AuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		token, err := ExtractBearerToken(r)
-		if err != nil {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
-		}
-
-		ctx, err := cs.AuthenticateUser(r.Context(), token)
-		if err != nil {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
-		}
-
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
-}
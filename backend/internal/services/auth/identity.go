@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"context"
+	"errors"
+
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+)
+
+// Identity provider names, as recorded against the users they authenticate.
+const (
+	ProviderClerk = "clerk"
+	ProviderDev   = "dev"
+	// ProviderToken marks users authenticated with a personal access token.
+	ProviderToken = "token"
+)
+
+// ErrUnauthenticated wraps the reason a token was rejected, as opposed to a
+// failure to look up or provision the user it belongs to.
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// ErrAccountSuspended is returned for valid tokens of a suspended user.
+var ErrAccountSuspended = errors.New("account suspended")
+
+// ErrProviderUnavailable wraps failures to reach the identity provider or
+// fetch its signing keys. They say nothing about the token, which can be
+// tried again once the provider is back.
+var ErrProviderUnavailable = errors.New("identity provider unavailable")
+
+// tokenRejections are the errors authenticators return for tokens that are
+// not valid, as opposed to tokens they could not check.
+var tokenRejections = []error{
+	ErrMalformedToken,
+	ErrInvalidSignature,
+	ErrTokenExpired,
+	ErrTokenNotYetValid,
+	ErrInvalidIssuer,
+	ErrInvalidAudience,
+	ErrUnknownKey,
+	ErrSessionEnded,
+	ErrTokenRejected,
+}
+
+// IsTokenRejected reports whether err is an authenticator's verdict that a
+// token is not valid.
+func IsTokenRejected(err error) bool {
+	for _, rejection := range tokenRejections {
+		if errors.Is(err, rejection) {
+			return true
+		}
+	}
+	return false
+}
+
+// Profile is what an identity provider knows about one of its users. It is
+// used to create the internal user the first time they sign in.
+type Profile struct {
+	Email         string
+	EmailVerified bool
+	Username      string
+	FirstName     string
+	LastName      string
+}
+
+// ProfileSource looks up the profile of a subject authenticated by the same
+// provider.
+type ProfileSource interface {
+	Profile(ctx context.Context, subject string) (Profile, error)
+}
+
+// CurrentUser is the internal user a request was authenticated as.
+type CurrentUser struct {
+	ID       string          `json:"id"`
+	Email    string          `json:"email"`
+	Username string          `json:"username"`
+	Role     models.UserRole `json:"role"`
+	Provider string          `json:"provider"`
+	Subject  string          `json:"subject"`
+
+	// TokenID and Scopes are set when the request was authenticated with a
+	// personal access token rather than a session.
+	TokenID string              `json:"token_id,omitempty"`
+	Scopes  []models.TokenScope `json:"scopes,omitempty"`
+}
+
+// IsAdmin reports whether the user is an administrator.
+func (u CurrentUser) IsAdmin() bool {
+	return u.Role == models.RoleAdmin
+}
+
+// HasScope reports whether the user may act with scope. Sessions have every
+// scope; tokens only the ones they were granted.
+func (u CurrentUser) HasScope(scope models.TokenScope) bool {
+	if u.TokenID == "" {
+		return true
+	}
+	return models.PersonalAccessToken{Scopes: u.Scopes}.HasScope(scope)
+}
+
+// UserAuthenticator authenticates a bearer token as an internal user.
+type UserAuthenticator interface {
+	AuthenticateUser(ctx context.Context, token string) (CurrentUser, error)
+}
+
+const currentUserContextKey ContextKey = "current_user"
+
+// SetCurrentUserInContext sets the current user in the context, along with
+// their ID for GetUserIDFromContext.
+func SetCurrentUserInContext(ctx context.Context, user CurrentUser) context.Context {
+	ctx = context.WithValue(ctx, currentUserContextKey, user)
+	return SetUserIDInContext(ctx, user.ID)
+}
+
+// GetCurrentUserFromContext retrieves the current user from the context
+func GetCurrentUserFromContext(ctx context.Context) (CurrentUser, error) {
+	user, ok := ctx.Value(currentUserContextKey).(CurrentUser)
+	if !ok {
+		return CurrentUser{}, errors.New("current user not found in context")
+	}
+	return user, nil
+}
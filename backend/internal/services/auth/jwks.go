@@ -0,0 +1,260 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrUnknownKey is returned for a token signed with a key that is not in the
+// key set, even after refreshing it. If the key set cannot be fetched, the
+// error wraps ErrProviderUnavailable instead.
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// KeySource looks up the public key a token was signed with by its key ID.
+// Keys are *rsa.PublicKey or P-256 *ecdsa.PublicKey.
+type KeySource interface {
+	Key(ctx context.Context, kid string) (crypto.PublicKey, error)
+}
+
+// StaticKeys is a fixed key set, for tests and for providers whose keys
+// never rotate.
+type StaticKeys map[string]crypto.PublicKey
+
+func (k StaticKeys) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	key, ok := k[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return key, nil
+}
+
+const (
+	// DefaultJWKSRefreshInterval is how often the key set is refetched in
+	// the background.
+	DefaultJWKSRefreshInterval = time.Hour
+
+	// An unknown key ID triggers a refetch, so that newly rotated keys are
+	// picked up straight away, but no more often than this.
+	jwksMinRefreshInterval = time.Minute
+)
+
+// JWKSCache holds the RSA and P-256 keys of a JSON Web Key Set fetched from a URL. It
+// is refreshed in the background by Run, and on demand when a token names a
+// key it does not have. If a refresh fails the previous keys stay in use.
+type JWKSCache struct {
+	url             string
+	client          *http.Client
+	refreshInterval time.Duration
+
+	mu          sync.RWMutex
+	keys        map[string]crypto.PublicKey
+	lastRefresh time.Time
+
+	// refreshMu serialises fetches so that concurrent requests for an
+	// unknown key only trigger one.
+	refreshMu sync.Mutex
+
+	done chan struct{}
+}
+
+func NewJWKSCache(url string, client *http.Client) *JWKSCache {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &JWKSCache{
+		url:             url,
+		client:          client,
+		refreshInterval: DefaultJWKSRefreshInterval,
+		keys:            make(map[string]crypto.PublicKey),
+		done:            make(chan struct{}),
+	}
+}
+
+// SetRefreshInterval sets how often Run refetches the key set.
+func (c *JWKSCache) SetRefreshInterval(interval time.Duration) {
+	c.refreshInterval = interval
+}
+
+// Key returns the key with the given ID, refetching the key set if it is
+// not known yet.
+func (c *JWKSCache) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	c.mu.RLock()
+	key, ok := c.keys[kid]
+	c.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+
+	if err := c.refresh(ctx, false); err != nil {
+		return nil, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if key, ok := c.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+// Run refreshes the key set every refresh interval until Stop is called.
+func (c *JWKSCache) Run() {
+	if err := c.refresh(context.Background(), true); err != nil {
+		log.Printf("Error fetching JWKS: %v", err)
+	}
+
+	ticker := time.NewTicker(c.refreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.refresh(context.Background(), true); err != nil {
+				log.Printf("Error refreshing JWKS: %v", err)
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// Stop stops Run.
+func (c *JWKSCache) Stop() {
+	close(c.done)
+}
+
+// refresh fetches the key set, unless it was fetched within the minimum
+// refresh interval and force is false.
+func (c *JWKSCache) refresh(ctx context.Context, force bool) error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	c.mu.RLock()
+	recent := time.Since(c.lastRefresh) < jwksMinRefreshInterval
+	c.mu.RUnlock()
+	if recent && !force {
+		return nil
+	}
+
+	keys, err := c.fetch(ctx)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Failed fetches count too, so that a key set that is down is not
+	// hammered by every request carrying an unknown key.
+	c.lastRefresh = time.Now()
+	if err != nil {
+		return err
+	}
+	c.keys = keys
+	return nil
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (c *JWKSCache) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to fetch JWKS: %v", ErrProviderUnavailable, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: failed to fetch JWKS: %s", ErrProviderUnavailable, resp.Status)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("%w: failed to decode JWKS: %v", ErrProviderUnavailable, err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		var key crypto.PublicKey
+		var err error
+		switch {
+		case k.Kty == "RSA" && (k.Alg == "" || k.Alg == "RS256"):
+			key, err = parseRSAKey(k.N, k.E)
+		case k.Kty == "EC" && k.Crv == "P-256" && (k.Alg == "" || k.Alg == "ES256"):
+			key, err = parseP256Key(k.X, k.Y)
+		default:
+			continue
+		}
+		if err != nil {
+			log.Printf("Skipping JWKS key %s: %v", k.Kid, err)
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%w: JWKS contains no usable signing keys", ErrProviderUnavailable)
+	}
+	return keys, nil
+}
+
+func parseRSAKey(n, e string) (*rsa.PublicKey, error) {
+	nBytes, err := base64.RawURLEncoding.DecodeString(n)
+	if err != nil {
+		return nil, fmt.Errorf("invalid modulus: %w", err)
+	}
+	eBytes, err := base64.RawURLEncoding.DecodeString(e)
+	if err != nil {
+		return nil, fmt.Errorf("invalid exponent: %w", err)
+	}
+	exponent := new(big.Int).SetBytes(eBytes)
+	if !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+		return nil, errors.New("invalid exponent")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(nBytes), E: int(exponent.Int64())}, nil
+}
+
+func parseP256Key(x, y string) (*ecdsa.PublicKey, error) {
+	xBytes, err := base64.RawURLEncoding.DecodeString(x)
+	if err != nil || len(xBytes) != 32 {
+		return nil, errors.New("invalid x coordinate")
+	}
+	yBytes, err := base64.RawURLEncoding.DecodeString(y)
+	if err != nil || len(yBytes) != 32 {
+		return nil, errors.New("invalid y coordinate")
+	}
+	// Rejects points that are not on the curve.
+	point := append(append([]byte{4}, xBytes...), yBytes...)
+	if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+		return nil, fmt.Errorf("invalid point: %w", err)
+	}
+	return &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(xBytes),
+		Y:     new(big.Int).SetBytes(yBytes),
+	}, nil
+}
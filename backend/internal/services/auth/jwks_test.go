@@ -0,0 +1,239 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// jwksServer serves a key set that tests can change, and counts fetches.
+type jwksServer struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	keys    []map[string]string
+	failing bool
+	fetches int
+}
+
+func newJWKSServer(t *testing.T) *jwksServer {
+	t.Helper()
+	s := &jwksServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.fetches++
+		if s.failing {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": s.keys})
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// serve replaces the key set with the public halves of keys.
+func (s *jwksServer) serve(keys map[string]crypto.Signer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = nil
+	for kid, key := range keys {
+		s.keys = append(s.keys, toJWK(kid, key.Public()))
+	}
+}
+
+func (s *jwksServer) setFailing(failing bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failing = failing
+}
+
+func (s *jwksServer) fetchCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fetches
+}
+
+func toJWK(kid string, key crypto.PublicKey) map[string]string {
+	b64 := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return map[string]string{
+			"kty": "RSA", "kid": kid, "use": "sig", "alg": "RS256",
+			"n": b64(k.N.Bytes()), "e": b64(big.NewInt(int64(k.E)).Bytes()),
+		}
+	case *ecdsa.PublicKey:
+		return map[string]string{
+			"kty": "EC", "kid": kid, "use": "sig", "alg": "ES256", "crv": "P-256",
+			"x": b64(k.X.FillBytes(make([]byte, 32))), "y": b64(k.Y.FillBytes(make([]byte, 32))),
+		}
+	}
+	panic("unsupported key type")
+}
+
+// expireRefresh lets the next unknown key ID refetch the key set straight
+// away, as if the minimum refresh interval had passed.
+func expireRefresh(c *JWKSCache) {
+	c.mu.Lock()
+	c.lastRefresh = time.Now().Add(-jwksMinRefreshInterval)
+	c.mu.Unlock()
+}
+
+func TestJWKSCacheKeys(t *testing.T) {
+	rsaKey := generateRSAKey(t)
+	ecKey := generateECKey(t)
+	server := newJWKSServer(t)
+	server.serve(map[string]crypto.Signer{"rsa": rsaKey, "ec": ecKey})
+	server.keys = append(server.keys,
+		map[string]string{"kty": "oct", "kid": "hmac", "k": "c2VjcmV0"},
+		map[string]string{"kty": "EC", "kid": "p384", "crv": "P-384", "x": "AA", "y": "AA"},
+		map[string]string{"kty": "EC", "kid": "bad-point", "crv": "P-256", "x": base64.RawURLEncoding.EncodeToString(make([]byte, 32)),
+			"y": base64.RawURLEncoding.EncodeToString(make([]byte, 32))},
+		map[string]string{"kty": "RSA", "kid": "encryption", "use": "enc", "n": "AQAB", "e": "AQAB"},
+	)
+
+	cache := NewJWKSCache(server.URL, nil)
+	ctx := context.Background()
+
+	key, err := cache.Key(ctx, "rsa")
+	if err != nil {
+		t.Fatalf("Key(rsa) error = %v", err)
+	}
+	if got, ok := key.(*rsa.PublicKey); !ok || !got.Equal(&rsaKey.PublicKey) {
+		t.Errorf("Key(rsa) = %v, want the served RSA key", key)
+	}
+	key, err = cache.Key(ctx, "ec")
+	if err != nil {
+		t.Fatalf("Key(ec) error = %v", err)
+	}
+	if got, ok := key.(*ecdsa.PublicKey); !ok || !got.Equal(&ecKey.PublicKey) {
+		t.Errorf("Key(ec) = %v, want the served EC key", key)
+	}
+	for _, kid := range []string{"hmac", "p384", "bad-point", "encryption"} {
+		if _, err := cache.Key(ctx, kid); !errors.Is(err, ErrUnknownKey) {
+			t.Errorf("Key(%s) error = %v, want %v", kid, err, ErrUnknownKey)
+		}
+	}
+	if n := server.fetchCount(); n != 1 {
+		t.Errorf("key set fetched %d times, want 1", n)
+	}
+}
+
+func TestJWKSCacheRotation(t *testing.T) {
+	oldKey := generateRSAKey(t)
+	newKey := generateECKey(t)
+	server := newJWKSServer(t)
+	server.serve(map[string]crypto.Signer{"old": oldKey})
+
+	cache := NewJWKSCache(server.URL, nil)
+	v := newTestVerifier(cache)
+	ctx := context.Background()
+
+	oldToken := signToken(t, "RS256", "old", oldKey, validClaims())
+	newToken := signToken(t, "ES256", "new", newKey, validClaims())
+	if _, err := v.Verify(ctx, oldToken); err != nil {
+		t.Fatalf("Verify() with the old key error = %v", err)
+	}
+
+	server.serve(map[string]crypto.Signer{"new": newKey})
+
+	// Unknown key IDs do not refetch more than once per minimum interval.
+	if _, err := v.Verify(ctx, newToken); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("Verify() straight after the last fetch error = %v, want %v", err, ErrUnknownKey)
+	}
+	if n := server.fetchCount(); n != 1 {
+		t.Fatalf("key set fetched %d times, want 1", n)
+	}
+
+	expireRefresh(cache)
+	if _, err := v.Verify(ctx, newToken); err != nil {
+		t.Fatalf("Verify() with the rotated key error = %v", err)
+	}
+	if _, err := v.Verify(ctx, oldToken); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Verify() with the retired key error = %v, want %v", err, ErrUnknownKey)
+	}
+}
+
+func TestJWKSCacheKeepsKeysWhenRefreshFails(t *testing.T) {
+	key := generateRSAKey(t)
+	server := newJWKSServer(t)
+	server.serve(map[string]crypto.Signer{"rsa": key})
+
+	cache := NewJWKSCache(server.URL, nil)
+	ctx := context.Background()
+	if _, err := cache.Key(ctx, "rsa"); err != nil {
+		t.Fatalf("Key() error = %v", err)
+	}
+
+	server.setFailing(true)
+	if err := cache.refresh(ctx, true); err == nil {
+		t.Fatal("refresh() from a failing server succeeded")
+	}
+	if _, err := cache.Key(ctx, "rsa"); err != nil {
+		t.Errorf("Key() after a failed refresh error = %v, want the previous key", err)
+	}
+
+	// An unknown key ID reports the fetch failure rather than an unknown key.
+	expireRefresh(cache)
+	if _, err := cache.Key(ctx, "other"); err == nil || errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Key(other) from a failing server error = %v, want a fetch error", err)
+	}
+}
+
+func TestJWKSCacheRefreshesOnceForConcurrentUnknownKeys(t *testing.T) {
+	key := generateRSAKey(t)
+	server := newJWKSServer(t)
+	server.serve(map[string]crypto.Signer{"rsa": key})
+	cache := NewJWKSCache(server.URL, nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cache.Key(context.Background(), "unknown")
+		}()
+	}
+	wg.Wait()
+	if n := server.fetchCount(); n != 1 {
+		t.Errorf("key set fetched %d times, want 1", n)
+	}
+}
+
+func TestJWKSCacheRun(t *testing.T) {
+	oldKey := generateRSAKey(t)
+	newKey := generateRSAKey(t)
+	server := newJWKSServer(t)
+	server.serve(map[string]crypto.Signer{"old": oldKey})
+
+	cache := NewJWKSCache(server.URL, nil)
+	cache.SetRefreshInterval(10 * time.Millisecond)
+	go cache.Run()
+	defer cache.Stop()
+
+	server.serve(map[string]crypto.Signer{"new": newKey})
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		cache.mu.RLock()
+		_, ok := cache.keys["new"]
+		cache.mu.RUnlock()
+		if ok {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("background refresh did not pick up the rotated key")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
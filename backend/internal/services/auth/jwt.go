@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// Errors returned by JWTVerifier.Verify.
+var (
+	ErrMalformedToken   = errors.New("malformed token")
+	ErrInvalidSignature = errors.New("invalid token signature")
+	ErrTokenExpired     = errors.New("token has expired")
+	ErrTokenNotYetValid = errors.New("token is not valid yet")
+	ErrInvalidIssuer    = errors.New("token issuer is not trusted")
+	ErrInvalidAudience  = errors.New("token authorized party is not allowed")
+)
+
+// DefaultClockSkew is how far the verifier tolerates the token issuer's
+// clock differing from ours.
+const DefaultClockSkew = 30 * time.Second
+
+// JWTVerifier verifies RS256- or ES256-signed session JWTs offline, against
+// keys from a KeySource.
+type JWTVerifier struct {
+	keys   KeySource
+	issuer string
+
+	// authorizedParties, if set, lists the origins whose tokens are
+	// accepted, checked against the azp claim.
+	authorizedParties map[string]bool
+
+	clockSkew time.Duration
+	now       func() time.Time
+}
+
+// NewJWTVerifier returns a verifier for tokens issued by issuer and signed
+// with keys from keys. Tokens carrying an azp claim must name one of
+// authorizedParties, unless it is empty.
+func NewJWTVerifier(keys KeySource, issuer string, authorizedParties []string) *JWTVerifier {
+	v := &JWTVerifier{
+		keys:      keys,
+		issuer:    issuer,
+		clockSkew: DefaultClockSkew,
+		now:       time.Now,
+	}
+	if len(authorizedParties) > 0 {
+		v.authorizedParties = make(map[string]bool, len(authorizedParties))
+		for _, party := range authorizedParties {
+			v.authorizedParties[strings.TrimSpace(party)] = true
+		}
+	}
+	return v
+}
+
+// SetClockSkew sets how far token times may be off.
+func (v *JWTVerifier) SetClockSkew(skew time.Duration) {
+	v.clockSkew = skew
+}
+
+// Verify checks a token's signature, issuer, authorized party and validity
+// period and returns its claims.
+func (v *JWTVerifier) Verify(ctx context.Context, token string) (*SessionClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformedToken
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+	if header.Alg != "RS256" && header.Alg != "ES256" {
+		return nil, fmt.Errorf("%w: unsupported algorithm %q", ErrMalformedToken, header.Alg)
+	}
+
+	key, err := v.keys.Key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if !verifySignature(header.Alg, key, digest[:], signature) {
+		return nil, ErrInvalidSignature
+	}
+
+	var claims SessionClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	if err := v.validate(&claims); err != nil {
+		return nil, err
+	}
+	return &claims, nil
+}
+
+func (v *JWTVerifier) validate(claims *SessionClaims) error {
+	now := v.now()
+	if claims.Subject == "" {
+		return fmt.Errorf("%w: missing subject", ErrMalformedToken)
+	}
+	if claims.ExpiresAt == 0 {
+		return fmt.Errorf("%w: missing expiry", ErrMalformedToken)
+	}
+	if now.After(time.Unix(claims.ExpiresAt, 0).Add(v.clockSkew)) {
+		return ErrTokenExpired
+	}
+	if claims.NotBefore != 0 && now.Add(v.clockSkew).Before(time.Unix(claims.NotBefore, 0)) {
+		return ErrTokenNotYetValid
+	}
+	if v.issuer != "" && claims.Issuer != v.issuer {
+		return ErrInvalidIssuer
+	}
+	if v.authorizedParties != nil && claims.AuthorizedParty != "" && !v.authorizedParties[claims.AuthorizedParty] {
+		return ErrInvalidAudience
+	}
+	return nil
+}
+
+// verifySignature checks a signature over a SHA-256 digest. The key must be
+// of the type the algorithm calls for, so that a token cannot pick how its
+// signature is checked.
+func verifySignature(alg string, key crypto.PublicKey, digest, signature []byte) bool {
+	switch alg {
+	case "RS256":
+		rsaKey, ok := key.(*rsa.PublicKey)
+		return ok && rsa.VerifyPKCS1v15(rsaKey, crypto.SHA256, digest, signature) == nil
+	case "ES256":
+		// The signature is R and S as fixed-size big-endian integers
+		// (RFC 7518, section 3.4), not ASN.1.
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok || ecKey.Curve.Params().Name != "P-256" || len(signature) != 64 {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(ecKey, digest, r, s)
+	}
+	return false
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return ErrMalformedToken
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return ErrMalformedToken
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testIssuer = "https://clerk.example.com"
+
+// testNow is the time tokens are checked at.
+var testNow = time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func generateRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+	return key
+}
+
+func generateECKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate EC key: %v", err)
+	}
+	return key
+}
+
+// signToken returns a token with the given header fields and claims, signed
+// with key: RS256 for RSA keys and ES256 for EC keys.
+func signToken(t *testing.T, alg, kid string, key crypto.Signer, claims interface{}) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("failed to encode claims: %v", err)
+	}
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(input))
+
+	var signature []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, k, digest[:])
+		if err == nil {
+			signature = make([]byte, 64)
+			r.FillBytes(signature[:32])
+			s.FillBytes(signature[32:])
+		}
+	}
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// validClaims are claims that pass verification at testNow.
+func validClaims() SessionClaims {
+	return SessionClaims{
+		Subject:         "user_123",
+		Issuer:          testIssuer,
+		AuthorizedParty: "https://app.example.com",
+		SessionID:       "sess_123",
+		IssuedAt:        testNow.Add(-time.Minute).Unix(),
+		NotBefore:       testNow.Add(-time.Minute).Unix(),
+		ExpiresAt:       testNow.Add(time.Minute).Unix(),
+	}
+}
+
+func newTestVerifier(keys KeySource) *JWTVerifier {
+	v := NewJWTVerifier(keys, testIssuer, []string{"https://app.example.com"})
+	v.now = func() time.Time { return testNow }
+	return v
+}
+
+func TestJWTVerifierVerify(t *testing.T) {
+	rsaKey := generateRSAKey(t)
+	ecKey := generateECKey(t)
+	otherKey := generateRSAKey(t)
+	keys := StaticKeys{"rsa": &rsaKey.PublicKey, "ec": &ecKey.PublicKey}
+
+	withClaims := func(change func(*SessionClaims)) SessionClaims {
+		claims := validClaims()
+		change(&claims)
+		return claims
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"valid RS256", signToken(t, "RS256", "rsa", rsaKey, validClaims()), nil},
+		{"valid ES256", signToken(t, "ES256", "ec", ecKey, validClaims()), nil},
+		{"no authorized party", signToken(t, "RS256", "rsa", rsaKey, withClaims(func(c *SessionClaims) { c.AuthorizedParty = "" })), nil},
+		{"expired within clock skew", signToken(t, "RS256", "rsa", rsaKey, withClaims(func(c *SessionClaims) {
+			c.ExpiresAt = testNow.Add(-DefaultClockSkew / 2).Unix()
+		})), nil},
+		{"expired", signToken(t, "RS256", "rsa", rsaKey, withClaims(func(c *SessionClaims) {
+			c.ExpiresAt = testNow.Add(-time.Minute).Unix()
+		})), ErrTokenExpired},
+		{"expired EC", signToken(t, "ES256", "ec", ecKey, withClaims(func(c *SessionClaims) {
+			c.ExpiresAt = testNow.Add(-time.Minute).Unix()
+		})), ErrTokenExpired},
+		{"not yet valid", signToken(t, "RS256", "rsa", rsaKey, withClaims(func(c *SessionClaims) {
+			c.NotBefore = testNow.Add(time.Minute).Unix()
+		})), ErrTokenNotYetValid},
+		{"wrong issuer", signToken(t, "RS256", "rsa", rsaKey, withClaims(func(c *SessionClaims) {
+			c.Issuer = "https://evil.example.com"
+		})), ErrInvalidIssuer},
+		{"wrong audience", signToken(t, "RS256", "rsa", rsaKey, withClaims(func(c *SessionClaims) {
+			c.AuthorizedParty = "https://evil.example.com"
+		})), ErrInvalidAudience},
+		{"wrong audience EC", signToken(t, "ES256", "ec", ecKey, withClaims(func(c *SessionClaims) {
+			c.AuthorizedParty = "https://evil.example.com"
+		})), ErrInvalidAudience},
+		{"unknown kid", signToken(t, "RS256", "missing", rsaKey, validClaims()), ErrUnknownKey},
+		{"signed with another key", signToken(t, "RS256", "rsa", otherKey, validClaims()), ErrInvalidSignature},
+		{"RS256 header with EC key", signToken(t, "RS256", "ec", rsaKey, validClaims()), ErrInvalidSignature},
+		{"ES256 header with RSA key", signToken(t, "ES256", "rsa", ecKey, validClaims()), ErrInvalidSignature},
+		{"missing subject", signToken(t, "RS256", "rsa", rsaKey, withClaims(func(c *SessionClaims) { c.Subject = "" })), ErrMalformedToken},
+		{"missing expiry", signToken(t, "RS256", "rsa", rsaKey, withClaims(func(c *SessionClaims) { c.ExpiresAt = 0 })), ErrMalformedToken},
+		{"unsupported algorithm", signToken(t, "HS256", "rsa", rsaKey, validClaims()), ErrMalformedToken},
+		{"not a JWT", "not-a-token", ErrMalformedToken},
+	}
+
+	v := newTestVerifier(keys)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := v.Verify(context.Background(), tt.token)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.want)
+			}
+			if tt.want == nil && claims.Subject != "user_123" {
+				t.Errorf("Verify() subject = %q, want user_123", claims.Subject)
+			}
+		})
+	}
+}
+
+func TestJWTVerifierTamperedClaims(t *testing.T) {
+	key := generateRSAKey(t)
+	token := signToken(t, "RS256", "rsa", key, validClaims())
+
+	forged := validClaims()
+	forged.Subject = "user_admin"
+	payload, _ := json.Marshal(forged)
+	parts := strings.Split(token, ".")
+	tampered := parts[0] + "." + base64.RawURLEncoding.EncodeToString(payload) + "." + parts[2]
+
+	_, err := newTestVerifier(StaticKeys{"rsa": &key.PublicKey}).Verify(context.Background(), tampered)
+	if !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Verify() error = %v, want %v", err, ErrInvalidSignature)
+	}
+}
+
+func TestJWTVerifierClockSkew(t *testing.T) {
+	key := generateRSAKey(t)
+	claims := validClaims()
+	claims.ExpiresAt = testNow.Add(-10 * time.Second).Unix()
+	token := signToken(t, "RS256", "rsa", key, claims)
+
+	v := newTestVerifier(StaticKeys{"rsa": &key.PublicKey})
+	if _, err := v.Verify(context.Background(), token); err != nil {
+		t.Fatalf("Verify() with default skew error = %v, want nil", err)
+	}
+	v.SetClockSkew(0)
+	if _, err := v.Verify(context.Background(), token); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("Verify() without skew error = %v, want %v", err, ErrTokenExpired)
+	}
+}
@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const testWebhookSecret = "whsec_MfKQ9r8GKYqrTwjUPD8ILPZIo2LaLaSw"
+
+// signWebhook returns the Svix headers for body, signed with secret at sent.
+func signWebhook(t *testing.T, secret, id string, sent time.Time, body []byte) http.Header {
+	t.Helper()
+	key, err := base64.StdEncoding.DecodeString(secret[len("whsec_"):])
+	if err != nil {
+		t.Fatalf("invalid secret: %v", err)
+	}
+	timestamp := strconv.FormatInt(sent.Unix(), 10)
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(id + "." + timestamp + "."))
+	mac.Write(body)
+
+	header := http.Header{}
+	header.Set("svix-id", id)
+	header.Set("svix-timestamp", timestamp)
+	header.Set("svix-signature", "v1,"+base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	return header
+}
+
+func TestSvixVerifierVerify(t *testing.T) {
+	verifier, err := NewSvixVerifier(testWebhookSecret)
+	if err != nil {
+		t.Fatalf("NewSvixVerifier() error = %v", err)
+	}
+	verifier.now = func() time.Time { return testNow }
+	body := []byte(`{"type":"user.created","data":{"id":"user_1"}}`)
+	otherSecret := "whsec_" + base64.StdEncoding.EncodeToString([]byte("another signing secret"))
+
+	tests := []struct {
+		name   string
+		header func() http.Header
+		body   []byte
+		want   error
+	}{
+		{"valid", func() http.Header { return signWebhook(t, testWebhookSecret, "msg_1", testNow, body) }, body, nil},
+		{"one of several signatures", func() http.Header {
+			header := signWebhook(t, testWebhookSecret, "msg_1", testNow, body)
+			old := signWebhook(t, otherSecret, "msg_1", testNow, body)
+			header.Set("svix-signature", old.Get("svix-signature")+" v2,ignored "+header.Get("svix-signature"))
+			return header
+		}, body, nil},
+		{"missing headers", func() http.Header {
+			header := signWebhook(t, testWebhookSecret, "msg_1", testNow, body)
+			header.Del("svix-id")
+			return header
+		}, body, ErrMissingWebhookHeaders},
+		{"too old", func() http.Header {
+			return signWebhook(t, testWebhookSecret, "msg_1", testNow.Add(-DefaultWebhookTolerance-time.Second), body)
+		}, body, ErrWebhookTimestamp},
+		{"from the future", func() http.Header {
+			return signWebhook(t, testWebhookSecret, "msg_1", testNow.Add(DefaultWebhookTolerance+time.Second), body)
+		}, body, ErrWebhookTimestamp},
+		{"other secret", func() http.Header { return signWebhook(t, otherSecret, "msg_1", testNow, body) }, body, ErrInvalidWebhookSignature},
+		{"changed body", func() http.Header { return signWebhook(t, testWebhookSecret, "msg_1", testNow, body) },
+			[]byte(`{"type":"user.deleted","data":{"id":"user_1"}}`), ErrInvalidWebhookSignature},
+		{"unsupported version", func() http.Header {
+			header := signWebhook(t, testWebhookSecret, "msg_1", testNow, body)
+			header.Set("svix-signature", "v1a"+header.Get("svix-signature")[2:])
+			return header
+		}, body, ErrInvalidWebhookSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := verifier.Verify(tt.header(), tt.body); err != tt.want {
+				t.Errorf("Verify() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewSvixVerifierRejectsInvalidSecrets(t *testing.T) {
+	for _, secret := range []string{"", "whsec_", "whsec_not base64!"} {
+		if _, err := NewSvixVerifier(secret); err == nil {
+			t.Errorf("NewSvixVerifier(%q) succeeded", secret)
+		}
+	}
+}
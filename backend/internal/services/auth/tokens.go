@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// PersonalAccessTokenPrefix starts every personal access token, which tells
+// them apart from provider session tokens.
+const PersonalAccessTokenPrefix = "pat_"
+
+// tokenDisplayLength is how much of a token is kept to identify it.
+const tokenDisplayLength = len(PersonalAccessTokenPrefix) + 8
+
+// NewPersonalAccessToken returns a new random token, the hash to store for
+// it, and the prefix to show in listings.
+func NewPersonalAccessToken() (token, hash, prefix string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", "", err
+	}
+	token = PersonalAccessTokenPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return token, HashPersonalAccessToken(token), token[:tokenDisplayLength], nil
+}
+
+// IsPersonalAccessToken reports whether token looks like a personal access
+// token rather than a session token.
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, PersonalAccessTokenPrefix)
+}
+
+// HashPersonalAccessToken returns the hash a token is stored and looked up
+// by. Tokens are long and random, so an unsalted hash is enough.
+func HashPersonalAccessToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
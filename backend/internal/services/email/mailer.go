@@ -0,0 +1,131 @@
+// Package email sends notification emails: immediate ones for the
+// notification types users choose, and daily or weekly digests of the rest.
+// Emails are rendered into a queue in the database and delivered by a
+// background worker that retries failures with backoff.
+//
+// Any SMTP server will do, including a local sink such as MailHog
+// (SMTP_HOST=localhost SMTP_PORT=1025) for development.
+package email
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/smtp"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Message is an email with a plain text and an HTML body.
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+
+	// Extra headers, such as List-Unsubscribe.
+	Headers map[string]string
+}
+
+// Mailer delivers email.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// SMTPMailer delivers email through an SMTP server, using STARTTLS when the
+// server offers it.
+type SMTPMailer struct {
+	addr string
+	host string
+	auth smtp.Auth
+	from string
+}
+
+// NewSMTPMailer returns a mailer for the server at host:port. Username may be
+// empty for servers that do not require authentication.
+func NewSMTPMailer(host string, port int, username, password, from string) *SMTPMailer {
+	m := &SMTPMailer{
+		addr: host + ":" + strconv.Itoa(port),
+		host: host,
+		from: from,
+	}
+	if username != "" {
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return m
+}
+
+func (m *SMTPMailer) Send(msg Message) error {
+	body, err := m.compose(msg)
+	if err != nil {
+		return err
+	}
+	if err := smtp.SendMail(m.addr, m.auth, m.from, []string{msg.To}, body); err != nil {
+		return fmt.Errorf("failed to send email to %s: %w", msg.To, err)
+	}
+	return nil
+}
+
+// compose builds a multipart/alternative message with the text body first,
+// so that clients which understand HTML show the HTML body.
+func (m *SMTPMailer) compose(msg Message) ([]byte, error) {
+	var buf bytes.Buffer
+	parts := multipart.NewWriter(&buf)
+
+	headers := map[string]string{
+		"From":         m.from,
+		"To":           msg.To,
+		"Subject":      mime.QEncoding.Encode("utf-8", msg.Subject),
+		"Date":         time.Now().Format(time.RFC1123Z),
+		"Message-ID":   "<" + uuid.New().String() + "@" + m.host + ">",
+		"MIME-Version": "1.0",
+		"Content-Type": "multipart/alternative; boundary=" + parts.Boundary(),
+	}
+	for name, value := range msg.Headers {
+		headers[name] = value
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var out bytes.Buffer
+	for _, name := range names {
+		fmt.Fprintf(&out, "%s: %s\r\n", name, headers[name])
+	}
+	out.WriteString("\r\n")
+
+	for _, alt := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {alt.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(strings.ReplaceAll(alt.body, "\n", "\r\n"))); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+
+	out.Write(buf.Bytes())
+	return out.Bytes(), nil
+}
@@ -0,0 +1,194 @@
+package email
+
+import (
+	"bufio"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// smtpSink is an SMTP server that accepts every message and keeps it.
+type smtpSink struct {
+	ln net.Listener
+
+	mu       sync.Mutex
+	messages []sinkMessage
+}
+
+type sinkMessage struct {
+	From string
+	To   []string
+	Data string
+}
+
+func newSMTPSink(t *testing.T) *smtpSink {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	s := &smtpSink{ln: ln}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+// mailer returns an SMTPMailer that sends to the sink.
+func (s *smtpSink) mailer() *SMTPMailer {
+	return NewSMTPMailer("127.0.0.1", s.ln.Addr().(*net.TCPAddr).Port, "", "", "notifications@example.com")
+}
+
+func (s *smtpSink) serve(conn net.Conn) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 sink ready")
+
+	var msg sinkMessage
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			tp.PrintfLine("250 sink")
+		case "MAIL":
+			msg = sinkMessage{From: address(arg)}
+			tp.PrintfLine("250 OK")
+		case "RCPT":
+			msg.To = append(msg.To, address(arg))
+			tp.PrintfLine("250 OK")
+		case "DATA":
+			tp.PrintfLine("354 Go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			msg.Data = string(data)
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			tp.PrintfLine("250 OK")
+		case "QUIT":
+			tp.PrintfLine("221 Bye")
+			return
+		default:
+			tp.PrintfLine("250 OK")
+		}
+	}
+}
+
+// address extracts the address from a MAIL FROM or RCPT TO argument.
+func address(arg string) string {
+	start, end := strings.Index(arg, "<"), strings.LastIndex(arg, ">")
+	if start < 0 || end < start {
+		return arg
+	}
+	return arg[start+1 : end]
+}
+
+// received returns the messages the sink has accepted so far.
+func (s *smtpSink) received() []sinkMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]sinkMessage(nil), s.messages...)
+}
+
+// parsedMessage is a received message with its bodies decoded.
+type parsedMessage struct {
+	Header mail.Header
+	Text   string
+	HTML   string
+}
+
+func parseMessage(t *testing.T, data string) parsedMessage {
+	t.Helper()
+	m, err := mail.ReadMessage(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to parse message: %v", err)
+	}
+	mediaType, params, err := mime.ParseMediaType(m.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q, want multipart/alternative", m.Header.Get("Content-Type"))
+	}
+
+	parsed := parsedMessage{Header: m.Header}
+	parts := multipart.NewReader(m.Body, params["boundary"])
+	for {
+		part, err := parts.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("failed to read part: %v", err)
+		}
+		body, err := io.ReadAll(quotedprintable.NewReader(bufio.NewReader(part)))
+		if err != nil {
+			t.Fatalf("failed to decode part: %v", err)
+		}
+		switch {
+		case strings.HasPrefix(part.Header.Get("Content-Type"), "text/plain"):
+			parsed.Text = string(body)
+		case strings.HasPrefix(part.Header.Get("Content-Type"), "text/html"):
+			parsed.HTML = string(body)
+		}
+	}
+	return parsed
+}
+
+func TestSMTPMailerSend(t *testing.T) {
+	sink := newSMTPSink(t)
+
+	err := sink.mailer().Send(Message{
+		To:      "alice@example.com",
+		Subject: "Bob shared “report.pdf” with you",
+		Text:    "Bob shared report.pdf with you.\nOpen it in the app.",
+		HTML:    "<p>Bob shared <b>report.pdf</b> with you.</p>",
+		Headers: map[string]string{"List-Unsubscribe": "<https://api.example.com/email/unsubscribe?token=abc>"},
+	})
+	if err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	received := sink.received()
+	if len(received) != 1 {
+		t.Fatalf("sink received %d messages, want 1", len(received))
+	}
+	if received[0].From != "notifications@example.com" {
+		t.Errorf("envelope sender = %q, want notifications@example.com", received[0].From)
+	}
+	if len(received[0].To) != 1 || received[0].To[0] != "alice@example.com" {
+		t.Errorf("envelope recipients = %v, want [alice@example.com]", received[0].To)
+	}
+
+	msg := parseMessage(t, received[0].Data)
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != "Bob shared “report.pdf” with you" {
+		t.Errorf("Subject = %q (%v), want the encoded subject", subject, err)
+	}
+	if got := msg.Header.Get("List-Unsubscribe"); got != "<https://api.example.com/email/unsubscribe?token=abc>" {
+		t.Errorf("List-Unsubscribe = %q", got)
+	}
+	if msg.Text != "Bob shared report.pdf with you.\nOpen it in the app." {
+		t.Errorf("text body = %q", msg.Text)
+	}
+	if msg.HTML != "<p>Bob shared <b>report.pdf</b> with you.</p>" {
+		t.Errorf("HTML body = %q", msg.HTML)
+	}
+}
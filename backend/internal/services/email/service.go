@@ -0,0 +1,332 @@
+package email
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/saint0x/file-storage-app/backend/internal/db"
+	"github.com/saint0x/file-storage-app/backend/internal/events"
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+	"github.com/saint0x/file-storage-app/backend/internal/services/notifications"
+)
+
+const (
+	// How often the queue is checked for emails to deliver, and how many
+	// are delivered per batch.
+	deliverInterval  = 30 * time.Second
+	deliverBatchSize = 50
+
+	// How often users are checked for due digests.
+	digestInterval = 5 * time.Minute
+
+	// How long sent digest items are kept.
+	digestItemRetention = 30 * 24 * time.Hour
+
+	// Failed deliveries are retried after retryBaseDelay, doubling each
+	// time up to retryMaxDelay, and given up on after maxAttempts.
+	retryBaseDelay = time.Minute
+	retryMaxDelay  = 6 * time.Hour
+	maxAttempts    = 8
+)
+
+// Service queues notification emails and delivers them.
+type Service struct {
+	db     *db.SQLiteClient
+	mailer Mailer
+
+	// appURL is where links to items point; apiURL serves the unsubscribe
+	// endpoint.
+	appURL string
+	apiURL string
+
+	done chan struct{}
+}
+
+func NewService(db *db.SQLiteClient, mailer Mailer, appURL, apiURL string) *Service {
+	return &Service{
+		db:     db,
+		mailer: mailer,
+		appURL: strings.TrimRight(appURL, "/"),
+		apiURL: strings.TrimRight(apiURL, "/"),
+		done:   make(chan struct{}),
+	}
+}
+
+// Notify emails a user about a notification now, or holds it for their next
+// digest, depending on mode. Each event is emailed to a user at most once.
+func (s *Service) Notify(eventID string, notification models.Notification, mode models.EmailMode) error {
+	settings, err := s.db.GetEmailSettings(notification.UserID)
+	if err != nil {
+		return err
+	}
+	if settings.Unsubscribed {
+		return nil
+	}
+
+	switch mode {
+	case models.EmailImmediate:
+		return s.queueNotification(eventID, notification, settings)
+	case models.EmailDigest:
+		if settings.DigestFrequency == models.DigestOff {
+			return nil
+		}
+		return s.db.AddDigestItem(models.DigestItem{
+			UserID:     notification.UserID,
+			EventID:    eventID,
+			Type:       notification.Type,
+			ActorID:    notification.ActorID,
+			TargetType: notification.TargetType,
+			TargetID:   notification.TargetID,
+			Data:       notification.Data,
+			CreatedAt:  notification.CreatedAt,
+		})
+	}
+	return nil
+}
+
+func (s *Service) queueNotification(eventID string, notification models.Notification, settings models.EmailSettings) error {
+	to, err := s.db.GetUserEmail(notification.UserID)
+	if err != nil {
+		return err
+	}
+
+	it := s.describe(notification.Type, notification.ActorID, notification.TargetType, notification.TargetID, notification.Data)
+	text, html, err := render("notification", notificationData{
+		Item:           it,
+		SettingsURL:    s.settingsURL(),
+		UnsubscribeURL: s.unsubscribeURL(settings.UnsubscribeToken),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to render notification email: %w", err)
+	}
+
+	return s.db.EnqueueEmail(models.QueuedEmail{
+		UserID:    notification.UserID,
+		DedupeKey: "notification:" + eventID + ":" + notification.UserID,
+		To:        to,
+		Subject:   it.Summary,
+		TextBody:  text,
+		HTMLBody:  html,
+		Headers:   s.unsubscribeHeaders(settings.UnsubscribeToken),
+	})
+}
+
+// Run delivers queued email and sends digests until Stop is called.
+func (s *Service) Run() {
+	deliverTicker := time.NewTicker(deliverInterval)
+	defer deliverTicker.Stop()
+	digestTicker := time.NewTicker(digestInterval)
+	defer digestTicker.Stop()
+
+	s.sendDigests(time.Now())
+	s.deliver(time.Now())
+
+	for {
+		select {
+		case <-digestTicker.C:
+			s.sendDigests(time.Now())
+		case <-deliverTicker.C:
+			s.deliver(time.Now())
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// Stop stops Run.
+func (s *Service) Stop() {
+	close(s.done)
+}
+
+// sendDigests queues a digest for every user whose digest is due.
+func (s *Service) sendDigests(now time.Time) {
+	for _, frequency := range []models.DigestFrequency{models.DigestDaily, models.DigestWeekly} {
+		userIDs, err := s.db.GetUsersDueForDigest(frequency, now.Add(-frequency.Interval()))
+		if err != nil {
+			log.Printf("Error finding users due a %s digest: %v", frequency, err)
+			continue
+		}
+		for _, userID := range userIDs {
+			if err := s.queueDigest(userID, frequency, now); err != nil {
+				log.Printf("Error queueing digest for user %s: %v", userID, err)
+			}
+		}
+	}
+
+	if _, err := s.db.PruneDigestItems(now.Add(-digestItemRetention)); err != nil {
+		log.Printf("Error pruning digest items: %v", err)
+	}
+}
+
+func (s *Service) queueDigest(userID string, frequency models.DigestFrequency, now time.Time) error {
+	items, err := s.db.GetPendingDigestItems(userID)
+	if err != nil || len(items) == 0 {
+		return err
+	}
+	settings, err := s.db.GetEmailSettings(userID)
+	if err != nil {
+		return err
+	}
+	to, err := s.db.GetUserEmail(userID)
+	if err != nil {
+		return err
+	}
+
+	data := digestData{
+		Frequency:      string(frequency),
+		SettingsURL:    s.settingsURL(),
+		UnsubscribeURL: s.unsubscribeURL(settings.UnsubscribeToken),
+	}
+	itemIDs := make([]string, len(items))
+	for i, digestItem := range items {
+		itemIDs[i] = digestItem.ID
+		data.Items = append(data.Items, s.describe(digestItem.Type, digestItem.ActorID, digestItem.TargetType, digestItem.TargetID, digestItem.Data))
+	}
+	text, html, err := render("digest", data)
+	if err != nil {
+		return fmt.Errorf("failed to render digest email: %w", err)
+	}
+
+	subject := fmt.Sprintf("Your %s digest: %d update", frequency, len(items))
+	if len(items) != 1 {
+		subject += "s"
+	}
+	return s.db.CompleteDigest(models.QueuedEmail{
+		UserID:    userID,
+		DedupeKey: "digest:" + userID + ":" + itemIDs[len(itemIDs)-1],
+		To:        to,
+		Subject:   subject,
+		TextBody:  text,
+		HTMLBody:  html,
+		Headers:   s.unsubscribeHeaders(settings.UnsubscribeToken),
+	}, itemIDs, now)
+}
+
+// deliver sends the queued emails that are due, in batches, rescheduling
+// the ones that fail.
+func (s *Service) deliver(now time.Time) {
+	for {
+		emails, err := s.db.GetDueEmails(now, deliverBatchSize)
+		if err != nil {
+			log.Printf("Error fetching queued emails: %v", err)
+			return
+		}
+		for _, queued := range emails {
+			s.deliverOne(queued, now)
+		}
+		if len(emails) < deliverBatchSize {
+			return
+		}
+	}
+}
+
+func (s *Service) deliverOne(queued models.QueuedEmail, now time.Time) {
+	err := s.mailer.Send(Message{
+		To:      queued.To,
+		Subject: queued.Subject,
+		Text:    queued.TextBody,
+		HTML:    queued.HTMLBody,
+		Headers: queued.Headers,
+	})
+	if err == nil {
+		if err := s.db.MarkEmailSent(queued.ID, now); err != nil {
+			log.Printf("Error marking email %s as sent: %v", queued.ID, err)
+		}
+		return
+	}
+
+	var next *time.Time
+	if attempt := queued.Attempts + 1; attempt < maxAttempts {
+		at := now.Add(retryDelay(attempt))
+		next = &at
+		log.Printf("Error sending email %s (attempt %d), retrying at %s: %v", queued.ID, attempt, at.Format(time.RFC3339), err)
+	} else {
+		log.Printf("Error sending email %s, giving up after %d attempts: %v", queued.ID, attempt, err)
+	}
+	if err := s.db.MarkEmailAttemptFailed(queued.ID, err.Error(), next); err != nil {
+		log.Printf("Error rescheduling email %s: %v", queued.ID, err)
+	}
+}
+
+// retryDelay is the wait after the given failed attempt.
+func retryDelay(attempt int) time.Duration {
+	delay := retryBaseDelay
+	for i := 1; i < attempt && delay < retryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > retryMaxDelay {
+		delay = retryMaxDelay
+	}
+	return delay
+}
+
+// describe summarises a notification in a sentence, with a link to what it
+// is about.
+func (s *Service) describe(notificationType, actorID, targetType, targetID string, rawData json.RawMessage) item {
+	var data map[string]interface{}
+	_ = json.Unmarshal(rawData, &data)
+	str := func(key string) string {
+		v, _ := data[key].(string)
+		return v
+	}
+
+	actor, err := s.db.GetUsername(actorID)
+	if err != nil || actor == "" {
+		actor = "Someone"
+	}
+
+	it := item{URL: s.targetURL(targetType, targetID)}
+	switch events.Type(notificationType) {
+	case events.ShareGranted:
+		it.Summary = fmt.Sprintf("%s shared %s with you", actor, str("file_name"))
+	case events.FriendRequested:
+		it.Summary = fmt.Sprintf("%s sent you a friend request", actor)
+	case events.FriendAccepted:
+		it.Summary = fmt.Sprintf("%s accepted your friend request", actor)
+	case events.CollectionMemberAdded:
+		it.Summary = fmt.Sprintf("%s added you to the collection %s", actor, str("collection_name"))
+	case events.CommentCreated, events.CommentUpdated:
+		it.Excerpt = str("body")
+		switch str("reason") {
+		case notifications.ReasonMention:
+			it.Summary = fmt.Sprintf("%s mentioned you in a comment", actor)
+		case notifications.ReasonReply:
+			it.Summary = fmt.Sprintf("%s replied to your comment", actor)
+		default:
+			it.Summary = fmt.Sprintf("%s commented on your %s", actor, targetType)
+		}
+	default:
+		it.Summary = fmt.Sprintf("%s: %s", actor, notificationType)
+	}
+	return it
+}
+
+func (s *Service) targetURL(targetType, targetID string) string {
+	switch targetType {
+	case "file", "folder", "collection":
+		return s.appURL + "/" + targetType + "s/" + url.PathEscape(targetID)
+	case "user":
+		return s.appURL + "/friends"
+	}
+	return s.appURL
+}
+
+func (s *Service) settingsURL() string {
+	return s.appURL + "/settings/notifications"
+}
+
+func (s *Service) unsubscribeURL(token string) string {
+	return s.apiURL + "/email/unsubscribe?token=" + url.QueryEscape(token)
+}
+
+// unsubscribeHeaders lets mail clients offer one-click unsubscribe (RFC 8058).
+func (s *Service) unsubscribeHeaders(token string) map[string]string {
+	return map[string]string{
+		"List-Unsubscribe":      "<" + s.unsubscribeURL(token) + ">",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}
+}
@@ -0,0 +1,222 @@
+package email
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/saint0x/file-storage-app/backend/internal/db"
+	"github.com/saint0x/file-storage-app/backend/internal/db/dbtest"
+	"github.com/saint0x/file-storage-app/backend/internal/events"
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+)
+
+// newTestService returns a service that sends to a new SMTP sink.
+func newTestService(t *testing.T) (*Service, *smtpSink, *db.SQLiteClient) {
+	t.Helper()
+	client := dbtest.New(t)
+	sink := newSMTPSink(t)
+	return NewService(client, sink.mailer(), "https://app.example.com", "https://api.example.com"), sink, client
+}
+
+// shareNotification is the notification userID gets when actorID shares
+// report.pdf with them.
+func shareNotification(userID, actorID string) models.Notification {
+	return models.Notification{
+		ID:         uuid.New().String(),
+		UserID:     userID,
+		Type:       string(events.ShareGranted),
+		ActorID:    actorID,
+		TargetType: "file",
+		TargetID:   uuid.New().String(),
+		Data:       json.RawMessage(`{"file_name":"report.pdf"}`),
+		CreatedAt:  time.Now(),
+	}
+}
+
+func TestNotifyImmediate(t *testing.T) {
+	s, sink, client := newTestService(t)
+	alice := dbtest.CreateUser(t, client, "alice")
+	bob := dbtest.CreateUser(t, client, "bob")
+
+	if err := s.Notify(uuid.New().String(), shareNotification(alice, bob), models.EmailImmediate); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	s.deliver(time.Now())
+
+	received := sink.received()
+	if len(received) != 1 {
+		t.Fatalf("sink received %d messages, want 1", len(received))
+	}
+	if received[0].To[0] != "alice@example.com" {
+		t.Errorf("recipient = %q, want alice@example.com", received[0].To[0])
+	}
+	msg := parseMessage(t, received[0].Data)
+	if subject := msg.Header.Get("Subject"); subject != "bob shared report.pdf with you" {
+		t.Errorf("Subject = %q", subject)
+	}
+	if !strings.Contains(msg.Text, "https://app.example.com/files/") {
+		t.Errorf("text body has no link to the file:\n%s", msg.Text)
+	}
+}
+
+func TestNotifyDeduplicatesEvents(t *testing.T) {
+	s, sink, client := newTestService(t)
+	alice := dbtest.CreateUser(t, client, "alice")
+	bob := dbtest.CreateUser(t, client, "bob")
+
+	eventID := uuid.New().String()
+	notification := shareNotification(alice, bob)
+	for i := 0; i < 2; i++ {
+		if err := s.Notify(eventID, notification, models.EmailImmediate); err != nil {
+			t.Fatalf("Notify failed: %v", err)
+		}
+		s.deliver(time.Now())
+	}
+
+	if n := len(sink.received()); n != 1 {
+		t.Errorf("sink received %d messages for one event, want 1", n)
+	}
+}
+
+func TestDigest(t *testing.T) {
+	s, sink, client := newTestService(t)
+	alice := dbtest.CreateUser(t, client, "alice")
+	bob := dbtest.CreateUser(t, client, "bob")
+
+	for i := 0; i < 2; i++ {
+		if err := s.Notify(uuid.New().String(), shareNotification(alice, bob), models.EmailDigest); err != nil {
+			t.Fatalf("Notify failed: %v", err)
+		}
+	}
+
+	// Nothing is sent before a full period has passed.
+	now := time.Now()
+	s.sendDigests(now)
+	s.deliver(now)
+	if n := len(sink.received()); n != 0 {
+		t.Fatalf("sink received %d messages before the digest was due, want 0", n)
+	}
+
+	later := now.Add(models.DigestDaily.Interval() + time.Minute)
+	s.sendDigests(later)
+	s.deliver(later)
+	received := sink.received()
+	if len(received) != 1 {
+		t.Fatalf("sink received %d messages, want 1 digest", len(received))
+	}
+	msg := parseMessage(t, received[0].Data)
+	if subject := msg.Header.Get("Subject"); subject != "Your daily digest: 2 updates" {
+		t.Errorf("Subject = %q", subject)
+	}
+	if n := strings.Count(msg.Text, "bob shared report.pdf with you"); n != 2 {
+		t.Errorf("digest lists %d items, want 2:\n%s", n, msg.Text)
+	}
+
+	// Items are only sent once.
+	s.sendDigests(later.Add(models.DigestDaily.Interval()))
+	s.deliver(later.Add(models.DigestDaily.Interval()))
+	if n := len(sink.received()); n != 1 {
+		t.Errorf("sink received %d messages after a second digest run, want 1", n)
+	}
+}
+
+func TestUnsubscribeToken(t *testing.T) {
+	s, sink, client := newTestService(t)
+	alice := dbtest.CreateUser(t, client, "alice")
+	bob := dbtest.CreateUser(t, client, "bob")
+
+	if err := s.Notify(uuid.New().String(), shareNotification(alice, bob), models.EmailImmediate); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	s.deliver(time.Now())
+	received := sink.received()
+	if len(received) != 1 {
+		t.Fatalf("sink received %d messages, want 1", len(received))
+	}
+
+	msg := parseMessage(t, received[0].Data)
+	if got := msg.Header.Get("List-Unsubscribe-Post"); got != "List-Unsubscribe=One-Click" {
+		t.Errorf("List-Unsubscribe-Post = %q", got)
+	}
+	link, err := url.Parse(strings.Trim(msg.Header.Get("List-Unsubscribe"), "<>"))
+	if err != nil {
+		t.Fatalf("invalid List-Unsubscribe header: %v", err)
+	}
+	if link.Host != "api.example.com" || link.Path != "/email/unsubscribe" {
+		t.Errorf("unsubscribe link = %s", link)
+	}
+	if !strings.Contains(msg.Text, link.String()) {
+		t.Errorf("text body does not include the unsubscribe link %s", link)
+	}
+
+	if err := client.UnsubscribeByToken("not-a-token"); err == nil {
+		t.Error("unsubscribing with an unknown token succeeded")
+	}
+	if err := client.UnsubscribeByToken(link.Query().Get("token")); err != nil {
+		t.Fatalf("UnsubscribeByToken failed: %v", err)
+	}
+
+	if err := s.Notify(uuid.New().String(), shareNotification(alice, bob), models.EmailImmediate); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	s.deliver(time.Now())
+	if n := len(sink.received()); n != 1 {
+		t.Errorf("sink received %d messages after unsubscribing, want 1", n)
+	}
+}
+
+// failingMailer fails every delivery.
+type failingMailer struct{}
+
+func (failingMailer) Send(Message) error { return errors.New("connection refused") }
+
+func TestDeliverRetriesFailures(t *testing.T) {
+	client := dbtest.New(t)
+	s := NewService(client, failingMailer{}, "https://app.example.com", "https://api.example.com")
+	alice := dbtest.CreateUser(t, client, "alice")
+	bob := dbtest.CreateUser(t, client, "bob")
+
+	if err := s.Notify(uuid.New().String(), shareNotification(alice, bob), models.EmailImmediate); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	now := time.Now()
+	s.deliver(now)
+
+	due, err := client.GetDueEmails(now, deliverBatchSize)
+	if err != nil {
+		t.Fatalf("GetDueEmails failed: %v", err)
+	}
+	if len(due) != 0 {
+		t.Errorf("%d emails are due again immediately after failing, want 0", len(due))
+	}
+
+	due, err = client.GetDueEmails(now.Add(retryBaseDelay), deliverBatchSize)
+	if err != nil {
+		t.Fatalf("GetDueEmails failed: %v", err)
+	}
+	if len(due) != 1 || due[0].Attempts != 1 {
+		t.Fatalf("due emails after the retry delay = %+v, want one with 1 attempt", due)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, retryBaseDelay},
+		{2, 2 * retryBaseDelay},
+		{3, 4 * retryBaseDelay},
+		{20, retryMaxDelay},
+	}
+	for _, tt := range tests {
+		if got := retryDelay(tt.attempt); got != tt.want {
+			t.Errorf("retryDelay(%d) = %s, want %s", tt.attempt, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package email
+
+import (
+	"bytes"
+	"embed"
+	htmltemplate "html/template"
+	texttemplate "text/template"
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+var (
+	textTemplates = texttemplate.Must(texttemplate.ParseFS(templateFS, "templates/*.txt.tmpl"))
+	htmlTemplates = htmltemplate.Must(htmltemplate.ParseFS(templateFS, "templates/*.html.tmpl"))
+)
+
+// item is one notification as shown in an email.
+type item struct {
+	Summary string
+	Excerpt string
+	URL     string
+}
+
+type notificationData struct {
+	Item           item
+	SettingsURL    string
+	UnsubscribeURL string
+}
+
+type digestData struct {
+	Frequency      string
+	Items          []item
+	SettingsURL    string
+	UnsubscribeURL string
+}
+
+// render executes the text and HTML templates called name.
+func render(name string, data interface{}) (text, html string, err error) {
+	var textBuf, htmlBuf bytes.Buffer
+	if err := textTemplates.ExecuteTemplate(&textBuf, name+".txt.tmpl", data); err != nil {
+		return "", "", err
+	}
+	if err := htmlTemplates.ExecuteTemplate(&htmlBuf, name+".html.tmpl", data); err != nil {
+		return "", "", err
+	}
+	return textBuf.String(), htmlBuf.String(), nil
+}
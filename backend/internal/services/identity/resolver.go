@@ -0,0 +1,196 @@
+package identity
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/saint0x/file-storage-app/backend/internal/db"
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+	"github.com/saint0x/file-storage-app/backend/internal/services/auth"
+)
+
+// Resolver maps the subjects an identity provider authenticates to internal
+// users, creating a user the first time a subject signs in. Everything past
+// authentication deals only in internal user IDs.
+type Resolver struct {
+	db            *db.SQLiteClient
+	provider      string
+	authenticator auth.Authenticator
+	profiles      auth.ProfileSource
+}
+
+// NewResolver returns a resolver for the users authenticator authenticates,
+// recorded under the provider name and provisioned from profiles.
+func NewResolver(db *db.SQLiteClient, provider string, authenticator auth.Authenticator, profiles auth.ProfileSource) *Resolver {
+	return &Resolver{
+		db:            db,
+		provider:      provider,
+		authenticator: authenticator,
+		profiles:      profiles,
+	}
+}
+
+// Provider returns the authenticator that verifies tokens for the resolver.
+func (r *Resolver) Provider() auth.Authenticator {
+	return r.authenticator
+}
+
+// AuthenticateUser verifies token, either a session token of the provider or
+// a personal access token, and returns the internal user it belongs to.
+// Rejected tokens are reported as auth.ErrUnauthenticated, and tokens of
+// suspended users as auth.ErrAccountSuspended. Tokens that could not be
+// checked because the provider is down are reported with an error wrapping
+// auth.ErrProviderUnavailable.
+func (r *Resolver) AuthenticateUser(ctx context.Context, token string) (auth.CurrentUser, error) {
+	user, suspended, err := r.authenticateUser(ctx, token)
+	if err != nil {
+		return auth.CurrentUser{}, err
+	}
+	if suspended {
+		return auth.CurrentUser{}, auth.ErrAccountSuspended
+	}
+	return user, nil
+}
+
+func (r *Resolver) authenticateUser(ctx context.Context, token string) (auth.CurrentUser, bool, error) {
+	if auth.IsPersonalAccessToken(token) {
+		return r.authenticatePersonalAccessToken(token)
+	}
+	subject, err := r.authenticator.Authenticate(ctx, token)
+	if auth.IsTokenRejected(err) {
+		return auth.CurrentUser{}, false, fmt.Errorf("%w: %v", auth.ErrUnauthenticated, err)
+	}
+	if err != nil {
+		return auth.CurrentUser{}, false, fmt.Errorf("failed to verify token: %w", err)
+	}
+	user, err := r.resolve(ctx, subject)
+	if err != nil {
+		return auth.CurrentUser{}, false, err
+	}
+	return r.currentUser(subject, user), user.SuspendedAt != nil, nil
+}
+
+// Authenticate verifies token and returns the internal ID of its user, so
+// that a Resolver can stand in for the provider's authenticator. Personal
+// access tokens are only accepted with the admin scope, since callers of
+// Authenticate cannot check narrower ones.
+func (r *Resolver) Authenticate(ctx context.Context, token string) (string, error) {
+	user, err := r.AuthenticateUser(ctx, token)
+	if err != nil {
+		return "", err
+	}
+	if !user.HasScope(models.ScopeAdmin) {
+		return "", fmt.Errorf("%w: token lacks the %s scope", auth.ErrUnauthenticated, models.ScopeAdmin)
+	}
+	return user.ID, nil
+}
+
+func (r *Resolver) authenticatePersonalAccessToken(token string) (auth.CurrentUser, bool, error) {
+	pat, err := r.db.GetPersonalAccessTokenByHash(auth.HashPersonalAccessToken(token))
+	if err == sql.ErrNoRows {
+		return auth.CurrentUser{}, false, fmt.Errorf("%w: unknown token", auth.ErrUnauthenticated)
+	}
+	if err != nil {
+		return auth.CurrentUser{}, false, fmt.Errorf("failed to look up token: %w", err)
+	}
+	if !pat.Usable(time.Now()) {
+		return auth.CurrentUser{}, false, fmt.Errorf("%w: token revoked or expired", auth.ErrUnauthenticated)
+	}
+	user, err := r.db.GetUserByID(pat.UserID)
+	if err != nil {
+		return auth.CurrentUser{}, false, fmt.Errorf("failed to look up token owner: %w", err)
+	}
+	if err := r.db.TouchPersonalAccessToken(pat.ID); err != nil {
+		log.Printf("Error recording use of token %s: %v", pat.ID, err)
+	}
+	return auth.CurrentUser{
+		ID:       user.ID.String(),
+		Email:    user.Email,
+		Username: user.Username,
+		Role:     user.Role,
+		Provider: auth.ProviderToken,
+		Subject:  pat.ID,
+		TokenID:  pat.ID,
+		Scopes:   pat.Scopes,
+	}, user.SuspendedAt != nil, nil
+}
+
+// Resolve returns the internal user linked to subject, provisioning one from
+// the provider's profile if the subject has not signed in before. An existing
+// account is adopted only if its email address matches one the provider has
+// verified.
+func (r *Resolver) Resolve(ctx context.Context, subject string) (auth.CurrentUser, error) {
+	user, err := r.resolve(ctx, subject)
+	if err != nil {
+		return auth.CurrentUser{}, err
+	}
+	return r.currentUser(subject, user), nil
+}
+
+// Provision links subject to an internal user created from profile, or
+// returns the user it is already linked to.
+func (r *Resolver) Provision(subject string, profile auth.Profile) (auth.CurrentUser, error) {
+	user, err := r.provision(subject, profile)
+	if err != nil {
+		return auth.CurrentUser{}, err
+	}
+	return r.currentUser(subject, user), nil
+}
+
+func (r *Resolver) resolve(ctx context.Context, subject string) (models.User, error) {
+	user, err := r.db.GetUserByIdentity(r.provider, subject)
+	if err == nil {
+		return user, nil
+	}
+	if err != sql.ErrNoRows {
+		return models.User{}, fmt.Errorf("failed to look up identity: %w", err)
+	}
+
+	profile, err := r.profiles.Profile(ctx, subject)
+	if err != nil {
+		return models.User{}, fmt.Errorf("failed to fetch profile: %w", err)
+	}
+	return r.provision(subject, profile)
+}
+
+func (r *Resolver) provision(subject string, profile auth.Profile) (models.User, error) {
+	if profile.Email == "" {
+		// Users must have a unique email address; accounts without one get
+		// an undeliverable address of their own.
+		profile.Email = subject + "@" + r.provider + ".invalid"
+		profile.EmailVerified = false
+	}
+	if profile.Username == "" {
+		profile.Username = strings.SplitN(profile.Email, "@", 2)[0]
+	}
+
+	user, err := r.db.ProvisionUser(r.provider, subject, models.User{
+		Email:     profile.Email,
+		Username:  profile.Username,
+		FirstName: profile.FirstName,
+		LastName:  profile.LastName,
+	}, profile.EmailVerified)
+	if err != nil {
+		// A concurrent first request may have provisioned the user already.
+		if existing, lookupErr := r.db.GetUserByIdentity(r.provider, subject); lookupErr == nil {
+			return existing, nil
+		}
+		return models.User{}, fmt.Errorf("failed to provision user: %w", err)
+	}
+	return user, nil
+}
+
+func (r *Resolver) currentUser(subject string, user models.User) auth.CurrentUser {
+	return auth.CurrentUser{
+		ID:       user.ID.String(),
+		Email:    user.Email,
+		Username: user.Username,
+		Role:     user.Role,
+		Provider: r.provider,
+		Subject:  subject,
+	}
+}
@@ -0,0 +1,200 @@
+package identity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/saint0x/file-storage-app/backend/internal/db"
+	"github.com/saint0x/file-storage-app/backend/internal/db/dbtest"
+	"github.com/saint0x/file-storage-app/backend/internal/services/auth"
+)
+
+// fakeAuthenticator authenticates every token as subject, or fails with err.
+type fakeAuthenticator struct {
+	subject string
+	err     error
+}
+
+func (a fakeAuthenticator) Authenticate(ctx context.Context, token string) (string, error) {
+	return a.subject, a.err
+}
+
+// fakeProfiles returns the same profile for every subject, or fails with err.
+type fakeProfiles struct {
+	profile auth.Profile
+	err     error
+}
+
+func (p fakeProfiles) Profile(ctx context.Context, subject string) (auth.Profile, error) {
+	return p.profile, p.err
+}
+
+func TestAuthenticateUserErrors(t *testing.T) {
+	unavailable := fmt.Errorf("%w: failed to fetch JWKS: 503 Service Unavailable", auth.ErrProviderUnavailable)
+	profile := auth.Profile{Email: "alice@example.com", EmailVerified: true, Username: "alice"}
+
+	tests := []struct {
+		name          string
+		authenticator fakeAuthenticator
+		profiles      fakeProfiles
+		unauthorized  bool
+		unavailable   bool
+	}{
+		{"valid", fakeAuthenticator{subject: "user_1"}, fakeProfiles{profile: profile}, false, false},
+		{"expired", fakeAuthenticator{err: auth.ErrTokenExpired}, fakeProfiles{}, true, false},
+		{"bad signature", fakeAuthenticator{err: auth.ErrInvalidSignature}, fakeProfiles{}, true, false},
+		{"unknown key", fakeAuthenticator{err: auth.ErrUnknownKey}, fakeProfiles{}, true, false},
+		{"rejected by Clerk", fakeAuthenticator{err: fmt.Errorf("%w: 401 Unauthorized", auth.ErrTokenRejected)}, fakeProfiles{}, true, false},
+		{"JWKS outage", fakeAuthenticator{err: unavailable}, fakeProfiles{}, false, true},
+		{"profile outage", fakeAuthenticator{subject: "user_1"}, fakeProfiles{err: unavailable}, false, true},
+		{"other failure", fakeAuthenticator{err: errors.New("session store is locked")}, fakeProfiles{}, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewResolver(dbtest.New(t), auth.ProviderClerk, tt.authenticator, tt.profiles)
+			_, err := r.AuthenticateUser(context.Background(), "token")
+			if tt.name == "valid" {
+				if err != nil {
+					t.Fatalf("AuthenticateUser() error = %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("AuthenticateUser() succeeded")
+			}
+			if got := errors.Is(err, auth.ErrUnauthenticated); got != tt.unauthorized {
+				t.Errorf("AuthenticateUser() error %q unauthenticated = %v, want %v", err, got, tt.unauthorized)
+			}
+			if got := errors.Is(err, auth.ErrProviderUnavailable); got != tt.unavailable {
+				t.Errorf("AuthenticateUser() error %q unavailable = %v, want %v", err, got, tt.unavailable)
+			}
+		})
+	}
+}
+
+func TestAuthenticateUserClerkAPIErrors(t *testing.T) {
+	tests := []struct {
+		status       int
+		unauthorized bool
+		unavailable  bool
+	}{
+		{http.StatusUnauthorized, true, false},
+		{http.StatusNotFound, true, false},
+		{http.StatusTooManyRequests, false, true},
+		{http.StatusInternalServerError, false, true},
+		{http.StatusBadGateway, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			clerk := auth.NewClerkService()
+			clerk.BaseURL = server.URL
+			r := NewResolver(dbtest.New(t), auth.ProviderClerk, clerk, clerk)
+
+			_, err := r.AuthenticateUser(context.Background(), "token")
+			if got := errors.Is(err, auth.ErrUnauthenticated); got != tt.unauthorized {
+				t.Errorf("AuthenticateUser() error %q unauthenticated = %v, want %v", err, got, tt.unauthorized)
+			}
+			if got := errors.Is(err, auth.ErrProviderUnavailable); got != tt.unavailable {
+				t.Errorf("AuthenticateUser() error %q unavailable = %v, want %v", err, got, tt.unavailable)
+			}
+		})
+	}
+}
+
+func TestAuthenticateUserUnreachableProvider(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	clerk := auth.NewClerkService()
+	clerk.SetJWTVerifier(auth.NewJWTVerifier(auth.NewJWKSCache(url+"/.well-known/jwks.json", nil), "", nil))
+	r := NewResolver(dbtest.New(t), auth.ProviderClerk, clerk, clerk)
+
+	// A well-formed RS256 token whose key has to be fetched.
+	token := "eyJhbGciOiJSUzI1NiIsImtpZCI6ImtleSJ9.eyJzdWIiOiJ1c2VyXzEifQ.c2ln"
+	_, err := r.AuthenticateUser(context.Background(), token)
+	if !errors.Is(err, auth.ErrProviderUnavailable) || errors.Is(err, auth.ErrUnauthenticated) {
+		t.Errorf("AuthenticateUser() error = %v, want a provider unavailable error", err)
+	}
+}
+
+func TestResolveProvisionsOnFirstSignIn(t *testing.T) {
+	client := dbtest.New(t)
+	profile := auth.Profile{Email: "alice@example.com", EmailVerified: true, Username: "alice"}
+	r := NewResolver(client, auth.ProviderClerk, fakeAuthenticator{}, fakeProfiles{profile: profile})
+
+	first, err := r.Resolve(context.Background(), "user_1")
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if first.Username != "alice" || first.Email != profile.Email || first.Subject != "user_1" {
+		t.Errorf("Resolve() = %+v, want alice provisioned from the profile", first)
+	}
+
+	// Later sign-ins use the linked identity without fetching the profile.
+	r = NewResolver(client, auth.ProviderClerk, fakeAuthenticator{}, fakeProfiles{err: errors.New("profile fetched again")})
+	again, err := r.Resolve(context.Background(), "user_1")
+	if err != nil || again.ID != first.ID {
+		t.Errorf("Resolve() again = %+v, %v, want user %s", again, err, first.ID)
+	}
+}
+
+func TestResolveLinksExistingAccounts(t *testing.T) {
+	tests := []struct {
+		name      string
+		profile   auth.Profile
+		wantLink  bool
+		wantError error
+	}{
+		{"verified email", auth.Profile{Email: "alice@example.com", EmailVerified: true, Username: "alice"}, true, nil},
+		{"unverified email", auth.Profile{Email: "alice@example.com", Username: "alice"}, false, db.ErrEmailInUse},
+		{"different email", auth.Profile{Email: "other@example.com", EmailVerified: true, Username: "alice"}, false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := dbtest.New(t)
+			existing := dbtest.CreateUser(t, client, "alice")
+			r := NewResolver(client, auth.ProviderClerk, fakeAuthenticator{}, fakeProfiles{profile: tt.profile})
+
+			user, err := r.Resolve(context.Background(), "user_1")
+			if tt.wantError != nil {
+				if !errors.Is(err, tt.wantError) {
+					t.Fatalf("Resolve() error = %v, want %v", err, tt.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve() error = %v", err)
+			}
+			if linked := user.ID == existing; linked != tt.wantLink {
+				t.Errorf("Resolve() linked to the existing account = %v, want %v", linked, tt.wantLink)
+			}
+			if !tt.wantLink && !strings.HasPrefix(user.Username, "alice-") {
+				t.Errorf("new user's username = %q, want a suffixed alice", user.Username)
+			}
+		})
+	}
+}
+
+func TestProvisionWithoutEmail(t *testing.T) {
+	client := dbtest.New(t)
+	r := NewResolver(client, auth.ProviderClerk, fakeAuthenticator{}, fakeProfiles{})
+
+	user, err := r.Provision("user_1", auth.Profile{})
+	if err != nil {
+		t.Fatalf("Provision() error = %v", err)
+	}
+	if want := "user_1@" + auth.ProviderClerk + ".invalid"; user.Email != want || user.Username != "user_1" {
+		t.Errorf("Provision() = %+v, want email %s and username user_1", user, want)
+	}
+}
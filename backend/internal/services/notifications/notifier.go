@@ -0,0 +1,184 @@
+// Package notifications turns domain events into notifications for the users
+// they concern.
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/saint0x/file-storage-app/backend/internal/db"
+	"github.com/saint0x/file-storage-app/backend/internal/events"
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+	"github.com/saint0x/file-storage-app/backend/internal/services/websocket"
+)
+
+// Types lists the event types users are notified about, and so the types
+// they can set preferences for.
+var Types = []events.Type{
+	events.ShareGranted,
+	events.FriendRequested,
+	events.FriendAccepted,
+	events.CollectionMemberAdded,
+	events.CommentCreated,
+	events.CommentUpdated,
+}
+
+// Why a user is notified about a comment.
+const (
+	ReasonMention = "mention"
+	ReasonReply   = "reply"
+	ReasonOwner   = "owner"
+)
+
+// Emailer emails users about their notifications, now or in a digest
+// depending on mode.
+type Emailer interface {
+	Notify(eventID string, notification models.Notification, mode models.EmailMode) error
+}
+
+// Notifier stores a notification for each user an event concerns and
+// delivers it to them live and, if an Emailer is set, by email.
+type Notifier struct {
+	db      *db.SQLiteClient
+	hub     *websocket.Hub
+	emailer Emailer
+}
+
+func NewNotifier(db *db.SQLiteClient, hub *websocket.Hub) *Notifier {
+	return &Notifier{db: db, hub: hub}
+}
+
+// SetEmailer sets how notifications are emailed. It must be called before
+// the notifier handles events.
+func (n *Notifier) SetEmailer(emailer Emailer) {
+	n.emailer = emailer
+}
+
+// recipient is a user to notify and what to tell them.
+type recipient struct {
+	userID string
+	data   map[string]interface{}
+}
+
+// HandleEvent notifies the users an event concerns, other than its actor,
+// through the channels they have turned on for that type of notification.
+func (n *Notifier) HandleEvent(ctx context.Context, event events.Event) {
+	targetType, targetID, recipients := n.recipients(event)
+
+	notified := map[string]bool{event.ActorID: true}
+	for _, r := range recipients {
+		if notified[r.userID] {
+			continue
+		}
+		notified[r.userID] = true
+
+		preference, err := n.db.GetNotificationPreference(r.userID, string(event.Type))
+		if err != nil {
+			log.Printf("Error fetching notification preference of user %s: %v", r.userID, err)
+			continue
+		}
+		email := n.emailer != nil && preference.Email != models.EmailOff
+		if !preference.InApp && !email {
+			continue
+		}
+
+		data, err := json.Marshal(r.data)
+		if err != nil {
+			log.Printf("Error encoding notification for %s: %v", event.ID, err)
+			continue
+		}
+		notification := models.Notification{
+			UserID:     r.userID,
+			Type:       string(event.Type),
+			ActorID:    event.ActorID,
+			TargetType: targetType,
+			TargetID:   targetID,
+			Data:       data,
+			CreatedAt:  event.OccurredAt,
+		}
+
+		if preference.InApp {
+			notification, err = n.db.CreateNotification(notification)
+			if err != nil {
+				log.Printf("Error creating notification for user %s: %v", r.userID, err)
+				continue
+			}
+			n.hub.SendToUser(r.userID, websocket.Notification, notification)
+		}
+		if email {
+			if err := n.emailer.Notify(event.ID, notification, preference.Email); err != nil {
+				log.Printf("Error emailing notification of %s to user %s: %v", event.ID, r.userID, err)
+			}
+		}
+	}
+}
+
+// recipients returns what an event is about and who should hear of it.
+func (n *Notifier) recipients(event events.Event) (targetType, targetID string, recipients []recipient) {
+	switch p := event.Payload.(type) {
+	case events.SharePayload:
+		if event.Type != events.ShareGranted {
+			return
+		}
+		for _, userID := range p.UserIDs {
+			recipients = append(recipients, recipient{userID, map[string]interface{}{"file_name": p.FileName}})
+		}
+		return "file", p.FileID, recipients
+
+	case events.FriendPayload:
+		data := map[string]interface{}{"friendship_id": p.FriendshipID}
+		switch event.Type {
+		case events.FriendRequested:
+			return "user", p.UserID, []recipient{{p.FriendID, data}}
+		case events.FriendAccepted:
+			return "user", p.FriendID, []recipient{{p.UserID, data}}
+		}
+
+	case events.CollectionMemberPayload:
+		if event.Type != events.CollectionMemberAdded {
+			return
+		}
+		data := map[string]interface{}{"role": p.Role}
+		if collection, err := n.db.GetCollection(p.CollectionID); err == nil {
+			data["collection_name"] = collection.Name
+		}
+		return "collection", p.CollectionID, []recipient{{p.UserID, data}}
+
+	case events.CommentPayload:
+		if event.Type != events.CommentCreated && event.Type != events.CommentUpdated {
+			return
+		}
+		comment := p.Comment
+		data := func(reason string) map[string]interface{} {
+			return map[string]interface{}{"comment_id": comment.ID, "body": excerpt(comment.Body), "reason": reason}
+		}
+		for _, userID := range p.Mentioned {
+			recipients = append(recipients, recipient{userID, data(ReasonMention)})
+		}
+		// Only a new comment is news to the thread; edits notify just the
+		// users they newly mention.
+		if event.Type == events.CommentCreated {
+			if comment.ParentID != nil {
+				if parent, err := n.db.GetComment(*comment.ParentID); err == nil {
+					recipients = append(recipients, recipient{parent.UserID, data(ReasonReply)})
+				}
+			}
+			if ownerID, err := n.db.GetTargetOwner(comment.TargetType, comment.TargetID); err == nil {
+				recipients = append(recipients, recipient{ownerID, data(ReasonOwner)})
+			}
+		}
+		return string(comment.TargetType), comment.TargetID, recipients
+	}
+	return
+}
+
+// excerpt shortens a comment body for display in a notification.
+func excerpt(body string) string {
+	const max = 140
+	runes := []rune(body)
+	if len(runes) <= max {
+		return body
+	}
+	return string(runes[:max-1]) + "…"
+}
@@ -0,0 +1,128 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/saint0x/file-storage-app/backend/internal/db"
+	"github.com/saint0x/file-storage-app/backend/internal/db/dbtest"
+	"github.com/saint0x/file-storage-app/backend/internal/events"
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+	"github.com/saint0x/file-storage-app/backend/internal/services/websocket"
+)
+
+// recordingEmailer remembers who it was asked to email and how.
+type recordingEmailer struct {
+	modes map[string]models.EmailMode
+}
+
+func (e *recordingEmailer) Notify(eventID string, notification models.Notification, mode models.EmailMode) error {
+	e.modes[notification.UserID] = mode
+	return nil
+}
+
+func reasons(t *testing.T, client *db.SQLiteClient, userID string) []string {
+	t.Helper()
+	list, _, err := client.GetNotifications(userID, false, "", 10)
+	if err != nil {
+		t.Fatalf("GetNotifications failed: %v", err)
+	}
+	var got []string
+	for _, n := range list {
+		var data map[string]string
+		if err := json.Unmarshal(n.Data, &data); err != nil {
+			t.Fatalf("failed to decode notification data: %v", err)
+		}
+		got = append(got, data["reason"])
+	}
+	return got
+}
+
+func TestHandleCommentEvent(t *testing.T) {
+	client := dbtest.New(t)
+	notifier := NewNotifier(client, websocket.NewHub(client, websocket.NewMemoryBroker()))
+	emailer := &recordingEmailer{modes: map[string]models.EmailMode{}}
+	notifier.SetEmailer(emailer)
+
+	owner := dbtest.CreateUser(t, client, "owner")
+	replier := dbtest.CreateUser(t, client, "replier")
+	parentAuthor := dbtest.CreateUser(t, client, "parent")
+	mentioned := dbtest.CreateUser(t, client, "mentioned")
+	fileID := dbtest.CreateFile(t, client, owner)
+	parent, err := client.CreateComment(models.Comment{
+		TargetType: models.CommentTargetFile, TargetID: fileID, UserID: parentAuthor, Body: "Looks good",
+	})
+	if err != nil {
+		t.Fatalf("CreateComment failed: %v", err)
+	}
+	// The owner only wants email, right away.
+	err = client.SetNotificationPreferences(owner, []models.NotificationPreference{
+		{Type: string(events.CommentCreated), InApp: false, Email: models.EmailImmediate},
+	})
+	if err != nil {
+		t.Fatalf("SetNotificationPreferences failed: %v", err)
+	}
+
+	reply := models.Comment{
+		ID: uuid.New().String(), TargetType: models.CommentTargetFile, TargetID: fileID,
+		UserID: replier, ParentID: &parent.ID, Body: "Agreed, @mentioned take a look",
+	}
+	notifier.HandleEvent(context.Background(), events.Event{
+		ID:         uuid.New().String(),
+		Type:       events.CommentCreated,
+		ActorID:    replier,
+		OccurredAt: time.Now(),
+		Payload:    events.CommentPayload{Comment: reply, Mentioned: []string{mentioned, replier}},
+	})
+
+	for userID, want := range map[string]string{mentioned: ReasonMention, parentAuthor: ReasonReply} {
+		if got := reasons(t, client, userID); len(got) != 1 || got[0] != want {
+			t.Errorf("notification reasons = %v, want [%s]", got, want)
+		}
+	}
+	for _, userID := range []string{owner, replier} {
+		if got := reasons(t, client, userID); len(got) != 0 {
+			t.Errorf("user %s was notified in the app: %v", userID, got)
+		}
+	}
+
+	wantEmails := map[string]models.EmailMode{
+		owner:        models.EmailImmediate,
+		mentioned:    models.EmailDigest,
+		parentAuthor: models.EmailDigest,
+	}
+	if len(emailer.modes) != len(wantEmails) {
+		t.Errorf("emailed %v, want %v", emailer.modes, wantEmails)
+	}
+	for userID, want := range wantEmails {
+		if got := emailer.modes[userID]; got != want {
+			t.Errorf("email mode for %s = %q, want %q", userID, got, want)
+		}
+	}
+}
+
+func TestHandleCommentEditOnlyNotifiesNewMentions(t *testing.T) {
+	client := dbtest.New(t)
+	notifier := NewNotifier(client, websocket.NewHub(client, websocket.NewMemoryBroker()))
+
+	owner := dbtest.CreateUser(t, client, "owner")
+	author := dbtest.CreateUser(t, client, "author")
+	mentioned := dbtest.CreateUser(t, client, "mentioned")
+	fileID := dbtest.CreateFile(t, client, owner)
+
+	comment := models.Comment{ID: uuid.New().String(), TargetType: models.CommentTargetFile, TargetID: fileID, UserID: author}
+	notifier.HandleEvent(context.Background(), events.Event{
+		ID: uuid.New().String(), Type: events.CommentUpdated, ActorID: author,
+		Payload: events.CommentPayload{Comment: comment, Mentioned: []string{mentioned}},
+	})
+
+	if got := reasons(t, client, mentioned); len(got) != 1 || got[0] != ReasonMention {
+		t.Errorf("mentioned user's notifications = %v, want [%s]", got, ReasonMention)
+	}
+	if got := reasons(t, client, owner); len(got) != 0 {
+		t.Errorf("owner was notified of an edit: %v", got)
+	}
+}
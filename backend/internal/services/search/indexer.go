@@ -0,0 +1,44 @@
+package search
+
+import (
+	"context"
+	"log"
+
+	"github.com/saint0x/file-storage-app/backend/internal/db"
+	"github.com/saint0x/file-storage-app/backend/internal/events"
+)
+
+// Indexer keeps the file search index in step with file events.
+type Indexer struct {
+	db *db.SQLiteClient
+}
+
+func NewIndexer(db *db.SQLiteClient) *Indexer {
+	return &Indexer{db: db}
+}
+
+// EventTypes lists the events the indexer handles.
+var EventTypes = []events.Type{events.FileCreated, events.FileUpdated, events.FileMoved, events.FileDeleted}
+
+// HandleEvent updates the index for a file event.
+func (i *Indexer) HandleEvent(ctx context.Context, event events.Event) {
+	var fileID string
+	switch payload := event.Payload.(type) {
+	case events.FilePayload:
+		fileID = payload.File.ID.String()
+	case events.FileMovedPayload:
+		fileID = payload.File.ID.String()
+	default:
+		return
+	}
+
+	var err error
+	if event.Type == events.FileDeleted {
+		err = i.db.RemoveFileFromIndex(fileID)
+	} else {
+		err = i.db.IndexFile(fileID)
+	}
+	if err != nil {
+		log.Printf("Error indexing file %s for %s: %v", fileID, event.Type, err)
+	}
+}
@@ -0,0 +1,80 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"syscall"
+)
+
+var (
+	// ErrInvalidURL is returned by CheckURL for URLs that are not absolute
+	// http or https URLs.
+	ErrInvalidURL = errors.New("url must be an absolute http or https URL")
+
+	// ErrBlockedAddress is returned when a webhook URL points at a loopback,
+	// link-local, private or unspecified address. Deliveries are not sent
+	// there, so that webhooks cannot be used to reach the server's own
+	// network.
+	ErrBlockedAddress = errors.New("url resolves to a loopback, link-local, private or unspecified address")
+)
+
+// sharedAddressSpace is the carrier-grade NAT range (RFC 6598), which is as
+// internal as the private ranges.
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// blockedIP reports whether ip is an address deliveries may not go to.
+func blockedIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsPrivate() || ip.IsUnspecified() || sharedAddressSpace.Contains(ip)
+}
+
+// SetAllowPrivateNetworks lets deliveries go to loopback, link-local and
+// private addresses, for webhooks served on a developer's machine. It must
+// be called before Run.
+func (d *Dispatcher) SetAllowPrivateNetworks(allow bool) {
+	d.allowPrivateNetworks = allow
+}
+
+// checkDial is the dialer's Control function. It runs after the host name
+// has been resolved, for every address connected to, so a name that
+// resolves to a blocked address, or starts to after CheckURL, is caught.
+func (d *Dispatcher) checkDial(network, address string, _ syscall.RawConn) error {
+	if d.allowPrivateNetworks {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || blockedIP(ip) {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, host)
+	}
+	return nil
+}
+
+// CheckURL reports whether deliveries can be sent to rawURL: it must be an
+// absolute http or https URL whose host resolves only to addresses that are
+// not blocked.
+func (d *Dispatcher) CheckURL(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return ErrInvalidURL
+	}
+	if d.allowPrivateNetworks {
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", u.Hostname(), err)
+	}
+	for _, addr := range addrs {
+		if blockedIP(addr.IP) {
+			return ErrBlockedAddress
+		}
+	}
+	return nil
+}
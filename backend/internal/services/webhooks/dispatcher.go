@@ -0,0 +1,424 @@
+// Package webhooks delivers domain events to the URLs users register.
+//
+// Each delivery is a JSON POST of the form
+//
+//	{"id": ..., "type": "file.created", "occurred_at": ..., "actor_id": ..., "data": {...}}
+//
+// signed with the webhook's secret. The X-Webhook-Signature header holds
+// "t=<unix time>,v1=<signature>", where the signature is the hex-encoded
+// HMAC-SHA256 of "<unix time>.<request body>". Receivers should recompute it
+// and reject requests whose time is too far from their own clock.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/saint0x/file-storage-app/backend/internal/db"
+	"github.com/saint0x/file-storage-app/backend/internal/events"
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+)
+
+// Headers sent with every delivery.
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+)
+
+// TestEventType is the type of the event sent by SendTest.
+const TestEventType = "webhook.test"
+
+// MaxConsecutiveFailures is how many failed attempts in a row disable a
+// webhook.
+const MaxConsecutiveFailures = 15
+
+const (
+	// How often the queue is checked for deliveries that are due, and how
+	// many are attempted per batch.
+	deliverInterval  = 10 * time.Second
+	deliverBatchSize = 20
+
+	requestTimeout = 10 * time.Second
+
+	// How much of a response body is kept in the delivery log.
+	maxResponseBody = 4 << 10
+
+	// Failed deliveries are retried after retryBaseDelay, doubling each
+	// time up to retryMaxDelay, and given up on after maxAttempts.
+	retryBaseDelay = 30 * time.Second
+	retryMaxDelay  = time.Hour
+	maxAttempts    = 8
+)
+
+// Dispatcher queues events for the webhooks that want them and delivers
+// them in the background.
+type Dispatcher struct {
+	db     *db.SQLiteClient
+	client *http.Client
+	wake   chan struct{}
+	done   chan struct{}
+
+	// Whether deliveries may go to loopback, link-local and private
+	// addresses.
+	allowPrivateNetworks bool
+}
+
+func NewDispatcher(db *db.SQLiteClient) *Dispatcher {
+	d := &Dispatcher{
+		db:   db,
+		wake: make(chan struct{}, 1),
+		done: make(chan struct{}),
+	}
+	dialer := &net.Dialer{
+		Timeout:   requestTimeout,
+		KeepAlive: 30 * time.Second,
+		Control:   d.checkDial,
+	}
+	d.client = &http.Client{
+		Timeout: requestTimeout,
+		// Deliveries are not sent through a proxy, which would hide the
+		// address actually connected to from checkDial.
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			ForceAttemptHTTP2:   true,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+			TLSHandshakeTimeout: requestTimeout,
+		},
+		// A redirect is treated as a failure rather than followed, so
+		// that deliveries only go to the registered URL.
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return d
+}
+
+// NewSecret returns a random secret for signing a webhook's deliveries.
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// Sign returns the signature of a delivery body sent at timestamp.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// payload is the body of a delivery.
+type payload struct {
+	ID         string      `json:"id"`
+	Type       string      `json:"type"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	ActorID    string      `json:"actor_id"`
+	Data       interface{} `json:"data"`
+}
+
+// HandleEvent queues the event for every active webhook subscribed to it
+// whose owner could see it and whose filters it passes. Delivery happens in
+// the background so that publishers are not held up.
+func (d *Dispatcher) HandleEvent(ctx context.Context, event events.Event) {
+	webhooks, err := d.db.GetActiveWebhooksForEvent(string(event.Type))
+	if err != nil {
+		log.Printf("Error fetching webhooks for %s: %v", event.ID, err)
+		return
+	}
+	if len(webhooks) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(payload{
+		ID:         event.ID,
+		Type:       string(event.Type),
+		OccurredAt: event.OccurredAt,
+		ActorID:    event.ActorID,
+		Data:       event.Payload,
+	})
+	if err != nil {
+		log.Printf("Error encoding webhook payload for %s: %v", event.ID, err)
+		return
+	}
+
+	audience := make(map[string]bool, len(event.Audience))
+	for _, userID := range event.Audience {
+		audience[userID] = true
+	}
+
+	queued := false
+	for _, webhook := range webhooks {
+		if !audience[webhook.UserID] || !d.matches(webhook, event) {
+			continue
+		}
+		_, inserted, err := d.db.EnqueueWebhookDelivery(models.WebhookDelivery{
+			WebhookID: webhook.ID,
+			EventID:   event.ID,
+			EventType: string(event.Type),
+			Payload:   body,
+		})
+		if err != nil {
+			log.Printf("Error queueing %s for webhook %s: %v", event.ID, webhook.ID, err)
+			continue
+		}
+		queued = queued || inserted
+	}
+
+	if queued {
+		select {
+		case d.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// matches reports whether an event passes a webhook's folder and collection
+// filters.
+func (d *Dispatcher) matches(webhook models.Webhook, event events.Event) bool {
+	if webhook.FolderID != nil && !d.inFolder(*webhook.FolderID, event) {
+		return false
+	}
+	if webhook.CollectionID != nil && !d.inCollection(*webhook.CollectionID, event) {
+		return false
+	}
+	return true
+}
+
+// inFolder reports whether an event is about folderID or something directly
+// in it.
+func (d *Dispatcher) inFolder(folderID string, event events.Event) bool {
+	is := func(id *string) bool { return id != nil && *id == folderID }
+
+	switch p := event.Payload.(type) {
+	case events.FilePayload:
+		return p.File.FolderID.Valid && p.File.FolderID.UUID.String() == folderID
+	case events.FileMovedPayload:
+		return is(p.FromFolderID) || is(p.ToFolderID)
+	case events.FolderPayload:
+		return p.Folder.ID.String() == folderID || (p.Folder.ParentID.Valid && p.Folder.ParentID.UUID.String() == folderID)
+	case events.SharePayload:
+		return d.fileInFolder(p.FileID, folderID)
+	case events.CommentPayload:
+		switch p.Comment.TargetType {
+		case models.CommentTargetFolder:
+			return p.Comment.TargetID == folderID
+		case models.CommentTargetFile:
+			return d.fileInFolder(p.Comment.TargetID, folderID)
+		}
+	}
+	return false
+}
+
+func (d *Dispatcher) fileInFolder(fileID, folderID string) bool {
+	file, err := d.db.GetFileByID(fileID)
+	return err == nil && file.FolderID.Valid && file.FolderID.UUID.String() == folderID
+}
+
+// inCollection reports whether an event is about collectionID or a file in
+// it.
+func (d *Dispatcher) inCollection(collectionID string, event events.Event) bool {
+	var fileID string
+	switch p := event.Payload.(type) {
+	case events.CollectionPayload:
+		return p.Collection.ID.String() == collectionID
+	case events.CollectionMemberPayload:
+		return p.CollectionID == collectionID
+	case events.CollectionFilesPayload:
+		return p.CollectionID == collectionID
+	case events.FilePayload:
+		fileID = p.File.ID.String()
+	case events.FileMovedPayload:
+		fileID = p.File.ID.String()
+	case events.SharePayload:
+		fileID = p.FileID
+	case events.CommentPayload:
+		if p.Comment.TargetType != models.CommentTargetFile {
+			return false
+		}
+		fileID = p.Comment.TargetID
+	default:
+		return false
+	}
+
+	in, err := d.db.FileInCollection(collectionID, fileID)
+	if err != nil {
+		log.Printf("Error checking whether file %s is in collection %s: %v", fileID, collectionID, err)
+	}
+	return in
+}
+
+// SendTest delivers a test event to a webhook straight away and returns the
+// outcome. Test deliveries are logged like any other but do not count
+// towards disabling the webhook, and are not retried.
+func (d *Dispatcher) SendTest(ctx context.Context, webhook models.Webhook) (models.WebhookDelivery, error) {
+	eventID := uuid.New().String()
+	body, err := json.Marshal(payload{
+		ID:         eventID,
+		Type:       TestEventType,
+		OccurredAt: time.Now(),
+		ActorID:    webhook.UserID,
+		Data:       map[string]string{"webhook_id": webhook.ID},
+	})
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+
+	delivery, _, err := d.db.EnqueueWebhookDelivery(models.WebhookDelivery{
+		WebhookID: webhook.ID,
+		EventID:   eventID,
+		EventType: TestEventType,
+		Payload:   body,
+		// Keep the worker from picking it up while it is being sent.
+		NextAttemptAt: time.Now().Add(requestTimeout * 2),
+	})
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+
+	delivery = d.attempt(ctx, webhook, delivery, time.Now())
+	if delivery.Status == models.WebhookDeliveryPending {
+		finished := time.Now()
+		delivery.Status = models.WebhookDeliveryFailed
+		delivery.CompletedAt = &finished
+	}
+	return delivery, d.db.UpdateWebhookDelivery(delivery)
+}
+
+// Run delivers queued events until Stop is called.
+func (d *Dispatcher) Run() {
+	ticker := time.NewTicker(deliverInterval)
+	defer ticker.Stop()
+
+	d.deliver()
+	for {
+		select {
+		case <-ticker.C:
+			d.deliver()
+		case <-d.wake:
+			d.deliver()
+		case <-d.done:
+			return
+		}
+	}
+}
+
+// Stop stops Run.
+func (d *Dispatcher) Stop() {
+	close(d.done)
+}
+
+// deliver attempts the deliveries that are due, in batches.
+func (d *Dispatcher) deliver() {
+	for {
+		now := time.Now()
+		deliveries, err := d.db.GetDueWebhookDeliveries(now, deliverBatchSize)
+		if err != nil {
+			log.Printf("Error fetching webhook deliveries: %v", err)
+			return
+		}
+		for _, delivery := range deliveries {
+			webhook, err := d.db.GetWebhookByID(delivery.WebhookID)
+			if err != nil {
+				log.Printf("Error fetching webhook %s: %v", delivery.WebhookID, err)
+				continue
+			}
+			delivery = d.attempt(context.Background(), webhook, delivery, now)
+			disabled, err := d.db.RecordWebhookAttempt(delivery, MaxConsecutiveFailures)
+			if err != nil {
+				log.Printf("Error recording delivery %s: %v", delivery.ID, err)
+			}
+			if disabled {
+				log.Printf("Disabled webhook %s after %d consecutive failures", webhook.ID, MaxConsecutiveFailures)
+			}
+		}
+		if len(deliveries) < deliverBatchSize {
+			return
+		}
+	}
+}
+
+// attempt sends a delivery once and returns it updated with the outcome:
+// succeeded, pending with a later retry, or failed after maxAttempts.
+func (d *Dispatcher) attempt(ctx context.Context, webhook models.Webhook, delivery models.WebhookDelivery, now time.Time) models.WebhookDelivery {
+	delivery.Attempts++
+	delivery.ResponseStatus = nil
+	delivery.ResponseBody = ""
+	delivery.LastError = ""
+
+	status, body, err := d.post(ctx, webhook, delivery)
+	if status != 0 {
+		delivery.ResponseStatus = &status
+		delivery.ResponseBody = body
+	}
+	if err == nil && (status < 200 || status > 299) {
+		err = fmt.Errorf("unexpected response status %d", status)
+	}
+
+	finished := time.Now()
+	switch {
+	case err == nil:
+		delivery.Status = models.WebhookDeliverySucceeded
+		delivery.CompletedAt = &finished
+	case delivery.Attempts >= maxAttempts:
+		delivery.LastError = err.Error()
+		delivery.Status = models.WebhookDeliveryFailed
+		delivery.CompletedAt = &finished
+	default:
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = now.Add(retryDelay(delivery.Attempts))
+	}
+	return delivery
+}
+
+func (d *Dispatcher) post(ctx context.Context, webhook models.Webhook, delivery models.WebhookDelivery) (int, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, "", err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "file-storage-app-webhooks/1.0")
+	req.Header.Set(EventHeader, delivery.EventType)
+	req.Header.Set(DeliveryHeader, delivery.ID)
+	req.Header.Set(SignatureHeader, fmt.Sprintf("t=%d,v1=%s", timestamp, Sign(webhook.Secret, timestamp, delivery.Payload)))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	return resp.StatusCode, string(body), nil
+}
+
+// retryDelay is the wait after the given failed attempt.
+func retryDelay(attempt int) time.Duration {
+	delay := retryBaseDelay
+	for i := 1; i < attempt && delay < retryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > retryMaxDelay {
+		delay = retryMaxDelay
+	}
+	return delay
+}
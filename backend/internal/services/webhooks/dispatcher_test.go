@@ -0,0 +1,162 @@
+package webhooks
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/saint0x/file-storage-app/backend/internal/db"
+	"github.com/saint0x/file-storage-app/backend/internal/db/dbtest"
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+)
+
+// createWebhook registers a webhook for a new user that delivers to url.
+func createWebhook(t *testing.T, client *db.SQLiteClient, url string) models.Webhook {
+	t.Helper()
+	userID := uuid.New().String()
+	_, err := client.DB.Exec(`INSERT INTO users (id, email, username) VALUES (?, 'alice@example.com', 'alice')`, userID)
+	if err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	secret, err := NewSecret()
+	if err != nil {
+		t.Fatalf("failed to generate secret: %v", err)
+	}
+	webhook, err := client.CreateWebhook(models.Webhook{
+		UserID:     userID,
+		URL:        url,
+		EventTypes: []string{"file.created"},
+		Secret:     secret,
+	})
+	if err != nil {
+		t.Fatalf("failed to create webhook: %v", err)
+	}
+	return webhook
+}
+
+func TestBlockedIP(t *testing.T) {
+	tests := []struct {
+		ip      string
+		blocked bool
+	}{
+		{"127.0.0.1", true},
+		{"127.8.9.10", true},
+		{"::1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"fe80::1", true},
+		{"fd00::1", true},
+		{"0.0.0.0", true},
+		{"::", true},
+		{"100.64.0.1", true},
+		{"::ffff:127.0.0.1", true},
+		{"::ffff:10.0.0.1", true},
+		{"93.184.216.34", false},
+		{"2606:4700::1111", false},
+		{"100.128.0.1", false},
+	}
+	for _, tt := range tests {
+		if got := blockedIP(net.ParseIP(tt.ip)); got != tt.blocked {
+			t.Errorf("blockedIP(%s) = %v, want %v", tt.ip, got, tt.blocked)
+		}
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	d := NewDispatcher(nil)
+	tests := []struct {
+		url  string
+		want error
+	}{
+		{"https://93.184.216.34/hooks", nil},
+		{"http://93.184.216.34:8080/hooks", nil},
+		{"ftp://93.184.216.34/hooks", ErrInvalidURL},
+		{"/hooks", ErrInvalidURL},
+		{"https://", ErrInvalidURL},
+		{"http://127.0.0.1/hooks", ErrBlockedAddress},
+		{"http://localhost:8080/hooks", ErrBlockedAddress},
+		{"http://[::1]/hooks", ErrBlockedAddress},
+		{"http://169.254.169.254/latest/meta-data/", ErrBlockedAddress},
+		{"http://10.0.0.5/hooks", ErrBlockedAddress},
+		{"http://0.0.0.0/hooks", ErrBlockedAddress},
+	}
+	for _, tt := range tests {
+		if err := d.CheckURL(context.Background(), tt.url); err != tt.want {
+			t.Errorf("CheckURL(%q) = %v, want %v", tt.url, err, tt.want)
+		}
+	}
+
+	d.SetAllowPrivateNetworks(true)
+	if err := d.CheckURL(context.Background(), "http://127.0.0.1/hooks"); err != nil {
+		t.Errorf("CheckURL with private networks allowed = %v, want nil", err)
+	}
+}
+
+// newReceiver starts a server on the loopback interface that records the
+// body of every request it gets.
+func newReceiver(t *testing.T) (*httptest.Server, chan []byte) {
+	t.Helper()
+	received := make(chan []byte, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- body
+		io.WriteString(w, "internal secret")
+	}))
+	t.Cleanup(server.Close)
+	return server, received
+}
+
+func TestSendTestBlocksPrivateAddresses(t *testing.T) {
+	client := dbtest.New(t)
+	server, received := newReceiver(t)
+
+	// The URL is stored directly, as one registered before URLs were
+	// checked, or whose host name later started resolving to a private
+	// address, would be.
+	webhook := createWebhook(t, client, server.URL)
+
+	delivery, err := NewDispatcher(client).SendTest(context.Background(), webhook)
+	if err != nil {
+		t.Fatalf("SendTest failed: %v", err)
+	}
+	select {
+	case <-received:
+		t.Fatal("delivery reached a server on the loopback interface")
+	default:
+	}
+	if delivery.Status != models.WebhookDeliveryFailed {
+		t.Errorf("delivery status = %s, want %s", delivery.Status, models.WebhookDeliveryFailed)
+	}
+	if delivery.ResponseStatus != nil || delivery.ResponseBody != "" {
+		t.Errorf("delivery recorded a response (%v, %q) from a blocked address", delivery.ResponseStatus, delivery.ResponseBody)
+	}
+	if !strings.Contains(delivery.LastError, ErrBlockedAddress.Error()) {
+		t.Errorf("delivery error = %q, want it to mention the blocked address", delivery.LastError)
+	}
+}
+
+func TestSendTestAllowedPrivateNetworks(t *testing.T) {
+	client := dbtest.New(t)
+	server, received := newReceiver(t)
+	webhook := createWebhook(t, client, server.URL)
+
+	d := NewDispatcher(client)
+	d.SetAllowPrivateNetworks(true)
+	delivery, err := d.SendTest(context.Background(), webhook)
+	if err != nil {
+		t.Fatalf("SendTest failed: %v", err)
+	}
+	if delivery.Status != models.WebhookDeliverySucceeded {
+		t.Fatalf("delivery status = %s (%s), want %s", delivery.Status, delivery.LastError, models.WebhookDeliverySucceeded)
+	}
+	if body := <-received; !strings.Contains(string(body), TestEventType) {
+		t.Errorf("delivered body = %s, want a %s event", body, TestEventType)
+	}
+}
@@ -0,0 +1,63 @@
+package websocket
+
+import (
+	"log"
+	"sync"
+)
+
+// Broker carries updates between hub instances. Every update a hub publishes
+// goes through its broker, and each hub delivers what it receives from the
+// broker to its own subscribed clients, so an update published on one
+// instance reaches subscribers on all of them.
+type Broker interface {
+	// Publish sends an update, addressed by its Topic, to every subscriber.
+	Publish(update Update) error
+
+	// Subscribe returns a channel of published updates and a function that
+	// stops the subscription.
+	Subscribe() (<-chan Update, func())
+}
+
+// MemoryBroker is a Broker for hubs running in the same process.
+type MemoryBroker struct {
+	mu          sync.Mutex
+	subscribers map[chan Update]bool
+}
+
+// NewMemoryBroker creates a MemoryBroker. A single instance can be shared by
+// several hubs.
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{subscribers: make(map[chan Update]bool)}
+}
+
+// Publish hands the update to every subscriber. Like the hub does with slow
+// clients, a subscriber that is not keeping up misses the update rather than
+// blocking the publisher.
+func (b *MemoryBroker) Publish(update Update) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- update:
+		default:
+			log.Printf("Broker subscriber is full, dropping %s update for %s", update.Type, update.Topic)
+		}
+	}
+	return nil
+}
+
+func (b *MemoryBroker) Subscribe() (<-chan Update, func()) {
+	ch := make(chan Update, 256)
+	b.mu.Lock()
+	b.subscribers[ch] = true
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, ch)
+			b.mu.Unlock()
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+
+	"github.com/saint0x/file-storage-app/backend/internal/db"
+	"github.com/saint0x/file-storage-app/backend/internal/db/dbtest"
+)
+
+// testFanOut checks that updates published on the first hub reach clients
+// connected to every hub.
+func testFanOut(t *testing.T, client *db.SQLiteClient, hubs []*Hub) {
+	t.Helper()
+	userID := dbtest.CreateUser(t, client, "alice")
+
+	clients := make([]*Client, len(hubs))
+	for i, hub := range hubs {
+		clients[i] = connect(hub, userID)
+	}
+
+	hubs[0].SendToUser(userID, Notification, map[string]string{"message": "hello"})
+
+	for i, c := range clients {
+		update := expectUpdate(t, c, Notification)
+		if update.Topic != UserTopic(userID) {
+			t.Errorf("hub %d: update topic = %q, want %q", i, update.Topic, UserTopic(userID))
+		}
+		if update.Seq == 0 {
+			t.Errorf("hub %d: update has no sequence number", i)
+		}
+	}
+}
+
+func TestMemoryBrokerFanOut(t *testing.T) {
+	client := dbtest.New(t)
+	broker := NewMemoryBroker()
+	testFanOut(t, client, []*Hub{
+		startHub(t, client, broker),
+		startHub(t, client, broker),
+		startHub(t, client, broker),
+	})
+}
+
+func TestSQLiteBrokerFanOut(t *testing.T) {
+	path := t.TempDir() + "/test.sqlite"
+
+	// Each hub gets its own connection to the database, as separate server
+	// processes would.
+	var hubs []*Hub
+	var client *db.SQLiteClient
+	for i := 0; i < 3; i++ {
+		client = dbtest.Open(t, path)
+		hubs = append(hubs, startHub(t, client, NewSQLiteBroker(client, 10*time.Millisecond)))
+	}
+	testFanOut(t, client, hubs)
+}
+
+func TestSQLiteBrokerSkipsEarlierUpdates(t *testing.T) {
+	client := dbtest.New(t)
+	broker := NewSQLiteBroker(client, 10*time.Millisecond)
+
+	if err := broker.Publish(Update{Type: Notification, Topic: UserTopic("before")}); err != nil {
+		t.Fatalf("failed to publish: %v", err)
+	}
+	updates, unsubscribe := broker.Subscribe()
+	defer unsubscribe()
+	if err := broker.Publish(Update{Type: Notification, Topic: UserTopic("after")}); err != nil {
+		t.Fatalf("failed to publish: %v", err)
+	}
+
+	select {
+	case update := <-updates:
+		if update.Topic != UserTopic("after") {
+			t.Errorf("first update topic = %q, want %q", update.Topic, UserTopic("after"))
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for update")
+	}
+}
+
+func TestBrokerUnsubscribe(t *testing.T) {
+	client := dbtest.New(t)
+	brokers := map[string]Broker{
+		"memory": NewMemoryBroker(),
+		"sqlite": NewSQLiteBroker(client, 10*time.Millisecond),
+	}
+	for name, broker := range brokers {
+		t.Run(name, func(t *testing.T) {
+			updates, unsubscribe := broker.Subscribe()
+			unsubscribe()
+			unsubscribe()
+			if err := broker.Publish(Update{Type: Notification, Topic: UserTopic("alice")}); err != nil {
+				t.Fatalf("failed to publish: %v", err)
+			}
+
+			select {
+			case update, ok := <-updates:
+				if ok {
+					t.Errorf("received %s update after unsubscribing", update.Type)
+				}
+			case <-time.After(100 * time.Millisecond):
+			}
+		})
+	}
+}
@@ -1,13 +1,19 @@
 package websocket
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
+	"github.com/saint0x/file-storage-app/backend/internal/services/auth"
 )
 
 const (
@@ -24,21 +30,48 @@ const (
 	maxMessageSize = 512
 )
 
-var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		// Implement proper origin checking in production
-		return true
-	},
-}
-
 // Client is a middleman between the websocket connection and the hub.
+// Server-Sent Events clients have no conn and are drained by ServeSSE.
 type Client struct {
-	ID   string
-	hub  *Hub
-	conn *websocket.Conn
-	send chan Update
+	ID       string
+	UserID   string
+	Username string
+	hub      *Hub
+	conn     *websocket.Conn
+	send     chan Update
+
+	// Topics the client is subscribed to and files or folders it is viewing,
+	// guarded by hub.mu.
+	topics  map[string]bool
+	viewing map[string]bool
+
+	// Time of the last heartbeat, guarded by hub.mu.
+	lastSeen time.Time
+}
+
+// newClient creates a client for an authenticated user.
+func newClient(hub *Hub, conn *websocket.Conn, userID string) *Client {
+	username, err := hub.db.GetUsername(userID)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Error fetching username for %s: %v", userID, err)
+	}
+	return &Client{
+		ID:       uuid.New().String(),
+		UserID:   userID,
+		Username: username,
+		hub:      hub,
+		conn:     conn,
+		send:     make(chan Update, 256),
+		topics:   make(map[string]bool),
+		viewing:  make(map[string]bool),
+		lastSeen: time.Now(),
+	}
+}
+
+// request is a message sent by a client to manage its subscriptions.
+type request struct {
+	Action string `json:"action"`
+	Topic  string `json:"topic"`
 }
 
 // readPump pumps messages from the websocket connection to the hub.
@@ -49,7 +82,11 @@ func (c *Client) readPump() {
 	}()
 	c.conn.SetReadLimit(maxMessageSize)
 	c.conn.SetReadDeadline(time.Now().Add(pongWait))
-	c.conn.SetPongHandler(func(string) error { c.conn.SetReadDeadline(time.Now().Add(pongWait)); return nil })
+	c.conn.SetPongHandler(func(string) error {
+		c.conn.SetReadDeadline(time.Now().Add(pongWait))
+		c.hub.touch(c)
+		return nil
+	})
 	for {
 		_, message, err := c.conn.ReadMessage()
 		if err != nil {
@@ -58,12 +95,44 @@ func (c *Client) readPump() {
 			}
 			break
 		}
-		var update Update
-		if err := json.Unmarshal(message, &update); err != nil {
-			log.Printf("error: %v", err)
+		var req request
+		if err := json.Unmarshal(message, &req); err != nil {
+			c.hub.SendUpdateToClient(c, Error, map[string]string{"error": "Invalid message"})
 			continue
 		}
-		c.hub.broadcast <- update
+		c.handleRequest(req)
+	}
+}
+
+// handleRequest applies a subscription request. Clients can only change what
+// they receive; nothing they send is forwarded to other clients.
+func (c *Client) handleRequest(req request) {
+	switch req.Action {
+	case "subscribe":
+		if err := c.hub.Subscribe(c, req.Topic); err != nil {
+			if err != ErrUnknownTopic && err != ErrForbiddenTopic {
+				log.Printf("Error authorizing topic %s for user %s: %v", req.Topic, c.UserID, err)
+				err = ErrForbiddenTopic
+			}
+			c.hub.SendUpdateToClient(c, Error, map[string]string{"topic": req.Topic, "error": err.Error()})
+			return
+		}
+		c.hub.SendUpdateToClient(c, Subscribed, map[string]string{"topic": req.Topic})
+	case "unsubscribe":
+		c.hub.Unsubscribe(c, req.Topic)
+		c.hub.SendUpdateToClient(c, Unsubscribed, map[string]string{"topic": req.Topic})
+	case "view":
+		if err := c.hub.View(c, req.Topic); err != nil {
+			if err != ErrUnknownTopic && err != ErrForbiddenTopic && err != ErrPresenceTopic {
+				log.Printf("Error authorizing topic %s for user %s: %v", req.Topic, c.UserID, err)
+				err = ErrForbiddenTopic
+			}
+			c.hub.SendUpdateToClient(c, Error, map[string]string{"topic": req.Topic, "error": err.Error()})
+		}
+	case "leave":
+		c.hub.Leave(c, req.Topic)
+	default:
+		c.hub.SendUpdateToClient(c, Error, map[string]string{"error": "Unknown action"})
 	}
 }
 
@@ -111,23 +180,98 @@ func (c *Client) writePump() {
 	}
 }
 
-// ServeWs handles websocket requests from the peer.
-func ServeWs(hub *Hub, w http.ResponseWriter, r *http.Request) {
+// ServeWs authenticates a websocket request and registers the connection
+// with the hub. Browsers cannot set headers on websocket requests, so the
+// session token may also be passed as the "token" query parameter. A
+// reconnecting client passes the last seq it processed as "last_seq" to
+// receive the updates it missed.
+func ServeWs(hub *Hub, authenticator auth.Authenticator, w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticate(authenticator, w, r)
+	if !ok {
+		return
+	}
+
+	var lastSeq *int64
+	if v := r.URL.Query().Get("last_seq"); v != "" {
+		seq, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || seq < 0 {
+			http.Error(w, "Invalid last_seq", http.StatusBadRequest)
+			return
+		}
+		lastSeq = &seq
+	}
+
+	upgrader := websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		CheckOrigin:     hub.checkOrigin,
+	}
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Println(err)
 		return
 	}
-	client := &Client{
-		ID:   uuid.New().String(),
-		hub:  hub,
-		conn: conn,
-		send: make(chan Update, 256),
-	}
-	client.hub.register <- client
+	client := newClient(hub, conn, userID)
+	hub.addClient(client, lastSeq)
 
 	// Allow collection of memory referenced by the caller by doing all work in
 	// new goroutines.
 	go client.writePump()
 	go client.readPump()
 }
+
+// authenticate verifies the request's token, writing an error response and
+// returning false unless it is accepted.
+func authenticate(authenticator auth.Authenticator, w http.ResponseWriter, r *http.Request) (string, bool) {
+	token := bearerToken(r)
+	if token == "" {
+		http.Error(w, "Missing token", http.StatusUnauthorized)
+		return "", false
+	}
+	userID, err := authenticator.Authenticate(r.Context(), token)
+	switch {
+	case err == nil:
+		return userID, true
+	case errors.Is(err, auth.ErrProviderUnavailable):
+		log.Printf("Error verifying token: %v", err)
+		http.Error(w, "Identity provider unavailable", http.StatusServiceUnavailable)
+	case errors.Is(err, auth.ErrAccountSuspended):
+		http.Error(w, "Account suspended", http.StatusForbidden)
+	case errors.Is(err, auth.ErrUnauthenticated), auth.IsTokenRejected(err):
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+	default:
+		log.Printf("Error resolving user: %v", err)
+		http.Error(w, "Failed to resolve user", http.StatusInternalServerError)
+	}
+	return "", false
+}
+
+func bearerToken(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); header != "" {
+		parts := strings.SplitN(header, " ", 2)
+		if len(parts) == 2 && parts[0] == "Bearer" {
+			return parts[1]
+		}
+		return ""
+	}
+	return r.URL.Query().Get("token")
+}
+
+// checkOrigin accepts requests from the configured origins, or from the
+// serving host when none are configured.
+func (h *Hub) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if len(h.allowedOrigins) == 0 {
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, r.Host)
+	}
+	for _, allowed := range h.allowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,147 @@
+package websocket
+
+import (
+	"context"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/saint0x/file-storage-app/backend/internal/events"
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+)
+
+// eventUpdateTypes maps domain events to the updates sent to clients.
+var eventUpdateTypes = map[events.Type]UpdateType{
+	events.FileCreated:              FileUploaded,
+	events.FileUpdated:              FileUpdated,
+	events.FileMoved:                FileMoved,
+	events.FileDeleted:              FileDeleted,
+	events.FolderCreated:            FolderCreated,
+	events.ShareGranted:             ShareGranted,
+	events.ShareRevoked:             ShareRevoked,
+	events.FriendRequested:          FriendRequested,
+	events.FriendAccepted:           FriendAccepted,
+	events.FriendBlocked:            FriendBlocked,
+	events.FriendRemoved:            FriendRemoved,
+	events.CollectionCreated:        CollectionCreated,
+	events.CollectionUpdated:        CollectionUpdated,
+	events.CollectionDeleted:        CollectionDeleted,
+	events.CollectionMemberAdded:    CollectionUpdated,
+	events.CollectionMemberUpdated:  CollectionUpdated,
+	events.CollectionMemberRemoved:  CollectionUpdated,
+	events.CollectionFilesAdded:     CollectionUpdated,
+	events.CollectionFilesRemoved:   CollectionUpdated,
+	events.CollectionFilesReordered: CollectionUpdated,
+	events.CommentCreated:           CommentCreated,
+	events.CommentUpdated:           CommentUpdated,
+	events.CommentDeleted:           CommentDeleted,
+	events.CommentResolved:          CommentResolved,
+}
+
+// HandleEvent sends a domain event to the users in its audience and to the
+// clients subscribed to the resources it concerns. Users who may have lost
+// access through it have their subscriptions checked again.
+func (h *Hub) HandleEvent(ctx context.Context, event events.Event) {
+	updateType, ok := eventUpdateTypes[event.Type]
+	if !ok {
+		return
+	}
+	h.SendToUsers(event.Audience, updateType, event.Payload)
+	for _, topic := range resourceTopics(event.Payload) {
+		h.PublishToTopic(topic, updateType, event.Payload)
+	}
+	for _, userID := range revokedUsers(event) {
+		h.publishUpdate(Update{Type: accessChanged, Topic: UserTopic(userID)})
+	}
+
+	switch payload := event.Payload.(type) {
+	case events.FilePayload:
+		if event.Type == events.FileCreated {
+			h.notifySmartCollections(payload.File.UserID.String(), payload.File.ID.String())
+		}
+	case events.CommentPayload:
+		h.SendToUsers(payload.Mentioned, CommentMention, map[string]interface{}{"comment": payload.Comment})
+	}
+}
+
+// notifySmartCollections lets the members of smart collections that a new
+// file now belongs to know about it.
+func (h *Hub) notifySmartCollections(ownerID, fileID string) {
+	collections, err := h.db.GetSmartCollectionsMatchingFile(ownerID, fileID)
+	if err != nil {
+		log.Printf("Error matching smart collections for file %s: %v", fileID, err)
+		return
+	}
+	for _, collection := range collections {
+		audience, err := h.db.GetCollectionAudience(collection.ID.String())
+		if err != nil {
+			log.Printf("Error fetching audience for collection %s: %v", collection.ID, err)
+			continue
+		}
+		h.SendToUsers(audience, CollectionUpdated, events.CollectionFilesPayload{
+			CollectionID: collection.ID.String(),
+			FileIDs:      []string{fileID},
+		})
+	}
+}
+
+// resourceTopics returns the file, folder and collection topics an event's
+// payload is about.
+func resourceTopics(payload interface{}) []string {
+	var topics []string
+	folder := func(id uuid.NullUUID) {
+		if id.Valid {
+			topics = append(topics, FolderTopic(id.UUID.String()))
+		}
+	}
+
+	switch p := payload.(type) {
+	case events.FilePayload:
+		topics = append(topics, FileTopic(p.File.ID.String()))
+		folder(p.File.FolderID)
+	case events.FileMovedPayload:
+		topics = append(topics, FileTopic(p.File.ID.String()))
+		for _, id := range []*string{p.FromFolderID, p.ToFolderID} {
+			if id != nil {
+				topics = append(topics, FolderTopic(*id))
+			}
+		}
+	case events.FolderPayload:
+		topics = append(topics, FolderTopic(p.Folder.ID.String()))
+		folder(p.Folder.ParentID)
+	case events.SharePayload:
+		topics = append(topics, FileTopic(p.FileID))
+	case events.CollectionPayload:
+		topics = append(topics, CollectionTopic(p.Collection.ID.String()))
+	case events.CollectionMemberPayload:
+		topics = append(topics, CollectionTopic(p.CollectionID))
+	case events.CollectionFilesPayload:
+		topics = append(topics, CollectionTopic(p.CollectionID))
+	case events.CommentPayload:
+		switch p.Comment.TargetType {
+		case models.CommentTargetFile:
+			topics = append(topics, FileTopic(p.Comment.TargetID))
+		case models.CommentTargetFolder:
+			topics = append(topics, FolderTopic(p.Comment.TargetID))
+		}
+	}
+	return topics
+}
+
+// revokedUsers returns the users an event may have taken access away from.
+func revokedUsers(event events.Event) []string {
+	switch p := event.Payload.(type) {
+	case events.SharePayload:
+		if event.Type == events.ShareRevoked {
+			return p.UserIDs
+		}
+	case events.CollectionMemberPayload:
+		if event.Type == events.CollectionMemberRemoved {
+			return []string{p.UserID}
+		}
+	case events.CollectionPayload:
+		if event.Type == events.CollectionDeleted {
+			return event.Audience
+		}
+	}
+	return nil
+}
@@ -1,12 +1,14 @@
 package websocket
 
 import (
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
 	"sync"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/saint0x/file-storage-app/backend/internal/db"
-	"github.com/saint0x/file-storage-app/backend/internal/models"
 )
 
 // UpdateType represents different types of updates that can be sent
@@ -14,91 +16,280 @@ type UpdateType string
 
 const (
 	FileUploaded      UpdateType = "file_uploaded"
+	FileUpdated       UpdateType = "file_updated"
+	FileMoved         UpdateType = "file_moved"
 	FileDeleted       UpdateType = "file_deleted"
+	FolderCreated     UpdateType = "folder_created"
+	ShareGranted      UpdateType = "share_granted"
+	ShareRevoked      UpdateType = "share_revoked"
+	FriendRequested   UpdateType = "friend_requested"
+	FriendAccepted    UpdateType = "friend_accepted"
+	FriendBlocked     UpdateType = "friend_blocked"
+	FriendRemoved     UpdateType = "friend_removed"
 	CollectionCreated UpdateType = "collection_created"
 	CollectionUpdated UpdateType = "collection_updated"
 	CollectionDeleted UpdateType = "collection_deleted"
-	UserJoined        UpdateType = "user_joined"
-	UserLeft          UpdateType = "user_left"
+	PresenceJoined    UpdateType = "presence_joined"
+	PresenceLeft      UpdateType = "presence_left"
+	CommentCreated    UpdateType = "comment_created"
+	CommentUpdated    UpdateType = "comment_updated"
+	CommentDeleted    UpdateType = "comment_deleted"
+	CommentResolved   UpdateType = "comment_resolved"
+	CommentMention    UpdateType = "comment_mention"
+	Notification      UpdateType = "notification"
+	NotificationsRead UpdateType = "notifications_read"
+
+	// Control messages sent in reply to client requests.
+	Subscribed     UpdateType = "subscribed"
+	Unsubscribed   UpdateType = "unsubscribed"
+	ResyncRequired UpdateType = "resync_required"
+	Error          UpdateType = "error"
 )
 
-// Update represents a structured update message
+// accessChanged travels between hubs on a user's topic, and is not sent to
+// clients: the user may have lost access to resources, so each hub checks
+// their subscriptions again.
+const accessChanged UpdateType = "access_changed"
+
+// DefaultEventRetention is how long updates are kept for replay unless
+// configured otherwise.
+const DefaultEventRetention = 7 * 24 * time.Hour
+
+// How often expired events are pruned from the event log.
+const pruneInterval = time.Hour
+
+// Update represents a structured update message. Updates sent to a user
+// carry that user's event sequence number so clients can resume with last_seq.
 type Update struct {
-	Type UpdateType  `json:"type"`
-	Data interface{} `json:"data"`
+	Type  UpdateType  `json:"type"`
+	Topic string      `json:"topic,omitempty"`
+	Seq   int64       `json:"seq,omitempty"`
+	Data  interface{} `json:"data"`
 }
 
-// Hub maintains the set of active clients and broadcasts messages to the clients.
+// Topic prefixes. Every client is subscribed to its own user topic; resource
+// topics must be requested and are authorized against the database.
+const (
+	UserTopicPrefix       = "user:"
+	FileTopicPrefix       = "file:"
+	FolderTopicPrefix     = "folder:"
+	CollectionTopicPrefix = "collection:"
+)
+
+// UserTopic returns the private topic of a user.
+func UserTopic(userID string) string { return UserTopicPrefix + userID }
+
+// FileTopic returns the topic for updates about a file.
+func FileTopic(fileID string) string { return FileTopicPrefix + fileID }
+
+// FolderTopic returns the topic for updates about a folder.
+func FolderTopic(folderID string) string { return FolderTopicPrefix + folderID }
+
+// CollectionTopic returns the topic for updates about a collection.
+func CollectionTopic(collectionID string) string { return CollectionTopicPrefix + collectionID }
+
+var (
+	ErrUnknownTopic   = errors.New("unknown topic")
+	ErrForbiddenTopic = errors.New("not allowed to subscribe to topic")
+)
+
+// Hub maintains the set of active clients and delivers updates to the
+// clients subscribed to each topic.
 type Hub struct {
 	// Registered clients.
 	clients map[*Client]bool
 
-	// Inbound messages from the clients.
-	broadcast chan Update
+	// Subscribed clients by topic.
+	topics map[string]map[*Client]bool
 
-	// Register requests from the clients.
-	register chan *Client
+	// Users viewing each file or folder topic.
+	presence map[string]map[string]*viewerState
+
+	// Carries published updates to every hub instance, including this one.
+	broker Broker
+
+	// Updates received from the broker and the function that stops them.
+	incoming    <-chan Update
+	unsubscribe func()
+
+	// Closed to stop Run.
+	done chan struct{}
 
 	// Unregister requests from clients.
 	unregister chan *Client
 
-	// Mutex for thread-safe operations on the clients map
-	mu sync.RWMutex
+	// Mutex for thread-safe operations on the clients and topics maps
+	mu sync.Mutex
+
+	// Updates queued while mu was held, published once it is released.
+	pending []Update
+
+	// Origins allowed to open a websocket; empty means same-origin only.
+	allowedOrigins []string
+
+	// How long user events are kept for replay.
+	eventRetention time.Duration
 
 	// SQLite client for database operations
 	db *db.SQLiteClient
 }
 
-// NewHub creates a new Hub instance
-func NewHub(db *db.SQLiteClient) *Hub {
+// NewHub creates a new Hub instance that exchanges updates with other
+// instances through broker.
+func NewHub(db *db.SQLiteClient, broker Broker) *Hub {
+	incoming, unsubscribe := broker.Subscribe()
 	return &Hub{
-		broadcast:  make(chan Update),
-		register:   make(chan *Client),
-		unregister: make(chan *Client),
-		clients:    make(map[*Client]bool),
-		db:         db,
+		broker:         broker,
+		incoming:       incoming,
+		unsubscribe:    unsubscribe,
+		done:           make(chan struct{}),
+		unregister:     make(chan *Client),
+		clients:        make(map[*Client]bool),
+		topics:         make(map[string]map[*Client]bool),
+		presence:       make(map[string]map[string]*viewerState),
+		eventRetention: DefaultEventRetention,
+		db:             db,
 	}
 }
 
+// SetAllowedOrigins restricts websocket upgrades to the given origins.
+func (h *Hub) SetAllowedOrigins(origins []string) {
+	h.allowedOrigins = nil
+	for _, origin := range origins {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			h.allowedOrigins = append(h.allowedOrigins, origin)
+		}
+	}
+}
+
+// SetEventRetention sets how long user events are kept for replay.
+func (h *Hub) SetEventRetention(retention time.Duration) {
+	h.eventRetention = retention
+}
+
 // Run starts the hub and handles client connections and messages
 func (h *Hub) Run() {
+	defer h.unsubscribe()
+
+	pruneTicker := time.NewTicker(pruneInterval)
+	defer pruneTicker.Stop()
+	h.pruneEvents()
+
+	presenceTicker := time.NewTicker(pingPeriod)
+	defer presenceTicker.Stop()
+
 	for {
 		select {
-		case client := <-h.register:
-			h.mu.Lock()
-			h.clients[client] = true
-			h.mu.Unlock()
-			h.BroadcastUpdate(UserJoined, client.ID)
+		case <-pruneTicker.C:
+			h.pruneEvents()
+		case <-presenceTicker.C:
+			h.expirePresence()
 		case client := <-h.unregister:
 			h.mu.Lock()
-			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				close(client.send)
-				h.BroadcastUpdate(UserLeft, client.ID)
+			h.removeClientLocked(client)
+			h.unlock()
+		case update, ok := <-h.incoming:
+			if !ok {
+				return
 			}
-			h.mu.Unlock()
-		case update := <-h.broadcast:
-			h.mu.RLock()
-			for client := range h.clients {
-				select {
-				case client.send <- update:
-				default:
-					close(client.send)
-					delete(h.clients, client)
-				}
+			if update.Type == accessChanged {
+				go h.recheckSubscriptions(strings.TrimPrefix(update.Topic, UserTopicPrefix))
+				continue
 			}
-			h.mu.RUnlock()
+			h.mu.Lock()
+			h.deliverLocked(update)
+			h.unlock()
+		case <-h.done:
+			return
 		}
 	}
 }
 
-// BroadcastUpdate sends an update to all connected clients
-func (h *Hub) BroadcastUpdate(updateType UpdateType, data interface{}) {
-	update := Update{
-		Type: updateType,
-		Data: data,
+// addClient registers a client and subscribes it to its own user topic. If
+// lastSeq is set, the events the client missed are queued first.
+// Registration is synchronous so that the client's first subscription
+// request cannot race its registration.
+func (h *Hub) addClient(client *Client, lastSeq *int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.clients[client] = true
+	h.subscribeLocked(client, UserTopic(client.UserID))
+	if lastSeq != nil {
+		h.replayLocked(client, *lastSeq)
+	}
+}
+
+// replayLocked queues the events recorded after lastSeq on a newly
+// registered client, or a resync_required update if they are no longer
+// available. Live updates are queued behind the replayed ones; an update
+// recorded during the replay may arrive twice, so clients should skip
+// updates whose seq they have already processed.
+func (h *Hub) replayLocked(client *Client, lastSeq int64) {
+	events, complete, err := h.db.GetUserEventsSince(client.UserID, lastSeq, cap(client.send)-1)
+	if err != nil {
+		log.Printf("Error replaying events for user %s: %v", client.UserID, err)
+		complete = false
+	}
+	if !complete {
+		client.send <- Update{Type: ResyncRequired, Topic: UserTopic(client.UserID), Data: map[string]int64{"last_seq": lastSeq}}
+		return
+	}
+	for _, event := range events {
+		client.send <- Update{Type: UpdateType(event.Type), Topic: event.Topic, Seq: event.Seq, Data: event.Data}
+	}
+}
+
+func (h *Hub) pruneEvents() {
+	if h.eventRetention <= 0 {
+		return
+	}
+	if _, err := h.db.PruneUserEvents(time.Now().Add(-h.eventRetention)); err != nil {
+		log.Printf("Error pruning events: %v", err)
+	}
+}
+
+// PublishToTopic sends an update to every client currently subscribed to
+// topic. The update is not recorded, so clients that are offline miss it.
+func (h *Hub) PublishToTopic(topic string, updateType UpdateType, data interface{}) {
+	h.publishUpdate(Update{Type: updateType, Topic: topic, Data: data})
+}
+
+// SendToUser records an update in the user's event log and sends it to every
+// connection of that user.
+func (h *Hub) SendToUser(userID string, updateType UpdateType, data interface{}) {
+	topic := UserTopic(userID)
+	update := Update{Type: updateType, Topic: topic, Data: data}
+	event, err := h.db.AppendUserEvent(userID, string(updateType), topic, data)
+	if err != nil {
+		log.Printf("Error recording event for user %s: %v", userID, err)
+	} else {
+		update.Seq = event.Seq
+	}
+	h.publishUpdate(update)
+}
+
+func (h *Hub) publishUpdate(update Update) {
+	if err := h.broker.Publish(update); err != nil {
+		log.Printf("Error publishing %s update to %s: %v", update.Type, update.Topic, err)
+	}
+}
+
+// unlock releases h.mu and then publishes the updates queued while it was
+// held, so that a slow broker never blocks the hub.
+func (h *Hub) unlock() {
+	pending := h.pending
+	h.pending = nil
+	h.mu.Unlock()
+	for _, update := range pending {
+		h.publishUpdate(update)
+	}
+}
+
+// SendToUsers sends an update to every connection of each user.
+func (h *Hub) SendToUsers(userIDs []string, updateType UpdateType, data interface{}) {
+	for _, userID := range userIDs {
+		h.SendToUser(userID, updateType, data)
 	}
-	h.broadcast <- update
 }
 
 // SendUpdateToClient sends an update to a specific client
@@ -107,31 +298,168 @@ func (h *Hub) SendUpdateToClient(client *Client, updateType UpdateType, data int
 		Type: updateType,
 		Data: data,
 	}
+	h.mu.Lock()
+	defer h.unlock()
+	if !h.clients[client] {
+		return
+	}
 	select {
 	case client.send <- update:
 	default:
+		h.removeClientLocked(client)
+	}
+}
+
+// Subscribe adds a client to a resource topic after checking that its user
+// may see that resource.
+func (h *Hub) Subscribe(client *Client, topic string) error {
+	if err := h.authorize(client.UserID, topic); err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.clients[client] {
+		h.subscribeLocked(client, topic)
+	}
+	return nil
+}
+
+// Unsubscribe removes a client from a topic. Clients cannot leave their own user topic.
+func (h *Hub) Unsubscribe(client *Client, topic string) {
+	if topic == UserTopic(client.UserID) {
+		return
+	}
+	h.mu.Lock()
+	defer h.unlock()
+	h.unsubscribeLocked(client, topic)
+	h.leaveLocked(client, topic)
+}
+
+// recheckSubscriptions authorizes the resource topics userID's clients are
+// subscribed to or viewing again, and drops the ones they may no longer see.
+func (h *Hub) recheckSubscriptions(userID string) {
+	h.mu.Lock()
+	topics := map[string]bool{}
+	for client := range h.clients {
+		if client.UserID != userID {
+			continue
+		}
+		for topic := range client.topics {
+			topics[topic] = true
+		}
+		for topic := range client.viewing {
+			topics[topic] = true
+		}
+	}
+	h.unlock()
+
+	for topic := range topics {
+		if topic == UserTopic(userID) {
+			continue
+		}
+		err := h.authorize(userID, topic)
+		if err == nil {
+			continue
+		}
+		if err != ErrForbiddenTopic {
+			log.Printf("Error authorizing topic %s for user %s: %v", topic, userID, err)
+		}
+
 		h.mu.Lock()
-		close(client.send)
-		delete(h.clients, client)
-		h.mu.Unlock()
+		for client := range h.clients {
+			if client.UserID != userID || (!client.topics[topic] && !client.viewing[topic]) {
+				continue
+			}
+			h.unsubscribeLocked(client, topic)
+			h.leaveLocked(client, topic)
+			select {
+			case client.send <- Update{Type: Unsubscribed, Data: map[string]string{"topic": topic}}:
+			default:
+				h.removeClientLocked(client)
+			}
+		}
+		h.unlock()
 	}
 }
 
-// recordPing records a ping in the SQLite database
-func (h *Hub) recordPing(clientID string) error {
-	ping := models.Ping{
-		ID:        uuid.New(),
-		ClientID:  clientID,
-		Timestamp: time.Now(),
+// authorize reports whether userID may receive updates published to topic.
+func (h *Hub) authorize(userID, topic string) error {
+	var allowed bool
+	var err error
+	switch {
+	case strings.HasPrefix(topic, UserTopicPrefix):
+		allowed = topic == UserTopic(userID)
+	case strings.HasPrefix(topic, FileTopicPrefix):
+		allowed, err = h.db.UserCanAccessFile(userID, strings.TrimPrefix(topic, FileTopicPrefix))
+	case strings.HasPrefix(topic, FolderTopicPrefix):
+		allowed, err = h.db.UserCanAccessFolder(userID, strings.TrimPrefix(topic, FolderTopicPrefix))
+	case strings.HasPrefix(topic, CollectionTopicPrefix):
+		_, err = h.db.GetCollectionRole(strings.TrimPrefix(topic, CollectionTopicPrefix), userID)
+		allowed = err == nil
+		if err == sql.ErrNoRows {
+			err = nil
+		}
+	default:
+		return ErrUnknownTopic
+	}
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return ErrForbiddenTopic
+	}
+	return nil
+}
+
+// deliverLocked hands an update to every local client subscribed to its
+// topic, dropping clients that cannot keep up.
+func (h *Hub) deliverLocked(update Update) {
+	for client := range h.topics[update.Topic] {
+		select {
+		case client.send <- update:
+		default:
+			h.removeClientLocked(client)
+		}
+	}
+}
+
+func (h *Hub) subscribeLocked(client *Client, topic string) {
+	if h.topics[topic] == nil {
+		h.topics[topic] = make(map[*Client]bool)
 	}
+	h.topics[topic][client] = true
+	client.topics[topic] = true
+}
 
-	_, err := h.db.DB.Exec("INSERT INTO pings (id, client_id, timestamp) VALUES (?, ?, ?)",
-		ping.ID, ping.ClientID, ping.Timestamp)
-	return err
+func (h *Hub) unsubscribeLocked(client *Client, topic string) {
+	delete(h.topics[topic], client)
+	if len(h.topics[topic]) == 0 {
+		delete(h.topics, topic)
+	}
+	delete(client.topics, topic)
+}
+
+func (h *Hub) removeClientLocked(client *Client) {
+	if _, ok := h.clients[client]; !ok {
+		return
+	}
+	delete(h.clients, client)
+	for topic := range client.topics {
+		h.unsubscribeLocked(client, topic)
+	}
+	for topic := range client.viewing {
+		h.leaveLocked(client, topic)
+	}
+	close(client.send)
+}
+
+// recordPing records a ping in the SQLite database
+func (h *Hub) recordPing(clientID string) error {
+	return h.db.RecordPing(clientID)
 }
 
 // Add this method to the Hub struct
 func (h *Hub) Stop() {
-	close(h.broadcast)
+	close(h.done)
 	// Add any additional cleanup logic here
 }
@@ -0,0 +1,148 @@
+package websocket
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/saint0x/file-storage-app/backend/internal/db"
+	"github.com/saint0x/file-storage-app/backend/internal/db/dbtest"
+	"github.com/saint0x/file-storage-app/backend/internal/events"
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+)
+
+// startHub runs a hub until the test ends.
+func startHub(t *testing.T, client *db.SQLiteClient, broker Broker) *Hub {
+	t.Helper()
+	hub := NewHub(client, broker)
+	go hub.Run()
+	t.Cleanup(hub.Stop)
+	return hub
+}
+
+// connect registers a client for userID without a connection, as ServeSSE
+// does.
+func connect(hub *Hub, userID string) *Client {
+	client := newClient(hub, nil, userID)
+	hub.addClient(client, nil)
+	return client
+}
+
+// expectUpdate waits for the next update of type want on client.
+func expectUpdate(t *testing.T, client *Client, want UpdateType) Update {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case update := <-client.send:
+			if update.Type == want {
+				return update
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for %s update", want)
+		}
+	}
+}
+
+func TestHandleEventPublishesToResourceTopics(t *testing.T) {
+	client := dbtest.New(t)
+	hub := startHub(t, client, NewMemoryBroker())
+
+	owner := dbtest.CreateUser(t, client, "owner")
+	friend := dbtest.CreateUser(t, client, "friend")
+	fileID := dbtest.CreateFile(t, client, owner)
+	if _, err := client.ShareFileWithFriends(fileID, owner, []string{friend}); err != nil {
+		t.Fatalf("failed to share file: %v", err)
+	}
+
+	viewer := connect(hub, friend)
+	if err := hub.Subscribe(viewer, FileTopic(fileID)); err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+
+	hub.HandleEvent(context.Background(), events.Event{
+		Type:    events.FileUpdated,
+		Payload: events.FilePayload{File: models.File{ID: uuid.MustParse(fileID), UserID: uuid.MustParse(owner)}},
+	})
+
+	update := expectUpdate(t, viewer, FileUpdated)
+	if update.Topic != FileTopic(fileID) {
+		t.Errorf("update topic = %q, want %q", update.Topic, FileTopic(fileID))
+	}
+}
+
+func TestHandleEventDropsRevokedSubscriptions(t *testing.T) {
+	client := dbtest.New(t)
+	hub := startHub(t, client, NewMemoryBroker())
+
+	owner := dbtest.CreateUser(t, client, "owner")
+	friend := dbtest.CreateUser(t, client, "friend")
+	fileID := dbtest.CreateFile(t, client, owner)
+	if _, err := client.ShareFileWithFriends(fileID, owner, []string{friend}); err != nil {
+		t.Fatalf("failed to share file: %v", err)
+	}
+
+	viewer := connect(hub, friend)
+	if err := hub.Subscribe(viewer, FileTopic(fileID)); err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+
+	revoked, err := client.UnshareFile(fileID, []string{friend})
+	if err != nil {
+		t.Fatalf("failed to unshare file: %v", err)
+	}
+	hub.HandleEvent(context.Background(), events.Event{
+		Type:    events.ShareRevoked,
+		Payload: events.SharePayload{FileID: fileID, OwnerID: owner, UserIDs: revoked},
+	})
+
+	update := expectUpdate(t, viewer, Unsubscribed)
+	if topic := update.Data.(map[string]string)["topic"]; topic != FileTopic(fileID) {
+		t.Errorf("unsubscribed topic = %q, want %q", topic, FileTopic(fileID))
+	}
+
+	hub.mu.Lock()
+	subscribed := viewer.topics[FileTopic(fileID)]
+	hub.mu.Unlock()
+	if subscribed {
+		t.Error("client is still subscribed to the file after its share was revoked")
+	}
+}
+
+func TestRecordPing(t *testing.T) {
+	client := dbtest.New(t)
+	hub := NewHub(client, NewMemoryBroker())
+
+	if err := hub.recordPing("client-1"); err != nil {
+		t.Fatalf("recordPing failed: %v", err)
+	}
+	var count int
+	if err := client.DB.QueryRow(`SELECT COUNT(*) FROM pings WHERE client_id = ?`, "client-1").Scan(&count); err != nil {
+		t.Fatalf("failed to count pings: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("recorded %d pings, want 1", count)
+	}
+}
+
+func TestAddClientReplaysMissedEvents(t *testing.T) {
+	client := dbtest.New(t)
+	hub := NewHub(client, NewMemoryBroker())
+	userID := dbtest.CreateUser(t, client, "alice")
+
+	hub.SendToUser(userID, FileUploaded, map[string]string{"name": "a.txt"})
+	hub.SendToUser(userID, FileDeleted, map[string]string{"name": "b.txt"})
+
+	resumed := newClient(hub, nil, userID)
+	lastSeq := int64(1)
+	hub.addClient(resumed, &lastSeq)
+	if update := expectUpdate(t, resumed, FileDeleted); update.Seq != 2 {
+		t.Errorf("replayed update seq = %d, want 2", update.Seq)
+	}
+
+	stale := newClient(hub, nil, userID)
+	lastSeq = 5
+	hub.addClient(stale, &lastSeq)
+	expectUpdate(t, stale, ResyncRequired)
+}
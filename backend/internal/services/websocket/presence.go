@@ -0,0 +1,141 @@
+package websocket
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrPresenceTopic is returned when presence is requested for a topic that
+// is not a file or folder.
+var ErrPresenceTopic = errors.New("presence is only tracked for files and folders")
+
+// Viewer is a user currently viewing a file or folder.
+type Viewer struct {
+	UserID   string    `json:"user_id"`
+	Username string    `json:"username"`
+	Since    time.Time `json:"since"`
+}
+
+// viewerState tracks the connections through which a user views a topic;
+// the user stays present until the last of them leaves.
+type viewerState struct {
+	Viewer
+	clients map[*Client]bool
+}
+
+func isPresenceTopic(topic string) bool {
+	return strings.HasPrefix(topic, FileTopicPrefix) || strings.HasPrefix(topic, FolderTopicPrefix)
+}
+
+// View marks the client's user as viewing a file or folder topic. The client
+// is subscribed to the topic so it receives the presence of other viewers.
+func (h *Hub) View(client *Client, topic string) error {
+	if !isPresenceTopic(topic) {
+		return ErrPresenceTopic
+	}
+	if err := h.authorize(client.UserID, topic); err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.unlock()
+	if !h.clients[client] {
+		return nil
+	}
+	h.subscribeLocked(client, topic)
+	client.viewing[topic] = true
+
+	if h.presence[topic] == nil {
+		h.presence[topic] = make(map[string]*viewerState)
+	}
+	state, ok := h.presence[topic][client.UserID]
+	if !ok {
+		state = &viewerState{
+			Viewer:  Viewer{UserID: client.UserID, Username: client.Username, Since: time.Now()},
+			clients: make(map[*Client]bool),
+		}
+		h.presence[topic][client.UserID] = state
+	}
+	state.clients[client] = true
+	if !ok {
+		h.pending = append(h.pending, Update{Type: PresenceJoined, Topic: topic, Data: state.Viewer})
+	}
+	return nil
+}
+
+// Leave marks the client as no longer viewing topic. The client stays
+// subscribed to the topic until it unsubscribes.
+func (h *Hub) Leave(client *Client, topic string) {
+	h.mu.Lock()
+	defer h.unlock()
+	h.leaveLocked(client, topic)
+}
+
+// Viewers returns the users connected to this instance who are currently
+// viewing a file or folder topic, provided userID is allowed to see it.
+// Viewers connected to other instances are not included: presence_joined and
+// presence_left updates reach subscribers through the broker, but the list
+// itself is only kept by the hub each viewer is connected to.
+func (h *Hub) Viewers(userID, topic string) ([]Viewer, error) {
+	if !isPresenceTopic(topic) {
+		return nil, ErrPresenceTopic
+	}
+	if err := h.authorize(userID, topic); err != nil {
+		return nil, err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	viewers := make([]Viewer, 0, len(h.presence[topic]))
+	for _, state := range h.presence[topic] {
+		viewers = append(viewers, state.Viewer)
+	}
+	sort.Slice(viewers, func(i, j int) bool { return viewers[i].Since.Before(viewers[j].Since) })
+	return viewers, nil
+}
+
+// touch records a heartbeat from the client.
+func (h *Hub) touch(client *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	client.lastSeen = time.Now()
+}
+
+// expirePresence drops viewers whose connections have missed their
+// heartbeats, even if the connection itself has not been closed yet.
+func (h *Hub) expirePresence() {
+	h.mu.Lock()
+	defer h.unlock()
+	cutoff := time.Now().Add(-pongWait)
+	for client := range h.clients {
+		if len(client.viewing) == 0 || client.lastSeen.After(cutoff) {
+			continue
+		}
+		for topic := range client.viewing {
+			h.leaveLocked(client, topic)
+		}
+	}
+}
+
+func (h *Hub) leaveLocked(client *Client, topic string) {
+	if !client.viewing[topic] {
+		return
+	}
+	delete(client.viewing, topic)
+
+	state, ok := h.presence[topic][client.UserID]
+	if !ok {
+		return
+	}
+	delete(state.clients, client)
+	if len(state.clients) > 0 {
+		return
+	}
+	delete(h.presence[topic], client.UserID)
+	if len(h.presence[topic]) == 0 {
+		delete(h.presence, topic)
+	}
+	h.pending = append(h.pending, Update{Type: PresenceLeft, Topic: topic, Data: state.Viewer})
+}
@@ -0,0 +1,87 @@
+package websocket
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/saint0x/file-storage-app/backend/internal/db/dbtest"
+)
+
+// lockCheckingBroker records every published update along with whether the
+// hub's lock was held while it was published.
+type lockCheckingBroker struct {
+	*MemoryBroker
+	hub *Hub
+
+	mu        sync.Mutex
+	published []UpdateType
+	locked    bool
+}
+
+func (b *lockCheckingBroker) Publish(update Update) error {
+	b.mu.Lock()
+	b.published = append(b.published, update.Type)
+	if b.hub.mu.TryLock() {
+		b.hub.mu.Unlock()
+	} else {
+		b.locked = true
+	}
+	b.mu.Unlock()
+	return b.MemoryBroker.Publish(update)
+}
+
+func TestPresencePublishesOutsideHubLock(t *testing.T) {
+	client := dbtest.New(t)
+	broker := &lockCheckingBroker{MemoryBroker: NewMemoryBroker()}
+	hub := NewHub(client, broker)
+	broker.hub = hub
+
+	owner := dbtest.CreateUser(t, client, "owner")
+	topic := FileTopic(dbtest.CreateFile(t, client, owner))
+	viewer := connect(hub, owner)
+	if err := hub.View(viewer, topic); err != nil {
+		t.Fatalf("View failed: %v", err)
+	}
+	viewers, err := hub.Viewers(owner, topic)
+	if err != nil || len(viewers) != 1 || viewers[0].UserID != owner {
+		t.Fatalf("Viewers() = %+v, %v, want the owner", viewers, err)
+	}
+	hub.Leave(viewer, topic)
+
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	if len(broker.published) != 2 || broker.published[0] != PresenceJoined || broker.published[1] != PresenceLeft {
+		t.Errorf("published %v, want presence_joined then presence_left", broker.published)
+	}
+	if broker.locked {
+		t.Error("presence update was published while the hub lock was held")
+	}
+}
+
+func TestViewersOnlyListsLocalConnections(t *testing.T) {
+	client := dbtest.New(t)
+	broker := NewMemoryBroker()
+	local := startHub(t, client, broker)
+	remote := startHub(t, client, broker)
+
+	owner := dbtest.CreateUser(t, client, "owner")
+	topic := FileTopic(dbtest.CreateFile(t, client, owner))
+	watcher := connect(remote, owner)
+	if err := remote.Subscribe(watcher, topic); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	if err := local.View(connect(local, owner), topic); err != nil {
+		t.Fatalf("View failed: %v", err)
+	}
+
+	// The join still reaches subscribers on the other instance.
+	if update := expectUpdate(t, watcher, PresenceJoined); update.Data.(Viewer).UserID != owner {
+		t.Errorf("presence update = %+v, want the owner joining", update)
+	}
+	if viewers, err := local.Viewers(owner, topic); err != nil || len(viewers) != 1 {
+		t.Errorf("local Viewers() = %+v, %v, want the owner", viewers, err)
+	}
+	if viewers, err := remote.Viewers(owner, topic); err != nil || len(viewers) != 0 {
+		t.Errorf("remote Viewers() = %+v, %v, want no viewers", viewers, err)
+	}
+}
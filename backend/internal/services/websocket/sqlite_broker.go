@@ -0,0 +1,117 @@
+package websocket
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/saint0x/file-storage-app/backend/internal/db"
+	"github.com/saint0x/file-storage-app/backend/internal/models"
+)
+
+const (
+	// DefaultBrokerPollInterval is how often a SQLiteBroker checks for new updates.
+	DefaultBrokerPollInterval = 250 * time.Millisecond
+
+	// How long published updates stay in the broker table. Subscribers only
+	// need them until their next poll.
+	brokerRetention = time.Minute
+
+	// Maximum number of updates read per poll.
+	brokerBatchSize = 500
+)
+
+// SQLiteBroker is a Broker for hub instances that share a SQLite database,
+// such as several server processes on one machine. Updates are written to a
+// table and each subscriber polls for rows it has not seen yet.
+type SQLiteBroker struct {
+	db           *db.SQLiteClient
+	pollInterval time.Duration
+}
+
+// NewSQLiteBroker creates a SQLiteBroker that polls every pollInterval.
+func NewSQLiteBroker(db *db.SQLiteClient, pollInterval time.Duration) *SQLiteBroker {
+	if pollInterval <= 0 {
+		pollInterval = DefaultBrokerPollInterval
+	}
+	return &SQLiteBroker{db: db, pollInterval: pollInterval}
+}
+
+func (b *SQLiteBroker) Publish(update Update) error {
+	data, err := json.Marshal(update.Data)
+	if err != nil {
+		return err
+	}
+	return b.db.InsertBrokerMessage(models.BrokerMessage{
+		Topic:     update.Topic,
+		Type:      string(update.Type),
+		Seq:       update.Seq,
+		Data:      data,
+		CreatedAt: time.Now(),
+	})
+}
+
+// Subscribe delivers updates published after the subscription starts.
+func (b *SQLiteBroker) Subscribe() (<-chan Update, func()) {
+	ch := make(chan Update, 256)
+	done := make(chan struct{})
+	var once sync.Once
+
+	lastID, err := b.db.GetLatestBrokerMessageID()
+	if err != nil {
+		log.Printf("Error reading broker position: %v", err)
+		lastID = -1
+	}
+	go b.poll(ch, done, lastID)
+	return ch, func() { once.Do(func() { close(done) }) }
+}
+
+// poll forwards new rows to ch. A negative lastID means the starting
+// position is not known yet and is read on the next tick.
+func (b *SQLiteBroker) poll(ch chan<- Update, done <-chan struct{}, lastID int64) {
+	defer close(ch)
+	ticker := time.NewTicker(b.pollInterval)
+	defer ticker.Stop()
+
+	lastPrune := time.Now()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		if lastID < 0 {
+			id, err := b.db.GetLatestBrokerMessageID()
+			if err != nil {
+				log.Printf("Error reading broker position: %v", err)
+				continue
+			}
+			lastID = id
+			continue
+		}
+
+		messages, err := b.db.GetBrokerMessagesAfter(lastID, brokerBatchSize)
+		if err != nil {
+			log.Printf("Error polling broker messages: %v", err)
+			continue
+		}
+		for _, msg := range messages {
+			lastID = msg.ID
+			update := Update{Type: UpdateType(msg.Type), Topic: msg.Topic, Seq: msg.Seq, Data: msg.Data}
+			select {
+			case ch <- update:
+			case <-done:
+				return
+			}
+		}
+
+		if time.Since(lastPrune) > brokerRetention {
+			lastPrune = time.Now()
+			if err := b.db.PruneBrokerMessages(time.Now().Add(-brokerRetention)); err != nil {
+				log.Printf("Error pruning broker messages: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/saint0x/file-storage-app/backend/internal/services/auth"
+)
+
+// ServeSSE streams the same updates as ServeWs over Server-Sent Events for
+// clients that cannot open a websocket. EventSource cannot send headers or
+// messages, so the token may be passed as the "token" query parameter and
+// resource topics are requested up front as a comma-separated "topics"
+// parameter. Updates from the user's event log carry their seq as the event
+// ID, so browsers resume automatically with Last-Event-ID.
+func ServeSSE(hub *Hub, authenticator auth.Authenticator, w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticate(authenticator, w, r)
+	if !ok {
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_seq")
+	}
+	var lastSeq *int64
+	if lastEventID != "" {
+		seq, err := strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil || seq < 0 {
+			http.Error(w, "Invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+		lastSeq = &seq
+	}
+
+	var topics []string
+	for _, topic := range strings.Split(r.URL.Query().Get("topics"), ",") {
+		if topic = strings.TrimSpace(topic); topic == "" {
+			continue
+		}
+		if err := hub.authorize(userID, topic); err != nil {
+			if err != ErrUnknownTopic && err != ErrForbiddenTopic {
+				log.Printf("Error authorizing topic %s for user %s: %v", topic, userID, err)
+			}
+			http.Error(w, fmt.Sprintf("Cannot subscribe to %s", topic), http.StatusForbidden)
+			return
+		}
+		topics = append(topics, topic)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	client := newClient(hub, nil, userID)
+	hub.addClient(client, lastSeq)
+	defer func() { hub.unregister <- client }()
+	for _, topic := range topics {
+		if err := hub.Subscribe(client, topic); err != nil {
+			log.Printf("Error subscribing SSE client to %s: %v", topic, err)
+		}
+	}
+
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case update, ok := <-client.send:
+			if !ok {
+				return
+			}
+			if err := writeEvent(w, update); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-ticker.C:
+			// Comment lines keep proxies from closing an idle stream.
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+			if err := hub.recordPing(client.ID); err != nil {
+				log.Printf("Error recording ping: %v", err)
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, update Update) error {
+	data, err := json.Marshal(update)
+	if err != nil {
+		return err
+	}
+	if update.Seq > 0 {
+		if _, err := fmt.Fprintf(w, "id: %d\n", update.Seq); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", update.Type, data)
+	return err
+}
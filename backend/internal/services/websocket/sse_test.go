@@ -0,0 +1,89 @@
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/saint0x/file-storage-app/backend/internal/db/dbtest"
+	"github.com/saint0x/file-storage-app/backend/internal/services/auth"
+)
+
+// staticAuthenticator accepts a single token for a single user.
+type staticAuthenticator struct{ token, userID string }
+
+func (a staticAuthenticator) Authenticate(ctx context.Context, token string) (string, error) {
+	if token != a.token {
+		return "", auth.ErrUnauthenticated
+	}
+	return a.userID, nil
+}
+
+func TestServeSSERejectsBadRequests(t *testing.T) {
+	client := dbtest.New(t)
+	hub := startHub(t, client, NewMemoryBroker())
+	alice := dbtest.CreateUser(t, client, "alice")
+	bob := dbtest.CreateUser(t, client, "bob")
+	authenticator := staticAuthenticator{token: "secret", userID: alice}
+
+	tests := []struct {
+		name  string
+		query string
+		want  int
+	}{
+		{"missing token", "", http.StatusUnauthorized},
+		{"wrong token", "?token=wrong", http.StatusUnauthorized},
+		{"invalid last_seq", "?token=secret&last_seq=-1", http.StatusBadRequest},
+		{"another user's topic", "?token=secret&topics=" + UserTopic(bob), http.StatusForbidden},
+		{"unknown topic", "?token=secret&topics=nothing", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			ServeSSE(hub, authenticator, rec, httptest.NewRequest(http.MethodGet, "/events"+tt.query, nil))
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
+
+func TestServeSSEResumesFromLastEventID(t *testing.T) {
+	client := dbtest.New(t)
+	hub := startHub(t, client, NewMemoryBroker())
+	alice := dbtest.CreateUser(t, client, "alice")
+	authenticator := staticAuthenticator{token: "secret", userID: alice}
+	hub.SendToUser(alice, FileUploaded, map[string]string{"name": "a.txt"})
+	hub.SendToUser(alice, FileDeleted, map[string]string{"name": "b.txt"})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ServeSSE(hub, authenticator, w, r)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q, want text/event-stream", ct)
+	}
+
+	var event []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() && scanner.Text() != "" {
+		event = append(event, scanner.Text())
+	}
+	if len(event) != 3 || event[0] != "id: 2" || event[1] != "event: "+string(FileDeleted) || !strings.HasPrefix(event[2], "data: ") {
+		t.Errorf("first event = %q, want the file_deleted update with id 2", event)
+	}
+}
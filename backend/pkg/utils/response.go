@@ -2,7 +2,10 @@ package utils
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+
+	apperrors "github.com/saint0x/file-storage-app/backend/pkg/errors"
 )
 
 // Response represents a standard API response
@@ -19,16 +22,14 @@ func RespondJSON(w http.ResponseWriter, status int, payload interface{}) {
 	json.NewEncoder(w).Encode(payload)
 }
 
-// RespondError sends an error response
+// RespondError sends an error response, with the status code of an
+// *errors.AppError or 500 for any other error.
 func RespondError(w http.ResponseWriter, err error) {
-	// You can implement custom error handling here
-	// For example, you can check for specific error types and set the status code accordingly
 	statusCode := http.StatusInternalServerError
-
-	// Example: Check for specific error types
-	// if errors.Is(err, SomeCustomError) {
-	//     statusCode = http.StatusBadRequest
-	// }
+	var appErr *apperrors.AppError
+	if errors.As(err, &appErr) {
+		statusCode = appErr.Code
+	}
 
 	http.Error(w, err.Error(), statusCode)
 }